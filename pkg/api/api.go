@@ -23,6 +23,8 @@ import (
 
 	"github.com/grafana/mimir/pkg/alertmanager"
 	"github.com/grafana/mimir/pkg/alertmanager/alertmanagerpb"
+	blockbuilderscheduler "github.com/grafana/mimir/pkg/blockbuilder/scheduler"
+	blockbuilderschedulerpb "github.com/grafana/mimir/pkg/blockbuilder/schedulerpb"
 	"github.com/grafana/mimir/pkg/compactor"
 	"github.com/grafana/mimir/pkg/distributor"
 	"github.com/grafana/mimir/pkg/distributor/distributorpb"
@@ -383,7 +385,9 @@ func (a *API) RegisterStoreGateway(s *storegateway.StoreGateway) {
 	a.RegisterRoute("/store-gateway/ring", http.HandlerFunc(s.RingHandler), false, true, "GET", "POST")
 	a.RegisterRoute("/store-gateway/tenants", http.HandlerFunc(s.TenantsHandler), false, true, "GET")
 	a.RegisterRoute("/store-gateway/tenant/{tenant}/blocks", http.HandlerFunc(s.BlocksHandler), false, true, "GET")
+	a.RegisterRoute("/store-gateway/tenant/{tenant}/block/{block}", http.HandlerFunc(s.BlockHandler), false, true, "GET")
 	a.RegisterRoute("/store-gateway/prepare-shutdown", http.HandlerFunc(s.PrepareShutdownHandler), false, true, "GET", "POST", "DELETE")
+	a.RegisterRoute("/store-gateway/api-docs.json", http.HandlerFunc(s.APIDocsHandler), false, true, "GET")
 }
 
 // RegisterCompactor registers routes associated with the compactor.
@@ -401,6 +405,10 @@ func (a *API) RegisterCompactor(c *compactor.MultitenantCompactor) {
 	a.RegisterRoute("/compactor/delete_tenant_status", http.HandlerFunc(c.DeleteTenantStatus), true, true, "GET")
 	a.RegisterRoute("/compactor/tenants", http.HandlerFunc(c.TenantsHandler), false, true, "GET")
 	a.RegisterRoute("/compactor/tenant/{tenant}/planned_jobs", http.HandlerFunc(c.PlannedJobsHandler), false, true, "GET")
+	if c.BlocksAdminEnabled() {
+		a.RegisterRoute("/compactor/tenant/{tenant}/blocks", http.HandlerFunc(c.BlocksHandler), false, true, "GET")
+		a.RegisterRoute("/compactor/api-docs.json", http.HandlerFunc(c.APIDocsHandler), false, true, "GET")
+	}
 }
 
 func (a *API) DisableServerHTTPTimeouts(next http.Handler) http.Handler {
@@ -471,11 +479,25 @@ func (a *API) RegisterQueryScheduler(f *scheduler.Scheduler) {
 		{Desc: "Ring status", Path: "/query-scheduler/ring"},
 	})
 	a.RegisterRoute("/query-scheduler/ring", http.HandlerFunc(f.RingHandler), false, true, "GET", "POST")
+	a.RegisterRoute("/query-scheduler/starvation", http.HandlerFunc(f.StarvationHandler), false, true, "GET")
 
 	schedulerpb.RegisterSchedulerForFrontendServer(a.server.GRPC, f)
 	schedulerpb.RegisterSchedulerForQuerierServer(a.server.GRPC, f)
 }
 
+// RegisterBlockBuilderScheduler registers the admin routes of the block-builder-scheduler.
+func (a *API) RegisterBlockBuilderScheduler(s *blockbuilderscheduler.BlockBuilderScheduler) {
+	blockbuilderschedulerpb.RegisterBlockBuilderSchedulerServer(a.server.GRPC, s)
+
+	a.RegisterRoute("/block-builder-scheduler/jobs/cancel", http.HandlerFunc(s.CancelJobHandler), false, true, "POST")
+	a.RegisterRoute("/block-builder-scheduler/skipped-ranges", http.HandlerFunc(s.SkippedRangesHandler), false, true, "GET")
+	a.RegisterRoute("/block-builder-scheduler/jobs", http.HandlerFunc(s.JobsHandler), false, true, "GET")
+	a.RegisterRoute("/block-builder-scheduler/jobs/failed", http.HandlerFunc(s.FailedJobsHandler), false, true, "GET")
+	a.RegisterRoute("/block-builder-scheduler/jobs/requeue", http.HandlerFunc(s.RequeueFailedJobHandler), false, true, "POST")
+	a.RegisterRoute("/block-builder-scheduler/progress", http.HandlerFunc(s.ProgressHandler), false, true, "GET")
+	a.RegisterRoute("/block-builder-scheduler/workers/shutdown", http.HandlerFunc(s.WorkerShutdownHandler), false, true, "POST")
+}
+
 func (a *API) RegisterOverridesExporter(oe *exporter.OverridesExporter) {
 	a.indexPage.AddLinks(defaultWeight, "Overrides-exporter", []IndexPageLink{
 		{Desc: "Ring status", Path: "/overrides-exporter/ring"},
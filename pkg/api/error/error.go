@@ -134,6 +134,7 @@ func AddDetails(err error, details string) error {
 }
 
 // IsNonRetryableAPIError returns true if err is an apiError which should be failed and not retried.
+// It's used by the query-frontend retry middleware, unless retries are decided by the error class.
 func IsNonRetryableAPIError(err error) bool {
 	apiErr := &APIError{}
 	// Reasoning:
@@ -5,8 +5,13 @@ package scheduler
 import (
 	"flag"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/ingest"
 )
 
@@ -16,17 +21,46 @@ type Config struct {
 	ConsumeInterval    time.Duration `yaml:"consume_interval"`
 	StartupObserveTime time.Duration `yaml:"startup_observe_time"`
 	JobLeaseExpiry     time.Duration `yaml:"job_lease_expiry"`
+	AssignmentPolicy   string        `yaml:"assignment_policy"`
+
+	MaxJobsPerPartition int `yaml:"max_jobs_per_partition"`
+	MaxJobFailures      int `yaml:"max_job_failures"`
+	MaxJobsPerWorker    int `yaml:"max_jobs_per_worker"`
+
+	PartitionAffinityTTL time.Duration `yaml:"partition_affinity_ttl"`
+
+	StatePersistInterval time.Duration `yaml:"state_persist_interval"`
+
+	MaxPartitionLag  int64         `yaml:"max_partition_lag"`
+	LagCheckInterval time.Duration `yaml:"lag_check_interval"`
+
+	MinJobRecords int64         `yaml:"min_job_records"`
+	MaxJobAge     time.Duration `yaml:"max_job_age"`
+
+	WorkerAuthToken flagext.Secret `yaml:"worker_auth_token"`
 
 	// Config parameters defined outside the block-builder-scheduler config and are injected dynamically.
-	Kafka ingest.KafkaConfig `yaml:"-"`
+	Kafka  ingest.KafkaConfig `yaml:"-"`
+	Bucket bucket.Config      `yaml:"-"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.ConsumerGroup, "block-builder-scheduler.consumer-group", "block-builder", "The Kafka consumer group used for getting/setting commmitted offsets.")
 	f.DurationVar(&cfg.SchedulingInterval, "block-builder-scheduler.scheduling-interval", 20*time.Second, "How frequently to recompute the schedule.")
 	f.DurationVar(&cfg.ConsumeInterval, "block-builder-scheduler.consume-interval", 1*time.Hour, "Interval between consumption cycles.")
-	f.DurationVar(&cfg.StartupObserveTime, "block-builder-scheduler.startup-observe-time", 25*time.Second, "How long to observe worker state before scheduling jobs.")
+	f.DurationVar(&cfg.StartupObserveTime, "block-builder-scheduler.startup-observe-time", 25*time.Second, "How long to observe worker state before scheduling jobs. If the state of the jobs was persisted, the observation ends earlier once all the jobs assigned in the persisted state were reported by their workers or completed.")
 	f.DurationVar(&cfg.JobLeaseExpiry, "block-builder-scheduler.job-lease-expiry", 2*time.Minute, "How long a job lease will live for before expiring.")
+	f.StringVar(&cfg.AssignmentPolicy, "block-builder-scheduler.assignment-policy", AssignmentPolicyOldestFirst, fmt.Sprintf("The order in which the jobs are assigned to workers. Supported values: %s.", strings.Join(assignmentPolicies, ", ")))
+	f.IntVar(&cfg.MaxJobsPerPartition, "block-builder-scheduler.max-jobs-per-partition", 1, "Maximum number of jobs of the same partition assigned to workers at the same time. 0 means no limit.")
+	f.IntVar(&cfg.MaxJobFailures, "block-builder-scheduler.max-job-failures", 0, "Maximum number of times the lease of a job can expire before the job is moved to the failed jobs, where it stays until an operator requeues it. 0 means no limit.")
+	f.IntVar(&cfg.MaxJobsPerWorker, "block-builder-scheduler.max-jobs-per-worker", 0, "Maximum number of jobs assigned to the same worker at the same time. 0 means no limit.")
+	f.DurationVar(&cfg.PartitionAffinityTTL, "block-builder-scheduler.partition-affinity-ttl", 0, "How long after a worker completes a job of a partition the new jobs of the partition are left for that worker, unless it already has the maximum number of assigned jobs. 0 disables the partition affinity.")
+	f.DurationVar(&cfg.StatePersistInterval, "block-builder-scheduler.state-persist-interval", 0, "How frequently to persist the state of the jobs to the blocks storage bucket, so that a restarted scheduler doesn't lose the in-flight assignments. The state is also persisted on shutdown. 0 disables the persistence.")
+	f.Int64Var(&cfg.MaxPartitionLag, "block-builder-scheduler.max-partition-lag", 0, "Maximum number of records of a partition after the offset committed by the consumer group. When a partition exceeds it, a job is created for the partition right away, rather than waiting for its records to be older than the consume interval. 0 disables the limit.")
+	f.DurationVar(&cfg.LagCheckInterval, "block-builder-scheduler.lag-check-interval", 5*time.Second, "How frequently to check the lag of the partitions against -block-builder-scheduler.max-partition-lag.")
+	f.Int64Var(&cfg.MinJobRecords, "block-builder-scheduler.min-job-records", 0, "Minimum number of records of a partition after the committed offset for the partition to get a job. Partitions with fewer records only get a job once their oldest record is older than -block-builder-scheduler.max-job-age, so that low traffic doesn't produce many tiny jobs. 0 disables the minimum.")
+	f.Var(&cfg.WorkerAuthToken, "block-builder-scheduler.worker-auth-token", "Token the block-builder workers must send to get and update jobs, so that a worker configured with the address of another scheduler can't take its jobs. Empty means the workers aren't authenticated.")
+	f.DurationVar(&cfg.MaxJobAge, "block-builder-scheduler.max-job-age", 6*time.Hour, "How old the oldest record of a partition can get before the partition gets a job, even if it has fewer records than -block-builder-scheduler.min-job-records.")
 }
 
 func (cfg *Config) Validate() error {
@@ -48,5 +82,35 @@ func (cfg *Config) Validate() error {
 	if cfg.JobLeaseExpiry <= 0 {
 		return fmt.Errorf("job lease expiry (%d) must be positive", cfg.JobLeaseExpiry)
 	}
+	if !slices.Contains(assignmentPolicies, cfg.AssignmentPolicy) {
+		return fmt.Errorf("unsupported assignment policy %q, supported values: %s", cfg.AssignmentPolicy, strings.Join(assignmentPolicies, ", "))
+	}
+	if cfg.MaxJobsPerPartition < 0 {
+		return fmt.Errorf("max jobs per partition (%d) must not be negative", cfg.MaxJobsPerPartition)
+	}
+	if cfg.MaxJobFailures < 0 {
+		return fmt.Errorf("max job failures (%d) must not be negative", cfg.MaxJobFailures)
+	}
+	if cfg.MaxJobsPerWorker < 0 {
+		return fmt.Errorf("max jobs per worker (%d) must not be negative", cfg.MaxJobsPerWorker)
+	}
+	if cfg.PartitionAffinityTTL < 0 {
+		return fmt.Errorf("partition affinity TTL (%d) must not be negative", cfg.PartitionAffinityTTL)
+	}
+	if cfg.StatePersistInterval < 0 {
+		return fmt.Errorf("state persist interval (%d) must not be negative", cfg.StatePersistInterval)
+	}
+	if cfg.MaxPartitionLag < 0 {
+		return fmt.Errorf("max partition lag (%d) must not be negative", cfg.MaxPartitionLag)
+	}
+	if cfg.MaxPartitionLag > 0 && cfg.LagCheckInterval <= 0 {
+		return fmt.Errorf("lag check interval (%d) must be positive", cfg.LagCheckInterval)
+	}
+	if cfg.MinJobRecords < 0 {
+		return fmt.Errorf("min job records (%d) must not be negative", cfg.MinJobRecords)
+	}
+	if cfg.MinJobRecords > 0 && cfg.MaxJobAge <= 0 {
+		return fmt.Errorf("max job age (%d) must be positive", cfg.MaxJobAge)
+	}
 	return nil
 }
@@ -5,6 +5,9 @@ package scheduler
 import (
 	"container/heap"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,28 +19,86 @@ var (
 	errJobNotFound    = errors.New("job not found")
 	errJobNotAssigned = errors.New("job not assigned to given worker")
 	errBadEpoch       = errors.New("bad epoch")
+	errJobCancelled   = errors.New("job cancelled")
+
+	errWorkerLimitReached = errors.New("worker has the max number of assigned jobs")
+)
+
+const (
+	// AssignmentPolicyOldestFirst assigns the jobs with the oldest commit record timestamp first.
+	AssignmentPolicyOldestFirst = "oldest-first"
+	// AssignmentPolicyLargestLagFirst assigns the jobs with the most records to consume first.
+	AssignmentPolicyLargestLagFirst = "largest-lag-first"
 )
 
+var assignmentPolicies = []string{AssignmentPolicyOldestFirst, AssignmentPolicyLargestLagFirst}
+
+// maxAssignScan bounds the number of unassigned jobs assign checks against the per-partition limit.
+const maxAssignScan = 1000
+
 type jobQueue struct {
-	leaseExpiry time.Duration
-	logger      log.Logger
+	leaseExpiry         time.Duration
+	maxJobsPerPartition int
+	maxFailures         int
+	maxJobsPerWorker    int
+	affinityTTL         time.Duration
+	metrics             jobQueueMetrics
+	logger              log.Logger
 
 	mu         sync.Mutex
 	epoch      int64
 	jobs       map[string]*job
 	unassigned jobHeap
+
+	// assignedPerPartition counts the assigned jobs of each partition.
+	assignedPerPartition map[int32]int
+
+	// assignedPerWorker indexes the assigned jobs by worker, and then by ID.
+	assignedPerWorker map[string]map[string]*job
+
+	// affinity holds, for each partition, the worker which last completed one of its jobs. Until
+	// the affinity expires, new jobs of the partition are left for that worker.
+	affinity map[int32]partitionAffinity
+
+	// cancelled holds the IDs of jobs cancelled by an operator, so that their
+	// assignees can be told to stop working on them.
+	cancelled map[string]struct{}
+
+	// failed holds the jobs whose leases expired more than maxFailures times. They're
+	// not assigned again until an operator requeues them.
+	failed map[string]*job
 }
 
-func newJobQueue(leaseExpiry time.Duration, logger log.Logger) *jobQueue {
+// newJobQueue returns a jobQueue assigning its jobs in the order of the given assignment policy, at most
+// maxJobsPerPartition jobs of each partition, and at most maxJobsPerWorker jobs to each worker, at a time.
+// A limit of 0 disables it. Jobs whose leases expire more than maxFailures times are moved to the failed
+// jobs; a maxFailures of 0 disables the limit. New jobs of a partition are preferably assigned to the worker
+// which completed its last job, for up to affinityTTL after the completion; an affinityTTL of 0 disables the
+// affinity.
+func newJobQueue(leaseExpiry time.Duration, policy string, maxJobsPerPartition, maxFailures, maxJobsPerWorker int, affinityTTL time.Duration, metrics jobQueueMetrics, logger log.Logger) *jobQueue {
 	return &jobQueue{
-		leaseExpiry: leaseExpiry,
-		logger:      logger,
-
-		jobs: make(map[string]*job),
+		leaseExpiry:         leaseExpiry,
+		maxJobsPerPartition: maxJobsPerPartition,
+		maxFailures:         maxFailures,
+		maxJobsPerWorker:    maxJobsPerWorker,
+		affinityTTL:         affinityTTL,
+		metrics:             metrics,
+		logger:              logger,
+
+		jobs:                 make(map[string]*job),
+		unassigned:           jobHeap{less: jobLessFunc(policy)},
+		assignedPerPartition: make(map[int32]int),
+		assignedPerWorker:    make(map[string]map[string]*job),
+		affinity:             make(map[int32]partitionAffinity),
+		cancelled:            make(map[string]struct{}),
+		failed:               make(map[string]*job),
 	}
 }
 
-// assign assigns the highest-priority unassigned job to the given worker.
+// assign assigns the highest-priority unassigned job to the given worker, or returns errWorkerLimitReached if
+// the worker already has the maximum number of assigned jobs. Jobs of partitions which already have the maximum
+// number of assigned jobs are skipped, as are jobs of partitions with an affinity to another worker, unless that
+// worker already has the maximum number of assigned jobs.
 func (s *jobQueue) assign(workerID string) (jobKey, jobSpec, error) {
 	if workerID == "" {
 		return jobKey{}, jobSpec{}, errors.New("workerID cannot be empty")
@@ -45,19 +106,125 @@ func (s *jobQueue) assign(workerID string) (jobKey, jobSpec, error) {
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
 
-	if s.unassigned.Len() == 0 {
+	if s.workerLimitReached(workerID) {
+		return jobKey{}, jobSpec{}, errWorkerLimitReached
+	}
+
+	now := time.Now()
+	var (
+		j       *job
+		skipped []*job
+	)
+	for s.unassigned.Len() > 0 && len(skipped) < maxAssignScan {
+		candidate := heap.Pop(&s.unassigned).(*job)
+		if s.partitionLimitReached(candidate.spec.partition) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		if preferred, ok := s.preferredWorker(candidate.spec.partition, now); ok && preferred != workerID && !s.workerLimitReached(preferred) {
+			skipped = append(skipped, candidate)
+			continue
+		}
+		j = candidate
+		break
+	}
+	for _, sj := range skipped {
+		heap.Push(&s.unassigned, sj)
+	}
+	if j == nil {
 		return jobKey{}, jobSpec{}, errNoJobAvailable
 	}
 
-	j := heap.Pop(&s.unassigned).(*job)
 	j.key.epoch = s.epoch
 	s.epoch++
-	j.assignee = workerID
+	j.front = false
+	s.setAssignee(j, workerID)
 	j.leaseExpiry = time.Now().Add(s.leaseExpiry)
+	if !j.planned.IsZero() {
+		s.metrics.assignmentLatency.Observe(now.Sub(j.planned).Seconds())
+		j.planned = time.Time{}
+	}
 	return j.key, j.spec, nil
 }
 
+// updateJobsMetrics updates the metrics of the number of outstanding and assigned jobs. Must be called with the lock held.
+func (s *jobQueue) updateJobsMetrics() {
+	s.metrics.outstandingJobs.Set(float64(s.unassigned.Len()))
+	s.metrics.assignedJobs.Set(float64(len(s.jobs) - s.unassigned.Len()))
+}
+
+// partitionLimitReached returns true if no more jobs of the partition can be assigned. Must be called with the lock held.
+func (s *jobQueue) partitionLimitReached(partition int32) bool {
+	return s.maxJobsPerPartition > 0 && s.assignedPerPartition[partition] >= s.maxJobsPerPartition
+}
+
+// workerLimitReached returns true if no more jobs can be assigned to the worker. Must be called with the lock held.
+func (s *jobQueue) workerLimitReached(workerID string) bool {
+	return s.maxJobsPerWorker > 0 && len(s.assignedPerWorker[workerID]) >= s.maxJobsPerWorker
+}
+
+// preferredWorker returns the worker with an affinity to the partition, if any. Expired affinities are
+// removed. Must be called with the lock held.
+func (s *jobQueue) preferredWorker(partition int32, now time.Time) (string, bool) {
+	a, ok := s.affinity[partition]
+	if !ok {
+		return "", false
+	}
+	if now.After(a.expiry) {
+		delete(s.affinity, partition)
+		return "", false
+	}
+	return a.workerID, true
+}
+
+// setAssignee assigns or, with an empty workerID, unassigns the job, keeping track of
+// the assigned jobs per partition and per worker. Must be called with the lock held.
+func (s *jobQueue) setAssignee(j *job, workerID string) {
+	switch {
+	case j.assignee == "" && workerID != "":
+		s.assignedPerPartition[j.spec.partition]++
+		if s.assignedPerWorker[workerID] == nil {
+			s.assignedPerWorker[workerID] = make(map[string]*job)
+		}
+		s.assignedPerWorker[workerID][j.key.id] = j
+	case j.assignee != "" && workerID == "":
+		s.releasePartitionSlot(j.spec.partition)
+		s.releaseWorkerSlot(j)
+	}
+	j.assignee = workerID
+}
+
+func (s *jobQueue) releasePartitionSlot(partition int32) {
+	s.assignedPerPartition[partition]--
+	if s.assignedPerPartition[partition] <= 0 {
+		delete(s.assignedPerPartition, partition)
+	}
+}
+
+func (s *jobQueue) releaseWorkerSlot(j *job) {
+	delete(s.assignedPerWorker[j.assignee], j.key.id)
+	if len(s.assignedPerWorker[j.assignee]) == 0 {
+		delete(s.assignedPerWorker, j.assignee)
+	}
+}
+
+// partitionLimitedJobs returns the number of unassigned jobs which can't be assigned
+// because their partition already has the maximum number of assigned jobs.
+func (s *jobQueue) partitionLimitedJobs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, j := range s.unassigned.jobs {
+		if s.partitionLimitReached(j.spec.partition) {
+			n++
+		}
+	}
+	return n
+}
+
 // importJob imports a job with the given ID and spec into the jobQueue. This is
 // meant to be used during recovery, when we're reconstructing the jobQueue from
 // worker updates.
@@ -71,6 +238,7 @@ func (s *jobQueue) importJob(key jobKey, workerID string, spec jobSpec) error {
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
 
 	// When we start assigning new jobs, the epochs need to be compatible with
 	// these "imported" jobs.
@@ -86,18 +254,24 @@ func (s *jobQueue) importJob(key jobKey, workerID string, spec jobSpec) error {
 			}
 		} else {
 			// Otherwise, this caller is the new authority, so we accept the update.
-			j.assignee = workerID
+			if j.assignee == "" {
+				s.removeUnassigned(j)
+			}
+			s.setAssignee(j, "")
 			j.key = key
 			j.spec = spec
+			s.setAssignee(j, workerID)
 		}
 	} else {
-		s.jobs[key.id] = &job{
+		// Imported jobs are already being worked on, so they're counted even if they exceed the per-partition limit.
+		j := &job{
 			key:         key,
-			assignee:    workerID,
 			leaseExpiry: time.Now().Add(s.leaseExpiry),
 			failCount:   0,
 			spec:        spec,
 		}
+		s.setAssignee(j, workerID)
+		s.jobs[key.id] = j
 	}
 	return nil
 }
@@ -106,11 +280,24 @@ func (s *jobQueue) importJob(key jobKey, workerID string, spec jobSpec) error {
 func (s *jobQueue) addOrUpdate(id string, spec jobSpec) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
+
+	if _, ok := s.cancelled[id]; ok {
+		// A cancelled job must not be planned again.
+		return
+	}
+	if _, ok := s.failed[id]; ok {
+		// A failed job is only planned again when an operator requeues it.
+		return
+	}
 
 	if j, ok := s.jobs[id]; ok {
-		// We can only update an unassigned job.
+		// We can only update an unassigned job. Its position in the assignment order may change.
 		if j.assignee == "" {
 			j.spec = spec
+			if i := s.unassigned.index(j); i >= 0 {
+				heap.Fix(&s.unassigned, i)
+			}
 		}
 		return
 	}
@@ -125,6 +312,7 @@ func (s *jobQueue) addOrUpdate(id string, spec jobSpec) {
 		leaseExpiry: time.Now().Add(s.leaseExpiry),
 		failCount:   0,
 		spec:        spec,
+		planned:     time.Now(),
 	}
 	s.jobs[id] = j
 	heap.Push(&s.unassigned, j)
@@ -145,6 +333,9 @@ func (s *jobQueue) renewLease(key jobKey, workerID string) error {
 
 	j, ok := s.jobs[key.id]
 	if !ok {
+		if _, cancelled := s.cancelled[key.id]; cancelled {
+			return errJobCancelled
+		}
 		return errJobNotFound
 	}
 	if j.assignee != workerID {
@@ -170,9 +361,13 @@ func (s *jobQueue) completeJob(key jobKey, workerID string) error {
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
 
 	j, ok := s.jobs[key.id]
 	if !ok {
+		if _, cancelled := s.cancelled[key.id]; cancelled {
+			return errJobCancelled
+		}
 		return errJobNotFound
 	}
 	if j.assignee != workerID {
@@ -182,25 +377,201 @@ func (s *jobQueue) completeJob(key jobKey, workerID string) error {
 		return errBadEpoch
 	}
 
+	s.setAssignee(j, "")
 	delete(s.jobs, key.id)
+	s.metrics.jobsCompleted.WithLabelValues(fmt.Sprint(j.spec.partition)).Inc()
+
+	if s.affinityTTL > 0 {
+		s.affinity[j.spec.partition] = partitionAffinity{workerID: workerID, expiry: time.Now().Add(s.affinityTTL)}
+	}
 	return nil
 }
 
+// cancelJob removes the job with the given ID from the jobQueue, whether it's
+// assigned or not. Further updates from its assignee get errJobCancelled.
+// Returns the removed job.
+func (s *jobQueue) cancelJob(id string) (job, error) {
+	if id == "" {
+		return job{}, errors.New("jobID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+
+	if j.assignee == "" {
+		s.removeUnassigned(j)
+	} else {
+		s.releasePartitionSlot(j.spec.partition)
+		s.releaseWorkerSlot(j)
+	}
+	delete(s.jobs, id)
+	s.cancelled[id] = struct{}{}
+	return *j, nil
+}
+
+// removeUnassigned removes the job from the unassigned jobs. Must be called with the lock held.
+func (s *jobQueue) removeUnassigned(j *job) {
+	if i := s.unassigned.index(j); i >= 0 {
+		heap.Remove(&s.unassigned, i)
+	}
+}
+
+// headLag returns the lag of the unassigned job which is first in the assignment order, or false if there are
+// no unassigned jobs. Jobs skipped because of the per-partition or per-worker limits aren't taken into account.
+func (s *jobQueue) headLag() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unassigned.Len() == 0 {
+		return 0, false
+	}
+	return s.unassigned.jobs[0].spec.lag(), true
+}
+
+// snapshot returns a copy of all the jobs, and the epoch of the next assignment.
+func (s *jobQueue) snapshot() ([]job, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	return jobs, s.epoch
+}
+
+// restore adds the jobs of a persisted snapshot to the jobQueue, keeping their assignees and leases, so
+// that they aren't assigned again. Jobs which are already known are skipped. This is meant to be used
+// during recovery, before importing the jobs observed from worker updates.
+func (s *jobQueue) restore(jobs []job, epoch int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
+
+	s.epoch = max(s.epoch, epoch)
+	for _, rj := range jobs {
+		if _, ok := s.jobs[rj.key.id]; ok {
+			continue
+		}
+		s.epoch = max(s.epoch, rj.key.epoch+1)
+
+		j := &job{
+			key:         rj.key,
+			leaseExpiry: rj.leaseExpiry,
+			failCount:   rj.failCount,
+			spec:        rj.spec,
+		}
+		s.jobs[j.key.id] = j
+		if rj.assignee == "" {
+			heap.Push(&s.unassigned, j)
+		} else {
+			// Restored jobs are already being worked on, so they're counted even if they exceed the per-partition limit.
+			s.setAssignee(j, rj.assignee)
+		}
+	}
+}
+
+// isCancelled returns true if the job with the given ID was cancelled.
+func (s *jobQueue) isCancelled(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.cancelled[id]
+	return ok
+}
+
 // clearExpiredLeases unassigns jobs whose leases have expired, making them
-// eligible for reassignment.
-func (s *jobQueue) clearExpiredLeases() {
+// eligible for reassignment. Jobs which failed too many times are moved to the
+// failed jobs instead, and returned.
+func (s *jobQueue) clearExpiredLeases() []job {
 	now := time.Now()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
 
+	var failed []job
 	for _, j := range s.jobs {
 		if j.assignee != "" && now.After(j.leaseExpiry) {
-			j.assignee = ""
+			// The job's partition slot is freed right away, so another job of the partition can be assigned.
+			s.setAssignee(j, "")
 			j.failCount++
+			s.metrics.leaseExpirations.Inc()
+			if s.maxFailures > 0 && j.failCount > s.maxFailures {
+				delete(s.jobs, j.key.id)
+				s.failed[j.key.id] = j
+				failed = append(failed, *j)
+				continue
+			}
 			heap.Push(&s.unassigned, j)
 		}
 	}
+	return failed
+}
+
+// releaseWorker unassigns all the jobs of a worker which is shutting down, making them eligible for
+// reassignment ahead of the other unassigned jobs. Unlike an expired lease, it doesn't count as a failure.
+// Returns the unassigned jobs.
+func (s *jobQueue) releaseWorker(workerID string) []job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
+
+	released := make([]job, 0, len(s.assignedPerWorker[workerID]))
+	for _, j := range s.assignedPerWorker[workerID] {
+		s.setAssignee(j, "")
+		j.front = true
+		heap.Push(&s.unassigned, j)
+		released = append(released, *j)
+	}
+
+	// The worker won't complete any other job, so it's not preferred for any partition anymore.
+	for partition, a := range s.affinity {
+		if a.workerID == workerID {
+			delete(s.affinity, partition)
+		}
+	}
+
+	slices.SortFunc(released, func(a, b job) int { return strings.Compare(a.key.id, b.key.id) })
+	return released
+}
+
+// failedJobs returns the jobs which failed too many times, sorted by ID.
+func (s *jobQueue) failedJobs() []job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]job, 0, len(s.failed))
+	for _, j := range s.failed {
+		jobs = append(jobs, *j)
+	}
+	slices.SortFunc(jobs, func(a, b job) int { return strings.Compare(a.key.id, b.key.id) })
+	return jobs
+}
+
+// requeueFailedJob moves the failed job with the given ID back to the unassigned jobs,
+// resetting its fail count.
+func (s *jobQueue) requeueFailedJob(id string) (job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.updateJobsMetrics()
+
+	j, ok := s.failed[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+	delete(s.failed, id)
+
+	j.failCount = 0
+	s.jobs[id] = j
+	heap.Push(&s.unassigned, j)
+	return *j, nil
 }
 
 type job struct {
@@ -210,10 +581,22 @@ type job struct {
 	leaseExpiry time.Time
 	failCount   int
 
+	// front is true for jobs released by a worker shutting down, which are assigned before the other jobs.
+	front bool
+
+	// planned is the time the job was added to the queue, until it's first assigned. It's zero for the jobs
+	// which were imported or restored.
+	planned time.Time
+
 	// job payload details. We can make this generic later for reuse.
 	spec jobSpec
 }
 
+type partitionAffinity struct {
+	workerID string
+	expiry   time.Time
+}
+
 type jobKey struct {
 	id string
 	// The assignment epoch. This is used to break ties when multiple workers
@@ -229,30 +612,74 @@ type jobSpec struct {
 	commitRecTs    time.Time
 	lastSeenOffset int64
 	lastBlockEndTs time.Time
+
+	// endRecTs is an upper bound of the record timestamp at the end offset: the time the job was last planned.
+	endRecTs time.Time
 }
 
 func (a *jobSpec) less(b *jobSpec) bool {
 	return a.commitRecTs.Before(b.commitRecTs)
 }
 
-type jobHeap []*job
+// lag returns the number of records the job consumes.
+func (a *jobSpec) lag() int64 {
+	return a.endOffset - a.startOffset
+}
+
+// lessByLag orders the jobs with the largest lag first, and the ones with the same lag by commit record timestamp.
+func lessByLag(a, b *jobSpec) bool {
+	if a.lag() != b.lag() {
+		return a.lag() > b.lag()
+	}
+	return a.less(b)
+}
+
+// jobLessFunc returns the function ordering the jobs for the given assignment policy. Unknown policies fall
+// back to the oldest-first one.
+func jobLessFunc(policy string) func(a, b *jobSpec) bool {
+	if policy == AssignmentPolicyLargestLagFirst {
+		return lessByLag
+	}
+	return (*jobSpec).less
+}
+
+type jobHeap struct {
+	jobs []*job
+	less func(a, b *jobSpec) bool
+}
 
 // Implement the heap.Interface for jobHeap.
-func (h jobHeap) Len() int           { return len(h) }
-func (h jobHeap) Less(i, j int) bool { return h[i].spec.less(&h[j].spec) }
-func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h jobHeap) Len() int      { return len(h.jobs) }
+func (h jobHeap) Swap(i, j int) { h.jobs[i], h.jobs[j] = h.jobs[j], h.jobs[i] }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h.jobs[i].front != h.jobs[j].front {
+		return h.jobs[i].front
+	}
+	return h.less(&h.jobs[i].spec, &h.jobs[j].spec)
+}
 
 func (h *jobHeap) Push(x interface{}) {
-	*h = append(*h, x.(*job))
+	h.jobs = append(h.jobs, x.(*job))
 }
 
 func (h *jobHeap) Pop() interface{} {
-	old := *h
+	old := h.jobs
 	n := len(old)
 	x := old[n-1]
 	old[n-1] = nil
-	*h = old[0 : n-1]
+	h.jobs = old[0 : n-1]
 	return x
 }
 
+// index returns the index of the job in the heap, or -1 if it isn't there.
+func (h jobHeap) index(j *job) int {
+	for i, hj := range h.jobs {
+		if hj == j {
+			return i
+		}
+	}
+	return -1
+}
+
 var _ heap.Interface = (*jobHeap)(nil)
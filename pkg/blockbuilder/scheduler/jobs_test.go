@@ -4,18 +4,22 @@ package scheduler
 
 import (
 	"container/heap"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/mimir/pkg/util/test"
 )
 
 func TestAssign(t *testing.T) {
-	s := newJobQueue(988*time.Hour, test.NewTestingLogger(t))
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
 
 	j0, j0spec, err := s.assign("w0")
 	require.Empty(t, j0.id)
@@ -43,7 +47,7 @@ func TestAssign(t *testing.T) {
 }
 
 func TestAssignComplete(t *testing.T) {
-	s := newJobQueue(988*time.Hour, test.NewTestingLogger(t))
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
 
 	{
 		err := s.completeJob(jobKey{"rando job", 965}, "w0")
@@ -87,7 +91,7 @@ func TestAssignComplete(t *testing.T) {
 }
 
 func TestLease(t *testing.T) {
-	s := newJobQueue(988*time.Hour, test.NewTestingLogger(t))
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
 	s.addOrUpdate("job1", jobSpec{topic: "hello", commitRecTs: time.Now()})
 	jk, jspec, err := s.assign("w0")
 	require.NotZero(t, jk.id)
@@ -127,8 +131,141 @@ func TestLease(t *testing.T) {
 
 // TestImportJob tests the importJob method - the method that is called to learn
 // about jobs in-flight from a previous scheduler instance.
+func TestCancel(t *testing.T) {
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		s.addOrUpdate(fmt.Sprintf("job%d", i), jobSpec{commitRecTs: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	assigned, _, err := s.assign("w0")
+	require.NoError(t, err)
+	require.Equal(t, "job0", assigned.id)
+
+	_, err = s.cancelJob("job2")
+	require.NoError(t, err)
+	_, err = s.cancelJob("job0")
+	require.NoError(t, err)
+	_, err = s.cancelJob("job0")
+	require.ErrorIs(t, err, errJobNotFound)
+
+	// The assignee of a cancelled job is told about the cancellation.
+	require.ErrorIs(t, s.renewLease(assigned, "w0"), errJobCancelled)
+	require.ErrorIs(t, s.completeJob(assigned, "w0"), errJobCancelled)
+
+	// A cancelled job can't be planned again.
+	s.addOrUpdate("job2", jobSpec{commitRecTs: now})
+
+	// The remaining jobs are still assigned in order.
+	for _, expected := range []string{"job1", "job3", "job4"} {
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, expected, k.id)
+	}
+	_, _, err = s.assign("w0")
+	require.ErrorIs(t, err, errNoJobAvailable)
+}
+
+func TestPartitionLimit(t *testing.T) {
+	now := time.Now()
+	newQueue := func(t *testing.T) *jobQueue {
+		s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 1, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, startOffset: 0, commitRecTs: now})
+		s.addOrUpdate("p0/100", jobSpec{partition: 0, startOffset: 100, commitRecTs: now.Add(time.Minute)})
+		s.addOrUpdate("p1/0", jobSpec{partition: 1, startOffset: 0, commitRecTs: now.Add(2 * time.Minute)})
+		return s
+	}
+
+	t.Run("jobs of a partition are assigned one at a time", func(t *testing.T) {
+		s := newQueue(t)
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p0/0", k.id)
+
+		// The second job of partition 0 is skipped in favor of partition 1.
+		require.Equal(t, 1, s.partitionLimitedJobs())
+		k, _, err = s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p1/0", k.id)
+
+		_, _, err = s.assign("w2")
+		require.ErrorIs(t, err, errNoJobAvailable)
+		require.Equal(t, 1, s.partitionLimitedJobs())
+	})
+
+	t.Run("completing a job unblocks the next one of the partition", func(t *testing.T) {
+		s := newQueue(t)
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p0/0", k.id)
+		require.NoError(t, s.completeJob(k, "w0"))
+		require.Equal(t, 0, s.partitionLimitedJobs())
+
+		k, _, err = s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+	})
+
+	t.Run("lease expiry frees the partition slot", func(t *testing.T) {
+		s := newQueue(t)
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p0/0", k.id)
+
+		s.jobs[k.id].leaseExpiry = now.Add(-time.Minute)
+		s.clearExpiredLeases()
+
+		// The expired job is back in the queue, and is assigned first again.
+		k2, _, err := s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/0", k2.id)
+		require.Equal(t, "w1", s.jobs[k2.id].assignee)
+		require.Equal(t, 1, s.assignedPerPartition[0])
+	})
+
+	t.Run("cancelling an assigned job frees the partition slot", func(t *testing.T) {
+		s := newQueue(t)
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		_, err = s.cancelJob(k.id)
+		require.NoError(t, err)
+
+		k, _, err = s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+	})
+
+	t.Run("imported jobs take their partition slot", func(t *testing.T) {
+		s := newQueue(t)
+		require.NoError(t, s.importJob(jobKey{"p0/50", 10}, "w0", jobSpec{partition: 0, startOffset: 50}))
+
+		k, _, err := s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p1/0", k.id)
+		require.Equal(t, 2, s.partitionLimitedJobs())
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, commitRecTs: now})
+		s.addOrUpdate("p0/100", jobSpec{partition: 0, startOffset: 100, commitRecTs: now.Add(time.Minute)})
+
+		for _, expected := range []string{"p0/0", "p0/100"} {
+			k, _, err := s.assign("w0")
+			require.NoError(t, err)
+			require.Equal(t, expected, k.id)
+		}
+		require.Equal(t, 0, s.partitionLimitedJobs())
+	})
+}
+
 func TestImportJob(t *testing.T) {
-	s := newJobQueue(988*time.Hour, test.NewTestingLogger(t))
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
 	spec := jobSpec{commitRecTs: time.Now().Add(-1 * time.Hour)}
 	require.NoError(t, s.importJob(jobKey{"job1", 122}, "w0", spec))
 	require.NoError(t, s.importJob(jobKey{"job1", 123}, "w2", spec))
@@ -163,17 +300,376 @@ func TestMinHeap(t *testing.T) {
 	r := rand.New(rand.NewSource(9900))
 	r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
 
-	h := jobHeap{}
+	h := jobHeap{less: jobLessFunc(AssignmentPolicyOldestFirst)}
 	for _, j := range order {
 		heap.Push(&h, jobs[j])
 	}
 
-	require.Len(t, h, n)
+	require.Len(t, h.jobs, n)
 
 	for i := 0; i < len(jobs); i++ {
 		p := heap.Pop(&h).(*job)
 		require.Equal(t, jobs[i], p, "pop order should be in increasing commitRecTs")
 	}
 
-	require.Empty(t, h)
+	require.Empty(t, h.jobs)
+}
+
+func TestRestore(t *testing.T) {
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 1, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+	leaseExpiry := time.Now().Add(time.Minute)
+	s.restore([]job{
+		{key: jobKey{"job1", 7}, assignee: "w0", leaseExpiry: leaseExpiry, spec: jobSpec{partition: 1, commitRecTs: time.Unix(1, 0)}},
+		{key: jobKey{"job2", 3}, spec: jobSpec{partition: 1, commitRecTs: time.Unix(2, 0)}},
+		{key: jobKey{"job3", 4}, failCount: 2, spec: jobSpec{partition: 2, commitRecTs: time.Unix(3, 0)}},
+	}, 10)
+
+	require.Len(t, s.jobs, 3)
+	require.Equal(t, "w0", s.jobs["job1"].assignee)
+	require.Equal(t, leaseExpiry, s.jobs["job1"].leaseExpiry)
+	require.Equal(t, 2, s.jobs["job3"].failCount)
+
+	// The restored assignment counts against the partition limit, and new assignments continue from the restored epoch.
+	k, _, err := s.assign("w1")
+	require.NoError(t, err)
+	require.Equal(t, jobKey{"job3", 10}, k)
+	_, _, err = s.assign("w1")
+	require.ErrorIs(t, err, errNoJobAvailable)
+
+	// An imported job with a higher epoch takes over a restored unassigned one, which can't be assigned anymore.
+	require.NoError(t, s.importJob(jobKey{"job2", 20}, "w2", jobSpec{partition: 1}))
+	require.Empty(t, s.unassigned.jobs)
+	require.Equal(t, "w2", s.jobs["job2"].assignee)
+}
+
+func TestMaxFailures(t *testing.T) {
+	const maxFailures = 2
+
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, maxFailures, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+	now := time.Now()
+	s.addOrUpdate("job1", jobSpec{partition: 1, commitRecTs: now.Add(-time.Hour)})
+	s.addOrUpdate("job2", jobSpec{partition: 2, commitRecTs: now})
+
+	// The oldest job is assigned and expires again and again.
+	for i := 0; i <= maxFailures; i++ {
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "job1", k.id)
+		require.Empty(t, s.failedJobs())
+
+		s.jobs[k.id].leaseExpiry = time.Now().Add(-time.Minute)
+		failed := s.clearExpiredLeases()
+		if i < maxFailures {
+			require.Empty(t, failed)
+		} else {
+			require.Len(t, failed, 1)
+			require.Equal(t, "job1", failed[0].key.id)
+			require.Equal(t, maxFailures+1, failed[0].failCount)
+		}
+	}
+
+	// The failed job doesn't starve the other one anymore, and isn't planned again.
+	require.Len(t, s.failedJobs(), 1)
+	require.NotContains(t, s.jobs, "job1")
+	s.addOrUpdate("job1", jobSpec{partition: 1, commitRecTs: now.Add(-time.Hour)})
+	k, _, err := s.assign("w0")
+	require.NoError(t, err)
+	require.Equal(t, "job2", k.id)
+	_, _, err = s.assign("w0")
+	require.ErrorIs(t, err, errNoJobAvailable)
+
+	// Once requeued, the job can be assigned again, with a fresh fail count.
+	_, err = s.requeueFailedJob("job2")
+	require.ErrorIs(t, err, errJobNotFound)
+	requeued, err := s.requeueFailedJob("job1")
+	require.NoError(t, err)
+	require.Zero(t, requeued.failCount)
+	require.Empty(t, s.failedJobs())
+
+	k, _, err = s.assign("w1")
+	require.NoError(t, err)
+	require.Equal(t, "job1", k.id)
+	require.NoError(t, s.completeJob(k, "w1"))
+}
+
+func TestWorkerLimit(t *testing.T) {
+	now := time.Now()
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 2, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+	for i := 0; i < 4; i++ {
+		s.addOrUpdate(fmt.Sprintf("p%d/0", i), jobSpec{partition: int32(i), commitRecTs: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	// A worker gets at most 2 jobs at a time, while the others still get theirs.
+	for _, expected := range []string{"p0/0", "p1/0"} {
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, expected, k.id)
+	}
+	_, _, err := s.assign("w0")
+	require.ErrorIs(t, err, errWorkerLimitReached)
+
+	k, _, err := s.assign("w1")
+	require.NoError(t, err)
+	require.Equal(t, "p2/0", k.id)
+
+	// Completing, expiring or cancelling a job frees a slot of its worker.
+	require.NoError(t, s.completeJob(jobKey{"p0/0", 0}, "w0"))
+	k, _, err = s.assign("w0")
+	require.NoError(t, err)
+	require.Equal(t, "p3/0", k.id)
+	_, _, err = s.assign("w0")
+	require.ErrorIs(t, err, errWorkerLimitReached)
+
+	_, err = s.cancelJob("p1/0")
+	require.NoError(t, err)
+	require.Len(t, s.assignedPerWorker["w0"], 1)
+
+	s.jobs["p3/0"].leaseExpiry = now.Add(-time.Minute)
+	s.clearExpiredLeases()
+	require.NotContains(t, s.assignedPerWorker, "w0")
+	require.Len(t, s.assignedPerWorker["w1"], 1)
+}
+
+func TestPartitionAffinity(t *testing.T) {
+	now := time.Now()
+	newQueue := func(t *testing.T, maxJobsPerWorker int, affinityTTL time.Duration) *jobQueue {
+		s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, maxJobsPerWorker, affinityTTL, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+
+		// w0 completes a job of partition 0.
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, commitRecTs: now})
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.NoError(t, s.completeJob(k, "w0"))
+
+		s.addOrUpdate("p0/100", jobSpec{partition: 0, startOffset: 100, commitRecTs: now.Add(time.Minute)})
+		s.addOrUpdate("p1/0", jobSpec{partition: 1, commitRecTs: now.Add(2 * time.Minute)})
+		return s
+	}
+
+	t.Run("the new job of the partition is left for the preferred worker", func(t *testing.T) {
+		s := newQueue(t, 0, time.Hour)
+
+		k, _, err := s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p1/0", k.id)
+		_, _, err = s.assign("w1")
+		require.ErrorIs(t, err, errNoJobAvailable)
+
+		k, _, err = s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+	})
+
+	t.Run("the job is assigned to another worker if the preferred one is at its limit", func(t *testing.T) {
+		s := newQueue(t, 1, time.Hour)
+		s.addOrUpdate("p2/0", jobSpec{partition: 2, commitRecTs: now.Add(-time.Minute)})
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p2/0", k.id)
+
+		k, _, err = s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+	})
+
+	t.Run("the affinity expires", func(t *testing.T) {
+		s := newQueue(t, 0, time.Hour)
+		s.affinity[0] = partitionAffinity{workerID: "w0", expiry: now.Add(-time.Second)}
+
+		k, _, err := s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+		require.NotContains(t, s.affinity, int32(0))
+	})
+
+	t.Run("no affinity", func(t *testing.T) {
+		s := newQueue(t, 0, 0)
+
+		k, _, err := s.assign("w1")
+		require.NoError(t, err)
+		require.Equal(t, "p0/100", k.id)
+		require.Empty(t, s.affinity)
+	})
+}
+
+func TestAssignmentPolicy(t *testing.T) {
+	now := time.Now()
+	newQueue := func(t *testing.T, policy string) *jobQueue {
+		s := newJobQueue(988*time.Hour, policy, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, startOffset: 0, endOffset: 10, commitRecTs: now.Add(-3 * time.Hour)})
+		s.addOrUpdate("p1/0", jobSpec{partition: 1, startOffset: 0, endOffset: 1000, commitRecTs: now.Add(-time.Hour)})
+		s.addOrUpdate("p2/0", jobSpec{partition: 2, startOffset: 0, endOffset: 100, commitRecTs: now.Add(-2 * time.Hour)})
+		s.addOrUpdate("p3/0", jobSpec{partition: 3, startOffset: 0, endOffset: 100, commitRecTs: now.Add(-4 * time.Hour)})
+		return s
+	}
+	assignAll := func(t *testing.T, s *jobQueue) []string {
+		var ids []string
+		for {
+			k, _, err := s.assign("w0")
+			if errors.Is(err, errNoJobAvailable) {
+				return ids
+			}
+			require.NoError(t, err)
+			ids = append(ids, k.id)
+		}
+	}
+
+	t.Run("oldest first", func(t *testing.T) {
+		s := newQueue(t, AssignmentPolicyOldestFirst)
+		lag, ok := s.headLag()
+		require.True(t, ok)
+		require.Equal(t, int64(100), lag)
+		require.Equal(t, []string{"p3/0", "p0/0", "p2/0", "p1/0"}, assignAll(t, s))
+
+		_, ok = s.headLag()
+		require.False(t, ok)
+	})
+
+	t.Run("largest lag first", func(t *testing.T) {
+		s := newQueue(t, AssignmentPolicyLargestLagFirst)
+		lag, ok := s.headLag()
+		require.True(t, ok)
+		require.Equal(t, int64(1000), lag)
+
+		// Jobs with the same lag are assigned oldest first.
+		require.Equal(t, []string{"p1/0", "p3/0", "p2/0", "p0/0"}, assignAll(t, s))
+	})
+
+	t.Run("updating the lag of an unassigned job changes its order", func(t *testing.T) {
+		s := newQueue(t, AssignmentPolicyLargestLagFirst)
+
+		// The partition got many more records since the job was planned.
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, startOffset: 0, endOffset: 5000, commitRecTs: now.Add(-3 * time.Hour)})
+		lag, _ := s.headLag()
+		require.Equal(t, int64(5000), lag)
+
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, "p0/0", k.id)
+
+		// Updates of assigned jobs are ignored, so they don't change the order either.
+		s.addOrUpdate("p0/0", jobSpec{partition: 0, startOffset: 0, endOffset: 1, commitRecTs: now.Add(-3 * time.Hour)})
+		require.Equal(t, int64(5000), s.jobs["p0/0"].spec.lag())
+		require.Equal(t, []string{"p1/0", "p3/0", "p2/0"}, assignAll(t, s))
+	})
+
+	t.Run("updating the commit record timestamp of an unassigned job changes its order", func(t *testing.T) {
+		s := newQueue(t, AssignmentPolicyOldestFirst)
+		s.addOrUpdate("p1/0", jobSpec{partition: 1, startOffset: 0, endOffset: 1000, commitRecTs: now.Add(-5 * time.Hour)})
+		require.Equal(t, []string{"p1/0", "p3/0", "p0/0", "p2/0"}, assignAll(t, s))
+	})
+}
+
+func TestReleaseWorker(t *testing.T) {
+	now := time.Now()
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, time.Hour, newJobQueueMetrics(nil), test.NewTestingLogger(t))
+	for i := 0; i < 4; i++ {
+		s.addOrUpdate(fmt.Sprintf("p%d/0", i), jobSpec{partition: int32(i), commitRecTs: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	// The first job failed once already.
+	k, _, err := s.assign("w0")
+	require.NoError(t, err)
+	s.jobs[k.id].leaseExpiry = now.Add(-time.Minute)
+	s.clearExpiredLeases()
+	require.Equal(t, 1, s.jobs[k.id].failCount)
+
+	for _, expected := range []string{"p0/0", "p1/0", "p2/0"} {
+		k, _, err := s.assign("w0")
+		require.NoError(t, err)
+		require.Equal(t, expected, k.id)
+	}
+	k, _, err = s.assign("w1")
+	require.NoError(t, err)
+	require.Equal(t, "p3/0", k.id)
+	s.affinity[7] = partitionAffinity{workerID: "w0", expiry: now.Add(time.Hour)}
+
+	// An older job is planned, but the released jobs are assigned first.
+	s.addOrUpdate("p4/0", jobSpec{partition: 4, commitRecTs: now.Add(-time.Hour)})
+
+	released := s.releaseWorker("w0")
+	require.Len(t, released, 3)
+	for i, expected := range []string{"p0/0", "p1/0", "p2/0"} {
+		require.Equal(t, expected, released[i].key.id)
+		require.Empty(t, released[i].assignee)
+	}
+	require.NotContains(t, s.assignedPerWorker, "w0")
+	require.Empty(t, s.assignedPerPartition[0])
+	require.NotContains(t, s.affinity, int32(7))
+
+	// The released jobs can be reassigned right away, and their fail counts are unchanged.
+	for _, expected := range []string{"p0/0", "p1/0", "p2/0", "p4/0"} {
+		k, _, err := s.assign("w2")
+		require.NoError(t, err)
+		require.Equal(t, expected, k.id)
+	}
+	require.Equal(t, 1, s.jobs["p0/0"].failCount)
+	require.Zero(t, s.jobs["p1/0"].failCount)
+	require.Equal(t, "w1", s.jobs["p3/0"].assignee)
+
+	// Updates from the worker which shut down are rejected.
+	require.ErrorIs(t, s.renewLease(jobKey{"p1/0", 1}, "w0"), errJobNotAssigned)
+
+	// Releasing a worker without jobs is a no-op.
+	require.Empty(t, s.releaseWorker("w0"))
+}
+
+func TestJobQueueMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	s := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(reg), test.NewTestingLogger(t))
+
+	now := time.Now()
+	s.addOrUpdate("job1", jobSpec{partition: 1, commitRecTs: now.Add(-2 * time.Hour)})
+	s.addOrUpdate("job2", jobSpec{partition: 2, commitRecTs: now.Add(-time.Hour)})
+	s.addOrUpdate("job3", jobSpec{partition: 2, commitRecTs: now})
+
+	k1, _, err := s.assign("w0")
+	require.NoError(t, err)
+	require.Equal(t, "job1", k1.id)
+	k2, _, err := s.assign("w1")
+	require.NoError(t, err)
+	require.Equal(t, "job2", k2.id)
+
+	// The lease of job1 expires, and it's assigned again.
+	s.jobs[k1.id].leaseExpiry = time.Now().Add(-time.Minute)
+	require.Empty(t, s.clearExpiredLeases())
+	k1, _, err = s.assign("w2")
+	require.NoError(t, err)
+	require.Equal(t, "job1", k1.id)
+
+	require.NoError(t, s.completeJob(k1, "w2"))
+	require.NoError(t, s.completeJob(k2, "w1"))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_assigned_jobs The number of jobs assigned to a worker.
+		# TYPE cortex_blockbuilder_scheduler_assigned_jobs gauge
+		cortex_blockbuilder_scheduler_assigned_jobs 0
+		# HELP cortex_blockbuilder_scheduler_jobs_completed_total The number of jobs completed by the workers.
+		# TYPE cortex_blockbuilder_scheduler_jobs_completed_total counter
+		cortex_blockbuilder_scheduler_jobs_completed_total{partition="1"} 1
+		cortex_blockbuilder_scheduler_jobs_completed_total{partition="2"} 1
+		# HELP cortex_blockbuilder_scheduler_lease_expirations_total The number of times the lease of an assigned job expired.
+		# TYPE cortex_blockbuilder_scheduler_lease_expirations_total counter
+		cortex_blockbuilder_scheduler_lease_expirations_total 1
+		# HELP cortex_blockbuilder_scheduler_outstanding_jobs The number of jobs waiting to be assigned to a worker.
+		# TYPE cortex_blockbuilder_scheduler_outstanding_jobs gauge
+		cortex_blockbuilder_scheduler_outstanding_jobs 1
+	`),
+		"cortex_blockbuilder_scheduler_assigned_jobs",
+		"cortex_blockbuilder_scheduler_jobs_completed_total",
+		"cortex_blockbuilder_scheduler_lease_expirations_total",
+		"cortex_blockbuilder_scheduler_outstanding_jobs",
+	))
+
+	// The latency is observed once per job, when it's first assigned.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var latencyCount uint64
+	for _, f := range families {
+		if f.GetName() == "cortex_blockbuilder_scheduler_job_assignment_latency_seconds" {
+			latencyCount = f.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	require.Equal(t, uint64(2), latencyCount)
 }
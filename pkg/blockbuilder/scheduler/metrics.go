@@ -12,6 +12,15 @@ type schedulerMetrics struct {
 	partitionStartOffset     *prometheus.GaugeVec
 	partitionCommittedOffset *prometheus.GaugeVec
 	partitionEndOffset       *prometheus.GaugeVec
+	partitionLag             *prometheus.GaugeVec
+	partitionLimitedJobs     prometheus.Gauge
+	statePersistFailures     prometheus.Counter
+	jobsFailed               *prometheus.CounterVec
+	oldestIncompleteWindow   prometheus.Gauge
+	queueHeadJobLag          prometheus.Gauge
+	workerShutdowns          prometheus.Counter
+	staleEpochUpdates        prometheus.Counter
+	jobQueue                 jobQueueMetrics
 }
 
 func newSchedulerMetrics(reg prometheus.Registerer) schedulerMetrics {
@@ -34,5 +43,74 @@ func newSchedulerMetrics(reg prometheus.Registerer) schedulerMetrics {
 			Name: "cortex_blockbuilder_scheduler_partition_committed_offset",
 			Help: "The observed committed offset of each partition.",
 		}, []string{"partition"}),
+		partitionLag: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_partition_lag_records",
+			Help: "The number of records of each partition after the committed offset, which weren't built into blocks yet.",
+		}, []string{"partition"}),
+		partitionLimitedJobs: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_jobs_blocked_by_partition_limit",
+			Help: "The number of unassigned jobs which can't be assigned because their partition has reached the max number of assigned jobs.",
+		}),
+		statePersistFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_state_persist_failures_total",
+			Help: "The number of times persisting the state of the jobs failed.",
+		}),
+		jobsFailed: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_jobs_failed_total",
+			Help: "The number of jobs moved to the failed jobs because their leases expired too many times.",
+		}, []string{"partition"}),
+		oldestIncompleteWindow: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds",
+			Help: "How long ago the oldest time window whose records weren't all built into blocks ended, across all the partitions. 0 if it didn't end yet.",
+		}),
+		queueHeadJobLag: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_queue_head_job_lag_records",
+			Help: "The number of records to consume of the unassigned job which is next in the assignment order. 0 if there are no unassigned jobs.",
+		}),
+		workerShutdowns: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_worker_shutdowns_total",
+			Help: "The number of times a worker announced its shutdown, releasing its jobs.",
+		}),
+		staleEpochUpdates: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_stale_epoch_updates_total",
+			Help: "The number of job updates rejected because their epoch was lower than the highest epoch seen for the job.",
+		}),
+		jobQueue: newJobQueueMetrics(reg),
+	}
+}
+
+// jobQueueMetrics are the metrics updated by the operations of the jobQueue.
+type jobQueueMetrics struct {
+	outstandingJobs   prometheus.Gauge
+	assignedJobs      prometheus.Gauge
+	assignmentLatency prometheus.Histogram
+	leaseExpirations  prometheus.Counter
+	jobsCompleted     *prometheus.CounterVec
+}
+
+func newJobQueueMetrics(reg prometheus.Registerer) jobQueueMetrics {
+	return jobQueueMetrics{
+		outstandingJobs: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_outstanding_jobs",
+			Help: "The number of jobs waiting to be assigned to a worker.",
+		}),
+		assignedJobs: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blockbuilder_scheduler_assigned_jobs",
+			Help: "The number of jobs assigned to a worker.",
+		}),
+		assignmentLatency: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name: "cortex_blockbuilder_scheduler_job_assignment_latency_seconds",
+			Help: "Time from when a job is planned until it's first assigned to a worker.",
+
+			NativeHistogramBucketFactor: 1.1,
+		}),
+		leaseExpirations: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_lease_expirations_total",
+			Help: "The number of times the lease of an assigned job expired.",
+		}),
+		jobsCompleted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_blockbuilder_scheduler_jobs_completed_total",
+			Help: "The number of jobs completed by the workers.",
+		}, []string{"partition"}),
 	}
 }
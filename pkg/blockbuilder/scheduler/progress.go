@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"slices"
+	"time"
+)
+
+// progressWindow is the size of the time windows the progress is tracked for. It matches the range of the
+// blocks built by the block-builders, and windows are aligned to it.
+const progressWindow = 2 * time.Hour
+
+// progressWindowLayout is the layout of the window parameter of the progress endpoint, e.g. 2024-05-01T00.
+const progressWindowLayout = "2006-01-02T15"
+
+const (
+	windowStatusCovered = "covered"
+	windowStatusPending = "pending"
+	windowStatusUnknown = "unknown"
+)
+
+// progressTracker tracks, per partition, the record timestamp up to which the blocks were built. All the
+// records of a partition before its committed offset were built, so a window is covered as soon as the
+// record timestamp of the committed offset reaches its end. It's not safe for concurrent use.
+type progressTracker struct {
+	builtUntil map[int32]time.Time
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{builtUntil: make(map[int32]time.Time)}
+}
+
+// windowOf returns the start of the window the given record timestamp belongs to. Windows include their
+// start and exclude their end, so a record exactly on the edge of two windows belongs to the later one.
+func windowOf(ts time.Time) time.Time {
+	return ts.UTC().Truncate(progressWindow)
+}
+
+// knownTimestamp returns false for record timestamps which weren't tracked, e.g. because the partition
+// had no commit metadata.
+func knownTimestamp(ts time.Time) bool {
+	return ts.UnixMilli() > 0
+}
+
+// advance records that all the records of the partition up to the given timestamp were built. The
+// tracked timestamp never moves backwards.
+func (p *progressTracker) advance(partition int32, ts time.Time) {
+	if !knownTimestamp(ts) {
+		return
+	}
+	if ts.After(p.builtUntil[partition]) {
+		p.builtUntil[partition] = ts
+	}
+}
+
+// partitions returns the tracked partitions, sorted.
+func (p *progressTracker) partitions() []int32 {
+	partitions := make([]int32, 0, len(p.builtUntil))
+	for partition := range p.builtUntil {
+		partitions = append(partitions, partition)
+	}
+	slices.Sort(partitions)
+	return partitions
+}
+
+// windowStatus returns the status of the window starting at the given time, for the partition:
+//   - covered if all the records of the window were built,
+//   - pending if some of the records of the window were built, or a job overlapping the window is planned,
+//   - unknown otherwise, e.g. for windows in the future or partitions whose progress isn't tracked yet.
+//
+// It also returns the IDs of the planned jobs overlapping the window.
+func (p *progressTracker) windowStatus(partition int32, window time.Time, planned []job) (string, []string) {
+	var overlapping []string
+	for _, j := range planned {
+		if j.spec.partition == partition && jobOverlapsWindow(j.spec, window) {
+			overlapping = append(overlapping, j.key.id)
+		}
+	}
+
+	builtUntil, ok := p.builtUntil[partition]
+	switch {
+	case ok && !builtUntil.Before(window.Add(progressWindow)):
+		return windowStatusCovered, nil
+	case len(overlapping) > 0:
+		return windowStatusPending, overlapping
+	case ok && !builtUntil.Before(window):
+		return windowStatusPending, nil
+	default:
+		return windowStatusUnknown, nil
+	}
+}
+
+// jobOverlapsWindow returns true if any of the records the job is expected to consume may fall in the window. A job
+// consumes the records after its commit record timestamp, up to the record timestamp of its end offset.
+func jobOverlapsWindow(spec jobSpec, window time.Time) bool {
+	if !knownTimestamp(spec.commitRecTs) || !knownTimestamp(spec.endRecTs) {
+		return false
+	}
+	return !windowOf(spec.commitRecTs).After(window) && !windowOf(spec.endRecTs).Before(window)
+}
+
+// oldestIncompleteWindow returns the start of the oldest window which isn't covered in all the tracked partitions.
+// It returns false if no partition is tracked.
+func (p *progressTracker) oldestIncompleteWindow() (time.Time, bool) {
+	var (
+		oldest time.Time
+		found  bool
+	)
+	for _, ts := range p.builtUntil {
+		if w := windowOf(ts); !found || w.Before(oldest) {
+			oldest, found = w, true
+		}
+	}
+	return oldest, found
+}
+
+// oldestIncompleteWindowAge returns how long ago the oldest incomplete window ended, or 0 if it hasn't ended yet.
+func (p *progressTracker) oldestIncompleteWindowAge(now time.Time) time.Duration {
+	w, ok := p.oldestIncompleteWindow()
+	if !ok {
+		return 0
+	}
+	return max(0, now.Sub(w.Add(progressWindow)))
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	p := newProgressTracker()
+
+	// Job A ends exactly on the edge of two windows, so it overlaps both. Job B spans three windows.
+	jobA := job{key: jobKey{id: "ingest/0/10"}, spec: jobSpec{partition: 0, commitRecTs: base.Add(30 * time.Minute), endRecTs: base.Add(2 * time.Hour)}}
+	jobB := job{key: jobKey{id: "ingest/1/20"}, spec: jobSpec{partition: 1, commitRecTs: base.Add(time.Hour), endRecTs: base.Add(5 * time.Hour)}}
+	planned := []job{jobA, jobB}
+
+	p.advance(0, jobA.spec.commitRecTs)
+	p.advance(1, jobB.spec.commitRecTs)
+
+	requireStatus := func(partition int32, window time.Time, planned []job, expected string, expectedJobs ...string) {
+		t.Helper()
+		st, jobs := p.windowStatus(partition, window, planned)
+		require.Equal(t, expected, st, "partition %d, window %s", partition, window)
+		require.Equal(t, expectedJobs, jobs, "partition %d, window %s", partition, window)
+	}
+
+	requireStatus(0, base.Add(-2*time.Hour), planned, windowStatusCovered)
+	requireStatus(0, base, planned, windowStatusPending, jobA.key.id)
+	requireStatus(0, base.Add(2*time.Hour), planned, windowStatusPending, jobA.key.id)
+	requireStatus(0, base.Add(4*time.Hour), planned, windowStatusUnknown)
+	requireStatus(1, base, planned, windowStatusPending, jobB.key.id)
+	requireStatus(1, base.Add(2*time.Hour), planned, windowStatusPending, jobB.key.id)
+	requireStatus(1, base.Add(4*time.Hour), planned, windowStatusPending, jobB.key.id)
+	requireStatus(1, base.Add(6*time.Hour), planned, windowStatusUnknown)
+	requireStatus(2, base, planned, windowStatusUnknown)
+
+	w, ok := p.oldestIncompleteWindow()
+	require.True(t, ok)
+	require.Equal(t, base, w)
+	require.Equal(t, time.Hour, p.oldestIncompleteWindowAge(base.Add(3*time.Hour)))
+
+	// Job A completes. Its last record is on the edge, so the first window is covered, while the second one has
+	// only been built up to its start.
+	p.advance(0, jobA.spec.endRecTs)
+	planned = []job{jobB}
+	requireStatus(0, base, planned, windowStatusCovered)
+	requireStatus(0, base.Add(2*time.Hour), planned, windowStatusPending)
+	requireStatus(1, base, planned, windowStatusPending, jobB.key.id)
+
+	// Partition 1 still holds the first window back.
+	w, _ = p.oldestIncompleteWindow()
+	require.Equal(t, base, w)
+
+	// Job B completes, so the windows it spans are covered but the one it ends in.
+	p.advance(1, jobB.spec.endRecTs)
+	planned = nil
+	requireStatus(1, base, planned, windowStatusCovered)
+	requireStatus(1, base.Add(2*time.Hour), planned, windowStatusCovered)
+	requireStatus(1, base.Add(4*time.Hour), planned, windowStatusPending)
+
+	w, _ = p.oldestIncompleteWindow()
+	require.Equal(t, base.Add(2*time.Hour), w)
+	require.Zero(t, p.oldestIncompleteWindowAge(base.Add(3*time.Hour)), "the window didn't end yet")
+	require.Equal(t, 2*time.Hour, p.oldestIncompleteWindowAge(base.Add(6*time.Hour)))
+
+	// The progress never moves backwards, and unknown timestamps are ignored.
+	p.advance(0, base)
+	p.advance(0, time.UnixMilli(0))
+	p.advance(3, time.Time{})
+	requireStatus(0, base, planned, windowStatusCovered)
+	require.Equal(t, []int32{0, 1}, p.partitions())
+}
+
+func TestProgressHandler(t *testing.T) {
+	sched, _ := mustScheduler(t)
+	reg := sched.register.(*prometheus.Registry)
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	getProgress := func(window string) (int, progressContents) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		sched.ProgressHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/progress?window="+window, nil))
+
+		var contents progressContents
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &contents))
+		}
+		return rec.Code, contents
+	}
+
+	code, _ := getProgress("2024-05-01T00")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	sched.completeObservationMode()
+
+	for _, window := range []string{"", "yesterday", "2024-05-01", "2024-05-01T01", "2024-05-01T00:30"} {
+		code, _ := getProgress(window)
+		require.Equal(t, http.StatusBadRequest, code, window)
+	}
+
+	// Nothing is known yet.
+	code, contents := getProgress("2024-05-01T00")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, windowStatusUnknown, contents.Status)
+	require.Empty(t, contents.Partitions)
+
+	// Both partitions were built up to the first window, and have a job planned spanning it.
+	sched.advanceProgress(0, base.Add(-time.Hour))
+	sched.advanceProgress(1, base.Add(-30*time.Minute))
+	sched.jobs.addOrUpdate("ingest/0/100", jobSpec{topic: "ingest", partition: 0, startOffset: 100, endOffset: 200, commitRecTs: base.Add(-time.Hour), endRecTs: base.Add(3 * time.Hour)})
+	sched.jobs.addOrUpdate("ingest/1/300", jobSpec{topic: "ingest", partition: 1, startOffset: 300, endOffset: 400, commitRecTs: base.Add(-30 * time.Minute), endRecTs: base.Add(2 * time.Hour)})
+
+	code, contents = getProgress("2024-05-01T00")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, base, contents.WindowStart)
+	require.Equal(t, base.Add(2*time.Hour), contents.WindowEnd)
+	require.Equal(t, windowStatusPending, contents.Status)
+	require.Len(t, contents.Partitions, 2)
+	require.Equal(t, []string{"ingest/0/100"}, contents.Partitions[0].Jobs)
+	require.Equal(t, []string{"ingest/1/300"}, contents.Partitions[1].Jobs)
+
+	sched.updateProgressMetrics(base.Add(4 * time.Hour))
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds How long ago the oldest time window whose records weren't all built into blocks ended, across all the partitions. 0 if it didn't end yet.
+		# TYPE cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds gauge
+		cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds 14400
+	`), "cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds"))
+
+	// The job of partition 1 completes, up to the end of the window.
+	key, spec, err := sched.assignJob("w0")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/0/100", key.id)
+	key1, spec1, err := sched.assignJob("w1")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/300", key1.id)
+	require.NoError(t, sched.updateJob(key1, "w1", true, spec1))
+
+	code, contents = getProgress("2024-05-01T00")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, windowStatusPending, contents.Status)
+	require.Equal(t, windowStatusPending, contents.Partitions[0].Status)
+	require.Equal(t, windowStatusCovered, contents.Partitions[1].Status)
+	require.Empty(t, contents.Partitions[1].Jobs)
+	require.Equal(t, base.Add(2*time.Hour), *contents.Partitions[1].BuiltUntil)
+
+	// The job of partition 0 completes too, so the first window is covered everywhere.
+	require.NoError(t, sched.updateJob(key, "w0", true, spec))
+
+	code, contents = getProgress("2024-05-01T00")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, windowStatusCovered, contents.Status)
+
+	code, contents = getProgress("2024-05-01T02")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, windowStatusPending, contents.Status)
+	require.Equal(t, windowStatusPending, contents.Partitions[0].Status)
+	require.Equal(t, windowStatusPending, contents.Partitions[1].Status)
+
+	code, contents = getProgress("2024-05-01T04")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, windowStatusUnknown, contents.Status)
+
+	// Partition 1 now holds back the second window, which ended 2h ago.
+	sched.updateProgressMetrics(base.Add(6 * time.Hour))
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds How long ago the oldest time window whose records weren't all built into blocks ended, across all the partitions. 0 if it didn't end yet.
+		# TYPE cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds gauge
+		cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds 7200
+	`), "cortex_blockbuilder_scheduler_oldest_incomplete_window_age_seconds"))
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,10 +16,12 @@ import (
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/services"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/mimir/pkg/blockbuilder"
+	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/ingest"
 )
 
@@ -32,10 +35,64 @@ type BlockBuilderScheduler struct {
 	register    prometheus.Registerer
 	metrics     schedulerMetrics
 
+	// stateBucket is where the state of the jobs is persisted. It's nil if the persistence is disabled.
+	stateBucket objstore.Bucket
+
 	mu                  sync.Mutex
 	committed           kadm.Offsets
 	observations        obsMap
 	observationComplete bool
+	skipped             map[int32][]skippedRange
+	progress            *progressTracker
+
+	// loadedJobs and loadedEpoch are the persisted state loaded on startup. They're reconciled with
+	// the worker updates received during the observation period.
+	loadedJobs  map[string]job
+	loadedEpoch int64
+
+	// unreconciled holds, by partition, the IDs of the jobs assigned in the loaded state which weren't reported by
+	// a worker yet. reconciled is closed once there are none left, which ends the observation period early.
+	unreconciled map[int32]map[string]struct{}
+	reconciled   chan struct{}
+
+	// epochs holds the highest epoch seen for each job, whether assigned by this scheduler, by the previous one, or
+	// reported by a worker. The updates of a job carrying a lower epoch are rejected.
+	epochs map[string]seenEpoch
+}
+
+// seenEpoch is the highest epoch seen for a job, and the offset the job starts from.
+type seenEpoch struct {
+	epoch       int64
+	partition   int32
+	startOffset int64
+}
+
+// staleEpochError is returned for the updates of a job carrying an epoch lower than the highest one seen for the
+// job. The job was assigned again since, so the worker must abandon it.
+type staleEpochError struct {
+	jobID       string
+	epoch       int64
+	latestEpoch int64
+}
+
+func (e *staleEpochError) Error() string {
+	return fmt.Sprintf("stale epoch %d of job %s, whose latest epoch is %d: the job must be abandoned", e.epoch, e.jobID, e.latestEpoch)
+}
+
+// Is makes a staleEpochError match errBadEpoch.
+func (e *staleEpochError) Is(target error) bool {
+	return target == errBadEpoch
+}
+
+// skippedRange is an offset range which was cancelled by an operator, and
+// therefore skipped without building blocks from it.
+type skippedRange struct {
+	JobID       string    `json:"job_id"`
+	Partition   int32     `json:"partition"`
+	StartOffset int64     `json:"start_offset"`
+	EndOffset   int64     `json:"end_offset"`
+	Reason      string    `json:"reason"`
+	CancelledAt time.Time `json:"cancelled_at"`
 }
 
 func New(
@@ -52,6 +109,10 @@ func New(
 
 		committed:    make(kadm.Offsets),
 		observations: make(obsMap),
+		skipped:      make(map[int32][]skippedRange),
+		progress:     newProgressTracker(),
+		reconciled:   make(chan struct{}),
+		epochs:       make(map[string]seenEpoch),
 	}
 	s.Service = services.NewBasicService(s.starting, s.running, s.stopping)
 	return s, nil
@@ -69,9 +130,24 @@ func (s *BlockBuilderScheduler) starting(ctx context.Context) error {
 
 	s.adminClient = kadm.NewClient(kc)
 
+	if s.cfg.StatePersistInterval > 0 {
+		bkt, err := bucket.NewClient(ctx, s.cfg.Bucket, "block-builder-scheduler", s.logger, s.register)
+		if err != nil {
+			return fmt.Errorf("creating state bucket client: %w", err)
+		}
+		// The state is stored in the prefix dedicated to Mimir internals.
+		s.stateBucket = bucket.NewPrefixedBucketClient(bkt, bucket.MimirInternalsPrefix)
+
+		// A state that can't be loaded is not fatal: the scheduler falls back to learning the state from the workers.
+		if err := s.loadState(ctx); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to load the persisted state of the jobs", "err", err)
+		}
+	}
+
 	// The startup process for block-builder-scheduler entails learning the state of the world:
 	//  1. obtain an initial set of offset info from Kafka
-	//  2. listen to worker updates for a while to learn what the previous scheduler knew
+	//  2. listen to worker updates for a while to learn what the previous scheduler knew, or until
+	//     the jobs assigned in the persisted state were all reported
 	// When both of those are complete, we transition from observation mode to normal operation.
 
 	var wg sync.WaitGroup
@@ -83,14 +159,15 @@ func (s *BlockBuilderScheduler) starting(ctx context.Context) error {
 		if err != nil {
 			panic(err)
 		}
+
+		s.mu.Lock()
 		s.committed = commitOffsetsFromLag(lag)
+		s.checkReconciled()
+		s.mu.Unlock()
 	}()
 	go func() {
 		defer wg.Done()
-		select {
-		case <-time.After(s.cfg.StartupObserveTime):
-		case <-ctx.Done():
-		}
+		s.awaitObservation(ctx)
 	}()
 
 	wg.Wait()
@@ -103,7 +180,23 @@ func (s *BlockBuilderScheduler) starting(ctx context.Context) error {
 	return nil
 }
 
+// awaitObservation waits until the startup observe time elapses, or until all the jobs assigned in the persisted
+// state were reconciled with the worker updates, whichever comes first.
+func (s *BlockBuilderScheduler) awaitObservation(ctx context.Context) {
+	select {
+	case <-time.After(s.cfg.StartupObserveTime):
+	case <-s.reconciled:
+		level.Info(s.logger).Log("msg", "all the jobs assigned in the persisted state were reported, ending the observation period")
+	case <-ctx.Done():
+	}
+}
+
 func (s *BlockBuilderScheduler) stopping(_ error) error {
+	if s.stateBucket != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		s.persistStateOrWarn(ctx)
+		cancel()
+	}
 	s.adminClient.Close()
 	return nil
 }
@@ -111,11 +204,32 @@ func (s *BlockBuilderScheduler) stopping(_ error) error {
 func (s *BlockBuilderScheduler) running(ctx context.Context) error {
 	updateTick := time.NewTicker(s.cfg.SchedulingInterval)
 	defer updateTick.Stop()
+
+	var persistTick <-chan time.Time
+	if s.stateBucket != nil {
+		t := time.NewTicker(s.cfg.StatePersistInterval)
+		defer t.Stop()
+		persistTick = t.C
+	}
+
+	var lagTick <-chan time.Time
+	if s.cfg.MaxPartitionLag > 0 {
+		t := time.NewTicker(s.cfg.LagCheckInterval)
+		defer t.Stop()
+		lagTick = t.C
+	}
+
 	for {
 		select {
 		case <-updateTick.C:
-			s.jobs.clearExpiredLeases()
+			s.clearExpiredLeases()
 			s.updateSchedule(ctx)
+			s.updateQueueMetrics()
+		case <-lagTick:
+			s.checkPartitionLag(ctx)
+			s.updateQueueMetrics()
+		case <-persistTick:
+			s.persistStateOrWarn(ctx)
 		case <-ctx.Done():
 			return nil
 		}
@@ -130,12 +244,31 @@ func (s *BlockBuilderScheduler) completeObservationMode() {
 		return
 	}
 
-	s.jobs = newJobQueue(s.cfg.JobLeaseExpiry, s.logger)
+	s.jobs = newJobQueue(s.cfg.JobLeaseExpiry, s.cfg.AssignmentPolicy, s.cfg.MaxJobsPerPartition, s.cfg.MaxJobFailures, s.cfg.MaxJobsPerWorker, s.cfg.PartitionAffinityTTL, s.metrics.jobQueue, s.logger)
 	s.finalizeObservations()
 	s.observations = nil
+	s.loadedJobs = nil
+	s.unreconciled = nil
 	s.observationComplete = true
 }
 
+// clearExpiredLeases makes the jobs whose leases expired eligible for reassignment, tracking the ones which failed too many times.
+func (s *BlockBuilderScheduler) clearExpiredLeases() {
+	for _, j := range s.jobs.clearExpiredLeases() {
+		s.metrics.jobsFailed.WithLabelValues(fmt.Sprint(j.spec.partition)).Inc()
+		level.Warn(s.logger).Log(
+			"msg", "job failed too many times; it won't be assigned again until it's requeued",
+			"job_id", j.key.id,
+			"fail_count", j.failCount,
+			"topic", j.spec.topic,
+			"partition", j.spec.partition,
+			"start_offset", j.spec.startOffset,
+			"end_offset", j.spec.endOffset,
+			"commit_rec_ts", j.spec.commitRecTs,
+		)
+	}
+}
+
 func (s *BlockBuilderScheduler) updateSchedule(ctx context.Context) {
 	startTime := time.Now()
 	defer func() {
@@ -148,14 +281,10 @@ func (s *BlockBuilderScheduler) updateSchedule(ctx context.Context) {
 		return
 	}
 
-	if ps, ok := lag[s.cfg.Kafka.Topic]; ok {
-		for part, gl := range ps {
-			partStr := fmt.Sprint(part)
-			s.metrics.partitionStartOffset.WithLabelValues(partStr).Set(float64(gl.Start.Offset))
-			s.metrics.partitionEndOffset.WithLabelValues(partStr).Set(float64(gl.End.Offset))
-			s.metrics.partitionCommittedOffset.WithLabelValues(partStr).Set(float64(gl.Commit.At))
-		}
-	}
+	s.observeLag(lag, startTime)
+	defer s.updateProgressMetrics(startTime)
+
+	s.scheduleLaggingPartitions(lag, startTime)
 
 	oldTime := time.Now().Add(-s.cfg.ConsumeInterval)
 	oldOffsets, err := s.adminClient.ListOffsetsAfterMilli(ctx, oldTime.UnixMilli(), s.cfg.Kafka.Topic)
@@ -164,6 +293,16 @@ func (s *BlockBuilderScheduler) updateSchedule(ctx context.Context) {
 		return
 	}
 
+	// The offsets of the records older than the max job age, for the partitions with too few records for a job.
+	var agedOffsets kadm.ListedOffsets
+	if s.cfg.MinJobRecords > 0 {
+		agedOffsets, err = s.adminClient.ListOffsetsAfterMilli(ctx, time.Now().Add(-s.cfg.MaxJobAge).UnixMilli(), s.cfg.Kafka.Topic)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "failed to obtain aged offsets", "err", err)
+			return
+		}
+	}
+
 	// See if the group-committed offset per partition is behind our "old" offsets.
 
 	oldOffsets.Each(func(o kadm.ListedOffset) {
@@ -172,26 +311,110 @@ func (s *BlockBuilderScheduler) updateSchedule(ctx context.Context) {
 			return
 		}
 		if l, ok := lag.Lookup(o.Topic, o.Partition); ok {
-			if l.Commit.At < o.Offset {
+			if startOffset := s.jobStartOffset(l); startOffset < o.Offset {
+				if !s.jobLargeOrOldEnough(l, startOffset, agedOffsets) {
+					level.Debug(s.logger).Log("msg", "partition ready, but its records are too few and too recent for a job", "p", o.Partition, "records", l.End.Offset-startOffset, "min_records", s.cfg.MinJobRecords)
+					return
+				}
 				level.Info(s.logger).Log("msg", "partition ready", "p", o.Partition)
-
-				// The job is uniquely identified by {topic, partition, consumption start offset}.
-				jobID := fmt.Sprintf("%s/%d/%d", o.Topic, o.Partition, l.Commit.At)
-				partState := blockbuilder.PartitionStateFromLag(s.logger, l, 0)
-				s.jobs.addOrUpdate(jobID, jobSpec{
-					topic:          o.Topic,
-					partition:      o.Partition,
-					startOffset:    l.Commit.At,
-					endOffset:      l.End.Offset,
-					commitRecTs:    partState.CommitRecordTimestamp,
-					lastSeenOffset: partState.LastSeenOffset,
-					lastBlockEndTs: partState.LastBlockEnd,
-				})
+				s.addJob(l, startOffset, startTime)
 			}
 		}
 	})
 }
 
+// jobLargeOrOldEnough returns whether the partition has enough records after startOffset for a job, or the
+// oldest of them is older than the max job age, i.e. it comes before the aged offset of the partition.
+func (s *BlockBuilderScheduler) jobLargeOrOldEnough(l kadm.GroupMemberLag, startOffset int64, agedOffsets kadm.ListedOffsets) bool {
+	if s.cfg.MinJobRecords <= 0 || l.End.Offset-startOffset >= s.cfg.MinJobRecords {
+		return true
+	}
+	aged, ok := agedOffsets.Lookup(l.Topic, l.Partition)
+	if !ok || aged.Err != nil {
+		level.Warn(s.logger).Log("msg", "failed to get aged offset", "partition", l.Partition, "err", aged.Err)
+		return false
+	}
+	return startOffset < aged.Offset
+}
+
+// checkPartitionLag refreshes the lag of the partitions, and creates the jobs of the partitions whose lag exceeds
+// the max partition lag right away, rather than waiting for the next schedule update.
+func (s *BlockBuilderScheduler) checkPartitionLag(ctx context.Context) {
+	now := time.Now()
+	lag, err := blockbuilder.GetGroupLag(ctx, s.adminClient, s.cfg.Kafka.Topic, s.cfg.ConsumerGroup, 0)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to get group lag", "err", err)
+		return
+	}
+
+	s.observeLag(lag, now)
+	s.updateProgressMetrics(now)
+	s.scheduleLaggingPartitions(lag, now)
+}
+
+// observeLag updates the offset and lag metrics of the partitions, and the build progress of the partitions
+// which are fully built.
+func (s *BlockBuilderScheduler) observeLag(lag kadm.GroupLag, now time.Time) {
+	ps, ok := lag[s.cfg.Kafka.Topic]
+	if !ok {
+		return
+	}
+	for part, gl := range ps {
+		partStr := fmt.Sprint(part)
+		s.metrics.partitionStartOffset.WithLabelValues(partStr).Set(float64(gl.Start.Offset))
+		s.metrics.partitionEndOffset.WithLabelValues(partStr).Set(float64(gl.End.Offset))
+		s.metrics.partitionCommittedOffset.WithLabelValues(partStr).Set(float64(gl.Commit.At))
+		s.metrics.partitionLag.WithLabelValues(partStr).Set(float64(max(0, gl.End.Offset-s.jobStartOffset(gl))))
+		s.pruneEpochs(part, gl.Commit.At)
+
+		if gl.Commit.At >= 0 && gl.Commit.At >= gl.End.Offset {
+			// All the records of the partition were built, so any new record belongs to a later window.
+			s.advanceProgress(part, now)
+		}
+	}
+}
+
+// scheduleLaggingPartitions creates or refreshes the jobs of the partitions with more records to consume than
+// the max partition lag, regardless of how old their records are. It's a no-op if the max partition lag is disabled.
+func (s *BlockBuilderScheduler) scheduleLaggingPartitions(lag kadm.GroupLag, now time.Time) {
+	if s.cfg.MaxPartitionLag <= 0 {
+		return
+	}
+	for part, gl := range lag[s.cfg.Kafka.Topic] {
+		startOffset := s.jobStartOffset(gl)
+		if partLag := gl.End.Offset - startOffset; partLag > s.cfg.MaxPartitionLag {
+			level.Info(s.logger).Log("msg", "partition lag exceeds the max partition lag", "p", part, "lag", partLag, "max_lag", s.cfg.MaxPartitionLag)
+			s.addJob(gl, startOffset, now)
+		}
+	}
+}
+
+// jobStartOffset returns the offset the next job of the partition starts from.
+func (s *BlockBuilderScheduler) jobStartOffset(l kadm.GroupMemberLag) int64 {
+	// Ranges skipped by cancelled jobs may have moved our local notion of the
+	// committed offset past the one in Kafka.
+	return max(l.Commit.At, s.committedOffset(l.Topic, l.Partition))
+}
+
+// addJob creates the job consuming the partition from startOffset to its end offset, or refreshes it if it
+// already exists.
+func (s *BlockBuilderScheduler) addJob(l kadm.GroupMemberLag, startOffset int64, now time.Time) {
+	// The job is uniquely identified by {topic, partition, consumption start offset}.
+	jobID := fmt.Sprintf("%s/%d/%d", l.Topic, l.Partition, startOffset)
+	partState := blockbuilder.PartitionStateFromLag(s.logger, l, 0)
+	s.advanceProgress(l.Partition, partState.CommitRecordTimestamp)
+	s.jobs.addOrUpdate(jobID, jobSpec{
+		topic:          l.Topic,
+		partition:      l.Partition,
+		startOffset:    startOffset,
+		endOffset:      l.End.Offset,
+		commitRecTs:    partState.CommitRecordTimestamp,
+		endRecTs:       now,
+		lastSeenOffset: partState.LastSeenOffset,
+		lastBlockEndTs: partState.LastBlockEnd,
+	})
+}
+
 func (s *BlockBuilderScheduler) fetchLag(ctx context.Context) (kadm.GroupLag, error) {
 	boff := backoff.New(ctx, backoff.Config{
 		MinBackoff: 100 * time.Millisecond,
@@ -224,8 +447,18 @@ func commitOffsetsFromLag(lag kadm.GroupLag) kadm.Offsets {
 	return offsets
 }
 
+// committedOffset returns the local notion of the committed offset for the given partition, or -1 if unknown.
+func (s *BlockBuilderScheduler) committedOffset(topic string, partition int32) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.committed.Lookup(topic, partition); ok {
+		return c.At
+	}
+	return -1
+}
+
 // assignJob returns an assigned job for the given workerID.
-// (This is a temporary method for unit tests until we have RPCs.)
 func (s *BlockBuilderScheduler) assignJob(workerID string) (jobKey, jobSpec, error) {
 	s.mu.Lock()
 	doneObserving := s.observationComplete
@@ -235,24 +468,46 @@ func (s *BlockBuilderScheduler) assignJob(workerID string) (jobKey, jobSpec, err
 		return jobKey{}, jobSpec{}, status.Error(codes.Unavailable, "observation period not complete")
 	}
 
-	return s.jobs.assign(workerID)
+	key, spec, err := s.jobs.assign(workerID)
+	if err == nil {
+		s.mu.Lock()
+		s.observeEpoch(key, spec)
+		s.mu.Unlock()
+	}
+	s.updateQueueMetrics()
+	return key, spec, err
+}
+
+func (s *BlockBuilderScheduler) updateQueueMetrics() {
+	s.metrics.partitionLimitedJobs.Set(float64(s.jobs.partitionLimitedJobs()))
+	headLag, _ := s.jobs.headLag()
+	s.metrics.queueHeadJobLag.Set(float64(headLag))
 }
 
 // updateJob takes a job update from the client and records it, if necessary.
-// (This is a temporary method for unit tests until we have RPCs.)
 func (s *BlockBuilderScheduler) updateJob(key jobKey, workerID string, complete bool, j jobSpec) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.checkEpoch(key); err != nil {
+		return err
+	}
+
 	if !s.observationComplete {
 		if err := s.updateObservation(key, workerID, complete, j); err != nil {
 			return fmt.Errorf("observe update: %w", err)
 		}
+		s.observeEpoch(key, j)
 
 		s.logger.Log("msg", "recovered job", "key", key, "worker", workerID)
 		return nil
 	}
 
+	if s.jobs.isCancelled(key.id) {
+		// Tell the worker to stop working on the job.
+		return errJobCancelled
+	}
+
 	if c, ok := s.committed.Lookup(s.cfg.Kafka.Topic, j.partition); ok {
 		if j.startOffset <= c.At {
 			// Update of a completed/committed job. Ignore.
@@ -268,6 +523,8 @@ func (s *BlockBuilderScheduler) updateJob(key jobKey, workerID string, complete
 				return fmt.Errorf("complete job: %w", err)
 			}
 		}
+		s.progress.advance(j.partition, j.endRecTs)
+		s.observeEpoch(key, j)
 
 		// TODO: Push forward the local notion of the committed offset.
 
@@ -277,12 +534,249 @@ func (s *BlockBuilderScheduler) updateJob(key jobKey, workerID string, complete
 		if err := s.jobs.renewLease(key, workerID); err != nil {
 			return fmt.Errorf("renew lease: %w", err)
 		}
+		s.observeEpoch(key, j)
 		s.logger.Log("msg", "renewed lease", "key", key, "worker", workerID)
 	}
 	return nil
 }
 
+// checkEpoch returns a staleEpochError if the epoch of the update is lower than the highest one seen for the job.
+// It must be called with the lock held.
+func (s *BlockBuilderScheduler) checkEpoch(key jobKey) error {
+	seen, ok := s.epochs[key.id]
+	if !ok || key.epoch >= seen.epoch {
+		return nil
+	}
+	s.metrics.staleEpochUpdates.Inc()
+	level.Warn(s.logger).Log("msg", "rejected job update with a stale epoch", "job_id", key.id, "epoch", key.epoch, "latest_epoch", seen.epoch)
+	return &staleEpochError{jobID: key.id, epoch: key.epoch, latestEpoch: seen.epoch}
+}
+
+// observeEpoch records the epoch of the job, if it's the highest seen. It must be called with the lock held.
+func (s *BlockBuilderScheduler) observeEpoch(key jobKey, spec jobSpec) {
+	if seen, ok := s.epochs[key.id]; ok && seen.epoch >= key.epoch {
+		return
+	}
+	s.epochs[key.id] = seenEpoch{epoch: key.epoch, partition: spec.partition, startOffset: spec.startOffset}
+}
+
+// pruneEpochs forgets the epochs of the partition's jobs starting before the committed offset. Their updates are
+// ignored as historical anyway.
+func (s *BlockBuilderScheduler) pruneEpochs(partition int32, committed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, seen := range s.epochs {
+		if seen.partition == partition && seen.startOffset < committed {
+			delete(s.epochs, id)
+		}
+	}
+}
+
+// cancelJob cancels the job with the given ID on behalf of an operator. The job's
+// offset range is recorded as skipped, and the local committed offset of the
+// partition advances over it if it starts at the committed offset. If the job is
+// assigned, the next update from its worker gets errJobCancelled.
+func (s *BlockBuilderScheduler) cancelJob(jobID, reason string) (skippedRange, error) {
+	if reason == "" {
+		return skippedRange{}, errors.New("reason cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observationComplete {
+		return skippedRange{}, status.Error(codes.Unavailable, "observation period not complete")
+	}
+
+	j, err := s.jobs.cancelJob(jobID)
+	if err != nil {
+		return skippedRange{}, err
+	}
+
+	r := skippedRange{
+		JobID:       jobID,
+		Partition:   j.spec.partition,
+		StartOffset: j.spec.startOffset,
+		EndOffset:   j.spec.endOffset,
+		Reason:      reason,
+		CancelledAt: time.Now(),
+	}
+	s.skipped[r.Partition] = append(s.skipped[r.Partition], r)
+
+	if c, ok := s.committed.Lookup(j.spec.topic, j.spec.partition); ok && c.At >= j.spec.startOffset && c.At < j.spec.endOffset {
+		c.At = j.spec.endOffset
+		s.committed[j.spec.topic][j.spec.partition] = c
+	}
+
+	level.Info(s.logger).Log(
+		"msg", "cancelled job; its offset range is skipped without building blocks",
+		"job_id", jobID,
+		"worker", j.assignee,
+		"partition", j.spec.partition,
+		"start_offset", j.spec.startOffset,
+		"end_offset", j.spec.endOffset,
+		"reason", reason,
+	)
+	return r, nil
+}
+
+// notifyWorkerShutdown releases the jobs assigned to a worker which is shutting down, so that they can be
+// reassigned right away rather than when their leases expire. Returns the released jobs.
+func (s *BlockBuilderScheduler) notifyWorkerShutdown(workerID string) ([]job, error) {
+	if workerID == "" {
+		return nil, errors.New("workerID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observationComplete {
+		return nil, status.Error(codes.Unavailable, "observation period not complete")
+	}
+
+	released := s.jobs.releaseWorker(workerID)
+	s.metrics.workerShutdowns.Inc()
+	level.Info(s.logger).Log("msg", "worker is shutting down; released its jobs", "worker", workerID, "jobs", len(released))
+	return released, nil
+}
+
+// failedJob is a job which failed too many times, as listed to operators.
+type failedJob struct {
+	JobID       string    `json:"job_id"`
+	Partition   int32     `json:"partition"`
+	StartOffset int64     `json:"start_offset"`
+	EndOffset   int64     `json:"end_offset"`
+	FailCount   int       `json:"fail_count"`
+	CommitRecTs time.Time `json:"commit_rec_ts"`
+}
+
+func newFailedJob(j job) failedJob {
+	return failedJob{
+		JobID:       j.key.id,
+		Partition:   j.spec.partition,
+		StartOffset: j.spec.startOffset,
+		EndOffset:   j.spec.endOffset,
+		FailCount:   j.failCount,
+		CommitRecTs: j.spec.commitRecTs,
+	}
+}
+
+// failedJobs returns the jobs which failed too many times.
+func (s *BlockBuilderScheduler) failedJobs() ([]failedJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observationComplete {
+		return nil, status.Error(codes.Unavailable, "observation period not complete")
+	}
+
+	jobs := s.jobs.failedJobs()
+	result := make([]failedJob, 0, len(jobs))
+	for _, j := range jobs {
+		result = append(result, newFailedJob(j))
+	}
+	return result, nil
+}
+
+// requeueFailedJob makes the failed job with the given ID eligible for assignment again, on behalf of an operator.
+func (s *BlockBuilderScheduler) requeueFailedJob(jobID string) (failedJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observationComplete {
+		return failedJob{}, status.Error(codes.Unavailable, "observation period not complete")
+	}
+
+	j, err := s.jobs.requeueFailedJob(jobID)
+	if err != nil {
+		return failedJob{}, err
+	}
+
+	level.Info(s.logger).Log("msg", "requeued failed job", "job_id", jobID, "partition", j.spec.partition, "start_offset", j.spec.startOffset, "end_offset", j.spec.endOffset)
+	return newFailedJob(j), nil
+}
+
+// advanceProgress records that all the records of the partition up to the given timestamp were built.
+func (s *BlockBuilderScheduler) advanceProgress(partition int32, ts time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.progress.advance(partition, ts)
+}
+
+func (s *BlockBuilderScheduler) updateProgressMetrics(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metrics.oldestIncompleteWindow.Set(s.progress.oldestIncompleteWindowAge(now).Seconds())
+}
+
+// partitionProgress is the status of a time window in a partition.
+type partitionProgress struct {
+	Partition  int32      `json:"partition"`
+	Status     string     `json:"status"`
+	BuiltUntil *time.Time `json:"built_until,omitempty"`
+	Jobs       []string   `json:"jobs,omitempty"`
+}
+
+// windowProgress returns the status of the window starting at the given time in each partition which is either
+// tracked or has planned jobs, sorted by partition.
+func (s *BlockBuilderScheduler) windowProgress(window time.Time) ([]partitionProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.observationComplete {
+		return nil, status.Error(codes.Unavailable, "observation period not complete")
+	}
+
+	// Failed jobs are still planned: their windows are pending until they're requeued and complete.
+	planned, _ := s.jobs.snapshot()
+	planned = append(planned, s.jobs.failedJobs()...)
+
+	partitions := s.progress.partitions()
+	for _, j := range planned {
+		partitions = append(partitions, j.spec.partition)
+	}
+	slices.Sort(partitions)
+	partitions = slices.Compact(partitions)
+
+	result := make([]partitionProgress, 0, len(partitions))
+	for _, p := range partitions {
+		st, jobs := s.progress.windowStatus(p, window, planned)
+		slices.Sort(jobs)
+		pp := partitionProgress{Partition: p, Status: st, Jobs: jobs}
+		if ts, ok := s.progress.builtUntil[p]; ok {
+			pp.BuiltUntil = &ts
+		}
+		result = append(result, pp)
+	}
+	return result, nil
+}
+
+// skippedRanges returns the offset ranges skipped by cancelled jobs, by partition.
+func (s *BlockBuilderScheduler) skippedRanges() map[int32][]skippedRange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ranges := make(map[int32][]skippedRange, len(s.skipped))
+	for p, rs := range s.skipped {
+		ranges[p] = append([]skippedRange(nil), rs...)
+	}
+	return ranges
+}
+
 func (s *BlockBuilderScheduler) updateObservation(key jobKey, workerID string, complete bool, j jobSpec) error {
+	// The persisted state knows about the assignments done by the previous scheduler, so it can tell stale updates apart.
+	if lj, ok := s.loadedJobs[key.id]; ok {
+		if key.epoch < lj.key.epoch {
+			return errBadEpoch
+		}
+		if key.epoch == lj.key.epoch && lj.assignee != "" && lj.assignee != workerID {
+			return errJobNotAssigned
+		}
+	}
+
 	rj, ok := s.observations[key.id]
 	if !ok {
 		s.observations[key.id] = &observation{
@@ -291,6 +785,7 @@ func (s *BlockBuilderScheduler) updateObservation(key jobKey, workerID string, c
 			workerID: workerID,
 			complete: complete,
 		}
+		s.reconcile(key.id)
 		return nil
 	}
 
@@ -304,15 +799,51 @@ func (s *BlockBuilderScheduler) updateObservation(key jobKey, workerID string, c
 	rj.spec = j
 	rj.workerID = workerID
 	rj.complete = complete
+	s.reconcile(key.id)
 	return nil
 }
 
-// finalizeObservations considers the observations and offsets from Kafka, rectifying them into
-// the starting state of the scheduler's normal operation.
+// reconcile marks the job assigned in the loaded state as reported by a worker.
+func (s *BlockBuilderScheduler) reconcile(jobID string) {
+	lj, ok := s.loadedJobs[jobID]
+	if !ok {
+		return
+	}
+	delete(s.unreconciled[lj.spec.partition], jobID)
+	s.checkReconciled()
+}
+
+// checkReconciled closes reconciled if all the jobs assigned in the loaded state were reported by a worker, or were
+// completed since the state was persisted. All the partitions are reconciled right away if the loaded state has no
+// assigned jobs, while the observation period only ends with the startup observe time if no state was loaded.
+func (s *BlockBuilderScheduler) checkReconciled() {
+	if s.loadedJobs == nil || s.unreconciled == nil {
+		return
+	}
+	for partition, ids := range s.unreconciled {
+		for id := range ids {
+			lj := s.loadedJobs[id]
+			if o, ok := s.committed.Lookup(lj.spec.topic, partition); ok && lj.spec.startOffset < o.At {
+				delete(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			delete(s.unreconciled, partition)
+		}
+	}
+	if len(s.unreconciled) == 0 {
+		s.unreconciled = nil
+		close(s.reconciled)
+	}
+}
+
+// finalizeObservations considers the observations, the persisted state and the offsets from Kafka,
+// rectifying them into the starting state of the scheduler's normal operation.
 func (s *BlockBuilderScheduler) finalizeObservations() {
 	for _, rj := range s.observations {
 		if rj.complete {
 			// Completed.
+			s.progress.advance(rj.spec.partition, rj.spec.endRecTs)
 			if o, ok := s.committed.Lookup(rj.spec.topic, rj.spec.partition); ok {
 				if rj.spec.endOffset > o.At {
 					// Completed jobs can push forward the offsets we've learned from Kafka.
@@ -328,7 +859,22 @@ func (s *BlockBuilderScheduler) finalizeObservations() {
 					Metadata:  "{}", // TODO: take the new meta from the completion message.
 				})
 			}
-		} else {
+		}
+	}
+
+	// Persisted jobs are restored before the in-progress observations, which take precedence if their epoch is higher.
+	// Jobs before the committed offsets were completed after the state was persisted, so they're not restored.
+	restored := make([]job, 0, len(s.loadedJobs))
+	for _, lj := range s.loadedJobs {
+		if o, ok := s.committed.Lookup(lj.spec.topic, lj.spec.partition); ok && lj.spec.startOffset < o.At {
+			continue
+		}
+		restored = append(restored, lj)
+	}
+	s.jobs.restore(restored, s.loadedEpoch)
+
+	for _, rj := range s.observations {
+		if !rj.complete {
 			// An in-progress job.
 			// These don't affect offsets (yet), they just get added to the job queue.
 			if err := s.jobs.importJob(rj.key, rj.workerID, rj.spec); err != nil {
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/mimir/pkg/blockbuilder/schedulerpb"
+)
+
+var _ schedulerpb.BlockBuilderSchedulerServer = (*BlockBuilderScheduler)(nil)
+
+// AssignJob implements schedulerpb.BlockBuilderSchedulerServer.
+func (s *BlockBuilderScheduler) AssignJob(ctx context.Context, req *schedulerpb.AssignJobRequest) (*schedulerpb.AssignJobResponse, error) {
+	if err := s.authenticateWorker(ctx, req.WorkerId); err != nil {
+		return nil, err
+	}
+
+	key, spec, err := s.assignJob(req.WorkerId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &schedulerpb.AssignJobResponse{
+		Key:  &schedulerpb.JobKey{Id: key.id, Epoch: key.epoch},
+		Spec: jobSpecToProto(spec),
+	}, nil
+}
+
+// UpdateJob implements schedulerpb.BlockBuilderSchedulerServer.
+func (s *BlockBuilderScheduler) UpdateJob(ctx context.Context, req *schedulerpb.UpdateJobRequest) (*schedulerpb.UpdateJobResponse, error) {
+	if err := s.authenticateWorker(ctx, req.WorkerId); err != nil {
+		return nil, err
+	}
+	if req.Key == nil || req.Spec == nil {
+		return nil, status.Error(codes.InvalidArgument, "the job key and spec are required")
+	}
+
+	key := jobKey{id: req.Key.Id, epoch: req.Key.Epoch}
+	if err := s.updateJob(key, req.WorkerId, req.Complete, jobSpecFromProto(req.Spec)); err != nil {
+		return nil, grpcError(err)
+	}
+	return &schedulerpb.UpdateJobResponse{}, nil
+}
+
+// authenticateWorker checks that the request carries the worker auth token, if one is configured, so that a worker
+// configured with the address of another scheduler can't take its jobs.
+func (s *BlockBuilderScheduler) authenticateWorker(ctx context.Context, workerID string) error {
+	expected := s.cfg.WorkerAuthToken.String()
+	if expected == "" {
+		return nil
+	}
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(schedulerpb.WorkerAuthTokenMetadataKey); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		level.Warn(s.logger).Log("msg", "rejected request of a worker with a missing or invalid auth token", "worker", workerID)
+		return status.Error(codes.Unauthenticated, "missing or invalid worker auth token")
+	}
+	return nil
+}
+
+// grpcError returns the error with the gRPC status code the workers act upon.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var code codes.Code
+	switch {
+	case errors.Is(err, errNoJobAvailable), errors.Is(err, errJobNotFound):
+		code = codes.NotFound
+	case errors.Is(err, errWorkerLimitReached):
+		code = codes.ResourceExhausted
+	case errors.Is(err, errJobCancelled):
+		code = codes.Aborted
+	case errors.Is(err, errBadEpoch), errors.Is(err, errJobNotAssigned):
+		code = codes.FailedPrecondition
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
+
+func jobSpecToProto(spec jobSpec) *schedulerpb.JobSpec {
+	return &schedulerpb.JobSpec{
+		Topic:          spec.topic,
+		Partition:      spec.partition,
+		StartOffset:    spec.startOffset,
+		EndOffset:      spec.endOffset,
+		CommitRecTs:    timeToMillis(spec.commitRecTs),
+		LastSeenOffset: spec.lastSeenOffset,
+		LastBlockEndTs: timeToMillis(spec.lastBlockEndTs),
+		EndRecTs:       timeToMillis(spec.endRecTs),
+	}
+}
+
+func jobSpecFromProto(spec *schedulerpb.JobSpec) jobSpec {
+	return jobSpec{
+		topic:          spec.Topic,
+		partition:      spec.Partition,
+		startOffset:    spec.StartOffset,
+		endOffset:      spec.EndOffset,
+		commitRecTs:    millisToTime(spec.CommitRecTs),
+		lastSeenOffset: spec.LastSeenOffset,
+		lastBlockEndTs: millisToTime(spec.LastBlockEndTs),
+		endRecTs:       millisToTime(spec.EndRecTs),
+	}
+}
+
+// timeToMillis returns the milliseconds since the Unix epoch of t, or 0 if t is zero.
+func timeToMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// millisToTime returns the time of the milliseconds since the Unix epoch, or the zero time if ms is 0.
+func millisToTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
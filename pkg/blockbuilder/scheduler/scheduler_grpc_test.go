@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gogo/status"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/grafana/mimir/pkg/blockbuilder/schedulerpb"
+)
+
+func TestBlockBuilderScheduler_GRPC(t *testing.T) {
+	sched, _ := mustScheduler(t)
+	require.NoError(t, sched.cfg.WorkerAuthToken.Set("secret"))
+
+	listen := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	schedulerpb.RegisterBlockBuilderSchedulerServer(server, sched)
+	go func() { _ = server.Serve(listen) }()
+	t.Cleanup(server.Stop)
+
+	// nolint:staticcheck // grpc.DialContext() has been deprecated; we'll address it before upgrading to gRPC 2.
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listen.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := schedulerpb.NewBlockBuilderSchedulerClient(conn)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), schedulerpb.WorkerAuthTokenMetadataKey, token)
+	}
+	ctx := withToken("secret")
+	requireCode := func(t *testing.T, expected codes.Code, err error) {
+		t.Helper()
+		require.Error(t, err)
+		require.Equal(t, expected, status.Code(err), err)
+	}
+
+	// A new scheduler starts in observation mode.
+	_, err = client.AssignJob(ctx, &schedulerpb.AssignJobRequest{WorkerId: "w0"})
+	requireCode(t, codes.Unavailable, err)
+
+	sched.completeObservationMode()
+
+	_, err = client.AssignJob(ctx, &schedulerpb.AssignJobRequest{WorkerId: "w0"})
+	requireCode(t, codes.NotFound, err)
+
+	spec := jobSpec{
+		topic:       "ingest",
+		partition:   1,
+		startOffset: 100,
+		endOffset:   200,
+		commitRecTs: time.UnixMilli(time.Now().Add(-time.Hour).UnixMilli()),
+	}
+	sched.jobs.addOrUpdate("ingest/1/100", spec)
+
+	// The requests without the worker auth token are rejected.
+	_, err = client.AssignJob(context.Background(), &schedulerpb.AssignJobRequest{WorkerId: "w0"})
+	requireCode(t, codes.Unauthenticated, err)
+	_, err = client.AssignJob(withToken("wrong"), &schedulerpb.AssignJobRequest{WorkerId: "w0"})
+	requireCode(t, codes.Unauthenticated, err)
+
+	assigned, err := client.AssignJob(ctx, &schedulerpb.AssignJobRequest{WorkerId: "w0"})
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/100", assigned.Key.Id)
+	require.Equal(t, spec, jobSpecFromProto(assigned.Spec))
+
+	// The worker renews the lease of the job.
+	_, err = client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Key: assigned.Key, WorkerId: "w0", Spec: assigned.Spec})
+	require.NoError(t, err)
+
+	// Another worker can't update the job.
+	_, err = client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Key: assigned.Key, WorkerId: "w1", Spec: assigned.Spec})
+	requireCode(t, codes.FailedPrecondition, err)
+
+	_, err = client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Key: assigned.Key, WorkerId: "w0"})
+	requireCode(t, codes.InvalidArgument, err)
+
+	_, err = client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Key: assigned.Key, WorkerId: "w0", Spec: assigned.Spec, Complete: true})
+	require.NoError(t, err)
+	require.Empty(t, sched.jobs.jobs)
+
+	// The updates with an epoch older than the job's latest one are rejected.
+	staleKey := &schedulerpb.JobKey{Id: assigned.Key.Id, Epoch: assigned.Key.Epoch - 1}
+	_, err = client.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Key: staleKey, WorkerId: "w0", Spec: assigned.Spec})
+	requireCode(t, codes.FailedPrecondition, err)
+}
+
+func TestJobSpecProto(t *testing.T) {
+	spec := jobSpec{
+		topic:          "ingest",
+		partition:      3,
+		startOffset:    10,
+		endOffset:      20,
+		commitRecTs:    time.UnixMilli(1000),
+		lastSeenOffset: 15,
+		lastBlockEndTs: time.UnixMilli(2000),
+		endRecTs:       time.UnixMilli(3000),
+	}
+	require.Equal(t, spec, jobSpecFromProto(jobSpecToProto(spec)))
+
+	// The zero times are sent as 0.
+	pb := jobSpecToProto(jobSpec{topic: "ingest"})
+	require.Zero(t, pb.CommitRecTs)
+	require.Zero(t, pb.LastBlockEndTs)
+	require.Zero(t, pb.EndRecTs)
+	require.True(t, jobSpecFromProto(pb).commitRecTs.IsZero())
+}
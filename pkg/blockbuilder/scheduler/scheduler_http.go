@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	_ "embed" // Used to embed html template
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+//go:embed jobs.gohtml
+var jobsPageHTML string
+var jobsPageTemplate = template.Must(template.New("webpage").Parse(jobsPageHTML))
+
+const (
+	jobStatusOutstanding = "outstanding"
+	jobStatusInFlight    = "in-flight"
+	jobStatusFailed      = "failed"
+)
+
+type jobsPageContents struct {
+	Now             time.Time   `json:"now"`
+	Jobs            []jobStatus `json:"jobs"`
+	OutstandingJobs int         `json:"outstanding_jobs"`
+	InFlightJobs    int         `json:"in_flight_jobs"`
+	FailedJobs      int         `json:"failed_jobs"`
+}
+
+type jobStatus struct {
+	JobID       string     `json:"job_id"`
+	Status      string     `json:"status"`
+	Partition   int32      `json:"partition"`
+	StartOffset int64      `json:"start_offset"`
+	EndOffset   int64      `json:"end_offset"`
+	CommitRecTs time.Time  `json:"commit_rec_ts"`
+	Assignee    string     `json:"assignee,omitempty"`
+	LeaseExpiry *time.Time `json:"lease_expiry,omitempty"`
+	FailCount   int        `json:"fail_count"`
+}
+
+// JobsHandler shows the jobs known by the scheduler, in the order they're assigned to workers.
+func (s *BlockBuilderScheduler) JobsHandler(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	doneObserving := s.observationComplete
+	s.mu.Unlock()
+
+	if !doneObserving {
+		http.Error(w, "observation period not complete", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The jobs are copied out of the queue, so the rendering doesn't hold its lock.
+	jobs, _ := s.jobs.snapshot()
+	failed := s.jobs.failedJobs()
+
+	contents := jobsPageContents{
+		Now:  time.Now(),
+		Jobs: make([]jobStatus, 0, len(jobs)+len(failed)),
+	}
+	for _, j := range jobs {
+		js := newJobStatus(j, jobStatusOutstanding)
+		if j.assignee != "" {
+			js.Status = jobStatusInFlight
+			leaseExpiry := j.leaseExpiry
+			js.LeaseExpiry = &leaseExpiry
+			contents.InFlightJobs++
+		} else {
+			contents.OutstandingJobs++
+		}
+		contents.Jobs = append(contents.Jobs, js)
+	}
+	for _, j := range failed {
+		contents.Jobs = append(contents.Jobs, newJobStatus(j, jobStatusFailed))
+		contents.FailedJobs++
+	}
+
+	// Jobs are assigned by their commit record timestamp, see jobSpec.less.
+	slices.SortStableFunc(contents.Jobs, func(a, b jobStatus) int {
+		if c := a.CommitRecTs.Compare(b.CommitRecTs); c != 0 {
+			return c
+		}
+		return strings.Compare(a.JobID, b.JobID)
+	})
+
+	util.RenderHTTPResponse(w, contents, jobsPageTemplate, req)
+}
+
+func newJobStatus(j job, status string) jobStatus {
+	return jobStatus{
+		JobID:       j.key.id,
+		Status:      status,
+		Partition:   j.spec.partition,
+		StartOffset: j.spec.startOffset,
+		EndOffset:   j.spec.endOffset,
+		CommitRecTs: j.spec.commitRecTs,
+		Assignee:    j.assignee,
+		FailCount:   j.failCount,
+	}
+}
+
+// CancelJobHandler cancels a job on behalf of an operator. The job's offset range is skipped without building blocks.
+func (s *BlockBuilderScheduler) CancelJobHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	jobID := req.Form.Get("job_id")
+	if jobID == "" {
+		http.Error(w, "job_id is required", http.StatusBadRequest)
+		return
+	}
+	reason := req.Form.Get("reason")
+	if reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	r, err := s.cancelJob(jobID, reason)
+	if errors.Is(err, errJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	util.WriteJSONResponse(w, r)
+}
+
+// WorkerShutdownHandler releases the jobs of a worker which is shutting down, so that they're reassigned right
+// away. It returns the released jobs.
+func (s *BlockBuilderScheduler) WorkerShutdownHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	workerID := req.Form.Get("worker_id")
+	if workerID == "" {
+		http.Error(w, "worker_id is required", http.StatusBadRequest)
+		return
+	}
+
+	released, err := s.notifyWorkerShutdown(workerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	result := make([]jobStatus, 0, len(released))
+	for _, j := range released {
+		result = append(result, newJobStatus(j, jobStatusOutstanding))
+	}
+	util.WriteJSONResponse(w, result)
+}
+
+// FailedJobsHandler lists the jobs which failed too many times.
+func (s *BlockBuilderScheduler) FailedJobsHandler(w http.ResponseWriter, _ *http.Request) {
+	jobs, err := s.failedJobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	util.WriteJSONResponse(w, jobs)
+}
+
+// RequeueFailedJobHandler makes a job which failed too many times eligible for assignment again, on behalf of an operator.
+func (s *BlockBuilderScheduler) RequeueFailedJobHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	jobID := req.Form.Get("job_id")
+	if jobID == "" {
+		http.Error(w, "job_id is required", http.StatusBadRequest)
+		return
+	}
+
+	j, err := s.requeueFailedJob(jobID)
+	if errors.Is(err, errJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	util.WriteJSONResponse(w, j)
+}
+
+type progressContents struct {
+	WindowStart time.Time           `json:"window_start"`
+	WindowEnd   time.Time           `json:"window_end"`
+	Status      string              `json:"status"`
+	Partitions  []partitionProgress `json:"partitions"`
+}
+
+// ProgressHandler shows whether the records of a time window were built into blocks, per partition. The window is
+// covered when it's covered in all the partitions, pending when it's pending in any of them, and unknown otherwise.
+func (s *BlockBuilderScheduler) ProgressHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	param := req.Form.Get("window")
+	if param == "" {
+		http.Error(w, "window is required", http.StatusBadRequest)
+		return
+	}
+	window, err := time.Parse(progressWindowLayout, param)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid window %q: expected a UTC hour in the format %s", param, progressWindowLayout), http.StatusBadRequest)
+		return
+	}
+	if !windowOf(window).Equal(window) {
+		http.Error(w, fmt.Sprintf("invalid window %q: must be aligned to %s", param, progressWindow), http.StatusBadRequest)
+		return
+	}
+
+	partitions, err := s.windowProgress(window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	contents := progressContents{
+		WindowStart: window,
+		WindowEnd:   window.Add(progressWindow),
+		Status:      windowStatusUnknown,
+		Partitions:  partitions,
+	}
+	covered := len(partitions) > 0
+	for _, p := range partitions {
+		if p.Status == windowStatusPending {
+			contents.Status = windowStatusPending
+		}
+		covered = covered && p.Status == windowStatusCovered
+	}
+	if covered {
+		contents.Status = windowStatusCovered
+	}
+	util.WriteJSONResponse(w, contents)
+}
+
+// SkippedRangesHandler lists the offset ranges skipped by cancelled jobs, optionally filtered by partition.
+func (s *BlockBuilderScheduler) SkippedRangesHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ranges := s.skippedRanges()
+
+	if p := req.Form.Get("partition"); p != "" {
+		partition, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid partition %q", p), http.StatusBadRequest)
+			return
+		}
+		ranges = map[int32][]skippedRange{int32(partition): ranges[int32(partition)]}
+	}
+
+	partitions := make([]int32, 0, len(ranges))
+	for p := range ranges {
+		partitions = append(partitions, p)
+	}
+	slices.Sort(partitions)
+
+	result := make([]skippedRange, 0)
+	for _, p := range partitions {
+		result = append(result, ranges[p]...)
+	}
+	util.WriteJSONResponse(w, result)
+}
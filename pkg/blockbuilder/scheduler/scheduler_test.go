@@ -4,9 +4,13 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +18,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 
@@ -189,7 +194,7 @@ func TestObservations(t *testing.T) {
 	}
 
 	{
-		nq := newJobQueue(988*time.Hour, test.NewTestingLogger(t))
+		nq := newJobQueue(988*time.Hour, AssignmentPolicyOldestFirst, 0, 0, 0, 0, newJobQueueMetrics(nil), test.NewTestingLogger(t))
 		sched.jobs = nq
 		sched.finalizeObservations()
 		require.Len(t, nq.jobs, 0, "No observations, no jobs")
@@ -342,3 +347,669 @@ func TestMonitor(t *testing.T) {
 		cortex_blockbuilder_scheduler_partition_end_offset{partition="3"} 3
 	`), "cortex_blockbuilder_scheduler_partition_end_offset"))
 }
+
+func TestMaxPartitionLag(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(errors.New("test done")) })
+
+	sched, cli := mustScheduler(t)
+	sched.cfg.ConsumeInterval = time.Hour
+	reg := sched.register.(*prometheus.Registry)
+	sched.completeObservationMode()
+
+	// The records are recent, so they're not old enough for the partitions to be ready.
+	produce := func(partition int32, n int) {
+		for i := 0; i < n; i++ {
+			produceResult := cli.ProduceSync(ctx, &kgo.Record{
+				Timestamp: time.Now(),
+				Value:     []byte(fmt.Sprintf("value-%d", i)),
+				Topic:     "ingest",
+				Partition: partition,
+			})
+			require.NoError(t, produceResult.FirstErr())
+		}
+	}
+	produce(0, 10)
+	produce(1, 3)
+	produce(2, 10)
+
+	// The consumer group committed some of the records of partition 2.
+	offsets := make(kadm.Offsets)
+	offsets.Add(kadm.Offset{Topic: "ingest", Partition: 2, At: 8, LeaderEpoch: -1})
+	_, err := sched.adminClient.CommitOffsets(ctx, sched.cfg.ConsumerGroup, offsets)
+	require.NoError(t, err)
+
+	t.Run("disabled", func(t *testing.T) {
+		sched.updateSchedule(ctx)
+		require.Empty(t, sched.jobs.jobs)
+
+		require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(
+			`# HELP cortex_blockbuilder_scheduler_partition_committed_offset The observed committed offset of each partition.
+			# TYPE cortex_blockbuilder_scheduler_partition_committed_offset gauge
+			cortex_blockbuilder_scheduler_partition_committed_offset{partition="0"} 0
+			cortex_blockbuilder_scheduler_partition_committed_offset{partition="1"} 0
+			cortex_blockbuilder_scheduler_partition_committed_offset{partition="2"} 8
+			cortex_blockbuilder_scheduler_partition_committed_offset{partition="3"} 0
+			# HELP cortex_blockbuilder_scheduler_partition_lag_records The number of records of each partition after the committed offset, which weren't built into blocks yet.
+			# TYPE cortex_blockbuilder_scheduler_partition_lag_records gauge
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="0"} 10
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="1"} 3
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="2"} 2
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="3"} 0
+		`), "cortex_blockbuilder_scheduler_partition_committed_offset", "cortex_blockbuilder_scheduler_partition_lag_records"))
+	})
+
+	sched.cfg.MaxPartitionLag = 5
+
+	t.Run("lagging partitions get a job right away", func(t *testing.T) {
+		sched.checkPartitionLag(ctx)
+		require.Len(t, sched.jobs.jobs, 1)
+		require.Contains(t, sched.jobs.jobs, "ingest/0/0")
+		require.Equal(t, int64(10), sched.jobs.jobs["ingest/0/0"].spec.endOffset)
+
+		// Partition 1 exceeds the max lag too.
+		produce(1, 3)
+		sched.checkPartitionLag(ctx)
+		require.Len(t, sched.jobs.jobs, 2)
+		require.Contains(t, sched.jobs.jobs, "ingest/1/0")
+
+		require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(
+			`# HELP cortex_blockbuilder_scheduler_partition_lag_records The number of records of each partition after the committed offset, which weren't built into blocks yet.
+			# TYPE cortex_blockbuilder_scheduler_partition_lag_records gauge
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="0"} 10
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="1"} 6
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="2"} 2
+			cortex_blockbuilder_scheduler_partition_lag_records{partition="3"} 0
+		`), "cortex_blockbuilder_scheduler_partition_lag_records"))
+	})
+
+	t.Run("the job of a lagging partition is refreshed", func(t *testing.T) {
+		produce(0, 2)
+		sched.checkPartitionLag(ctx)
+		require.Equal(t, int64(12), sched.jobs.jobs["ingest/0/0"].spec.endOffset)
+
+		// An assigned job isn't updated.
+		key, _, err := sched.assignJob("w0")
+		require.NoError(t, err)
+		require.Equal(t, "ingest/0/0", key.id)
+		produce(0, 2)
+		sched.updateSchedule(ctx)
+		require.Equal(t, int64(12), sched.jobs.jobs["ingest/0/0"].spec.endOffset)
+		require.Len(t, sched.jobs.jobs, 2)
+	})
+}
+
+func TestMinJobRecords(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(errors.New("test done")) })
+
+	sched, cli := mustScheduler(t)
+	sched.cfg.MinJobRecords = 5
+	sched.cfg.MaxJobAge = time.Hour
+	reg := sched.register.(*prometheus.Registry)
+	sched.completeObservationMode()
+
+	produce := func(partition int32, n int, ts time.Time) {
+		for i := 0; i < n; i++ {
+			produceResult := cli.ProduceSync(ctx, &kgo.Record{
+				Timestamp: ts,
+				Value:     []byte(fmt.Sprintf("value-%d", i)),
+				Topic:     "ingest",
+				Partition: partition,
+			})
+			require.NoError(t, produceResult.FirstErr())
+		}
+	}
+	// All the records are older than the consume interval, so all the partitions with records are ready.
+	produce(0, 3, time.Now().Add(-10*time.Minute))
+	produce(1, 3, time.Now().Add(-2*time.Hour))
+	produce(2, 6, time.Now().Add(-10*time.Minute))
+
+	sched.updateSchedule(ctx)
+
+	// Partition 0 has too few records, which are too recent. Partition 1 has too few records, but they're old
+	// enough. Partition 2 has enough records.
+	require.Len(t, sched.jobs.jobs, 2)
+	require.NotContains(t, sched.jobs.jobs, "ingest/0/0")
+	require.Contains(t, sched.jobs.jobs, "ingest/1/0")
+	require.Contains(t, sched.jobs.jobs, "ingest/2/0")
+
+	// The offsets of the partition without a job are tracked anyway.
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(
+		`# HELP cortex_blockbuilder_scheduler_partition_end_offset The observed end offset of each partition.
+		# TYPE cortex_blockbuilder_scheduler_partition_end_offset gauge
+		cortex_blockbuilder_scheduler_partition_end_offset{partition="0"} 3
+		cortex_blockbuilder_scheduler_partition_end_offset{partition="1"} 3
+		cortex_blockbuilder_scheduler_partition_end_offset{partition="2"} 6
+		cortex_blockbuilder_scheduler_partition_end_offset{partition="3"} 0
+		# HELP cortex_blockbuilder_scheduler_partition_lag_records The number of records of each partition after the committed offset, which weren't built into blocks yet.
+		# TYPE cortex_blockbuilder_scheduler_partition_lag_records gauge
+		cortex_blockbuilder_scheduler_partition_lag_records{partition="0"} 3
+		cortex_blockbuilder_scheduler_partition_lag_records{partition="1"} 3
+		cortex_blockbuilder_scheduler_partition_lag_records{partition="2"} 6
+		cortex_blockbuilder_scheduler_partition_lag_records{partition="3"} 0
+	`), "cortex_blockbuilder_scheduler_partition_end_offset", "cortex_blockbuilder_scheduler_partition_lag_records"))
+
+	// Partition 0 gets a job once it has enough records.
+	produce(0, 2, time.Now().Add(-time.Minute))
+	sched.updateSchedule(ctx)
+	require.Contains(t, sched.jobs.jobs, "ingest/0/0")
+	require.Equal(t, int64(5), sched.jobs.jobs["ingest/0/0"].spec.endOffset)
+}
+
+func TestCancelJob(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(errors.New("test done")) })
+
+	sched, cli := mustScheduler(t)
+	sched.completeObservationMode()
+	sched.committed = kadm.Offsets{
+		"ingest": {1: kadm.Offset{Topic: "ingest", Partition: 1, At: 0}},
+	}
+
+	produce := func(partition int32, n int) {
+		for i := 0; i < n; i++ {
+			produceResult := cli.ProduceSync(ctx, &kgo.Record{
+				Timestamp: time.Unix(int64(i), 0),
+				Value:     []byte(fmt.Sprintf("value-%d", i)),
+				Topic:     "ingest",
+				Partition: partition,
+			})
+			require.NoError(t, produceResult.FirstErr())
+		}
+	}
+
+	produce(1, 10)
+	produce(2, 5)
+	sched.updateSchedule(ctx)
+	require.Len(t, sched.jobs.jobs, 2)
+
+	_, err := sched.cancelJob("ingest/1/0", "")
+	require.ErrorContains(t, err, "reason cannot be empty")
+	_, err = sched.cancelJob("ingest/9/0", "bad producer")
+	require.ErrorIs(t, err, errJobNotFound)
+
+	t.Run("cancel an outstanding job", func(t *testing.T) {
+		r, err := sched.cancelJob("ingest/1/0", "bad producer")
+		require.NoError(t, err)
+		require.Equal(t, int64(0), r.StartOffset)
+		require.Equal(t, int64(10), r.EndOffset)
+
+		// The only job left is the one for the other partition.
+		key, spec, err := sched.assignJob("w0")
+		require.NoError(t, err)
+		require.Equal(t, "ingest/2/0", key.id)
+		require.Equal(t, int32(2), spec.partition)
+		_, _, err = sched.assignJob("w0")
+		require.ErrorIs(t, err, errNoJobAvailable)
+
+		// The frontier advanced over the skipped range.
+		require.Equal(t, int64(10), sched.committedOffset("ingest", 1))
+	})
+
+	t.Run("the next job starts after the skipped range", func(t *testing.T) {
+		produce(1, 3)
+		sched.updateSchedule(ctx)
+
+		require.NotContains(t, sched.jobs.jobs, "ingest/1/0")
+		require.Contains(t, sched.jobs.jobs, "ingest/1/10")
+		require.Equal(t, int64(10), sched.jobs.jobs["ingest/1/10"].spec.startOffset)
+	})
+
+	t.Run("cancel an assigned job", func(t *testing.T) {
+		j := sched.jobs.jobs["ingest/2/0"]
+		require.Equal(t, "w0", j.assignee)
+
+		_, err := sched.cancelJob("ingest/2/0", "garbage data")
+		require.NoError(t, err)
+
+		// The worker is told to stop working on the job.
+		require.ErrorIs(t, sched.updateJob(j.key, "w0", false, j.spec), errJobCancelled)
+		require.ErrorIs(t, sched.updateJob(j.key, "w0", true, j.spec), errJobCancelled)
+
+		// The range of partition 2 isn't at the local committed offset, which is unknown, so the frontier doesn't move.
+		require.Equal(t, int64(-1), sched.committedOffset("ingest", 2))
+	})
+
+	t.Run("skipped ranges listing", func(t *testing.T) {
+		ranges := sched.skippedRanges()
+		require.Len(t, ranges, 2)
+		require.Equal(t, "bad producer", ranges[1][0].Reason)
+		require.Equal(t, "garbage data", ranges[2][0].Reason)
+
+		rec := httptest.NewRecorder()
+		sched.SkippedRangesHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/skipped-ranges?partition=2", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var listed []skippedRange
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+		require.Len(t, listed, 1)
+		require.Equal(t, "ingest/2/0", listed[0].JobID)
+		require.Equal(t, int64(0), listed[0].StartOffset)
+		require.Equal(t, int64(5), listed[0].EndOffset)
+	})
+
+	t.Run("cancel handler", func(t *testing.T) {
+		cancelReq := func(form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/block-builder-scheduler/jobs/cancel", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			sched.CancelJobHandler(rec, req)
+			return rec
+		}
+
+		require.Equal(t, http.StatusBadRequest, cancelReq(url.Values{"job_id": {"ingest/1/10"}}).Code)
+		require.Equal(t, http.StatusNotFound, cancelReq(url.Values{"job_id": {"ingest/1/0"}, "reason": {"again"}}).Code)
+		require.Equal(t, http.StatusOK, cancelReq(url.Values{"job_id": {"ingest/1/10"}, "reason": {"still bad"}}).Code)
+		require.Len(t, sched.skippedRanges()[1], 2)
+	})
+}
+
+func TestPersistedStateRestart(t *testing.T) {
+	ctx := context.Background()
+	_, kafkaAddr := testkafka.CreateClusterWithoutCustomConsumerGroupsSupport(t, 4, "ingest")
+	bkt := objstore.NewInMemBucket()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	spec := func(partition int32, start, end int64) jobSpec {
+		return jobSpec{
+			topic:          "ingest",
+			partition:      partition,
+			startOffset:    start,
+			endOffset:      end,
+			commitRecTs:    now.Add(-time.Duration(partition) * time.Hour),
+			endRecTs:       now.Add(-time.Duration(partition)*time.Hour + 30*time.Minute),
+			lastSeenOffset: start - 1,
+			lastBlockEndTs: now.Add(-2 * time.Hour),
+		}
+	}
+
+	// The first scheduler assigns two of the three jobs, persists its state, and then one of the assigned jobs completes.
+	sched1, _ := mustSchedulerWithKafkaAddr(t, kafkaAddr)
+	sched1.stateBucket = bkt
+	sched1.completeObservationMode()
+	sched1.jobs.addOrUpdate("ingest/1/100", spec(1, 100, 200))
+	sched1.jobs.addOrUpdate("ingest/2/300", spec(2, 300, 400))
+	sched1.jobs.addOrUpdate("ingest/3/500", spec(3, 500, 600))
+
+	k3, s3, err := sched1.assignJob("w3")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/3/500", k3.id)
+	k2, s2, err := sched1.assignJob("w2")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/2/300", k2.id)
+
+	require.NoError(t, sched1.persistState(ctx))
+	require.NoError(t, sched1.updateJob(k2, "w2", true, s2))
+
+	// The second scheduler loads the state, and learns the committed offsets from Kafka, where the completed job was committed.
+	sched2, _ := mustSchedulerWithKafkaAddr(t, kafkaAddr)
+	sched2.stateBucket = bkt
+	require.NoError(t, sched2.loadState(ctx))
+	sched2.committed = kadm.Offsets{
+		"ingest": {
+			1: kadm.Offset{Topic: "ingest", Partition: 1, At: 50},
+			2: kadm.Offset{Topic: "ingest", Partition: 2, At: 400},
+			3: kadm.Offset{Topic: "ingest", Partition: 3, At: 450},
+		},
+	}
+
+	// Updates during the observation period are reconciled against the loaded state.
+	require.NoError(t, sched2.updateJob(k3, "w3", false, s3))
+	require.ErrorIs(t, sched2.updateJob(k3, "w9", false, s3), errJobNotAssigned)
+	require.ErrorIs(t, sched2.updateJob(jobKey{id: k3.id, epoch: k3.epoch - 1}, "w3", false, s3), errBadEpoch)
+
+	sched2.completeObservationMode()
+
+	// The job assigned before the restart is still assigned to the same worker, with the same spec.
+	j3 := sched2.jobs.jobs[k3.id]
+	require.NotNil(t, j3)
+	require.Equal(t, "w3", j3.assignee)
+	require.Equal(t, k3, j3.key)
+	require.Equal(t, s3, j3.spec)
+	require.Equal(t, s3.endRecTs, j3.spec.endRecTs)
+	require.NoError(t, sched2.updateJob(k3, "w3", false, s3))
+
+	// The job completed after the state was persisted is not re-issued, and the assigned one is not assigned twice.
+	k1, s1, err := sched2.assignJob("w1")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/100", k1.id)
+	require.Equal(t, spec(1, 100, 200), s1)
+	require.Greater(t, k1.epoch, k3.epoch)
+	require.Greater(t, k1.epoch, k2.epoch)
+	_, _, err = sched2.assignJob("w4")
+	require.ErrorIs(t, err, errNoJobAvailable)
+
+	require.NoError(t, sched2.updateJob(k3, "w3", true, s3))
+	require.NoError(t, sched2.updateJob(k1, "w1", true, s1))
+	require.Empty(t, sched2.jobs.jobs)
+}
+
+func TestPersistedStateNotLoaded(t *testing.T) {
+	ctx := context.Background()
+	sched, _ := mustScheduler(t)
+	sched.stateBucket = objstore.NewInMemBucket()
+
+	// Nothing was persisted yet.
+	require.NoError(t, sched.loadState(ctx))
+	require.Empty(t, sched.loadedJobs)
+
+	// A scheduler still in observation mode doesn't overwrite the persisted state.
+	require.NoError(t, sched.persistState(ctx))
+	exists, err := sched.stateBucket.Exists(ctx, stateObjectName)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	sched.completeObservationMode()
+	require.NoError(t, sched.persistState(ctx))
+	exists, err = sched.stateBucket.Exists(ctx, stateObjectName)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestFailedJobs(t *testing.T) {
+	sched, _ := mustScheduler(t)
+
+	rec := httptest.NewRecorder()
+	sched.FailedJobsHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/jobs/failed", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	sched.cfg.MaxJobFailures = 1
+	sched.completeObservationMode()
+	sched.jobs.addOrUpdate("ingest/1/100", jobSpec{topic: "ingest", partition: 1, startOffset: 100, endOffset: 200, commitRecTs: time.Now()})
+
+	// Drive the job through repeated lease expiries.
+	for i := 0; i < 2; i++ {
+		k, _, err := sched.assignJob("w0")
+		require.NoError(t, err)
+		sched.jobs.jobs[k.id].leaseExpiry = time.Now().Add(-time.Minute)
+		sched.clearExpiredLeases()
+	}
+	_, _, err := sched.assignJob("w0")
+	require.ErrorIs(t, err, errNoJobAvailable)
+
+	require.NoError(t, promtest.GatherAndCompare(sched.register.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_jobs_failed_total The number of jobs moved to the failed jobs because their leases expired too many times.
+		# TYPE cortex_blockbuilder_scheduler_jobs_failed_total counter
+		cortex_blockbuilder_scheduler_jobs_failed_total{partition="1"} 1
+	`), "cortex_blockbuilder_scheduler_jobs_failed_total"))
+
+	rec = httptest.NewRecorder()
+	sched.FailedJobsHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/jobs/failed", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed []failedJob
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	require.Equal(t, "ingest/1/100", listed[0].JobID)
+	require.Equal(t, int64(100), listed[0].StartOffset)
+	require.Equal(t, 2, listed[0].FailCount)
+
+	requeueReq := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/block-builder-scheduler/jobs/requeue", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		sched.RequeueFailedJobHandler(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusBadRequest, requeueReq(url.Values{}).Code)
+	require.Equal(t, http.StatusNotFound, requeueReq(url.Values{"job_id": {"ingest/2/0"}}).Code)
+	require.Equal(t, http.StatusOK, requeueReq(url.Values{"job_id": {"ingest/1/100"}}).Code)
+
+	k, _, err := sched.assignJob("w1")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/100", k.id)
+}
+
+func TestJobsHandler(t *testing.T) {
+	sched, _ := mustScheduler(t)
+
+	rec := httptest.NewRecorder()
+	sched.JobsHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/jobs", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	sched.cfg.MaxJobFailures = 1
+	sched.completeObservationMode()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	sched.jobs.addOrUpdate("ingest/1/100", jobSpec{topic: "ingest", partition: 1, startOffset: 100, endOffset: 200, commitRecTs: now.Add(-3 * time.Hour)})
+	sched.jobs.addOrUpdate("ingest/2/300", jobSpec{topic: "ingest", partition: 2, startOffset: 300, endOffset: 400, commitRecTs: now.Add(-2 * time.Hour)})
+	sched.jobs.addOrUpdate("ingest/3/500", jobSpec{topic: "ingest", partition: 3, startOffset: 500, endOffset: 600, commitRecTs: now.Add(-1 * time.Hour)})
+	sched.jobs.addOrUpdate("ingest/4/700", jobSpec{topic: "ingest", partition: 4, startOffset: 700, endOffset: 800, commitRecTs: now.Add(-4 * time.Hour)})
+
+	// The oldest job fails twice, and the next one is assigned.
+	for i := 0; i < 2; i++ {
+		k, _, err := sched.assignJob("w0")
+		require.NoError(t, err)
+		require.Equal(t, "ingest/4/700", k.id)
+		sched.jobs.jobs[k.id].leaseExpiry = time.Now().Add(-time.Minute)
+		sched.clearExpiredLeases()
+	}
+	k, _, err := sched.assignJob("w1")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/100", k.id)
+
+	req := httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/jobs", nil)
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	sched.JobsHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var page jobsPageContents
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Equal(t, 2, page.OutstandingJobs)
+	require.Equal(t, 1, page.InFlightJobs)
+	require.Equal(t, 1, page.FailedJobs)
+
+	// Jobs are sorted in assignment order.
+	ids := make([]string, 0, len(page.Jobs))
+	for _, j := range page.Jobs {
+		ids = append(ids, j.JobID)
+	}
+	require.Equal(t, []string{"ingest/4/700", "ingest/1/100", "ingest/2/300", "ingest/3/500"}, ids)
+
+	require.Equal(t, jobStatusFailed, page.Jobs[0].Status)
+	require.Equal(t, 2, page.Jobs[0].FailCount)
+	require.Nil(t, page.Jobs[0].LeaseExpiry)
+
+	require.Equal(t, jobStatusInFlight, page.Jobs[1].Status)
+	require.Equal(t, "w1", page.Jobs[1].Assignee)
+	require.Equal(t, int32(1), page.Jobs[1].Partition)
+	require.Equal(t, int64(100), page.Jobs[1].StartOffset)
+	require.Equal(t, int64(200), page.Jobs[1].EndOffset)
+	require.True(t, now.Add(-3*time.Hour).Equal(page.Jobs[1].CommitRecTs))
+	require.NotNil(t, page.Jobs[1].LeaseExpiry)
+
+	require.Equal(t, jobStatusOutstanding, page.Jobs[2].Status)
+	require.Empty(t, page.Jobs[2].Assignee)
+	require.Equal(t, jobStatusOutstanding, page.Jobs[3].Status)
+
+	// The HTML page renders the same jobs.
+	rec = httptest.NewRecorder()
+	sched.JobsHandler(rec, httptest.NewRequest(http.MethodGet, "/block-builder-scheduler/jobs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	for _, id := range ids {
+		require.Contains(t, rec.Body.String(), id)
+	}
+	require.Contains(t, rec.Body.String(), "in-flight")
+}
+
+func TestWorkerShutdownHandler(t *testing.T) {
+	sched, _ := mustScheduler(t)
+	reg := sched.register.(*prometheus.Registry)
+
+	shutdown := func(workerID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/block-builder-scheduler/workers/shutdown", strings.NewReader(url.Values{"worker_id": []string{workerID}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		sched.WorkerShutdownHandler(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, shutdown("w0").Code)
+
+	sched.completeObservationMode()
+	require.Equal(t, http.StatusBadRequest, shutdown("").Code)
+
+	now := time.Now()
+	sched.jobs.addOrUpdate("ingest/1/100", jobSpec{topic: "ingest", partition: 1, startOffset: 100, endOffset: 200, commitRecTs: now.Add(-2 * time.Hour)})
+	sched.jobs.addOrUpdate("ingest/2/300", jobSpec{topic: "ingest", partition: 2, startOffset: 300, endOffset: 400, commitRecTs: now.Add(-time.Hour)})
+	for i := 0; i < 2; i++ {
+		_, _, err := sched.assignJob("w0")
+		require.NoError(t, err)
+	}
+
+	rec := shutdown("w0")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var released []jobStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &released))
+	require.Len(t, released, 2)
+	require.Equal(t, "ingest/1/100", released[0].JobID)
+	require.Equal(t, "ingest/2/300", released[1].JobID)
+	require.Equal(t, jobStatusOutstanding, released[0].Status)
+
+	k, _, err := sched.assignJob("w1")
+	require.NoError(t, err)
+	require.Equal(t, "ingest/1/100", k.id)
+
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_worker_shutdowns_total The number of times a worker announced its shutdown, releasing its jobs.
+		# TYPE cortex_blockbuilder_scheduler_worker_shutdowns_total counter
+		cortex_blockbuilder_scheduler_worker_shutdowns_total 1
+	`), "cortex_blockbuilder_scheduler_worker_shutdowns_total"))
+}
+
+func TestStaleEpochUpdates(t *testing.T) {
+	sched, _ := mustScheduler(t)
+	reg := sched.register.(*prometheus.Registry)
+
+	spec := jobSpec{topic: "ingest", partition: 1, startOffset: 100, endOffset: 200, commitRecTs: time.Now().Add(-time.Hour)}
+	requireStale := func(t *testing.T, err error, latestEpoch int64) {
+		t.Helper()
+		require.ErrorIs(t, err, errBadEpoch)
+		var staleErr *staleEpochError
+		require.ErrorAs(t, err, &staleErr)
+		require.Equal(t, latestEpoch, staleErr.latestEpoch)
+	}
+
+	// The updates are replayed out of order during the observation period: the ones with a lower epoch than
+	// the highest seen are rejected, whichever worker they come from.
+	require.NoError(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 7}, "w7", false, spec))
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 5}, "w5", true, spec), 7)
+	require.NoError(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 9}, "w9", false, spec))
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 8}, "w8", false, spec), 9)
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 7}, "w7", true, spec), 9)
+	require.NoError(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 9}, "w9", false, spec))
+
+	sched.completeObservationMode()
+
+	// The job is imported with the highest epoch, and the stale workers still can't complete it afterwards.
+	j := sched.jobs.jobs["ingest/1/100"]
+	require.NotNil(t, j)
+	require.Equal(t, int64(9), j.key.epoch)
+	require.Equal(t, "w9", j.assignee)
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 8}, "w8", true, spec), 9)
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 5}, "w5", false, spec), 9)
+	require.Contains(t, sched.jobs.jobs, "ingest/1/100")
+
+	// Once completed, the job can't be resurrected by a stale update either.
+	require.NoError(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 9}, "w9", true, spec))
+	requireStale(t, sched.updateJob(jobKey{id: "ingest/1/100", epoch: 7}, "w7", true, spec), 9)
+	require.NotContains(t, sched.jobs.jobs, "ingest/1/100")
+
+	// The epochs of the jobs assigned by the scheduler are tracked too.
+	sched.jobs.addOrUpdate("ingest/2/300", jobSpec{topic: "ingest", partition: 2, startOffset: 300, endOffset: 400, commitRecTs: time.Now().Add(-time.Hour)})
+	k, s, err := sched.assignJob("w0")
+	require.NoError(t, err)
+	requireStale(t, sched.updateJob(jobKey{id: k.id, epoch: k.epoch - 1}, "w0", false, s), k.epoch)
+	require.NoError(t, sched.updateJob(k, "w0", false, s))
+
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_blockbuilder_scheduler_stale_epoch_updates_total The number of job updates rejected because their epoch was lower than the highest epoch seen for the job.
+		# TYPE cortex_blockbuilder_scheduler_stale_epoch_updates_total counter
+		cortex_blockbuilder_scheduler_stale_epoch_updates_total 7
+	`), "cortex_blockbuilder_scheduler_stale_epoch_updates_total"))
+
+	// The epochs of the jobs before the committed offset are forgotten, since their updates are ignored anyway.
+	sched.pruneEpochs(2, 301)
+	require.NotContains(t, sched.epochs, k.id)
+}
+
+func TestObservationEndsWhenReconciled(t *testing.T) {
+	ctx := context.Background()
+	_, kafkaAddr := testkafka.CreateClusterWithoutCustomConsumerGroupsSupport(t, 4, "ingest")
+	bkt := objstore.NewInMemBucket()
+
+	awaitObservation := func(sched *BlockBuilderScheduler) <-chan struct{} {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		done := make(chan struct{})
+		go func() {
+			sched.awaitObservation(ctx)
+			close(done)
+		}()
+		return done
+	}
+	spec := func(partition int32, start int64) jobSpec {
+		return jobSpec{topic: "ingest", partition: partition, startOffset: start, endOffset: start + 100, commitRecTs: time.Now().Add(-time.Hour)}
+	}
+
+	// Without a persisted state, the observation period lasts until the startup observe time.
+	sched0, _ := mustSchedulerWithKafkaAddr(t, kafkaAddr)
+	sched0.cfg.StartupObserveTime = time.Hour
+	sched0.stateBucket = bkt
+	require.NoError(t, sched0.loadState(ctx))
+	done := awaitObservation(sched0)
+	require.NoError(t, sched0.updateJob(jobKey{id: "ingest/1/100", epoch: 1}, "w1", false, spec(1, 100)))
+	select {
+	case <-done:
+		require.FailNow(t, "the observation period ended before the startup observe time")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The previous scheduler assigns a job of each of three partitions, and persists its state.
+	sched1, _ := mustSchedulerWithKafkaAddr(t, kafkaAddr)
+	sched1.stateBucket = bkt
+	sched1.completeObservationMode()
+	keys := map[string]jobKey{}
+	for p := int32(1); p <= 3; p++ {
+		sched1.jobs.addOrUpdate(fmt.Sprintf("ingest/%d/100", p), spec(p, 100))
+		k, _, err := sched1.assignJob(fmt.Sprintf("w%d", p))
+		require.NoError(t, err)
+		keys[fmt.Sprintf("w%d", p)] = k
+	}
+	require.NoError(t, sched1.persistState(ctx))
+
+	sched2, _ := mustSchedulerWithKafkaAddr(t, kafkaAddr)
+	sched2.cfg.StartupObserveTime = time.Hour
+	sched2.stateBucket = bkt
+	require.NoError(t, sched2.loadState(ctx))
+	done = awaitObservation(sched2)
+
+	// Stale updates don't reconcile the partitions.
+	k1 := keys["w1"]
+	require.ErrorIs(t, sched2.updateJob(jobKey{id: k1.id, epoch: k1.epoch - 1}, "w0", false, spec(1, 100)), errBadEpoch)
+	require.NoError(t, sched2.updateJob(k1, "w1", false, spec(1, 100)))
+	require.NoError(t, sched2.updateJob(keys["w2"], "w2", true, spec(2, 100)))
+	select {
+	case <-done:
+		require.FailNow(t, "the observation period ended before all the partitions were reconciled")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The job of the last partition was completed since the state was persisted, as the committed offsets tell.
+	sched2.mu.Lock()
+	sched2.committed = kadm.Offsets{"ingest": {3: kadm.Offset{Topic: "ingest", Partition: 3, At: 200}}}
+	sched2.checkReconciled()
+	sched2.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the observation period didn't end once all the partitions were reconciled")
+	}
+
+	// Stale updates are still rejected once the observation period is over.
+	sched2.completeObservationMode()
+	require.ErrorIs(t, sched2.updateJob(jobKey{id: k1.id, epoch: k1.epoch - 1}, "w0", false, spec(1, 100)), errBadEpoch)
+	require.NoError(t, sched2.updateJob(k1, "w1", true, spec(1, 100)))
+}
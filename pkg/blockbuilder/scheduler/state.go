@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/runutil"
+)
+
+// stateObjectName is the name of the object holding the persisted state, relative to the bucket
+// prefix dedicated to Mimir internals.
+const stateObjectName = "block-builder-scheduler/state.json"
+
+const stateVersion1 = 1
+
+// schedulerState is the persisted state of the jobs. Its JSON encoding must stay stable, since a
+// scheduler loads the state persisted by the previous one.
+type schedulerState struct {
+	Version int        `json:"version"`
+	Epoch   int64      `json:"epoch"`
+	Jobs    []jobState `json:"jobs"`
+	SavedAt time.Time  `json:"saved_at"`
+}
+
+type jobState struct {
+	ID          string       `json:"id"`
+	Epoch       int64        `json:"epoch"`
+	Assignee    string       `json:"assignee,omitempty"`
+	LeaseExpiry time.Time    `json:"lease_expiry"`
+	FailCount   int          `json:"fail_count"`
+	Spec        jobSpecState `json:"spec"`
+}
+
+type jobSpecState struct {
+	Topic          string    `json:"topic"`
+	Partition      int32     `json:"partition"`
+	StartOffset    int64     `json:"start_offset"`
+	EndOffset      int64     `json:"end_offset"`
+	CommitRecTs    time.Time `json:"commit_rec_ts"`
+	EndRecTs       time.Time `json:"end_rec_ts"`
+	LastSeenOffset int64     `json:"last_seen_offset"`
+	LastBlockEndTs time.Time `json:"last_block_end_ts"`
+}
+
+func newSchedulerState(jobs []job, epoch int64) schedulerState {
+	st := schedulerState{
+		Version: stateVersion1,
+		Epoch:   epoch,
+		Jobs:    make([]jobState, 0, len(jobs)),
+		SavedAt: time.Now(),
+	}
+	for _, j := range jobs {
+		st.Jobs = append(st.Jobs, jobState{
+			ID:          j.key.id,
+			Epoch:       j.key.epoch,
+			Assignee:    j.assignee,
+			LeaseExpiry: j.leaseExpiry,
+			FailCount:   j.failCount,
+			Spec: jobSpecState{
+				Topic:          j.spec.topic,
+				Partition:      j.spec.partition,
+				StartOffset:    j.spec.startOffset,
+				EndOffset:      j.spec.endOffset,
+				CommitRecTs:    j.spec.commitRecTs,
+				EndRecTs:       j.spec.endRecTs,
+				LastSeenOffset: j.spec.lastSeenOffset,
+				LastBlockEndTs: j.spec.lastBlockEndTs,
+			},
+		})
+	}
+	return st
+}
+
+// jobs returns the jobs of the state, by ID.
+func (st schedulerState) jobs() map[string]job {
+	jobs := make(map[string]job, len(st.Jobs))
+	for _, js := range st.Jobs {
+		jobs[js.ID] = job{
+			key:         jobKey{id: js.ID, epoch: js.Epoch},
+			assignee:    js.Assignee,
+			leaseExpiry: js.LeaseExpiry,
+			failCount:   js.FailCount,
+			spec: jobSpec{
+				topic:          js.Spec.Topic,
+				partition:      js.Spec.Partition,
+				startOffset:    js.Spec.StartOffset,
+				endOffset:      js.Spec.EndOffset,
+				commitRecTs:    js.Spec.CommitRecTs,
+				endRecTs:       js.Spec.EndRecTs,
+				lastSeenOffset: js.Spec.LastSeenOffset,
+				lastBlockEndTs: js.Spec.LastBlockEndTs,
+			},
+		}
+	}
+	return jobs
+}
+
+// persistState uploads the state of the jobs to the bucket. The state is only persisted after the
+// observation period, so that a scheduler which hasn't learned the state of the world yet doesn't
+// overwrite the state persisted by the previous one.
+func (s *BlockBuilderScheduler) persistState(ctx context.Context) error {
+	s.mu.Lock()
+	doneObserving := s.observationComplete
+	s.mu.Unlock()
+
+	if !doneObserving {
+		return nil
+	}
+
+	data, err := json.Marshal(newSchedulerState(s.jobs.snapshot()))
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	if err := s.stateBucket.Upload(ctx, stateObjectName, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("upload state: %w", err)
+	}
+	return nil
+}
+
+// persistStateOrWarn persists the state, tracking and logging any failure.
+func (s *BlockBuilderScheduler) persistStateOrWarn(ctx context.Context) {
+	if err := s.persistState(ctx); err != nil {
+		s.metrics.statePersistFailures.Inc()
+		level.Warn(s.logger).Log("msg", "failed to persist the state of the jobs", "err", err)
+	}
+}
+
+// loadState loads the state persisted by the previous scheduler, if any. It must be called before
+// the observation period completes, since the loaded state is reconciled with the worker updates.
+func (s *BlockBuilderScheduler) loadState(ctx context.Context) error {
+	r, err := s.stateBucket.Get(ctx, stateObjectName)
+	if err != nil {
+		if s.stateBucket.IsObjNotFoundErr(err) {
+			// Nothing was persisted yet.
+			return nil
+		}
+		return fmt.Errorf("get state: %w", err)
+	}
+	defer runutil.CloseWithLogOnErr(s.logger, r, "failed to close state reader")
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read state: %w", err)
+	}
+	var st schedulerState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("unmarshal state: %w", err)
+	}
+	if st.Version != stateVersion1 {
+		return fmt.Errorf("unsupported state version %d", st.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadedJobs = st.jobs()
+	s.loadedEpoch = st.Epoch
+	s.unreconciled = make(map[int32]map[string]struct{})
+	for id, lj := range s.loadedJobs {
+		s.observeEpoch(lj.key, lj.spec)
+		if lj.assignee == "" {
+			continue
+		}
+		if s.unreconciled[lj.spec.partition] == nil {
+			s.unreconciled[lj.spec.partition] = make(map[string]struct{})
+		}
+		s.unreconciled[lj.spec.partition][id] = struct{}{}
+	}
+	s.checkReconciled()
+	level.Info(s.logger).Log("msg", "loaded the persisted state of the jobs", "jobs", len(st.Jobs), "saved_at", st.SavedAt)
+	return nil
+}
@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package schedulerpb
+
+// WorkerAuthTokenMetadataKey is the gRPC metadata key of the token the block-builder workers send to the
+// block-builder-scheduler, if the scheduler requires one.
+const WorkerAuthTokenMetadataKey = "x-block-builder-worker-token"
@@ -36,6 +36,7 @@ import (
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/blocksadmin"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
@@ -105,6 +106,8 @@ type Config struct {
 	TenantCleanupDelay         time.Duration           `yaml:"tenant_cleanup_delay" category:"advanced"`
 	MaxCompactionTime          time.Duration           `yaml:"max_compaction_time" category:"advanced"`
 	NoBlocksFileCleanupEnabled bool                    `yaml:"no_blocks_file_cleanup_enabled" category:"experimental"`
+	BlocksAdminEnabled         bool                    `yaml:"blocks_admin_enabled" category:"experimental"`
+	BlocksAdmin                blocksadmin.PagesConfig `yaml:",inline"`
 
 	// Compactor concurrency options
 	MaxOpeningBlocksConcurrency         int `yaml:"max_opening_blocks_concurrency" category:"advanced"`          // Number of goroutines opening blocks before compaction.
@@ -156,6 +159,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 		"If 0, blocks will be deleted straight away. Note that deleting blocks immediately can cause query failures.")
 	f.DurationVar(&cfg.TenantCleanupDelay, "compactor.tenant-cleanup-delay", 6*time.Hour, "For tenants marked for deletion, this is the time between deletion of the last block, and doing final cleanup (marker files, debug files) of the tenant.")
 	f.BoolVar(&cfg.NoBlocksFileCleanupEnabled, "compactor.no-blocks-file-cleanup-enabled", false, "If enabled, will delete the bucket-index, markers and debug files in the tenant bucket when there are no blocks left in the index.")
+	f.BoolVar(&cfg.BlocksAdminEnabled, "compactor.blocks-admin-enabled", false, "If enabled, the compactor serves the tenant blocks admin page, the same as the store-gateway.")
+	cfg.BlocksAdmin.RegisterFlagsWithPrefix("compactor.", f)
 	// compactor concurrency options
 	f.IntVar(&cfg.MaxOpeningBlocksConcurrency, "compactor.max-opening-blocks-concurrency", 1, "Number of goroutines opening blocks before compaction.")
 	f.IntVar(&cfg.MaxClosingBlocksConcurrency, "compactor.max-closing-blocks-concurrency", 1, "Max number of blocks that can be closed concurrently during split compaction. Note that closing a newly compacted block uses a lot of memory for writing the index.")
@@ -198,6 +203,9 @@ func (cfg *Config) Validate(logger log.Logger) error {
 	if !util.StringsContain(CompactionOrders, cfg.CompactionJobsOrder) {
 		return errInvalidCompactionOrder
 	}
+	if err := cfg.BlocksAdmin.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -267,6 +275,9 @@ type MultitenantCompactor struct {
 	// Client used to run operations on the bucket storing blocks.
 	bucketClient objstore.Bucket
 
+	// Admin pages to browse the blocks in the bucket, if enabled.
+	blocksAdmin *blocksadmin.Handler
+
 	// Ring used for sharding compactions.
 	ringLifecycler         *ring.BasicLifecycler
 	ring                   *ring.Ring
@@ -467,6 +478,13 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 	// Wrap the bucket client to write block deletion marks in the global location too.
 	c.bucketClient = block.BucketWithGlobalMarkers(c.bucketClient)
 
+	if c.compactorCfg.BlocksAdminEnabled {
+		blocksAdminCfg := c.compactorCfg.BlocksAdmin.HandlerConfig("Compactor", "/compactor")
+		// The compactor serves its own tenants page.
+		blocksAdminCfg.OmitTenantsRoute = true
+		c.blocksAdmin = blocksadmin.New(blocksAdminCfg, c.bucketClient, nil, c.logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "compactor"}, c.registerer))
+	}
+
 	// Initialize the compactors ring if sharding is enabled.
 	c.ring, c.ringLifecycler, err = newRingAndLifecycler(c.compactorCfg.ShardingRing, c.logger, c.registerer)
 	if err != nil {
@@ -44,3 +44,29 @@ func (c *MultitenantCompactor) RingHandler(w http.ResponseWriter, req *http.Requ
 
 	c.ring.ServeHTTP(w, req)
 }
+
+// BlocksAdminEnabled returns true if the compactor serves the tenant blocks admin page.
+func (c *MultitenantCompactor) BlocksAdminEnabled() bool {
+	return c.compactorCfg.BlocksAdminEnabled
+}
+
+// BlocksHandler serves the list of blocks of a tenant.
+func (c *MultitenantCompactor) BlocksHandler(w http.ResponseWriter, req *http.Request) {
+	if c.State() != services.Running || c.blocksAdmin == nil {
+		// The bucket client is created when the MultitenantCompactor starts.
+		writeMessage(w, "Compactor is not running yet.", c.logger)
+		return
+	}
+
+	c.blocksAdmin.BlocksHandler(w, req)
+}
+
+// APIDocsHandler serves the OpenAPI document describing the compactor blocks admin HTTP endpoints.
+func (c *MultitenantCompactor) APIDocsHandler(w http.ResponseWriter, req *http.Request) {
+	if c.State() != services.Running || c.blocksAdmin == nil {
+		writeMessage(w, "Compactor is not running yet.", c.logger)
+		return
+	}
+
+	c.blocksAdmin.APIDocsHandler(w, req)
+}
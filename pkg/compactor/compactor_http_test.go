@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestMultitenantCompactor_BlocksHandler(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("blocks admin enabled=%t", enabled), func(t *testing.T) {
+			bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
+			require.NoError(t, bucketClient.Upload(context.Background(), "user-1/file", strings.NewReader("data")))
+
+			cfg := prepareConfig(t)
+			cfg.BlocksAdminEnabled = enabled
+			c, _, _, _, _ := prepare(t, cfg, bucketClient)
+			assert.Equal(t, enabled, c.BlocksAdminEnabled())
+
+			router := mux.NewRouter()
+			router.Path("/compactor/tenants").HandlerFunc(c.TenantsHandler)
+			router.Path("/compactor/tenant/{tenant}/blocks").HandlerFunc(c.BlocksHandler)
+			get := func(path string) string {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				require.Equal(t, http.StatusOK, rec.Code)
+				return rec.Body.String()
+			}
+
+			// The bucket client is only available once the compactor is running.
+			assert.Contains(t, get("/compactor/tenant/user-1/blocks"), "Compactor is not running yet.")
+
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+			t.Cleanup(func() {
+				require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+			})
+
+			body := get("/compactor/tenant/user-1/blocks")
+			if enabled {
+				assert.Contains(t, body, "<h1>Compactor: bucket tenant blocks</h1>")
+			} else {
+				assert.Contains(t, body, "Compactor is not running yet.")
+			}
+
+			body = get("/compactor/tenants")
+			if enabled {
+				assert.Contains(t, body, `href="tenant/user-1/blocks"`)
+			} else {
+				assert.NotContains(t, body, `href="tenant/user-1/blocks"`)
+			}
+		})
+	}
+}
@@ -26,8 +26,9 @@ var tenantsPageHTML string
 var tenantsTemplate = template.Must(template.New("webpage").Parse(tenantsPageHTML))
 
 type tenantsPageContents struct {
-	Now     time.Time `json:"now"`
-	Tenants []string  `json:"tenants,omitempty"`
+	Now        time.Time `json:"now"`
+	Tenants    []string  `json:"tenants,omitempty"`
+	ShowBlocks bool      `json:"-"`
 }
 
 func (c *MultitenantCompactor) TenantsHandler(w http.ResponseWriter, req *http.Request) {
@@ -38,8 +39,9 @@ func (c *MultitenantCompactor) TenantsHandler(w http.ResponseWriter, req *http.R
 	}
 
 	util.RenderHTTPResponse(w, tenantsPageContents{
-		Now:     time.Now(),
-		Tenants: tenants,
+		Now:        time.Now(),
+		Tenants:    tenants,
+		ShowBlocks: c.BlocksAdminEnabled(),
 	}, tenantsTemplate, req)
 }
 
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+)
+
+// maxOversizedKeys is the max number of request keys remembered as having a response larger than
+// the coalescing limit. When the limit is reached, the remembered keys are reset.
+const maxOversizedKeys = 1024
+
+// coalescingIgnoredHeaders are the request headers which don't change the response, so that requests which only
+// differ in them are coalesced. All the other headers are part of the coalescing key, since the query-frontend and
+// the downstream may honor them, e.g. Cache-Control or Authorization.
+var coalescingIgnoredHeaders = map[string]struct{}{
+	// Tracing and request identification.
+	"Traceparent":       {},
+	"Tracestate":        {},
+	"Uber-Trace-Id":     {},
+	"Jaeger-Debug-Id":   {},
+	"Jaeger-Baggage":    {},
+	"B3":                {},
+	"X-B3-Traceid":      {},
+	"X-B3-Spanid":       {},
+	"X-B3-Parentspanid": {},
+	"X-B3-Sampled":      {},
+	"X-B3-Flags":        {},
+	"X-Request-Id":      {},
+
+	// Connection and client details.
+	"Connection":        {},
+	"Keep-Alive":        {},
+	"Content-Length":    {},
+	"User-Agent":        {},
+	"X-Forwarded-For":   {},
+	"X-Forwarded-Host":  {},
+	"X-Forwarded-Proto": {},
+	"X-Real-Ip":         {},
+}
+
+// coalescingRoundTripper coalesces concurrent identical requests into a single downstream request.
+// All coalesced requests get a copy of the same response (or the same error).
+type coalescingRoundTripper struct {
+	next            http.RoundTripper
+	maxResponseSize int64
+
+	mtx       sync.Mutex
+	inflight  map[string]*coalescedCall
+	oversized map[string]struct{}
+
+	coalescedRequests prometheus.Counter
+}
+
+type coalescedCall struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	// Guarded by coalescingRoundTripper.mtx.
+	waiters       int
+	leaderWaiting bool
+
+	// Set before done is closed.
+	resp      *http.Response
+	body      []byte
+	oversized bool
+	err       error
+}
+
+func newCoalescingRoundTripper(next http.RoundTripper, maxResponseSize int64, reg prometheus.Registerer) *coalescingRoundTripper {
+	return &coalescingRoundTripper{
+		next:            next,
+		maxResponseSize: maxResponseSize,
+		inflight:        map[string]*coalescedCall{},
+		oversized:       map[string]struct{}{},
+		coalescedRequests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_coalesced_requests_total",
+			Help: "Total number of requests that have been served by sharing an identical in-flight downstream request.",
+		}),
+	}
+}
+
+func (c *coalescingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	key, body, ok, err := coalescingKey(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return c.next.RoundTrip(r)
+	}
+
+	c.mtx.Lock()
+	if _, skip := c.oversized[key]; skip {
+		c.mtx.Unlock()
+		return c.next.RoundTrip(r)
+	}
+	if call, found := c.inflight[key]; found {
+		call.waiters++
+		c.mtx.Unlock()
+		return c.wait(r, key, call, false)
+	}
+
+	// The shared request must not be canceled when the request which started it is canceled,
+	// because other requests may be waiting on it. It's canceled once there are no more waiters,
+	// or when the deadline of the request which started it expires.
+	var (
+		ctx    = context.WithoutCancel(r.Context())
+		cancel context.CancelFunc
+	)
+	if deadline, ok := r.Context().Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	call := &coalescedCall{
+		done:          make(chan struct{}),
+		cancel:        cancel,
+		waiters:       1,
+		leaderWaiting: true,
+	}
+	c.inflight[key] = call
+	c.mtx.Unlock()
+
+	shared := r.Clone(ctx)
+	if body != nil {
+		shared.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	go c.do(key, call, shared)
+
+	return c.wait(r, key, call, true)
+}
+
+// do runs the shared request and stores its outcome in the call.
+func (c *coalescingRoundTripper) do(key string, call *coalescedCall, req *http.Request) {
+	resp, err := c.next.RoundTrip(req)
+
+	var body []byte
+	oversized := false
+	if err == nil {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize+1))
+		switch {
+		case err != nil:
+			_ = resp.Body.Close()
+		case int64(len(body)) > c.maxResponseSize:
+			// The response is too large to be shared: it's streamed to the request which
+			// started the call, while the other waiters issue their own request.
+			oversized = true
+			resp.Body = &cancelOnCloseBody{
+				Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+				closer: resp.Body,
+				cancel: call.cancel,
+			}
+			body = nil
+		default:
+			_ = resp.Body.Close()
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// The call may have already been removed because all its waiters left, and replaced by a newer one.
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	if oversized {
+		if len(c.oversized) >= maxOversizedKeys {
+			c.oversized = map[string]struct{}{}
+		}
+		c.oversized[key] = struct{}{}
+
+		if !call.leaderWaiting {
+			// Nobody is going to read the response body.
+			_ = resp.Body.Close()
+		}
+	} else {
+		call.cancel()
+	}
+
+	call.resp, call.body, call.oversized, call.err = resp, body, oversized, err
+	close(call.done)
+}
+
+// wait waits for the outcome of the call, or for the request to be canceled.
+func (c *coalescingRoundTripper) wait(r *http.Request, key string, call *coalescedCall, leader bool) (*http.Response, error) {
+	select {
+	case <-call.done:
+	case <-r.Context().Done():
+		c.mtx.Lock()
+		call.waiters--
+		if leader {
+			call.leaderWaiting = false
+		}
+		select {
+		case <-call.done:
+			// The call completed concurrently. If the leader was supposed to consume an oversized response, close it.
+			if leader && call.oversized {
+				_ = call.resp.Body.Close()
+			}
+		default:
+			if call.waiters == 0 {
+				// Remove the call so that new identical requests don't join a canceled call.
+				if c.inflight[key] == call {
+					delete(c.inflight, key)
+				}
+				call.cancel()
+			}
+		}
+		c.mtx.Unlock()
+		return nil, r.Context().Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	if call.oversized {
+		if leader {
+			return call.resp, nil
+		}
+		return c.next.RoundTrip(r)
+	}
+
+	if !leader {
+		c.coalescedRequests.Inc()
+	}
+
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.ContentLength = int64(len(call.body))
+	return &resp, nil
+}
+
+// coalescingKey returns the key used to identify identical requests, and the request body (if any). Requests are
+// identical if they have the same tenants, method, URL, body and headers, apart from the coalescingIgnoredHeaders.
+// The request body is consumed and replaced with an equivalent reader. Returns false if the request
+// can't be coalesced.
+func coalescingKey(r *http.Request) (string, []byte, bool, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return "", nil, false, nil
+	}
+	if !isCoalescableQuery(r.URL.Path) {
+		return "", nil, false, nil
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return "", nil, false, nil
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		if err != nil {
+			return "", nil, false, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	h := sha256.New()
+	for _, s := range []string{r.Method, r.URL.Path, r.URL.RawQuery} {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		if _, ignored := coalescingIgnoredHeaders[http.CanonicalHeaderKey(name)]; !ignored {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		_, _ = h.Write([]byte(http.CanonicalHeaderKey(name)))
+		for _, v := range r.Header[name] {
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(v))
+		}
+		_, _ = h.Write([]byte{0, 0})
+	}
+	_, _ = h.Write(body)
+
+	return tenant.JoinTenantIDs(tenantIDs) + ":" + hex.EncodeToString(h.Sum(nil)), body, true, nil
+}
+
+func isCoalescableQuery(path string) bool {
+	return querymiddleware.IsRangeQuery(path) ||
+		querymiddleware.IsInstantQuery(path) ||
+		querymiddleware.IsLabelsQuery(path) ||
+		querymiddleware.IsSeriesQuery(path)
+}
+
+// cancelOnCloseBody cancels the context of the request which produced the body once it's closed.
+type cancelOnCloseBody struct {
+	io.Reader
+	closer io.Closer
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.closer.Close()
+}
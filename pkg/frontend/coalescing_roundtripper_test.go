@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// blockingRoundTripper counts downstream requests and blocks them until release is closed.
+type blockingRoundTripper struct {
+	hits    atomic.Int64
+	release chan struct{}
+	body    string
+	err     error
+
+	canceled chan struct{}
+}
+
+func newBlockingRoundTripper(body string) *blockingRoundTripper {
+	return &blockingRoundTripper{release: make(chan struct{}), body: body, canceled: make(chan struct{}, 10)}
+}
+
+func (b *blockingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	b.hits.Inc()
+	select {
+	case <-b.release:
+	case <-r.Context().Done():
+		b.canceled <- struct{}{}
+		return nil, r.Context().Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(b.body)),
+	}, nil
+}
+
+func newCoalescingTestRequest(ctx context.Context) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/query_range?query=up&start=0&end=100&step=10", nil)
+	return req.WithContext(user.InjectOrgID(ctx, "user-1"))
+}
+
+func TestCoalescingRoundTripper(t *testing.T) {
+	const numRequests = 3
+
+	runConcurrently := func(t *testing.T, rt http.RoundTripper, downstream *blockingRoundTripper, expectedWaiters int) ([]*http.Response, []error) {
+		var (
+			wg        sync.WaitGroup
+			responses = make([]*http.Response, numRequests)
+			errs      = make([]error, numRequests)
+		)
+		for i := 0; i < numRequests; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], errs[i] = rt.RoundTrip(newCoalescingTestRequest(context.Background()))
+			}(i)
+		}
+
+		require.Eventually(t, func() bool {
+			return int(downstream.hits.Load()) >= 1 && pendingWaiters(rt.(*coalescingRoundTripper)) == expectedWaiters
+		}, time.Second, time.Millisecond)
+
+		close(downstream.release)
+		wg.Wait()
+		return responses, errs
+	}
+
+	t.Run("concurrent identical requests share a single downstream request", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, reg)
+
+		responses, errs := runConcurrently(t, rt, downstream, numRequests)
+
+		assert.Equal(t, int64(1), downstream.hits.Load())
+		for i := 0; i < numRequests; i++ {
+			require.NoError(t, errs[i])
+			body, err := io.ReadAll(responses[i].Body)
+			require.NoError(t, err)
+			assert.Equal(t, responseBody, string(body))
+			assert.Equal(t, "application/json", responses[i].Header.Get("Content-Type"))
+		}
+		assert.Equal(t, float64(numRequests-1), promtest.ToFloat64(rt.coalescedRequests))
+	})
+
+	t.Run("all waiters get the same error", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		downstream.err = errors.New("downstream failed")
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		_, errs := runConcurrently(t, rt, downstream, numRequests)
+
+		assert.Equal(t, int64(1), downstream.hits.Load())
+		for i := 0; i < numRequests; i++ {
+			assert.EqualError(t, errs[i], "downstream failed")
+		}
+	})
+
+	t.Run("oversized response disables coalescing for the key", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, int64(len(responseBody)-1), nil)
+
+		responses, errs := runConcurrently(t, rt, downstream, numRequests)
+
+		// One shared request, plus one request for each waiter which couldn't get the shared response.
+		assert.Equal(t, int64(numRequests), downstream.hits.Load())
+		for i := 0; i < numRequests; i++ {
+			require.NoError(t, errs[i])
+			body, err := io.ReadAll(responses[i].Body)
+			require.NoError(t, err)
+			require.NoError(t, responses[i].Body.Close())
+			assert.Equal(t, responseBody, string(body))
+		}
+
+		// Further requests for the same key are not coalesced.
+		_, err := rt.RoundTrip(newCoalescingTestRequest(context.Background()))
+		require.NoError(t, err)
+		assert.Equal(t, int64(numRequests+1), downstream.hits.Load())
+		assert.Empty(t, rt.inflight)
+	})
+
+	t.Run("canceling one waiter doesn't affect the others", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceledErr := make(chan error)
+		go func() {
+			_, err := rt.RoundTrip(newCoalescingTestRequest(ctx))
+			canceledErr <- err
+		}()
+		require.Eventually(t, func() bool { return pendingWaiters(rt) == 1 }, time.Second, time.Millisecond)
+
+		var (
+			wg        sync.WaitGroup
+			responses = make([]*http.Response, 2)
+			errs      = make([]error, 2)
+		)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], errs[i] = rt.RoundTrip(newCoalescingTestRequest(context.Background()))
+			}(i)
+		}
+		require.Eventually(t, func() bool { return pendingWaiters(rt) == 3 }, time.Second, time.Millisecond)
+
+		// Cancel the request which started the shared downstream request.
+		cancel()
+		require.ErrorIs(t, <-canceledErr, context.Canceled)
+		assert.Empty(t, downstream.canceled)
+
+		close(downstream.release)
+		wg.Wait()
+
+		assert.Equal(t, int64(1), downstream.hits.Load())
+		for i := 0; i < 2; i++ {
+			require.NoError(t, errs[i])
+			body, err := io.ReadAll(responses[i].Body)
+			require.NoError(t, err)
+			assert.Equal(t, responseBody, string(body))
+		}
+	})
+
+	t.Run("canceling all waiters cancels the downstream request", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		_, err := rt.RoundTrip(newCoalescingTestRequest(ctx))
+		require.ErrorIs(t, err, context.Canceled)
+
+		select {
+		case <-downstream.canceled:
+		case <-time.After(time.Second):
+			t.Fatal("expected downstream request to be canceled")
+		}
+	})
+
+	t.Run("request issued after all waiters left doesn't join the canceled call", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceledErr := make(chan error)
+		go func() {
+			_, err := rt.RoundTrip(newCoalescingTestRequest(ctx))
+			canceledErr <- err
+		}()
+		require.Eventually(t, func() bool { return pendingWaiters(rt) == 1 }, time.Second, time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-canceledErr, context.Canceled)
+
+		// Issue the same request right away, while the canceled downstream request may still be in-flight.
+		close(downstream.release)
+		resp, err := rt.RoundTrip(newCoalescingTestRequest(context.Background()))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, responseBody, string(body))
+		assert.Equal(t, int64(2), downstream.hits.Load())
+	})
+
+	t.Run("shared request keeps the deadline of the request which started it", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// A request without deadline joins the call, and is therefore bound to the leader's deadline.
+		errs := make(chan error, 2)
+		go func() {
+			_, err := rt.RoundTrip(newCoalescingTestRequest(ctx))
+			errs <- err
+		}()
+		require.Eventually(t, func() bool { return pendingWaiters(rt) == 1 }, time.Second, time.Millisecond)
+		go func() {
+			_, err := rt.RoundTrip(newCoalescingTestRequest(context.Background()))
+			errs <- err
+		}()
+
+		require.ErrorIs(t, <-errs, context.DeadlineExceeded)
+		require.ErrorIs(t, <-errs, context.DeadlineExceeded)
+		select {
+		case <-downstream.canceled:
+		case <-time.After(time.Second):
+			t.Fatal("expected downstream request to be canceled")
+		}
+	})
+
+	t.Run("requests differing in a header which changes the response are not coalesced", func(t *testing.T) {
+		for _, header := range []string{"Cache-Control", "Sharding-Control", "X-Read-Consistency", "Authorization"} {
+			t.Run(header, func(t *testing.T) {
+				downstream := newBlockingRoundTripper(responseBody)
+				rt := newCoalescingRoundTripper(downstream, 1024, prometheus.NewPedanticRegistry())
+
+				var wg sync.WaitGroup
+				errs := make([]error, 2)
+				for i := 0; i < 2; i++ {
+					req := newCoalescingTestRequest(context.Background())
+					if i == 1 {
+						req.Header.Set(header, "value")
+					}
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						_, errs[i] = rt.RoundTrip(req)
+					}(i)
+				}
+				require.Eventually(t, func() bool {
+					return downstream.hits.Load() == 2 && pendingWaiters(rt) == 2
+				}, time.Second, time.Millisecond)
+
+				close(downstream.release)
+				wg.Wait()
+				require.NoError(t, errs[0])
+				require.NoError(t, errs[1])
+				assert.Equal(t, float64(0), promtest.ToFloat64(rt.coalescedRequests))
+			})
+		}
+	})
+
+	t.Run("requests differing only in tracing headers are coalesced", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		rt := newCoalescingRoundTripper(downstream, 1024, prometheus.NewPedanticRegistry())
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, traceID := range []string{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "00-1bf7651916cd43dd8448eb211c80319c-c7ad6b7169203331-01"} {
+			req := newCoalescingTestRequest(context.Background())
+			req.Header.Set("Traceparent", traceID)
+			req.Header.Set("X-Request-Id", traceID)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = rt.RoundTrip(req)
+			}(i)
+		}
+		require.Eventually(t, func() bool { return pendingWaiters(rt) == 2 }, time.Second, time.Millisecond)
+
+		close(downstream.release)
+		wg.Wait()
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		assert.Equal(t, int64(1), downstream.hits.Load())
+		assert.Equal(t, float64(1), promtest.ToFloat64(rt.coalescedRequests))
+	})
+
+	t.Run("requests which are not queries are not coalesced", func(t *testing.T) {
+		downstream := newBlockingRoundTripper(responseBody)
+		close(downstream.release)
+		rt := newCoalescingRoundTripper(downstream, 1024, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/prometheus/api/v1/admin/tsdb/delete_series", nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+		_, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Empty(t, rt.inflight)
+	})
+}
+
+func pendingWaiters(rt *coalescingRoundTripper) int {
+	rt.mtx.Lock()
+	defer rt.mtx.Unlock()
+
+	waiters := 0
+	for _, call := range rt.inflight {
+		waiters += call.waiters
+	}
+	return waiters
+}
@@ -8,8 +8,10 @@ package frontend
 import (
 	"flag"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/netutil"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -29,7 +31,14 @@ type CombinedFrontendConfig struct {
 
 	QueryMiddleware querymiddleware.Config `yaml:",inline"`
 
-	DownstreamURL string `yaml:"downstream_url" category:"advanced"`
+	DownstreamURL                     string   `yaml:"downstream_url" category:"advanced"`
+	DownstreamTenantURLs              []string `yaml:"downstream_tenant_urls" category:"experimental"`
+	DownstreamCoalesceRequests        bool     `yaml:"downstream_coalesce_requests" category:"experimental"`
+	DownstreamCoalesceMaxResponseSize int64    `yaml:"downstream_coalesce_max_response_size" category:"experimental"`
+
+	DownstreamConcurrencyWaitTimeout time.Duration `yaml:"downstream_concurrency_wait_timeout" category:"experimental"`
+
+	DownstreamTransport DownstreamTransportConfig `yaml:"downstream_transport"`
 }
 
 func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
@@ -39,6 +48,11 @@ func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet, logger log.Log
 	cfg.QueryMiddleware.RegisterFlags(f)
 
 	f.StringVar(&cfg.DownstreamURL, "query-frontend.downstream-url", "", "URL of downstream Prometheus.")
+	f.Var((*flagext.StringSlice)(&cfg.DownstreamTenantURLs), "query-frontend.downstream-tenant-urls", "URL of the downstream Prometheus of a tenant, in the <tenant>=<url> format, overriding -query-frontend.downstream-url for the tenant's queries. Queries of multiple tenants use -query-frontend.downstream-url. This flag can be used multiple times.")
+	f.BoolVar(&cfg.DownstreamCoalesceRequests, "query-frontend.downstream-coalesce-requests", false, "When enabled and a downstream URL is configured, concurrent identical queries from the same tenant share a single downstream request.")
+	f.Int64Var(&cfg.DownstreamCoalesceMaxResponseSize, "query-frontend.downstream-coalesce-max-response-size", 10*1024*1024, "Max size, in bytes, of a downstream response that can be shared between coalesced requests. Requests whose response exceeds this size are no longer coalesced.")
+	f.DurationVar(&cfg.DownstreamConcurrencyWaitTimeout, "query-frontend.downstream-concurrency-wait-timeout", 0, "How long a request waits when the tenant reached -query-frontend.max-concurrent-downstream-requests, before being rejected with a 429 response. 0 to reject the request right away.")
+	cfg.DownstreamTransport.RegisterFlagsWithPrefix("query-frontend.downstream-transport.", f)
 }
 
 func (cfg *CombinedFrontendConfig) Validate() error {
@@ -48,6 +62,26 @@ func (cfg *CombinedFrontendConfig) Validate() error {
 	if err := cfg.QueryMiddleware.Validate(); err != nil {
 		return err
 	}
+	if len(cfg.DownstreamTenantURLs) > 0 {
+		if cfg.DownstreamURL == "" {
+			return errors.New("the per-tenant downstream URLs require the downstream URL to be configured")
+		}
+		if _, err := parseDownstreamTenantURLs(cfg.DownstreamTenantURLs); err != nil {
+			return err
+		}
+	}
+	if cfg.DownstreamCoalesceRequests && cfg.DownstreamCoalesceMaxResponseSize <= 0 {
+		return errors.New("the downstream coalescing max response size must be greater than 0")
+	}
+	if cfg.DownstreamConcurrencyWaitTimeout < 0 {
+		return errors.New("the downstream concurrency wait timeout must not be negative")
+	}
+	if err := cfg.DownstreamTransport.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Handler.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -61,6 +95,7 @@ func InitFrontend(
 	cfg CombinedFrontendConfig,
 	v1Limits v1.Limits,
 	v2Limits v2.Limits,
+	downstreamLimits DownstreamLimits,
 	grpcListenPort int,
 	log log.Logger,
 	reg prometheus.Registerer,
@@ -69,8 +104,16 @@ func InitFrontend(
 	switch {
 	case cfg.DownstreamURL != "":
 		// If the user has specified a downstream Prometheus, then we should use that.
-		rt, err := NewDownstreamRoundTripper(cfg.DownstreamURL)
-		return rt, nil, nil, err
+		rt, err := NewDownstreamRoundTripper(cfg.DownstreamURL, cfg.DownstreamTenantURLs, cfg.DownstreamTransport, reg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Coalesced requests share a single downstream request, so they count once towards the tenant's limit.
+		rt = newConcurrencyLimitingRoundTripper(rt, downstreamLimits, cfg.DownstreamConcurrencyWaitTimeout, reg)
+		if cfg.DownstreamCoalesceRequests {
+			rt = newCoalescingRoundTripper(rt, cfg.DownstreamCoalesceMaxResponseSize, reg)
+		}
+		return rt, nil, nil, nil
 
 	case cfg.FrontendV2.SchedulerAddress != "" || cfg.FrontendV2.QuerySchedulerDiscovery.Mode == schedulerdiscovery.ModeRing:
 		// Query-scheduler is enabled when its address is configured or ring-based service discovery is configured.
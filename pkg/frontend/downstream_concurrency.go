@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// DownstreamLimits are the limits of the requests sent to the downstream URL.
+type DownstreamLimits interface {
+	// MaxConcurrentDownstreamRequests returns the max number of concurrent downstream requests of the tenant,
+	// or 0 if there's no limit.
+	MaxConcurrentDownstreamRequests(userID string) int
+}
+
+// concurrencyLimitingRoundTripper limits the number of concurrent downstream requests of each tenant. A request
+// over the limit waits for one of the tenant's requests to complete, up to the wait timeout, and it's rejected
+// with a 429 response after that. A request is in flight until its response body is read to the end or closed.
+type concurrencyLimitingRoundTripper struct {
+	next        http.RoundTripper
+	limits      DownstreamLimits
+	waitTimeout time.Duration
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantDownstreamRequests
+
+	inflight *prometheus.GaugeVec
+	rejected *prometheus.CounterVec
+
+	// activeUsers tracks the tenants having rejected requests, to remove their metrics once they're inactive.
+	activeUsers *util.ActiveUsersCleanupService
+}
+
+// tenantDownstreamRequests tracks the downstream requests in flight of a tenant. It's removed once none is.
+type tenantDownstreamRequests struct {
+	inflight int
+	// released is closed, and replaced, when a request of the tenant completes, to wake up the waiting ones.
+	released chan struct{}
+}
+
+func newConcurrencyLimitingRoundTripper(next http.RoundTripper, limits DownstreamLimits, waitTimeout time.Duration, reg prometheus.Registerer) *concurrencyLimitingRoundTripper {
+	c := &concurrencyLimitingRoundTripper{
+		next:        next,
+		limits:      limits,
+		waitTimeout: waitTimeout,
+		tenants:     map[string]*tenantDownstreamRequests{},
+		inflight: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_query_frontend_downstream_inflight_requests",
+			Help: "Number of requests in flight to the downstream.",
+		}, []string{"user"}),
+		rejected: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_rejected_requests_total",
+			Help: "Total number of requests rejected because the tenant reached the max number of concurrent downstream requests.",
+		}, []string{"user"}),
+	}
+	c.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(c.cleanupMetricsForUser)
+	// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
+	_ = c.activeUsers.StartAsync(context.Background())
+
+	return c
+}
+
+// cleanupMetricsForUser removes the per-tenant metrics of the user. The in-flight requests gauge is removed
+// as soon as the tenant has no request in flight, so only the rejected requests counter is left.
+func (c *concurrencyLimitingRoundTripper) cleanupMetricsForUser(userID string) {
+	c.rejected.DeleteLabelValues(userID)
+}
+
+func (c *concurrencyLimitingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return c.next.RoundTrip(r)
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	limit := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, c.limits.MaxConcurrentDownstreamRequests)
+
+	if !c.acquire(r, userID, limit) {
+		if err := r.Context().Err(); err != nil {
+			return nil, err
+		}
+		c.rejected.WithLabelValues(userID).Inc()
+		c.activeUsers.UpdateUserTimestamp(userID, time.Now())
+		querymiddleware.ErrorClassificationFromContext(r.Context()).Record(querymiddleware.ErrorClassTooManyRequests)
+		return tooManyDownstreamRequestsResponse(r, limit), nil
+	}
+
+	resp, err := c.next.RoundTrip(r)
+	if err != nil {
+		c.release(userID)
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { c.release(userID) }}
+	return resp, nil
+}
+
+// acquire waits until the tenant has less than limit downstream requests in flight, and counts the request as
+// in flight. It returns false if the request can't be sent before the wait timeout, or if it's canceled.
+func (c *concurrencyLimitingRoundTripper) acquire(r *http.Request, userID string, limit int) bool {
+	var timeout <-chan time.Time
+	for {
+		c.mtx.Lock()
+		t := c.tenants[userID]
+		if t == nil {
+			t = &tenantDownstreamRequests{released: make(chan struct{})}
+			c.tenants[userID] = t
+		}
+		if limit <= 0 || t.inflight < limit {
+			t.inflight++
+			c.inflight.WithLabelValues(userID).Set(float64(t.inflight))
+			c.mtx.Unlock()
+			return true
+		}
+		released := t.released
+		c.mtx.Unlock()
+
+		if c.waitTimeout <= 0 {
+			return false
+		}
+		if timeout == nil {
+			timer := time.NewTimer(c.waitTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case <-released:
+		case <-timeout:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+// release counts a request of the tenant as completed, and wakes up the tenant's waiting requests.
+func (c *concurrencyLimitingRoundTripper) release(userID string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	t := c.tenants[userID]
+	t.inflight--
+	close(t.released)
+	if t.inflight == 0 {
+		delete(c.tenants, userID)
+		c.inflight.DeleteLabelValues(userID)
+		return
+	}
+	t.released = make(chan struct{})
+	c.inflight.WithLabelValues(userID).Set(float64(t.inflight))
+}
+
+// tooManyDownstreamRequestsResponse returns the 429 response to a request over the tenant's limit, with a
+// Prometheus JSON error envelope, so that the error class is preserved when the response is decoded.
+func tooManyDownstreamRequestsResponse(r *http.Request, limit int) *http.Response {
+	msg := fmt.Sprintf("the query-frontend reached the max number of concurrent downstream requests of the tenant (%d)", limit)
+	body, err := apierror.New(apierror.TypeTooManyRequests, msg).EncodeJSON()
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if err != nil {
+		body = []byte(msg)
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)),
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// releasingBody calls release once, when the response body is read to the end or closed, whichever comes first.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
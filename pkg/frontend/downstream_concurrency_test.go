@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+type downstreamLimitsMock map[string]int
+
+func (l downstreamLimitsMock) MaxConcurrentDownstreamRequests(userID string) int {
+	return l[userID]
+}
+
+// slowDownstream is a downstream Prometheus recording the max number of concurrent requests it gets. Requests
+// wait for the delay, and until release is closed.
+type slowDownstream struct {
+	*httptest.Server
+	delay   time.Duration
+	release chan struct{}
+
+	inflight    atomic.Int64
+	maxInflight atomic.Int64
+}
+
+func newSlowDownstream(t *testing.T, delay time.Duration) *slowDownstream {
+	d := &slowDownstream{delay: delay, release: make(chan struct{})}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := d.inflight.Inc()
+		defer d.inflight.Dec()
+		for peak := d.maxInflight.Load(); n > peak; peak = d.maxInflight.Load() {
+			if d.maxInflight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+
+		time.Sleep(d.delay)
+		<-d.release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	t.Cleanup(d.Server.Close)
+	return d
+}
+
+func TestConcurrencyLimitingRoundTripper(t *testing.T) {
+	const limit = 3
+
+	newRoundTripper := func(t *testing.T, downstream *slowDownstream, waitTimeout time.Duration) (http.RoundTripper, *prometheus.Registry) {
+		reg := prometheus.NewPedanticRegistry()
+		next, err := NewDownstreamRoundTripper(downstream.URL, nil, DownstreamTransportConfig{}, nil)
+		require.NoError(t, err)
+		return newConcurrencyLimitingRoundTripper(next, downstreamLimitsMock{"user-1": limit}, waitTimeout, reg), reg
+	}
+	do := func(rt http.RoundTripper, tenantID string) (*http.Response, string, error) {
+		req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/query?query=up", nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), tenantID))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, "", err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return resp, string(body), err
+	}
+	// startBlocked sends n requests of the tenant, and waits for them to be in flight to the downstream.
+	startBlocked := func(t *testing.T, rt http.RoundTripper, downstream *slowDownstream, tenantID string, n int) *sync.WaitGroup {
+		wg := &sync.WaitGroup{}
+		inflight := downstream.inflight.Load()
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, _, err := do(rt, tenantID)
+				if assert.NoError(t, err) {
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+				}
+			}()
+		}
+		require.Eventually(t, func() bool { return downstream.inflight.Load() == inflight+int64(n) }, 5*time.Second, 5*time.Millisecond)
+		return wg
+	}
+
+	t.Run("parallel requests wait for the tenant's requests in flight", func(t *testing.T) {
+		downstream := newSlowDownstream(t, 20*time.Millisecond)
+		close(downstream.release)
+		rt, reg := newRoundTripper(t, downstream, time.Minute)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, _, err := do(rt, "user-1")
+				if assert.NoError(t, err) {
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(limit), downstream.maxInflight.Load())
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(""), "cortex_query_frontend_downstream_inflight_requests", "cortex_query_frontend_downstream_rejected_requests_total"))
+	})
+
+	t.Run("requests over the limit are rejected with 429", func(t *testing.T) {
+		downstream := newSlowDownstream(t, 0)
+		rt, reg := newRoundTripper(t, downstream, 0)
+
+		wg := startBlocked(t, rt, downstream, "user-1", limit)
+
+		resp, body, err := do(rt, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"status":"error","errorType":"too_many_requests","error":"the query-frontend reached the max number of concurrent downstream requests of the tenant (3)"}`, body)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_downstream_inflight_requests Number of requests in flight to the downstream.
+			# TYPE cortex_query_frontend_downstream_inflight_requests gauge
+			cortex_query_frontend_downstream_inflight_requests{user="user-1"} 3
+			# HELP cortex_query_frontend_downstream_rejected_requests_total Total number of requests rejected because the tenant reached the max number of concurrent downstream requests.
+			# TYPE cortex_query_frontend_downstream_rejected_requests_total counter
+			cortex_query_frontend_downstream_rejected_requests_total{user="user-1"} 1
+		`), "cortex_query_frontend_downstream_inflight_requests", "cortex_query_frontend_downstream_rejected_requests_total"))
+
+		// The requests of other tenants aren't limited.
+		otherWg := startBlocked(t, rt, downstream, "user-2", 2*limit)
+
+		close(downstream.release)
+		wg.Wait()
+		otherWg.Wait()
+
+		// Once the requests are done, the tenant can send requests again.
+		resp, _, err = do(rt, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_downstream_rejected_requests_total Total number of requests rejected because the tenant reached the max number of concurrent downstream requests.
+			# TYPE cortex_query_frontend_downstream_rejected_requests_total counter
+			cortex_query_frontend_downstream_rejected_requests_total{user="user-1"} 1
+		`), "cortex_query_frontend_downstream_inflight_requests", "cortex_query_frontend_downstream_rejected_requests_total"))
+
+		// The metrics of the tenant are removed once it's inactive.
+		rt.(*concurrencyLimitingRoundTripper).cleanupMetricsForUser("user-1")
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(""), "cortex_query_frontend_downstream_inflight_requests", "cortex_query_frontend_downstream_rejected_requests_total"))
+	})
+
+	t.Run("requests are rejected after waiting for the timeout", func(t *testing.T) {
+		const waitTimeout = 100 * time.Millisecond
+
+		downstream := newSlowDownstream(t, 0)
+		rt, _ := newRoundTripper(t, downstream, waitTimeout)
+
+		wg := startBlocked(t, rt, downstream, "user-1", limit)
+
+		start := time.Now()
+		resp, _, err := do(rt, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.GreaterOrEqual(t, time.Since(start), waitTimeout)
+
+		close(downstream.release)
+		wg.Wait()
+		assert.Equal(t, int64(limit), downstream.maxInflight.Load())
+	})
+
+	t.Run("canceled waiting requests return the context error", func(t *testing.T) {
+		downstream := newSlowDownstream(t, 0)
+		rt, reg := newRoundTripper(t, downstream, time.Minute)
+
+		wg := startBlocked(t, rt, downstream, "user-1", limit)
+
+		ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), "user-1"), 50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/query?query=up", nil).WithContext(ctx)
+		_, err := rt.RoundTrip(req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(downstream.release)
+		wg.Wait()
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(""), "cortex_query_frontend_downstream_rejected_requests_total"))
+	})
+}
@@ -6,31 +6,264 @@
 package frontend
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/instrumentation"
 )
 
+// maxDownstreamErrorBodySize is the max size of an error response body read to classify the error.
+const maxDownstreamErrorBodySize = 1024 * 1024
+
 // RoundTripper that forwards requests to downstream URL.
 type downstreamRoundTripper struct {
 	downstreamURL *url.URL
+	// tenantURLs override the downstream URL for the requests of a single tenant.
+	tenantURLs map[string]*url.URL
+	transport  http.RoundTripper
+
+	errors           *prometheus.CounterVec
+	transportMetrics *downstreamTransportMetrics
+
+	responses       *prometheus.CounterVec
+	responseBytes   *prometheus.CounterVec
+	requestDuration *prometheus.CounterVec
+
+	// activeUsers tracks the tenants having per-tenant metrics, to remove them once the tenant is inactive.
+	activeUsers *util.ActiveUsersCleanupService
 }
 
-func NewDownstreamRoundTripper(downstreamURL string) (http.RoundTripper, error) {
+// NewDownstreamRoundTripper returns a RoundTripper forwarding requests to the downstream URL, or to the URL of
+// the tenant if the request is for a single tenant having one in tenantURLs, in the <tenant>=<url> format.
+func NewDownstreamRoundTripper(downstreamURL string, tenantURLs []string, transportCfg DownstreamTransportConfig, reg prometheus.Registerer) (http.RoundTripper, error) {
 	u, err := url.Parse(downstreamURL)
 	if err != nil {
 		return nil, err
 	}
+	parsedTenantURLs, err := parseDownstreamTenantURLs(tenantURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	transportMetrics := newDownstreamTransportMetrics(reg)
+	transport, err := newDownstreamTransport(transportCfg, transportMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	d := downstreamRoundTripper{
+		downstreamURL:    u,
+		tenantURLs:       parsedTenantURLs,
+		transport:        transport,
+		transportMetrics: transportMetrics,
+		errors: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_errors_total",
+			Help: "Total number of errors returned by the downstream, by error class.",
+		}, []string{"user", "reason"}),
+		responses: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_responses_total",
+			Help: "Total number of responses received from the downstream, by status code.",
+		}, []string{"user", "status_code"}),
+		responseBytes: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_response_bytes_total",
+			Help: "Total size of the response bodies received from the downstream.",
+		}, []string{"user"}),
+		requestDuration: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_request_duration_seconds_total",
+			Help: "Total time spent on the downstream requests, until their response bodies were read.",
+		}, []string{"user"}),
+	}
+	d.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(d.cleanupMetricsForUser)
+	// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
+	_ = d.activeUsers.StartAsync(context.Background())
 
-	return &instrumentation.TracerTransport{Next: downstreamRoundTripper{downstreamURL: u}}, nil
+	return &instrumentation.TracerTransport{Next: d}, nil
+}
+
+// cleanupMetricsForUser removes the per-tenant metrics of the user.
+func (d downstreamRoundTripper) cleanupMetricsForUser(userID string) {
+	d.errors.DeletePartialMatch(prometheus.Labels{"user": userID})
+	d.responses.DeletePartialMatch(prometheus.Labels{"user": userID})
+	d.responseBytes.DeletePartialMatch(prometheus.Labels{"user": userID})
+	d.requestDuration.DeletePartialMatch(prometheus.Labels{"user": userID})
+}
+
+// parseDownstreamTenantURLs parses the per-tenant downstream URLs in the <tenant>=<url> format.
+func parseDownstreamTenantURLs(tenantURLs []string) (map[string]*url.URL, error) {
+	parsed := make(map[string]*url.URL, len(tenantURLs))
+	for _, tenantURL := range tenantURLs {
+		// Tenant IDs can't contain an equal sign, while URLs can.
+		tenantID, rawURL, ok := strings.Cut(tenantURL, "=")
+		if !ok || tenantID == "" {
+			return nil, fmt.Errorf("invalid downstream tenant URL %q: expected <tenant>=<url>", tenantURL)
+		}
+		if err := tenant.ValidTenantID(tenantID); err != nil {
+			return nil, fmt.Errorf("invalid downstream tenant URL %q: %w", tenantURL, err)
+		}
+		if _, ok := parsed[tenantID]; ok {
+			return nil, fmt.Errorf("invalid downstream tenant URL %q: the tenant has multiple URLs", tenantURL)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid downstream tenant URL %q: %w", tenantURL, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid downstream tenant URL %q: the URL must have a scheme and a host", tenantURL)
+		}
+		parsed[tenantID] = u
+	}
+	return parsed, nil
+}
+
+// urlFor returns the downstream URL of the request: the URL of its tenant, if any, or the global one.
+func (d downstreamRoundTripper) urlFor(r *http.Request) *url.URL {
+	if len(d.tenantURLs) == 0 {
+		return d.downstreamURL
+	}
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil || len(tenantIDs) != 1 {
+		return d.downstreamURL
+	}
+	if u, ok := d.tenantURLs[tenantIDs[0]]; ok {
+		return u
+	}
+	return d.downstreamURL
 }
 
 func (d downstreamRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	r.URL.Scheme = d.downstreamURL.Scheme
-	r.URL.Host = d.downstreamURL.Host
-	r.URL.Path = path.Join(d.downstreamURL.Path, r.URL.Path)
+	downstreamURL := d.urlFor(r)
+	r.URL.Scheme = downstreamURL.Scheme
+	r.URL.Host = downstreamURL.Host
+	r.URL.Path = path.Join(downstreamURL.Path, r.URL.Path)
+	// The Host header is taken from the URL, rather than the one of the request to the query-frontend.
 	r.Host = ""
-	return http.DefaultTransport.RoundTrip(r)
+	// The downstream request is derived from the context of the incoming request, so that it's canceled when
+	// the client disconnects or the request deadline is exceeded.
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), d.transportMetrics.clientTrace()))
+
+	start := time.Now()
+	resp, err := d.transport.RoundTrip(r)
+	if err != nil {
+		d.recordError(r, querymiddleware.ErrorClassFromError(err))
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		if resp, err = d.classifyErrorResponse(r, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	d.instrumentResponse(r, resp, start)
+	return resp, nil
+}
+
+// instrumentResponse records the latency and the body size of the response in the downstream stats of the request
+// and in the metrics, once its body is read to the end or closed.
+func (d downstreamRoundTripper) instrumentResponse(r *http.Request, resp *http.Response, start time.Time) {
+	stats := querymiddleware.DownstreamStatsFromContext(r.Context())
+	tenantIDs, tenantErr := tenant.TenantIDs(r.Context())
+
+	resp.Body = &countingBody{ReadCloser: resp.Body, done: func(size int64) {
+		latency := time.Since(start)
+		stats.Record(resp.StatusCode, latency, size)
+		if tenantErr != nil {
+			return
+		}
+		userID := tenant.JoinTenantIDs(tenantIDs)
+		d.responses.WithLabelValues(userID, strconv.Itoa(resp.StatusCode)).Inc()
+		d.responseBytes.WithLabelValues(userID).Add(float64(size))
+		d.requestDuration.WithLabelValues(userID).Add(latency.Seconds())
+		d.activeUsers.UpdateUserTimestamp(userID, time.Now())
+	}}
+}
+
+// countingBody counts the bytes read from a response body, and calls done with their count once, when the body
+// is read to the end or closed, whichever comes first.
+type countingBody struct {
+	io.ReadCloser
+	size int64
+	done func(size int64)
+	once sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if errors.Is(err, io.EOF) {
+		b.once.Do(func() { b.done(b.size) })
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.done(b.size) })
+	return b.ReadCloser.Close()
+}
+
+// classifyErrorResponse classifies the error response and records its class. Error responses to queries
+// which are not Prometheus JSON error envelopes are rewritten into one, so that the error class is
+// preserved when the response is decoded.
+func (d downstreamRoundTripper) classifyErrorResponse(r *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownstreamErrorBodySize))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	truncated := len(body) == maxDownstreamErrorBodySize
+	if !truncated {
+		_ = resp.Body.Close()
+	}
+
+	class, message, isEnvelope := querymiddleware.ClassifyErrorResponse(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	d.recordError(r, class)
+
+	if !isEnvelope && !truncated && isCoalescableQuery(r.URL.Path) {
+		if encoded, err := apierror.New(class.APIErrorType(), message).EncodeJSON(); err == nil {
+			body = encoded
+			resp.Header = resp.Header.Clone()
+			resp.Header.Set("Content-Type", "application/json")
+			resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+
+	if truncated {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	} else {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+	}
+	return resp, nil
+}
+
+func (d downstreamRoundTripper) recordError(r *http.Request, class querymiddleware.ErrorClass) {
+	querymiddleware.ErrorClassificationFromContext(r.Context()).Record(class)
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	d.errors.WithLabelValues(userID, string(class)).Inc()
+	d.activeUsers.UpdateUserTimestamp(userID, time.Now())
 }
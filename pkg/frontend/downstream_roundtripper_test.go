@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+	"github.com/grafana/mimir/pkg/util/instrumentation"
+)
+
+func TestDownstreamRoundTripper_ErrorClassification(t *testing.T) {
+	tests := map[string]struct {
+		path                string
+		statusCode          int
+		contentType         string
+		body                string
+		expectedClass       querymiddleware.ErrorClass
+		expectedContentType string
+		expectedBody        string
+	}{
+		"success": {
+			path:                "/prometheus/api/v1/query",
+			statusCode:          http.StatusOK,
+			contentType:         "application/json",
+			body:                `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expectedClass:       querymiddleware.ErrorClassNone,
+			expectedContentType: "application/json",
+			expectedBody:        `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		},
+		"Prometheus error envelope is preserved": {
+			path:                "/prometheus/api/v1/query_range",
+			statusCode:          http.StatusBadRequest,
+			contentType:         "application/json",
+			body:                `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			expectedClass:       querymiddleware.ErrorClassBadData,
+			expectedContentType: "application/json",
+			expectedBody:        `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+		},
+		"plain text error from a proxy is rewritten into a Prometheus error envelope": {
+			path:                "/prometheus/api/v1/query",
+			statusCode:          http.StatusBadGateway,
+			contentType:         "text/plain",
+			body:                "upstream connect error",
+			expectedClass:       querymiddleware.ErrorClassUnavailable,
+			expectedContentType: "application/json",
+			expectedBody:        `{"status":"error","errorType":"unavailable","error":"upstream connect error"}`,
+		},
+		"plain text error on a non-query path is left as is": {
+			path:                "/prometheus/api/v1/metadata",
+			statusCode:          http.StatusGatewayTimeout,
+			contentType:         "text/plain",
+			body:                "timeout",
+			expectedClass:       querymiddleware.ErrorClassTimeout,
+			expectedContentType: "text/plain",
+			expectedBody:        "timeout",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.statusCode)
+				_, _ = io.WriteString(w, tc.body)
+			}))
+			t.Cleanup(server.Close)
+
+			reg := prometheus.NewPedanticRegistry()
+			rt, err := NewDownstreamRoundTripper(server.URL, nil, defaultDownstreamTransportConfig(), reg)
+			require.NoError(t, err)
+
+			classification, ctx := querymiddleware.ContextWithErrorClassification(user.InjectOrgID(context.Background(), "user-1"))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil).WithContext(ctx)
+			req.RequestURI = ""
+
+			resp, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			assert.Equal(t, tc.expectedContentType, resp.Header.Get("Content-Type"))
+			if tc.expectedContentType == "application/json" {
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			} else {
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+			assert.Equal(t, tc.expectedClass, classification.Class())
+
+			expectedMetrics := ""
+			if tc.expectedClass != querymiddleware.ErrorClassNone {
+				expectedMetrics = `
+					# HELP cortex_query_frontend_downstream_errors_total Total number of errors returned by the downstream, by error class.
+					# TYPE cortex_query_frontend_downstream_errors_total counter
+					cortex_query_frontend_downstream_errors_total{reason="` + string(tc.expectedClass) + `",user="user-1"} 1
+				`
+			}
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_query_frontend_downstream_errors_total"))
+		})
+	}
+}
+
+func TestDownstreamRoundTripper_DownstreamStats(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/prometheus/api/v1/labels" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	rt, err := NewDownstreamRoundTripper(server.URL, nil, defaultDownstreamTransportConfig(), reg)
+	require.NoError(t, err)
+
+	stats, ctx := querymiddleware.ContextWithDownstreamStats(user.InjectOrgID(context.Background(), "user-1"))
+	roundTrip := func(path string) []byte {
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		req.RequestURI = ""
+
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return b
+	}
+
+	// The stats are recorded once the body is read, and closing it doesn't record them again.
+	queryBody := roundTrip("/prometheus/api/v1/query")
+	require.Equal(t, body, string(queryBody))
+	require.Equal(t, 1, stats.Requests())
+	fields := stats.Fields()
+	require.Len(t, fields, 8)
+	assert.Equal(t, []interface{}{"downstream_requests", 1, "downstream_status_code", http.StatusOK}, fields[:4])
+	assert.Equal(t, "downstream_latency", fields[4])
+	assert.Greater(t, fields[5].(time.Duration), time.Duration(0))
+	assert.Equal(t, []interface{}{"downstream_response_size_bytes", int64(len(body))}, fields[6:])
+
+	// The status code is the one of the last response, while the sizes are summed up.
+	errorBody := roundTrip("/prometheus/api/v1/labels")
+	require.Equal(t, 2, stats.Requests())
+	fields = stats.Fields()
+	assert.Equal(t, []interface{}{"downstream_requests", 2, "downstream_status_code", http.StatusServiceUnavailable}, fields[:4])
+	assert.Equal(t, []interface{}{"downstream_response_size_bytes", int64(len(queryBody) + len(errorBody))}, fields[6:])
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+		# HELP cortex_query_frontend_downstream_responses_total Total number of responses received from the downstream, by status code.
+		# TYPE cortex_query_frontend_downstream_responses_total counter
+		cortex_query_frontend_downstream_responses_total{status_code="200",user="user-1"} 1
+		cortex_query_frontend_downstream_responses_total{status_code="503",user="user-1"} 1
+		# HELP cortex_query_frontend_downstream_response_bytes_total Total size of the response bodies received from the downstream.
+		# TYPE cortex_query_frontend_downstream_response_bytes_total counter
+		cortex_query_frontend_downstream_response_bytes_total{user="user-1"} %d
+	`, len(queryBody)+len(errorBody))), "cortex_query_frontend_downstream_responses_total", "cortex_query_frontend_downstream_response_bytes_total"))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var duration float64
+	for _, family := range families {
+		if family.GetName() == "cortex_query_frontend_downstream_request_duration_seconds_total" {
+			require.Len(t, family.GetMetric(), 1)
+			duration = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	assert.Greater(t, duration, 0.0)
+}
+
+func TestDownstreamRoundTripper_CleanupMetricsForUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/prometheus/api/v1/labels" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"status":"success","data":[]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	rt, err := NewDownstreamRoundTripper(server.URL, nil, defaultDownstreamTransportConfig(), reg)
+	require.NoError(t, err)
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		for _, path := range []string{"/prometheus/api/v1/series", "/prometheus/api/v1/labels"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(user.InjectOrgID(context.Background(), userID))
+			req.RequestURI = ""
+
+			resp, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			_, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+	}
+
+	metricNames := []string{
+		"cortex_query_frontend_downstream_errors_total",
+		"cortex_query_frontend_downstream_responses_total",
+		"cortex_query_frontend_downstream_response_bytes_total",
+		"cortex_query_frontend_downstream_request_duration_seconds_total",
+	}
+	countSeries := func(userID string) int {
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		count := 0
+		for _, family := range families {
+			if !slices.Contains(metricNames, family.GetName()) {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "user" && l.GetValue() == userID {
+						count++
+					}
+				}
+			}
+		}
+		return count
+	}
+	// One error, two responses, the response bytes and the request duration.
+	require.Equal(t, 5, countSeries("user-1"))
+	require.Equal(t, 5, countSeries("user-2"))
+
+	rt.(*instrumentation.TracerTransport).Next.(downstreamRoundTripper).cleanupMetricsForUser("user-1")
+
+	assert.Equal(t, 0, countSeries("user-1"))
+	assert.Equal(t, 5, countSeries("user-2"))
+}
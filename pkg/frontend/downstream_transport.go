@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	dstls "github.com/grafana/dskit/crypto/tls"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var errNegativeDownstreamTransportSetting = errors.New("the downstream transport connection limits and timeouts must not be negative")
+
+// DownstreamTransportConfig configures the HTTP transport used to send requests to the downstream URL.
+// The defaults match the settings of http.DefaultTransport.
+type DownstreamTransportConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_connections" category:"experimental"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_connections_per_host" category:"experimental"`
+	MaxConnsPerHost     int           `yaml:"max_connections_per_host" category:"experimental"`
+	IdleConnTimeout     time.Duration `yaml:"idle_connection_timeout" category:"experimental"`
+	DialTimeout         time.Duration `yaml:"dial_timeout" category:"experimental"`
+
+	TLS dstls.ClientConfig `yaml:",inline"`
+}
+
+func (cfg *DownstreamTransportConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxIdleConns, prefix+"max-idle-connections", 100, "Maximum number of idle (keep-alive) connections to the downstream. Set to 0 for no limit.")
+	f.IntVar(&cfg.MaxIdleConnsPerHost, prefix+"max-idle-connections-per-host", 0, "Maximum number of idle (keep-alive) connections to keep per downstream host. Set to 0 to use a built-in default value of 2.")
+	f.IntVar(&cfg.MaxConnsPerHost, prefix+"max-connections-per-host", 0, "Maximum number of connections per downstream host, including connections in the dialing, active and idle states. Requests exceeding the limit wait for a connection to become available. Set to 0 for no limit.")
+	f.DurationVar(&cfg.IdleConnTimeout, prefix+"idle-connection-timeout", 90*time.Second, "The time an idle connection to the downstream remains idle before closing. Set to 0 for no limit.")
+	f.DurationVar(&cfg.DialTimeout, prefix+"dial-timeout", 30*time.Second, "Maximum time to wait for a connection to the downstream to be established. Set to 0 for no limit.")
+	cfg.TLS.RegisterFlagsWithPrefix(prefix, f)
+}
+
+func (cfg *DownstreamTransportConfig) Validate() error {
+	if cfg.MaxIdleConns < 0 || cfg.MaxIdleConnsPerHost < 0 || cfg.MaxConnsPerHost < 0 || cfg.IdleConnTimeout < 0 || cfg.DialTimeout < 0 {
+		return errNegativeDownstreamTransportSetting
+	}
+	return nil
+}
+
+// downstreamTransportMetrics tracks the connections opened by the downstream transport.
+type downstreamTransportMetrics struct {
+	connectionsOpened prometheus.Counter
+	connectionsReused prometheus.Counter
+	openConnections   prometheus.Gauge
+	phaseDuration     *prometheus.HistogramVec
+}
+
+func newDownstreamTransportMetrics(reg prometheus.Registerer) *downstreamTransportMetrics {
+	return &downstreamTransportMetrics{
+		connectionsOpened: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_connections_opened_total",
+			Help: "Total number of connections opened to the downstream.",
+		}),
+		connectionsReused: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_downstream_connections_reused_total",
+			Help: "Total number of requests to the downstream which reused an existing connection.",
+		}),
+		openConnections: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_query_frontend_downstream_open_connections",
+			Help: "Number of currently open connections to the downstream.",
+		}),
+		phaseDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_query_frontend_downstream_connection_phase_duration_seconds",
+			Help:    "Time spent establishing connections to the downstream, by phase.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"phase"}),
+	}
+}
+
+// newDownstreamTransport returns an http.Transport configured by cfg. Dialed connections are tracked by metrics.
+func newDownstreamTransport(cfg DownstreamTransportConfig, metrics *downstreamTransportMetrics) (*http.Transport, error) {
+	var tlsConfig *tls.Config
+	if cfg.TLS != (dstls.ClientConfig{}) {
+		var err error
+		if tlsConfig, err = cfg.TLS.GetTLSConfig(); err != nil {
+			return nil, errors.Wrap(err, "failed to create the downstream TLS config")
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		metrics.connectionsOpened.Inc()
+		metrics.openConnections.Inc()
+		return &trackedConn{Conn: conn, onClose: metrics.openConnections.Dec}, nil
+	}
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// clientTrace returns a httptrace.ClientTrace recording the connection reuse and the duration
+// of the phases of establishing a connection for a single request.
+func (m *downstreamTransportMetrics) clientTrace() *httptrace.ClientTrace {
+	var (
+		mtx          sync.Mutex
+		dnsStart     time.Time
+		tlsStart     time.Time
+		connectStart = map[string]time.Time{}
+	)
+
+	observe := func(phase string, start time.Time) {
+		if !start.IsZero() {
+			m.phaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+		}
+	}
+
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				m.connectionsReused.Inc()
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mtx.Lock()
+			dnsStart = time.Now()
+			mtx.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			observe("dns", dnsStart)
+		},
+		// Connections to multiple addresses may be attempted concurrently.
+		ConnectStart: func(_, addr string) {
+			mtx.Lock()
+			connectStart[addr] = time.Now()
+			mtx.Unlock()
+		},
+		ConnectDone: func(_, addr string, _ error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			observe("connect", connectStart[addr])
+			delete(connectStart, addr)
+		},
+		TLSHandshakeStart: func() {
+			mtx.Lock()
+			tlsStart = time.Now()
+			mtx.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			observe("tls", tlsStart)
+		},
+	}
+}
+
+// trackedConn is a net.Conn calling onClose the first time it's closed.
+type trackedConn struct {
+	net.Conn
+
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(c.onClose)
+	return c.Conn.Close()
+}
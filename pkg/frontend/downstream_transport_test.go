@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package frontend
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func defaultDownstreamTransportConfig() DownstreamTransportConfig {
+	cfg := DownstreamTransportConfig{}
+	cfg.RegisterFlagsWithPrefix("", flag.NewFlagSet("", flag.PanicOnError))
+	return cfg
+}
+
+func TestDownstreamTransportConfig_Validate(t *testing.T) {
+	cfg := defaultDownstreamTransportConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.MaxConnsPerHost = -1
+	require.ErrorIs(t, cfg.Validate(), errNegativeDownstreamTransportSetting)
+}
+
+func TestDownstreamRoundTripper_MaxConnectionsPerHost(t *testing.T) {
+	const maxConns = 2
+
+	var (
+		inflight    = atomic.NewInt64(0)
+		maxInflight = atomic.NewInt64(0)
+		release     = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inflight.Inc()
+		defer inflight.Dec()
+		for {
+			prev := maxInflight.Load()
+			if n <= prev || maxInflight.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+
+		// Hold the connection until released.
+		<-release
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := defaultDownstreamTransportConfig()
+	cfg.MaxConnsPerHost = maxConns
+	reg := prometheus.NewPedanticRegistry()
+	rt, err := NewDownstreamRoundTripper(server.URL, nil, cfg, reg)
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil).WithContext(context.Background())
+			req.RequestURI = ""
+			resp, err := rt.RoundTrip(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}()
+	}
+
+	// Requests exceeding the limit wait for a connection rather than opening a new one.
+	require.Eventually(t, func() bool { return inflight.Load() == maxConns }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(maxConns), inflight.Load())
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_downstream_open_connections Number of currently open connections to the downstream.
+		# TYPE cortex_query_frontend_downstream_open_connections gauge
+		cortex_query_frontend_downstream_open_connections 2
+	`), "cortex_query_frontend_downstream_open_connections"))
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(maxConns), maxInflight.Load())
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_downstream_connections_opened_total Total number of connections opened to the downstream.
+		# TYPE cortex_query_frontend_downstream_connections_opened_total counter
+		cortex_query_frontend_downstream_connections_opened_total 2
+
+		# HELP cortex_query_frontend_downstream_connections_reused_total Total number of requests to the downstream which reused an existing connection.
+		# TYPE cortex_query_frontend_downstream_connections_reused_total counter
+		cortex_query_frontend_downstream_connections_reused_total 3
+	`), "cortex_query_frontend_downstream_connections_opened_total", "cortex_query_frontend_downstream_connections_reused_total"))
+}
+
+func TestDownstreamRoundTripper_ConnectionReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	rt, err := NewDownstreamRoundTripper(server.URL, nil, defaultDownstreamTransportConfig(), reg)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil).WithContext(context.Background())
+		req.RequestURI = ""
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_downstream_connections_opened_total Total number of connections opened to the downstream.
+		# TYPE cortex_query_frontend_downstream_connections_opened_total counter
+		cortex_query_frontend_downstream_connections_opened_total 1
+
+		# HELP cortex_query_frontend_downstream_connections_reused_total Total number of requests to the downstream which reused an existing connection.
+		# TYPE cortex_query_frontend_downstream_connections_reused_total counter
+		cortex_query_frontend_downstream_connections_reused_total 2
+
+		# HELP cortex_query_frontend_downstream_open_connections Number of currently open connections to the downstream.
+		# TYPE cortex_query_frontend_downstream_open_connections gauge
+		cortex_query_frontend_downstream_open_connections 1
+	`), "cortex_query_frontend_downstream_connections_opened_total", "cortex_query_frontend_downstream_connections_reused_total", "cortex_query_frontend_downstream_open_connections"))
+
+	// The connection is established once, without DNS resolution since the server address is an IP.
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "cortex_query_frontend_downstream_connection_phase_duration_seconds"))
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	strconv "strconv"
 	"strings"
@@ -24,11 +25,13 @@ import (
 	httpgrpc_server "github.com/grafana/dskit/httpgrpc/server"
 	"github.com/grafana/dskit/middleware"
 	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/grafana/dskit/user"
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -36,6 +39,7 @@ import (
 	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
 	"github.com/grafana/mimir/pkg/frontend/transport"
 	"github.com/grafana/mimir/pkg/frontend/v1/frontendv1pb"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	querier_worker "github.com/grafana/mimir/pkg/querier/worker"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerdiscovery"
 )
@@ -100,6 +104,129 @@ func TestFrontend_RequestHostHeaderWhenDownstreamURLIsConfigured(t *testing.T) {
 	testFrontend(t, config, nil, test, nil)
 }
 
+func TestFrontend_DownstreamTenantURLs(t *testing.T) {
+	type observedRequest struct {
+		server string
+		host   string
+		path   string
+	}
+	observed := make(chan observedRequest, 1)
+
+	// Create two HTTP servers mocking the default and the per-tenant downstream Prometheus API-compatible servers.
+	startDownstream := func(name string) net.Listener {
+		listen, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+
+		server := http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				observed <- observedRequest{server: name, host: r.Host, path: r.URL.Path}
+
+				_, err := w.Write([]byte(responseBody))
+				require.NoError(t, err)
+			}),
+		}
+		t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+		go server.Serve(listen) //nolint:errcheck
+		return listen
+	}
+	defaultDownstream := startDownstream("default")
+	tenantDownstream := startDownstream("tenant")
+
+	config := defaultFrontendConfig()
+	config.DownstreamURL = fmt.Sprintf("http://%s", defaultDownstream.Addr())
+	config.DownstreamTenantURLs = []string{fmt.Sprintf("team-b=http://%s/prefix", tenantDownstream.Addr())}
+	require.NoError(t, config.Validate())
+
+	test := func(addr string) {
+		for _, tc := range []struct {
+			orgID, expectedServer, expectedHost, expectedPath string
+		}{
+			{orgID: "team-a", expectedServer: "default", expectedHost: defaultDownstream.Addr().String(), expectedPath: "/api/v1/query_range"},
+			{orgID: "team-b", expectedServer: "tenant", expectedHost: tenantDownstream.Addr().String(), expectedPath: "/prefix/api/v1/query_range"},
+			// Queries of multiple tenants use the default downstream.
+			{orgID: "team-a|team-b", expectedServer: "default", expectedHost: defaultDownstream.Addr().String(), expectedPath: "/api/v1/query_range"},
+		} {
+			req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/%s", addr, query), nil)
+			require.NoError(t, err)
+			err = user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(context.Background(), tc.orgID), req)
+			require.NoError(t, err)
+
+			client := http.Client{
+				Transport: &nethttp.Transport{},
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, 200, resp.StatusCode)
+			_, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			// The Host received by the downstream is the host of the URL the request was routed to.
+			observedReq := <-observed
+			assert.Equal(t, tc.expectedServer, observedReq.server, tc.orgID)
+			assert.Equal(t, tc.expectedHost, observedReq.host, tc.orgID)
+			assert.Equal(t, tc.expectedPath, observedReq.path, tc.orgID)
+		}
+	}
+
+	testFrontend(t, config, nil, test, nil)
+}
+
+func TestCombinedFrontendConfig_Validate_DownstreamTenantURLs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		downstreamURL string
+		tenantURLs    []string
+		expectedErr   string
+	}{
+		"valid": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"team-a=http://prometheus-a:9090", "team-b=https://prometheus-b/prefix?x=y"},
+		},
+		"missing downstream URL": {
+			tenantURLs:  []string{"team-a=http://prometheus-a:9090"},
+			expectedErr: "the per-tenant downstream URLs require the downstream URL to be configured",
+		},
+		"missing tenant": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"=http://prometheus-a:9090"},
+			expectedErr:   `invalid downstream tenant URL "=http://prometheus-a:9090": expected <tenant>=<url>`,
+		},
+		"invalid tenant": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"team|a=http://prometheus-a:9090"},
+			expectedErr:   `invalid downstream tenant URL "team|a=http://prometheus-a:9090"`,
+		},
+		"URL without a host": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"team-a=prometheus-a:9090"},
+			expectedErr:   "the URL must have a scheme and a host",
+		},
+		"unparsable URL": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"team-a=http://prometheus-a:port"},
+			expectedErr:   `invalid downstream tenant URL "team-a=http://prometheus-a:port"`,
+		},
+		"duplicate tenant": {
+			downstreamURL: "http://prometheus:9090",
+			tenantURLs:    []string{"team-a=http://prometheus-a:9090", "team-a=http://prometheus-b:9090"},
+			expectedErr:   "the tenant has multiple URLs",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultFrontendConfig()
+			cfg.DownstreamURL = tc.downstreamURL
+			cfg.DownstreamTenantURLs = tc.tenantURLs
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
 func TestFrontend_LogsSlowQueriesFormValues(t *testing.T) {
 	// Create an HTTP server listening locally. This server mocks the downstream
 	// Prometheus API-compatible server.
@@ -162,6 +289,57 @@ func TestFrontend_LogsSlowQueriesFormValues(t *testing.T) {
 	testFrontend(t, config, nil, test, l)
 }
 
+func TestFrontend_LogsDownstreamStats(t *testing.T) {
+	downstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, err := w.Write([]byte(responseBody))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(downstreamServer.Close)
+
+	config := defaultFrontendConfig()
+	config.DownstreamURL = downstreamServer.URL
+	config.Handler.QueryStatsEnabled = true
+	config.Handler.LogQueriesLongerThan = time.Microsecond
+
+	var logs concurrency.SyncBuffer
+	test := func(addr string) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/%s", addr, query), nil)
+		require.NoError(t, err)
+		require.NoError(t, user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(context.Background(), "1"), req))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		expectedFields := []string{
+			"downstream_requests=1",
+			"downstream_status_code=200",
+			"downstream_latency=",
+			fmt.Sprintf("downstream_response_size_bytes=%d", len(responseBody)),
+		}
+		for _, msg := range []string{`msg="slow query detected"`, `msg="query stats"`} {
+			// The query is logged once the response is written, which may be after the client received it.
+			var line string
+			test.Poll(t, 5*time.Second, true, func() interface{} {
+				for _, l := range strings.Split(logs.String(), "\n") {
+					if strings.Contains(l, msg) {
+						line = l
+					}
+				}
+				return line != ""
+			})
+			for _, field := range expectedFields {
+				assert.Contains(t, line, field, msg)
+			}
+		}
+	}
+
+	testFrontend(t, config, nil, test, log.NewLogfmtLogger(&logs))
+}
+
 func TestFrontend_ReturnsRequestBodyTooLargeError(t *testing.T) {
 	// Create an HTTP server listening locally. This server mocks the downstream
 	// Prometheus API-compatible server.
@@ -183,18 +361,21 @@ func TestFrontend_ReturnsRequestBodyTooLargeError(t *testing.T) {
 	config.DownstreamURL = fmt.Sprintf("http://%s", downstreamListen.Addr())
 	config.Handler.MaxBodySize = 1
 
-	test := func(addr string) {
-		data := url.Values{}
-		data.Set("test", "max body size")
+	data := url.Values{}
+	data.Set("test", "max body size")
+	expectedMessage := fmt.Sprintf("http: request body too large: the limit is 1 bytes, set by -query-frontend.max-body-size, the request Content-Length is %d bytes", len(data.Encode()))
 
+	sendRequest := func(addr, accept string) (*http.Response, []byte) {
 		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/?foo=bar", addr), strings.NewReader(data.Encode()))
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 		req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+		if accept != "" {
+			req.Header.Add("Accept", accept)
+		}
 
 		ctx := context.Background()
 		req = req.WithContext(ctx)
-		assert.NoError(t, err)
-		err = user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(ctx, "1"), req)
+		err := user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(ctx, "1"), req)
 		assert.NoError(t, err)
 
 		client := http.Client{
@@ -202,18 +383,196 @@ func TestFrontend_ReturnsRequestBodyTooLargeError(t *testing.T) {
 		}
 
 		resp, err := client.Do(req)
-		assert.NoError(t, err)
+		require.NoError(t, err)
 		b, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		assert.NoError(t, err)
 
 		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode, string(b))
+		return resp, b
 	}
 
-	testFrontend(t, config, nil, test, nil)
+	reg := prometheus.NewPedanticRegistry()
+	test := func(addr string) {
+		_, b := sendRequest(addr, "")
+		assert.Equal(t, expectedMessage, strings.TrimSpace(string(b)))
+
+		// Clients not explicitly accepting JSON get the error as text.
+		_, b = sendRequest(addr, "text/plain, */*")
+		assert.Equal(t, expectedMessage, strings.TrimSpace(string(b)))
+
+		resp, b := sendRequest(addr, "application/json; charset=utf-8")
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.JSONEq(t, fmt.Sprintf(`{"status":"error","errorType":"too_large","error":%q}`, expectedMessage), string(b))
+
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_failed_requests_total Total number of requests failed by the query-frontend, by status code and error type.
+			# TYPE cortex_query_frontend_failed_requests_total counter
+			cortex_query_frontend_failed_requests_total{error_type="too_large",status_code="413"} 3
+		`), "cortex_query_frontend_failed_requests_total"))
+	}
+
+	testFrontendWithLimits(t, config, limits{}, nil, test, nil, reg)
+}
+
+func TestFrontend_CancelsDownstreamRequestOnClientDisconnect(t *testing.T) {
+	received := make(chan struct{})
+	canceled := make(chan struct{})
+
+	// The downstream blocks until its request is canceled.
+	downstreamServer := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(time.Minute):
+		}
+	}))
+	t.Cleanup(downstreamServer.Close)
+
+	config := defaultFrontendConfig()
+	config.DownstreamURL = downstreamServer.URL
+	config.Handler.LogQueriesLongerThan = time.Microsecond
+
+	var logs concurrency.SyncBuffer
+	reg := prometheus.NewPedanticRegistry()
+
+	run := func(addr string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/%s", addr, query), nil)
+		require.NoError(t, err)
+		require.NoError(t, user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(ctx, "1"), req))
+
+		errs := make(chan error, 1)
+		go func() {
+			client := http.Client{Transport: &nethttp.Transport{}}
+			resp, err := client.Do(req)
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			errs <- err
+		}()
+
+		// Close the client connection while the downstream is running the request.
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the downstream didn't receive the request")
+		}
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the downstream request wasn't canceled")
+		}
+
+		test.Poll(t, 5*time.Second, nil, func() interface{} {
+			return promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_query_frontend_queries_cancelled_total Total number of queries cancelled before completing, because the client disconnected or the deadline of the request was exceeded.
+				# TYPE cortex_query_frontend_queries_cancelled_total counter
+				cortex_query_frontend_queries_cancelled_total{reason="client_disconnected"} 1
+				cortex_query_frontend_queries_cancelled_total{reason="deadline_exceeded"} 0
+			`), "cortex_query_frontend_queries_cancelled_total")
+		})
+		assert.Contains(t, logs.String(), `msg="slow query detected"`)
+		assert.Contains(t, logs.String(), "cancellation_reason=client_disconnected")
+		assert.Contains(t, logs.String(), "canceled_after=")
+	}
+
+	testFrontendWithLimits(t, config, limits{}, nil, run, log.NewLogfmtLogger(&logs), reg)
+}
+
+func TestFrontend_QueryStatsHeaders(t *testing.T) {
+	queryStatsHeaders := []string{
+		transport.QueryWallTimeHeaderName,
+		transport.QueryFetchedSeriesHeaderName,
+		transport.QueryFetchedChunkBytesHeaderName,
+		transport.QueryQueueTimeHeaderName,
+	}
+
+	// The querier handler mimics a querier producing query stats.
+	querierHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := querier_stats.FromContext(r.Context())
+		stats.AddWallTime(2 * time.Second)
+		stats.AddFetchedSeries(3)
+		stats.AddFetchedChunkBytes(4096)
+
+		if r.URL.Query().Get("query") == "fail" {
+			http.Error(w, "query failed", http.StatusUnprocessableEntity)
+			return
+		}
+		_, err := w.Write([]byte(responseBody))
+		require.NoError(t, err)
+	})
+
+	sendQuery := func(t *testing.T, addr, query string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/api/v1/query?query=%s", addr, query), nil)
+		require.NoError(t, err)
+		require.NoError(t, user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(context.Background(), "1"), req))
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp
+	}
+
+	t.Run("querier stats are returned on success and error", func(t *testing.T) {
+		config := defaultFrontendConfig()
+		config.Handler.QueryStatsEnabled = true
+
+		testFrontendWithLimits(t, config, limits{queryStatsHeadersEnabled: true}, querierHandler, func(addr string) {
+			for query, expectedStatus := range map[string]int{"up": http.StatusOK, "fail": http.StatusUnprocessableEntity} {
+				resp := sendQuery(t, addr, query)
+				require.Equal(t, expectedStatus, resp.StatusCode)
+				require.Equal(t, "2", resp.Header.Get(transport.QueryWallTimeHeaderName))
+				require.Equal(t, "3", resp.Header.Get(transport.QueryFetchedSeriesHeaderName))
+				require.Equal(t, "4096", resp.Header.Get(transport.QueryFetchedChunkBytesHeaderName))
+				require.NotEmpty(t, resp.Header.Get(transport.QueryQueueTimeHeaderName))
+			}
+		}, nil, nil)
+	})
+
+	t.Run("only the wall time is returned with a downstream URL", func(t *testing.T) {
+		downstreamServer := httptest.NewServer(querierHandler)
+		t.Cleanup(downstreamServer.Close)
+
+		config := defaultFrontendConfig()
+		config.Handler.QueryStatsEnabled = true
+		config.DownstreamURL = downstreamServer.URL
+
+		testFrontendWithLimits(t, config, limits{queryStatsHeadersEnabled: true}, nil, func(addr string) {
+			resp := sendQuery(t, addr, "up")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.NotEmpty(t, resp.Header.Get(transport.QueryWallTimeHeaderName))
+			for _, name := range queryStatsHeaders[1:] {
+				require.Empty(t, resp.Header.Get(name), name)
+			}
+		}, nil, nil)
+	})
+
+	t.Run("no headers are returned by default", func(t *testing.T) {
+		config := defaultFrontendConfig()
+		config.Handler.QueryStatsEnabled = true
+
+		testFrontend(t, config, querierHandler, func(addr string) {
+			resp := sendQuery(t, addr, "up")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			for _, name := range queryStatsHeaders {
+				require.Empty(t, resp.Header.Get(name), name)
+			}
+		}, nil)
+	})
 }
 
 func testFrontend(t *testing.T, config CombinedFrontendConfig, handler http.Handler, test func(addr string), l log.Logger) {
+	testFrontendWithLimits(t, config, limits{}, handler, test, l, nil)
+}
+
+func testFrontendWithLimits(t *testing.T, config CombinedFrontendConfig, frontendLimits limits, handler http.Handler, test func(addr string), l log.Logger, reg prometheus.Registerer) {
 	logger := log.NewNopLogger()
 	if l != nil {
 		logger = l
@@ -232,7 +591,7 @@ func testFrontend(t *testing.T, config CombinedFrontendConfig, handler http.Hand
 	httpListen, err := net.Listen("tcp", "localhost:0")
 	require.NoError(t, err)
 
-	rt, v1, v2, err := InitFrontend(config, limits{}, limits{}, 0, logger, nil, codec)
+	rt, v1, v2, err := InitFrontend(config, frontendLimits, frontendLimits, frontendLimits, 0, logger, nil, codec)
 	require.NoError(t, err)
 	require.NotNil(t, rt)
 	// v1 will be nil if DownstreamURL is defined.
@@ -253,11 +612,12 @@ func testFrontend(t *testing.T, config CombinedFrontendConfig, handler http.Hand
 		frontendv1pb.RegisterFrontendServer(grpcServer, v1)
 	}
 
+	config.Handler.DownstreamURLEnabled = config.DownstreamURL != ""
 	r := mux.NewRouter()
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(config.Handler, rt, logger, nil, nil)))
+	).Wrap(transport.NewHandler(config.Handler, rt, frontendLimits, logger, reg, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -292,14 +652,24 @@ func defaultFrontendConfig() CombinedFrontendConfig {
 }
 
 type limits struct {
-	queriers             int
-	queryIngestersWithin time.Duration
+	queriers                        int
+	maxConcurrentDownstreamRequests int
+	queryIngestersWithin            time.Duration
+	queryStatsHeadersEnabled        bool
 }
 
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
 
+func (l limits) MaxConcurrentDownstreamRequests(string) int {
+	return l.maxConcurrentDownstreamRequests
+}
+
+func (l limits) QueryStatsHeadersEnabled(string) bool {
+	return l.queryStatsHeadersEnabled
+}
+
 func (l limits) QueryIngestersWithin(string) time.Duration {
 	return l.queryIngestersWithin
 }
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/grafana/dskit/httpgrpc"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+// ErrorClass classifies a query error by its cause, regardless of how it was reported.
+type ErrorClass string
+
+const (
+	ErrorClassNone            ErrorClass = ""
+	ErrorClassBadData         ErrorClass = "bad_data"
+	ErrorClassExecution       ErrorClass = "execution"
+	ErrorClassTimeout         ErrorClass = "timeout"
+	ErrorClassCanceled        ErrorClass = "canceled"
+	ErrorClassUnavailable     ErrorClass = "unavailable"
+	ErrorClassTooManyRequests ErrorClass = "too_many_requests"
+	ErrorClassTooLarge        ErrorClass = "too_large"
+	ErrorClassNotFound        ErrorClass = "not_found"
+	ErrorClassNotAcceptable   ErrorClass = "not_acceptable"
+	ErrorClassInternal        ErrorClass = "internal"
+)
+
+// Retryable returns true if a request failed with an error of this class may succeed when retried.
+// Errors caused by the request itself (e.g. a PromQL parse error or a query exceeding limits) always fail the same way.
+// Timeouts are not retried either, because the query has already used all of its time budget.
+func (c ErrorClass) Retryable() bool {
+	switch c {
+	case ErrorClassInternal, ErrorClassUnavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// APIErrorType returns the Prometheus API error type corresponding to the class.
+func (c ErrorClass) APIErrorType() apierror.Type {
+	switch c {
+	case ErrorClassTooLarge:
+		return apierror.TypeTooLargeEntry
+	default:
+		return apierror.Type(c)
+	}
+}
+
+// errorClassFromAPIErrorType maps a Prometheus API error type to an ErrorClass.
+// The second return value is false if the type is unknown.
+func errorClassFromAPIErrorType(typ apierror.Type) (ErrorClass, bool) {
+	switch typ {
+	case apierror.TypeBadData:
+		return ErrorClassBadData, true
+	case apierror.TypeExec:
+		return ErrorClassExecution, true
+	case apierror.TypeTimeout:
+		return ErrorClassTimeout, true
+	case apierror.TypeCanceled:
+		return ErrorClassCanceled, true
+	case apierror.TypeUnavailable:
+		return ErrorClassUnavailable, true
+	case apierror.TypeTooManyRequests:
+		return ErrorClassTooManyRequests, true
+	case apierror.TypeTooLargeEntry:
+		return ErrorClassTooLarge, true
+	case apierror.TypeNotFound:
+		return ErrorClassNotFound, true
+	case apierror.TypeNotAcceptable:
+		return ErrorClassNotAcceptable, true
+	case apierror.TypeInternal:
+		return ErrorClassInternal, true
+	default:
+		return ErrorClassNone, false
+	}
+}
+
+// ClassifyStatusCode classifies an error by the HTTP status code of the response.
+func ClassifyStatusCode(code int) ErrorClass {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrorClassBadData
+	case http.StatusUnprocessableEntity:
+		return ErrorClassExecution
+	case 499:
+		return ErrorClassCanceled
+	case http.StatusNotFound:
+		return ErrorClassNotFound
+	case http.StatusNotAcceptable:
+		return ErrorClassNotAcceptable
+	case http.StatusRequestEntityTooLarge:
+		return ErrorClassTooLarge
+	case http.StatusTooManyRequests:
+		return ErrorClassTooManyRequests
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return ErrorClassUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	}
+	if code/100 == 2 {
+		return ErrorClassNone
+	}
+	if code/100 == 4 {
+		return ErrorClassBadData
+	}
+	return ErrorClassInternal
+}
+
+// ClassifyErrorResponse classifies an HTTP error response. The Prometheus JSON error envelope is used when present,
+// otherwise the error is classified by its status code. It also returns the error message, and whether the body
+// was a Prometheus JSON error envelope. Returns ErrorClassNone if the response is not an error.
+func ClassifyErrorResponse(statusCode int, contentType string, body []byte) (class ErrorClass, message string, isEnvelope bool) {
+	if statusCode/100 == 2 {
+		return ErrorClassNone, "", false
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/json" {
+		var envelope struct {
+			Status    string `json:"status"`
+			ErrorType string `json:"errorType"`
+			Error     string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &envelope); err == nil && envelope.Status == statusError {
+			if class, ok := errorClassFromAPIErrorType(apierror.Type(envelope.ErrorType)); ok {
+				return class, envelope.Error, true
+			}
+			return ClassifyStatusCode(statusCode), envelope.Error, true
+		}
+	}
+
+	return ClassifyStatusCode(statusCode), string(body), false
+}
+
+// ErrorClassFromError classifies an error returned by a query handler.
+func ErrorClassFromError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if class, ok := errorClassFromAPIErrorType(apiErr.Type); ok {
+			return class
+		}
+		return ErrorClassInternal
+	}
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return ClassifyStatusCode(int(resp.Code))
+	}
+
+	// Errors which don't come with a response are transport errors.
+	return ErrorClassInternal
+}
+
+// ErrorClassification records the class of the last error returned by the downstream for a request.
+// It's safe for concurrent use, since a request may be split into multiple concurrent downstream requests.
+type ErrorClassification struct {
+	mtx   sync.Mutex
+	class ErrorClass
+}
+
+// Record records the class of an error returned by the downstream.
+func (c *ErrorClassification) Record(class ErrorClass) {
+	if c == nil || class == ErrorClassNone {
+		return
+	}
+
+	c.mtx.Lock()
+	c.class = class
+	c.mtx.Unlock()
+}
+
+// Class returns the recorded error class, or ErrorClassNone if no error was recorded.
+func (c *ErrorClassification) Class() ErrorClass {
+	if c == nil {
+		return ErrorClassNone
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.class
+}
+
+type errorClassificationContextKey struct{}
+
+// ContextWithErrorClassification returns a context with an empty ErrorClassification,
+// which can be retrieved with ErrorClassificationFromContext.
+func ContextWithErrorClassification(ctx context.Context) (*ErrorClassification, context.Context) {
+	c := &ErrorClassification{}
+	return c, context.WithValue(ctx, errorClassificationContextKey{}, c)
+}
+
+// ErrorClassificationFromContext returns the ErrorClassification from the context, or nil if there's none.
+func ErrorClassificationFromContext(ctx context.Context) *ErrorClassification {
+	c, _ := ctx.Value(errorClassificationContextKey{}).(*ErrorClassification)
+	return c
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestClassifyErrorResponse(t *testing.T) {
+	envelope := func(typ apierror.Type) []byte {
+		body, err := apierror.New(typ, "message").EncodeJSON()
+		require.NoError(t, err)
+		return body
+	}
+
+	tests := map[string]struct {
+		statusCode         int
+		contentType        string
+		body               []byte
+		expectedClass      ErrorClass
+		expectedMessage    string
+		expectedIsEnvelope bool
+		expectedRetryable  bool
+	}{
+		"success": {
+			statusCode:    http.StatusOK,
+			contentType:   "application/json",
+			body:          []byte(`{"status":"success"}`),
+			expectedClass: ErrorClassNone,
+		},
+		"PromQL parse error": {
+			statusCode:         http.StatusBadRequest,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeBadData),
+			expectedClass:      ErrorClassBadData,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"execution error": {
+			statusCode:         http.StatusUnprocessableEntity,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeExec),
+			expectedClass:      ErrorClassExecution,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"timeout": {
+			statusCode:         http.StatusServiceUnavailable,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeTimeout),
+			expectedClass:      ErrorClassTimeout,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"canceled": {
+			statusCode:         499,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeCanceled),
+			expectedClass:      ErrorClassCanceled,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"unavailable": {
+			statusCode:         http.StatusServiceUnavailable,
+			contentType:        "application/json; charset=utf-8",
+			body:               envelope(apierror.TypeUnavailable),
+			expectedClass:      ErrorClassUnavailable,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+			expectedRetryable:  true,
+		},
+		"too many requests": {
+			statusCode:         http.StatusTooManyRequests,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeTooManyRequests),
+			expectedClass:      ErrorClassTooManyRequests,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"too large": {
+			statusCode:         http.StatusRequestEntityTooLarge,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeTooLargeEntry),
+			expectedClass:      ErrorClassTooLarge,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"internal": {
+			statusCode:         http.StatusInternalServerError,
+			contentType:        "application/json",
+			body:               envelope(apierror.TypeInternal),
+			expectedClass:      ErrorClassInternal,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+			expectedRetryable:  true,
+		},
+		"envelope with unknown error type falls back to the status code": {
+			statusCode:         http.StatusBadRequest,
+			contentType:        "application/json",
+			body:               []byte(`{"status":"error","errorType":"whatever","error":"message"}`),
+			expectedClass:      ErrorClassBadData,
+			expectedMessage:    "message",
+			expectedIsEnvelope: true,
+		},
+		"plain text bad gateway from a proxy": {
+			statusCode:        http.StatusBadGateway,
+			contentType:       "text/plain",
+			body:              []byte("upstream connect error"),
+			expectedClass:     ErrorClassUnavailable,
+			expectedMessage:   "upstream connect error",
+			expectedRetryable: true,
+		},
+		"plain text gateway timeout from a proxy": {
+			statusCode:      http.StatusGatewayTimeout,
+			contentType:     "text/html",
+			body:            []byte("<html>timeout</html>"),
+			expectedClass:   ErrorClassTimeout,
+			expectedMessage: "<html>timeout</html>",
+		},
+		"invalid JSON": {
+			statusCode:        http.StatusInternalServerError,
+			contentType:       "application/json",
+			body:              []byte("{"),
+			expectedClass:     ErrorClassInternal,
+			expectedMessage:   "{",
+			expectedRetryable: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			class, message, isEnvelope := ClassifyErrorResponse(tc.statusCode, tc.contentType, tc.body)
+			assert.Equal(t, tc.expectedClass, class)
+			assert.Equal(t, tc.expectedMessage, message)
+			assert.Equal(t, tc.expectedIsEnvelope, isEnvelope)
+			assert.Equal(t, tc.expectedRetryable, class.Retryable())
+		})
+	}
+}
+
+func TestErrorClassFromError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected ErrorClass
+	}{
+		"nil":                  {err: nil, expected: ErrorClassNone},
+		"context canceled":     {err: fmt.Errorf("wrapped: %w", context.Canceled), expected: ErrorClassCanceled},
+		"deadline exceeded":    {err: context.DeadlineExceeded, expected: ErrorClassTimeout},
+		"API error":            {err: apierror.New(apierror.TypeExec, "message"), expected: ErrorClassExecution},
+		"unknown API error":    {err: apierror.New(apierror.Type("whatever"), "message"), expected: ErrorClassInternal},
+		"HTTP gRPC error":      {err: httpgrpc.Errorf(http.StatusServiceUnavailable, "message"), expected: ErrorClassUnavailable},
+		"transport error":      {err: errors.New("connection refused"), expected: ErrorClassInternal},
+		"HTTP gRPC 4xx":        {err: httpgrpc.Errorf(http.StatusBadRequest, "message"), expected: ErrorClassBadData},
+		"HTTP gRPC other 5xx":  {err: httpgrpc.Errorf(http.StatusNotImplemented, "message"), expected: ErrorClassInternal},
+		"HTTP gRPC conflict":   {err: httpgrpc.Errorf(http.StatusConflict, "message"), expected: ErrorClassBadData},
+		"HTTP gRPC not found":  {err: httpgrpc.Errorf(http.StatusNotFound, "message"), expected: ErrorClassNotFound},
+		"HTTP gRPC rate limit": {err: httpgrpc.Errorf(http.StatusTooManyRequests, "message"), expected: ErrorClassTooManyRequests},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ErrorClassFromError(tc.err))
+		})
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	// Recording on a context without classification is a no-op.
+	ErrorClassificationFromContext(context.Background()).Record(ErrorClassInternal)
+	assert.Equal(t, ErrorClassNone, ErrorClassificationFromContext(context.Background()).Class())
+
+	c, ctx := ContextWithErrorClassification(context.Background())
+	require.Same(t, c, ErrorClassificationFromContext(ctx))
+
+	c.Record(ErrorClassUnavailable)
+	c.Record(ErrorClassNone)
+	assert.Equal(t, ErrorClassUnavailable, c.Class())
+
+	c.Record(ErrorClassBadData)
+	assert.Equal(t, ErrorClassBadData, c.Class())
+}
@@ -40,26 +40,29 @@ func (m *retryMiddlewareMetrics) Observe(v float64) {
 }
 
 type retry struct {
-	log        log.Logger
-	next       MetricsQueryHandler
-	maxRetries int
+	log               log.Logger
+	next              MetricsQueryHandler
+	maxRetries        int
+	retryByErrorClass bool
 
 	metrics prometheus.Observer
 }
 
 // newRetryMiddleware returns a middleware that retries requests if they
-// fail with 500 or a non-HTTP error.
-func newRetryMiddleware(log log.Logger, maxRetries int, metrics prometheus.Observer) MetricsQueryMiddleware {
+// fail with 500 or a non-HTTP error. If retryByErrorClass is true, requests
+// are retried if they fail with a retryable error class instead (see ErrorClass.Retryable).
+func newRetryMiddleware(log log.Logger, maxRetries int, retryByErrorClass bool, metrics prometheus.Observer) MetricsQueryMiddleware {
 	if metrics == nil {
 		metrics = newRetryMiddlewareMetrics(nil)
 	}
 
 	return MetricsQueryMiddlewareFunc(func(next MetricsQueryHandler) MetricsQueryHandler {
 		return retry{
-			log:        log,
-			next:       next,
-			maxRetries: maxRetries,
-			metrics:    metrics,
+			log:               log,
+			next:              next,
+			maxRetries:        maxRetries,
+			retryByErrorClass: retryByErrorClass,
+			metrics:           metrics,
 		}
 	})
 }
@@ -78,19 +81,27 @@ func (r retry) Do(ctx context.Context, req MetricsQueryRequest) (Response, error
 			return resp, nil
 		}
 
-		if apierror.IsNonRetryableAPIError(err) || errors.Is(err, context.Canceled) {
+		class := ErrorClassFromError(err)
+		if !r.retryable(err, class) {
 			return nil, err
 		}
-		// Retry if we get a HTTP 500 or a non-HTTP error.
-		httpResp, ok := httpgrpc.HTTPResponseFromError(err)
-		if !ok || httpResp.Code/100 == 5 {
-			lastErr = err
-			log := util_log.WithContext(ctx, spanlogger.FromContext(ctx, r.log))
-			level.Error(log).Log("msg", "error processing request", "try", tries, "err", err)
-			continue
-		}
 
-		return nil, err
+		lastErr = err
+		log := util_log.WithContext(ctx, spanlogger.FromContext(ctx, r.log))
+		level.Error(log).Log("msg", "error processing request", "try", tries, "err", err, "error_class", class)
 	}
 	return nil, lastErr
 }
+
+func (r retry) retryable(err error, class ErrorClass) bool {
+	if r.retryByErrorClass {
+		return class.Retryable()
+	}
+
+	if apierror.IsNonRetryableAPIError(err) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	// Retry if we get a HTTP 500 or a non-HTTP error.
+	httpResp, ok := httpgrpc.HTTPResponseFromError(err)
+	return !ok || httpResp.Code/100 == 5
+}
@@ -32,13 +32,25 @@ func TestRetry(t *testing.T) {
 		Code: http.StatusInternalServerError,
 		Body: []byte("Internal Server Error"),
 	})
+	errUnavailable := apierror.New(apierror.TypeUnavailable, "no store-gateway instances available")
+	errExecution := apierror.New(apierror.TypeExec, "expanding series: too many chunks")
+	errTimeout := apierror.New(apierror.TypeTimeout, "query timed out")
+	errGatewayTimeout := httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code: http.StatusGatewayTimeout,
+		Body: []byte("Gateway Timeout"),
+	})
+	errBadGateway := httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code: http.StatusBadGateway,
+		Body: []byte("Bad Gateway"),
+	})
 
 	for _, tc := range []struct {
-		name            string
-		handler         MetricsQueryHandler
-		resp            Response
-		err             error
-		expectedRetries int
+		name              string
+		retryByErrorClass bool
+		handler           MetricsQueryHandler
+		resp              Response
+		err               error
+		expectedRetries   int
 	}{
 		{
 			name:            "retry failures",
@@ -75,6 +87,46 @@ func TestRetry(t *testing.T) {
 			}),
 			err: errInternal,
 		},
+		{
+			name:            "don't retry unavailable",
+			expectedRetries: 0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errUnavailable
+			}),
+			err: errUnavailable,
+		},
+		{
+			name:            "retry 504s",
+			expectedRetries: 5,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errGatewayTimeout
+			}),
+			err: errGatewayTimeout,
+		},
+		{
+			name:            "retry deadline exceeded",
+			expectedRetries: 5,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, context.DeadlineExceeded
+			}),
+			err: context.DeadlineExceeded,
+		},
+		{
+			name:            "don't retry execution errors",
+			expectedRetries: 0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errExecution
+			}),
+			err: errExecution,
+		},
+		{
+			name:            "don't retry timeouts",
+			expectedRetries: 0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errTimeout
+			}),
+			err: errTimeout,
+		},
 		{
 			name:            "last error",
 			expectedRetries: 4,
@@ -86,11 +138,65 @@ func TestRetry(t *testing.T) {
 			}),
 			err: errBadRequest,
 		},
+		{
+			name:              "retry unavailable by error class",
+			retryByErrorClass: true,
+			expectedRetries:   5,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errUnavailable
+			}),
+			err: errUnavailable,
+		},
+		{
+			name:              "retry 502s by error class",
+			retryByErrorClass: true,
+			expectedRetries:   5,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errBadGateway
+			}),
+			err: errBadGateway,
+		},
+		{
+			name:              "retry 500s by error class",
+			retryByErrorClass: true,
+			expectedRetries:   5,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errInternal
+			}),
+			err: errInternal,
+		},
+		{
+			name:              "don't retry 504s by error class",
+			retryByErrorClass: true,
+			expectedRetries:   0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errGatewayTimeout
+			}),
+			err: errGatewayTimeout,
+		},
+		{
+			name:              "don't retry deadline exceeded by error class",
+			retryByErrorClass: true,
+			expectedRetries:   0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, context.DeadlineExceeded
+			}),
+			err: context.DeadlineExceeded,
+		},
+		{
+			name:              "don't retry bad-data by error class",
+			retryByErrorClass: true,
+			expectedRetries:   0,
+			handler: HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
+				return nil, errUnprocessable
+			}),
+			err: errUnprocessable,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			try.Store(0)
 			mockMetrics := mockRetryMetrics{}
-			h := newRetryMiddleware(log.NewNopLogger(), 5, &mockMetrics).Wrap(tc.handler)
+			h := newRetryMiddleware(log.NewNopLogger(), 5, tc.retryByErrorClass, &mockMetrics).Wrap(tc.handler)
 			resp, err := h.Do(context.Background(), nil)
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.resp, resp)
@@ -111,7 +217,7 @@ func Test_RetryMiddlewareCancel(t *testing.T) {
 	var try atomic.Int32
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	_, err := newRetryMiddleware(log.NewNopLogger(), 5, nil).Wrap(
+	_, err := newRetryMiddleware(log.NewNopLogger(), 5, false, nil).Wrap(
 		HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
 			try.Inc()
 			return nil, ctx.Err()
@@ -121,7 +227,7 @@ func Test_RetryMiddlewareCancel(t *testing.T) {
 	require.Equal(t, ctx.Err(), err)
 
 	ctx, cancel = context.WithCancel(context.Background())
-	_, err = newRetryMiddleware(log.NewNopLogger(), 5, nil).Wrap(
+	_, err = newRetryMiddleware(log.NewNopLogger(), 5, false, nil).Wrap(
 		HandlerFunc(func(context.Context, MetricsQueryRequest) (Response, error) {
 			try.Inc()
 			cancel()
@@ -61,6 +61,7 @@ type Config struct {
 	CacheResults                     bool          `yaml:"cache_results"`
 	CacheErrors                      bool          `yaml:"cache_errors" category:"experimental"`
 	MaxRetries                       int           `yaml:"max_retries" category:"advanced"`
+	RetryByErrorClass                bool          `yaml:"retry_by_error_class" category:"experimental"`
 	NotRunningTimeout                time.Duration `yaml:"not_running_timeout" category:"advanced"`
 	ShardedQueries                   bool          `yaml:"parallelize_shardable_queries"`
 	PrunedQueries                    bool          `yaml:"prune_queries" category:"experimental"`
@@ -87,6 +88,7 @@ type Config struct {
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxRetries, "query-frontend.max-retries-per-request", 5, "Maximum number of retries for a single request; beyond this, the downstream error is returned.")
+	f.BoolVar(&cfg.RetryByErrorClass, "query-frontend.retry-by-error-class", false, "True to decide whether to retry a failed request by the class of its error: only internal and unavailable errors are retried. When false, requests failing with a 5xx or a non-HTTP error are retried, unless the error is a Prometheus API error other than internal.")
 	f.DurationVar(&cfg.NotRunningTimeout, "query-frontend.not-running-timeout", 2*time.Second, "Maximum time to wait for the query-frontend to become ready before rejecting requests received before the frontend was ready. 0 to disable (i.e. fail immediately if a request is received while the frontend is still starting up)")
 	f.DurationVar(&cfg.SplitQueriesByInterval, "query-frontend.split-queries-by-interval", 24*time.Hour, "Split range queries by an interval and execute in parallel. You should use a multiple of 24 hours to optimize querying blocks. 0 to disable it.")
 	f.BoolVar(&cfg.CacheResults, "query-frontend.cache-results", false, "Cache query results.")
@@ -462,8 +464,8 @@ func newQueryMiddlewares(
 
 	if cfg.MaxRetries > 0 {
 		retryMiddlewareMetrics := newRetryMiddlewareMetrics(registerer)
-		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, retryMiddlewareMetrics))
-		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, retryMiddlewareMetrics))
+		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, cfg.RetryByErrorClass, retryMiddlewareMetrics))
+		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("retry", metrics), newRetryMiddleware(log, cfg.MaxRetries, cfg.RetryByErrorClass, retryMiddlewareMetrics))
 	}
 
 	if parser.EnableExperimentalFunctions && cfg.BlockPromQLExperimentalFunctions {
@@ -5,6 +5,7 @@ package querymiddleware
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/grafana/dskit/tenant"
@@ -180,3 +181,75 @@ func QueryDetailsFromContext(ctx context.Context) *QueryDetails {
 	}
 	return o.(*QueryDetails)
 }
+
+// DownstreamStats records the responses of the downstream, when the query-frontend forwards the requests to a
+// downstream URL rather than to the query-scheduler. It's safe for concurrent use, since a request may be split
+// into multiple concurrent downstream requests.
+type DownstreamStats struct {
+	mtx           sync.Mutex
+	requests      int
+	latency       time.Duration
+	responseBytes int64
+	statusCode    int
+}
+
+// Record records a downstream response, once its body was read.
+func (s *DownstreamStats) Record(statusCode int, latency time.Duration, responseBytes int64) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests++
+	s.latency += latency
+	s.responseBytes += responseBytes
+	s.statusCode = statusCode
+}
+
+// Requests returns the number of recorded downstream responses.
+func (s *DownstreamStats) Requests() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.requests
+}
+
+// Fields returns the downstream stats as log fields, or nil if no downstream response was recorded.
+// The latency and the response size are summed across the downstream requests, and the status code is the one
+// of the last response.
+func (s *DownstreamStats) Fields() []any {
+	if s == nil {
+		return nil
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.requests == 0 {
+		return nil
+	}
+	return []any{
+		"downstream_requests", s.requests,
+		"downstream_status_code", s.statusCode,
+		"downstream_latency", s.latency,
+		"downstream_response_size_bytes", s.responseBytes,
+	}
+}
+
+type downstreamStatsContextKey struct{}
+
+// ContextWithDownstreamStats returns a context with empty DownstreamStats, which can be retrieved with
+// DownstreamStatsFromContext.
+func ContextWithDownstreamStats(ctx context.Context) (*DownstreamStats, context.Context) {
+	s := &DownstreamStats{}
+	return s, context.WithValue(ctx, downstreamStatsContextKey{}, s)
+}
+
+// DownstreamStatsFromContext returns the DownstreamStats from the context, or nil if there's none.
+func DownstreamStatsFromContext(ctx context.Context) *DownstreamStats {
+	s, _ := ctx.Value(downstreamStatsContextKey{}).(*DownstreamStats)
+	return s
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const maxBodySizeFlag = "query-frontend.max-body-size"
+
+// routeBodySizeLimit is the max body size of the requests whose path matches the pattern.
+type routeBodySizeLimit struct {
+	pattern string
+	regex   *regexp.Regexp
+	limit   int64
+}
+
+// bodySizeLimit is the max body size applied to a request, and the rule it comes from.
+type bodySizeLimit struct {
+	limit int64
+	// rule is the pattern of the route rule, or empty if the global max body size applies.
+	rule string
+}
+
+// String returns the name of the rule the limit comes from.
+func (l bodySizeLimit) String() string {
+	if l.rule == "" {
+		return "-" + maxBodySizeFlag
+	}
+	return fmt.Sprintf("the route rule %q", l.rule)
+}
+
+// tooLargeError returns an error naming the limit, the rule it comes from and the request Content-Length, if err is
+// caused by a request body exceeding the limit. Otherwise, err is returned as is. The error is encoded as a Prometheus
+// API error if the client accepts JSON, so that it can be told apart from other errors, and as text otherwise.
+func (l bodySizeLimit) tooLargeError(r *http.Request, err error) error {
+	if !util.IsRequestBodyTooLarge(err) {
+		return err
+	}
+
+	msg := fmt.Sprintf("http: request body too large: the limit is %d bytes, set by %s", l.limit, l)
+	// The Content-Length is unknown if the body is sent in chunks.
+	if r.ContentLength > 0 {
+		msg = fmt.Sprintf("%s, the request Content-Length is %d bytes", msg, r.ContentLength)
+	}
+	if !acceptsJSON(r) {
+		return httpgrpc.Error(http.StatusRequestEntityTooLarge, msg)
+	}
+
+	// The error type is the class of the error, since the API error types don't have one for requests.
+	body, err := apierror.New(apierror.Type(querymiddleware.ErrorClassTooLarge), msg).EncodeJSON()
+	if err != nil {
+		return httpgrpc.Error(http.StatusRequestEntityTooLarge, msg)
+	}
+	return httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code:    http.StatusRequestEntityTooLarge,
+		Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/json"}}},
+		Body:    body,
+	})
+}
+
+// acceptsJSON returns whether the client explicitly accepts JSON responses.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(mediaRange); err == nil && mediaType == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseRouteBodySizeLimits parses the rules in the <regex>=<bytes> format. The regular expressions are matched
+// against the start of the request path, so that a plain path works as a prefix.
+func parseRouteBodySizeLimits(rules []string) ([]routeBodySizeLimit, error) {
+	limits := make([]routeBodySizeLimit, 0, len(rules))
+	for _, rule := range rules {
+		// The limit can't contain an equal sign, while the regular expression can.
+		idx := strings.LastIndex(rule, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid route max body size %q: expected <regex>=<bytes>", rule)
+		}
+		pattern := rule[:idx]
+		limit, err := strconv.ParseInt(rule[idx+1:], 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid route max body size %q: the limit must be a positive number of bytes", rule)
+		}
+		regex, err := regexp.Compile("^(?:" + pattern + ")")
+		if err != nil {
+			return nil, fmt.Errorf("invalid route max body size %q: %w", rule, err)
+		}
+		limits = append(limits, routeBodySizeLimit{pattern: pattern, regex: regex, limit: limit})
+	}
+	return limits, nil
+}
+
+// warnShadowedRouteBodySizeLimits logs a warning for each rule whose paths may be matched by an earlier rule,
+// since the first matching rule applies. The check is best effort: a rule is reported when an earlier one
+// matches the literal prefix of its pattern.
+func warnShadowedRouteBodySizeLimits(limits []routeBodySizeLimit, logger log.Logger) {
+	for j, later := range limits {
+		prefix, _ := later.regex.LiteralPrefix()
+		for _, earlier := range limits[:j] {
+			if earlier.regex.MatchString(prefix) {
+				level.Warn(logger).Log(
+					"msg", "route max body size rule overlaps with an earlier rule, which applies first to the requests matching both",
+					"rule", later.pattern,
+					"earlier_rule", earlier.pattern,
+				)
+				break
+			}
+		}
+	}
+}
+
+// bodySizeLimit returns the max body size of the requests with the given path: the limit of the first matching
+// route rule, or the global max body size.
+func (f *Handler) bodySizeLimit(path string) bodySizeLimit {
+	for _, l := range f.routeBodySizeLimits {
+		if l.regex.MatchString(path) {
+			return bodySizeLimit{limit: l.limit, rule: l.pattern}
+		}
+	}
+	return bodySizeLimit{limit: f.cfg.MaxBodySize}
+}
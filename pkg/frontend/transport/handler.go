@@ -35,6 +35,7 @@ import (
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -43,6 +44,23 @@ const (
 	ServiceTimingHeaderName   = "Server-Timing"
 	cacheControlHeader        = "Cache-Control"
 	cacheControlLogField      = "header_cache_control"
+
+	// Headers returning the query stats to clients, when enabled for the tenant.
+	QueryWallTimeHeaderName          = "X-Query-Wall-Time-Seconds"
+	QueryFetchedSeriesHeaderName     = "X-Query-Fetched-Series"
+	QueryFetchedChunkBytesHeaderName = "X-Query-Fetched-Chunk-Bytes"
+	QueryQueueTimeHeaderName         = "X-Query-Queue-Time-Seconds"
+
+	// Reasons of the cancellation of a query.
+	cancellationReasonClientDisconnected = "client_disconnected"
+	cancellationReasonDeadlineExceeded   = "deadline_exceeded"
+
+	// Reasons of the failure of a query.
+	failedQueryReasonBodyTooLarge  = "body_too_large"
+	failedQueryReasonCanceled      = "canceled"
+	failedQueryReasonDeadline      = "deadline"
+	failedQueryReasonDownstream5xx = "downstream_5xx"
+	failedQueryReasonOther         = "other"
 )
 
 var (
@@ -56,16 +74,51 @@ type HandlerConfig struct {
 	LogQueriesLongerThan     time.Duration          `yaml:"log_queries_longer_than"`
 	LogQueryRequestHeaders   flagext.StringSliceCSV `yaml:"log_query_request_headers" category:"advanced"`
 	MaxBodySize              int64                  `yaml:"max_body_size" category:"advanced"`
+	RouteMaxBodySizes        []string               `yaml:"route_max_body_sizes" category:"experimental"`
 	QueryStatsEnabled        bool                   `yaml:"query_stats_enabled" category:"advanced"`
 	ActiveSeriesWriteTimeout time.Duration          `yaml:"active_series_write_timeout" category:"experimental"`
+
+	SizeMetricsPerTenantEnabled bool `yaml:"size_metrics_per_tenant_enabled" category:"experimental"`
+
+	SlowQueryLogFormat              string                 `yaml:"slow_query_log_format" category:"experimental"`
+	SlowQueryLogParamsAllowlist     flagext.StringSliceCSV `yaml:"slow_query_log_params_allowlist" category:"experimental"`
+	SlowQueryLogParamsDenylist      flagext.StringSliceCSV `yaml:"slow_query_log_params_denylist" category:"experimental"`
+	SlowQueryLogMaxParamValueLength int                    `yaml:"slow_query_log_max_param_value_length" category:"experimental"`
+
+	// DownstreamURLEnabled is true when queries are sent to a downstream URL instead of queriers,
+	// in which case the querier stats are not available.
+	DownstreamURLEnabled bool `yaml:"-"`
 }
 
 func (cfg *HandlerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.LogQueriesLongerThan, "query-frontend.log-queries-longer-than", 0, "Log queries that are slower than the specified duration. Set to 0 to disable. Set to < 0 to enable on all queries.")
 	f.Var(&cfg.LogQueryRequestHeaders, "query-frontend.log-query-request-headers", "Comma-separated list of request header names to include in query logs. Applies to both query stats and slow queries logs.")
-	f.Int64Var(&cfg.MaxBodySize, "query-frontend.max-body-size", 10*1024*1024, "Max body size for downstream prometheus.")
+	f.Int64Var(&cfg.MaxBodySize, maxBodySizeFlag, 10*1024*1024, "Max body size for downstream prometheus.")
+	f.Var((*flagext.StringSlice)(&cfg.RouteMaxBodySizes), "query-frontend.route-max-body-sizes", "Max body size of the requests whose path matches a regular expression, in the <regex>=<bytes> format. The regular expression is matched against the start of the path. The first matching rule applies, and requests not matching any rule get -"+maxBodySizeFlag+". This flag can be used multiple times.")
 	f.BoolVar(&cfg.QueryStatsEnabled, "query-frontend.query-stats-enabled", true, "False to disable query statistics tracking. When enabled, a message with some statistics is logged for every query.")
 	f.DurationVar(&cfg.ActiveSeriesWriteTimeout, "query-frontend.active-series-write-timeout", 5*time.Minute, "Timeout for writing active series responses. 0 means the value from `-server.http-write-timeout` is used.")
+	f.BoolVar(&cfg.SizeMetricsPerTenantEnabled, "query-frontend.size-metrics-per-tenant-enabled", false, "True to track the request body and response sizes of each tenant. When false, they're tracked across all the tenants, keeping the cardinality of the metrics low.")
+	f.StringVar(&cfg.SlowQueryLogFormat, "query-frontend.slow-query-log-format", slowQueryLogFormatLogfmt, fmt.Sprintf("Format of the request parameters in the slow queries log. Supported values: %s. With logfmt, each parameter is logged in a param_<name> field. With json, the parameters are logged as a single JSON object in the %s field.", strings.Join(slowQueryLogFormats, ", "), slowQueryLogParamsField))
+	f.Var(&cfg.SlowQueryLogParamsAllowlist, "query-frontend.slow-query-log-params-allowlist", "Comma-separated list of request parameter names to include in the slow queries log. If empty, all the parameters are included.")
+	f.Var(&cfg.SlowQueryLogParamsDenylist, "query-frontend.slow-query-log-params-denylist", "Comma-separated list of request parameter names to exclude from the slow queries log. Applies after the allowlist.")
+	f.IntVar(&cfg.SlowQueryLogMaxParamValueLength, "query-frontend.slow-query-log-max-param-value-length", 0, "Max length in bytes of the request parameter values in the slow queries log. Longer values are truncated. 0 to disable.")
+}
+
+func (cfg *HandlerConfig) Validate() error {
+	if err := validateSlowQueryLogFormat(cfg.SlowQueryLogFormat); err != nil {
+		return err
+	}
+	if cfg.SlowQueryLogMaxParamValueLength < 0 {
+		return errors.New("the slow queries log max param value length must not be negative")
+	}
+	_, err := parseRouteBodySizeLimits(cfg.RouteMaxBodySizes)
+	return err
+}
+
+// Limits are the per-tenant limits used by the Handler.
+type Limits interface {
+	// QueryStatsHeadersEnabled returns whether the query stats are returned to the tenant as response headers.
+	QueryStatsHeadersEnabled(userID string) bool
 }
 
 // Handler accepts queries and forwards them to RoundTripper. It can wait on in-flight requests and log slow queries,
@@ -75,15 +128,30 @@ type Handler struct {
 	headersToLog []string
 	log          log.Logger
 	roundTripper http.RoundTripper
+	limits       Limits
 	at           *activitytracker.ActivityTracker
 
+	routeBodySizeLimits []routeBodySizeLimit
+	slowQueryParams     slowQueryParams
+
 	// Metrics.
+	failedRequests   *prometheus.CounterVec
+	failedQueries    *prometheus.CounterVec
+	cancelledQueries *prometheus.CounterVec
+
+	// Size metrics, labelled by tenant if the size metrics per tenant are enabled.
+	requestBodySize *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+
+	// Query stats metrics, only tracked when the query stats are enabled.
 	querySeconds    *prometheus.CounterVec
 	querySeries     *prometheus.CounterVec
 	queryChunkBytes *prometheus.CounterVec
 	queryChunks     *prometheus.CounterVec
 	queryIndexBytes *prometheus.CounterVec
-	activeUsers     *util.ActiveUsersCleanupService
+
+	// activeUsers cleans up the metrics of the inactive tenants. It's nil if no metric is tracked per tenant.
+	activeUsers *util.ActiveUsersCleanupService
 
 	mtx              sync.Mutex
 	inflightRequests int
@@ -92,16 +160,68 @@ type Handler struct {
 }
 
 // NewHandler creates a new frontend handler.
-func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer, at *activitytracker.ActivityTracker) *Handler {
+func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, limits Limits, log log.Logger, reg prometheus.Registerer, at *activitytracker.ActivityTracker) *Handler {
 	h := &Handler{
 		cfg:          cfg,
 		headersToLog: filterHeadersToLog(cfg.LogQueryRequestHeaders),
 		log:          log,
 		roundTripper: roundTripper,
+		limits:       limits,
 		at:           at,
+
+		slowQueryParams: newSlowQueryParams(cfg),
 	}
 	h.cond = sync.NewCond(&h.mtx)
 
+	// The rules are checked by HandlerConfig.Validate.
+	routeBodySizeLimits, err := parseRouteBodySizeLimits(cfg.RouteMaxBodySizes)
+	if err != nil {
+		level.Error(log).Log("msg", "ignoring the route max body sizes", "err", err)
+	}
+	warnShadowedRouteBodySizeLimits(routeBodySizeLimits, log)
+	h.routeBodySizeLimits = routeBodySizeLimits
+
+	h.failedRequests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_failed_requests_total",
+		Help: "Total number of requests failed by the query-frontend, by status code and error type.",
+	}, []string{"status_code", "error_type"})
+
+	h.failedQueries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_failed_queries_total",
+		Help: "Total number of queries failed by the query-frontend, by coarse reason.",
+	}, []string{"reason"})
+	// Initialise the counters, so that they're exported before the first failure.
+	for _, reason := range []string{failedQueryReasonBodyTooLarge, failedQueryReasonCanceled, failedQueryReasonDeadline, failedQueryReasonDownstream5xx, failedQueryReasonOther} {
+		h.failedQueries.WithLabelValues(reason)
+	}
+
+	var sizeLabels []string
+	if cfg.SizeMetricsPerTenantEnabled {
+		sizeLabels = []string{"user"}
+	}
+	h.requestBodySize = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_query_frontend_request_body_size_bytes",
+		Help:    "Size of the bodies of the requests received by the query-frontend.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+
+		NativeHistogramBucketFactor: 1.1,
+	}, sizeLabels)
+	h.responseSize = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_query_frontend_response_size_bytes",
+		Help:    "Size of the bodies of the responses returned by the query-frontend for the requests sent downstream.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+
+		NativeHistogramBucketFactor: 1.1,
+	}, sizeLabels)
+
+	h.cancelledQueries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_queries_cancelled_total",
+		Help: "Total number of queries cancelled before completing, because the client disconnected or the deadline of the request was exceeded.",
+	}, []string{"reason"})
+	// Initialise the counters, so that they're exported before the first cancellation.
+	h.cancelledQueries.WithLabelValues(cancellationReasonClientDisconnected)
+	h.cancelledQueries.WithLabelValues(cancellationReasonDeadlineExceeded)
+
 	if cfg.QueryStatsEnabled {
 		h.querySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_query_seconds_total",
@@ -127,15 +247,10 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 			Name: "cortex_query_fetched_index_bytes_total",
 			Help: "Number of TSDB index bytes fetched from store-gateway to execute a query.",
 		}, []string{"user"})
+	}
 
-		h.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(func(user string) {
-			h.querySeconds.DeleteLabelValues(user, "true")
-			h.querySeconds.DeleteLabelValues(user, "false")
-			h.querySeries.DeleteLabelValues(user)
-			h.queryChunkBytes.DeleteLabelValues(user)
-			h.queryChunks.DeleteLabelValues(user)
-			h.queryIndexBytes.DeleteLabelValues(user)
-		})
+	if cfg.QueryStatsEnabled || cfg.SizeMetricsPerTenantEnabled {
+		h.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(h.cleanupMetricsForUser)
 		// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
 		_ = h.activeUsers.StartAsync(context.Background())
 	}
@@ -143,6 +258,22 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 	return h
 }
 
+// cleanupMetricsForUser removes the per-tenant metrics of the user.
+func (f *Handler) cleanupMetricsForUser(user string) {
+	if f.cfg.QueryStatsEnabled {
+		f.querySeconds.DeleteLabelValues(user, "true")
+		f.querySeconds.DeleteLabelValues(user, "false")
+		f.querySeries.DeleteLabelValues(user)
+		f.queryChunkBytes.DeleteLabelValues(user)
+		f.queryChunks.DeleteLabelValues(user)
+		f.queryIndexBytes.DeleteLabelValues(user)
+	}
+	if f.cfg.SizeMetricsPerTenantEnabled {
+		f.requestBodySize.DeleteLabelValues(user)
+		f.responseSize.DeleteLabelValues(user)
+	}
+}
+
 // Stop makes f enter stopped mode and wait on in-flight requests.
 func (f *Handler) Stop() {
 	f.mtx.Lock()
@@ -183,11 +314,25 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r = r.WithContext(ctx)
 	}
 
+	// Keep track of the class of errors returned by the downstream, to log it.
+	errorClassification, ctx := querymiddleware.ContextWithErrorClassification(r.Context())
+	// Keep track of the responses of the downstream URL, if any, to log them.
+	downstreamStats, ctx := querymiddleware.ContextWithDownstreamStats(ctx)
+	r = r.WithContext(ctx)
+
 	// Ensure to close the request body reader.
 	defer func() { _ = r.Body.Close() }()
 
 	// Limit the read body size.
-	r.Body = http.MaxBytesReader(w, r.Body, f.cfg.MaxBodySize)
+	bodyLimit := f.bodySizeLimit(r.URL.Path)
+	r.Body = http.MaxBytesReader(w, r.Body, bodyLimit.limit)
+
+	// Count the bytes of the body as it's read, to track its size once the request is done.
+	if r.ContentLength != 0 {
+		body := &countingReadCloser{ReadCloser: r.Body}
+		r.Body = body
+		defer func() { f.observeSize(f.requestBodySize, r, body.size) }()
+	}
 
 	var params url.Values
 	var err error
@@ -199,7 +344,13 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		writeError(w, apierror.New(apierror.TypeBadData, err.Error()))
+		if util.IsRequestBodyTooLarge(err) {
+			err = bodyLimit.tooLargeError(r, err)
+		} else {
+			err = apierror.New(apierror.TypeBadData, err.Error())
+		}
+		statusCode := writeError(w, err)
+		f.countFailedRequest(statusCode, errorClass(errorClassification, err, statusCode))
 		return
 	}
 
@@ -224,8 +375,19 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	queryResponseTime := time.Since(startTime)
 
 	if err != nil {
+		err = bodyLimit.tooLargeError(r, err)
+		f.writeQueryStatsHeaders(r, queryResponseTime, w.Header(), queryDetails)
 		statusCode := writeError(w, err)
-		f.reportQueryStats(r, params, startTime, queryResponseTime, 0, queryDetails, statusCode, err)
+		f.countFailedRequest(statusCode, errorClass(errorClassification, err, statusCode))
+
+		// The downstream request is canceled along with the request, since it shares its context.
+		if reason := cancellationReason(r.Context()); reason != "" {
+			f.cancelledQueries.WithLabelValues(reason).Inc()
+			if f.isSlowQuery(queryResponseTime) {
+				f.reportSlowQuery(r, params, queryResponseTime, 0, queryDetails, downstreamStats, reason)
+			}
+		}
+		f.reportQueryStats(r, params, startTime, queryResponseTime, 0, queryDetails, errorClassification, downstreamStats, bodyLimit, statusCode, err)
 		return
 	}
 
@@ -247,28 +409,66 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if f.cfg.QueryStatsEnabled {
 		writeServiceTimingHeader(queryResponseTime, hs, queryDetails.QuerierStats)
 	}
+	f.writeQueryStatsHeaders(r, queryResponseTime, hs, queryDetails)
 
 	w.WriteHeader(resp.StatusCode)
 	// we don't check for copy error as there is no much we can do at this point
 	queryResponseSize, _ := io.Copy(w, resp.Body)
+	f.observeSize(f.responseSize, r, queryResponseSize)
 
-	if f.cfg.LogQueriesLongerThan > 0 && queryResponseTime > f.cfg.LogQueriesLongerThan {
-		f.reportSlowQuery(r, params, queryResponseTime, queryDetails)
+	if resp.StatusCode/100 != 2 {
+		f.countFailedRequest(resp.StatusCode, errorClass(errorClassification, nil, resp.StatusCode))
+	}
+
+	if f.isSlowQuery(queryResponseTime) {
+		f.reportSlowQuery(r, params, queryResponseTime, queryResponseSize, queryDetails, downstreamStats, "")
 	}
 	if f.cfg.QueryStatsEnabled {
-		f.reportQueryStats(r, params, startTime, queryResponseTime, queryResponseSize, queryDetails, resp.StatusCode, nil)
+		f.reportQueryStats(r, params, startTime, queryResponseTime, queryResponseSize, queryDetails, errorClassification, downstreamStats, bodyLimit, resp.StatusCode, nil)
 	}
 }
 
-// reportSlowQuery reports slow queries.
-func (f *Handler) reportSlowQuery(r *http.Request, queryString url.Values, queryResponseTime time.Duration, details *querymiddleware.QueryDetails) {
-	logMessage := append([]any{
+func (f *Handler) isSlowQuery(queryResponseTime time.Duration) bool {
+	return f.cfg.LogQueriesLongerThan > 0 && queryResponseTime > f.cfg.LogQueriesLongerThan
+}
+
+// cancellationReason returns the reason why the request was canceled, or an empty string if it wasn't.
+func cancellationReason(ctx context.Context) string {
+	switch ctx.Err() {
+	case context.Canceled:
+		return cancellationReasonClientDisconnected
+	case context.DeadlineExceeded:
+		return cancellationReasonDeadlineExceeded
+	default:
+		return ""
+	}
+}
+
+// reportSlowQuery reports slow queries. The cancellation reason is empty if the query wasn't canceled.
+func (f *Handler) reportSlowQuery(r *http.Request, queryString url.Values, queryResponseTime time.Duration, queryResponseSizeBytes int64, details *querymiddleware.QueryDetails, downstreamStats *querymiddleware.DownstreamStats, canceledReason string) {
+	logMessage := []any{
 		"msg", "slow query detected",
 		"method", r.Method,
 		"host", r.Host,
 		"path", r.URL.Path,
 		"time_taken", queryResponseTime.String(),
-	}, formatQueryString(details, queryString)...)
+		"response_size_bytes", queryResponseSizeBytes,
+	}
+
+	if canceledReason != "" {
+		logMessage = append(logMessage, "canceled_after", queryResponseTime, "cancellation_reason", canceledReason)
+	}
+
+	// The querier stats aren't available when the query stats are disabled.
+	if details != nil && details.QuerierStats != nil {
+		logMessage = append(logMessage,
+			"sharded_queries", details.QuerierStats.LoadShardedQueries(),
+			"split_queries", details.QuerierStats.LoadSplitQueries(),
+		)
+	}
+
+	logMessage = append(logMessage, downstreamStats.Fields()...)
+	logMessage = append(logMessage, f.slowQueryParams.fields(details, queryString)...)
 
 	logMessage = append(logMessage, formatRequestHeaders(&r.Header, f.headersToLog)...)
 
@@ -282,6 +482,9 @@ func (f *Handler) reportQueryStats(
 	queryResponseTime time.Duration,
 	queryResponseSizeBytes int64,
 	details *querymiddleware.QueryDetails,
+	errorClassification *querymiddleware.ErrorClassification,
+	downstreamStats *querymiddleware.DownstreamStats,
+	bodyLimit bodySizeLimit,
 	queryResponseStatusCode int,
 	queryErr error,
 ) {
@@ -332,6 +535,9 @@ func (f *Handler) reportQueryStats(
 		"estimated_series_count", stats.GetEstimatedSeriesCount(),
 		"queue_time_seconds", stats.LoadQueueTime().Seconds(),
 		"encode_time_seconds", stats.LoadEncodeTime().Seconds(),
+		"chunk_merge_duplicate_samples", stats.LoadChunkMergeDuplicateSamples(),
+		"chunk_merge_appended_batches", stats.LoadChunkMergeAppendedBatches(),
+		"chunk_merge_merged_batches", stats.LoadChunkMergeMergedBatches(),
 	}, formatQueryString(details, queryString)...)
 
 	if details != nil {
@@ -352,6 +558,14 @@ func (f *Handler) reportQueryStats(
 		)
 	}
 
+	// The downstream stats are only available when the requests are forwarded to a downstream URL.
+	logMessage = append(logMessage, downstreamStats.Fields()...)
+
+	// Log the max body size only when a route rule applies, since it's otherwise the same for all the requests.
+	if bodyLimit.rule != "" {
+		logMessage = append(logMessage, "max_body_size", bodyLimit.limit, "max_body_size_rule", bodyLimit.rule)
+	}
+
 	// Log the read consistency only when explicitly defined.
 	if consistency, ok := querierapi.ReadConsistencyLevelFromContext(r.Context()); ok {
 		logMessage = append(logMessage, "read_consistency", consistency)
@@ -374,6 +588,7 @@ func (f *Handler) reportQueryStats(
 
 		logMessage = append(logMessage,
 			"status", logStatus,
+			"error_class", errorClass(errorClassification, queryErr, queryResponseStatusCode),
 			"err", queryErr)
 	} else {
 		logMessage = append(logMessage,
@@ -383,20 +598,86 @@ func (f *Handler) reportQueryStats(
 	level.Info(util_log.WithContext(r.Context(), f.log)).Log(logMessage...)
 }
 
+// errorClass returns the class of the error of a failed request.
+func errorClass(errorClassification *querymiddleware.ErrorClassification, queryErr error, statusCode int) querymiddleware.ErrorClass {
+	// Prefer the class of the error returned by the downstream, since the error returned
+	// to the client may have been wrapped or re-encoded on the way back.
+	class := errorClassification.Class()
+	if class == querymiddleware.ErrorClassNone {
+		class = querymiddleware.ErrorClassFromError(queryErr)
+	}
+	if (class == querymiddleware.ErrorClassNone || class == querymiddleware.ErrorClassInternal) && statusCode/100 != 2 {
+		class = querymiddleware.ClassifyStatusCode(statusCode)
+	}
+	return class
+}
+
+func (f *Handler) countFailedRequest(statusCode int, class querymiddleware.ErrorClass) {
+	f.failedRequests.WithLabelValues(strconv.Itoa(statusCode), string(class)).Inc()
+	f.failedQueries.WithLabelValues(failedQueryReason(statusCode, class)).Inc()
+}
+
+// failedQueryReason returns the coarse reason of the failure of a query, from its status code and error class.
+func failedQueryReason(statusCode int, class querymiddleware.ErrorClass) string {
+	switch {
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return failedQueryReasonBodyTooLarge
+	case statusCode == StatusClientClosedRequest || class == querymiddleware.ErrorClassCanceled:
+		return failedQueryReasonCanceled
+	case statusCode == http.StatusGatewayTimeout || class == querymiddleware.ErrorClassTimeout:
+		return failedQueryReasonDeadline
+	case statusCode/100 == 5:
+		return failedQueryReasonDownstream5xx
+	default:
+		return failedQueryReasonOther
+	}
+}
+
+// observeSize observes the size in the histogram, for the tenant of the request if the size metrics per tenant
+// are enabled.
+func (f *Handler) observeSize(h *prometheus.HistogramVec, r *http.Request, size int64) {
+	if !f.cfg.SizeMetricsPerTenantEnabled {
+		h.WithLabelValues().Observe(float64(size))
+		return
+	}
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	h.WithLabelValues(userID).Observe(float64(size))
+	f.activeUsers.UpdateUserTimestamp(userID, time.Now())
+}
+
+// countingReadCloser counts the bytes read from the wrapped reader.
+type countingReadCloser struct {
+	io.ReadCloser
+	size int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.size += int64(n)
+	return n, err
+}
+
 // formatQueryString prefers printing start, end, and step from details if they are not nil.
 func formatQueryString(details *querymiddleware.QueryDetails, queryString url.Values) (fields []any) {
 	for k, v := range queryString {
-		var formattedValue string
-		if details != nil {
-			formattedValue = paramValueFromDetails(details, k)
-		}
+		fields = append(fields, fmt.Sprintf("param_%s", k), formatParamValue(details, k, v))
+	}
+	return fields
+}
 
-		if formattedValue == "" {
-			formattedValue = strings.Join(v, ",")
+// formatParamValue returns the value of the parameter from details if not nil and non-zero, otherwise the values
+// from the request joined by commas.
+func formatParamValue(details *querymiddleware.QueryDetails, paramName string, values []string) string {
+	if details != nil {
+		if v := paramValueFromDetails(details, paramName); v != "" {
+			return v
 		}
-		fields = append(fields, fmt.Sprintf("param_%s", k), formattedValue)
 	}
-	return fields
+	return strings.Join(values, ",")
 }
 
 // paramValueFromDetails returns the value of the parameter from details if the value there is non-zero.
@@ -448,7 +729,8 @@ func writeError(w http.ResponseWriter, err error) int {
 	case errors.Is(err, context.DeadlineExceeded):
 		err = errDeadlineExceeded
 	default:
-		if util.IsRequestBodyTooLarge(err) {
+		// Errors naming the limit which was exceeded are kept as they are.
+		if _, ok := httpgrpc.HTTPResponseFromError(err); !ok && util.IsRequestBodyTooLarge(err) {
 			err = errRequestEntityTooLarge
 		}
 	}
@@ -489,6 +771,30 @@ func writeServiceTimingHeader(queryResponseTime time.Duration, headers http.Head
 	}
 }
 
+// writeQueryStatsHeaders writes the query stats response headers, if enabled for the tenant. The querier stats are
+// only written if available, otherwise only the wall time measured by the query-frontend is written.
+func (f *Handler) writeQueryStatsHeaders(r *http.Request, queryResponseTime time.Duration, headers http.Header, details *querymiddleware.QueryDetails) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil || !validation.AllTrueBooleansPerTenant(tenantIDs, f.limits.QueryStatsHeadersEnabled) {
+		return
+	}
+
+	if f.cfg.DownstreamURLEnabled || details == nil || details.QuerierStats == nil {
+		headers.Set(QueryWallTimeHeaderName, formatSeconds(queryResponseTime))
+		return
+	}
+
+	stats := details.QuerierStats
+	headers.Set(QueryWallTimeHeaderName, formatSeconds(stats.LoadWallTime()))
+	headers.Set(QueryFetchedSeriesHeaderName, strconv.FormatUint(stats.LoadFetchedSeries(), 10))
+	headers.Set(QueryFetchedChunkBytesHeaderName, strconv.FormatUint(stats.LoadFetchedChunkBytes(), 10))
+	headers.Set(QueryQueueTimeHeaderName, formatSeconds(stats.LoadQueueTime()))
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
 func statsValue(name string, val interface{}) string {
 	switch v := val.(type) {
 	case time.Duration:
@@ -14,6 +14,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -31,6 +32,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,6 +41,7 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
 	"github.com/grafana/mimir/pkg/querier/api"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
 )
 
@@ -48,6 +51,14 @@ func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 	return f(r)
 }
 
+type mockLimits struct {
+	queryStatsHeadersEnabled bool
+}
+
+func (m mockLimits) QueryStatsHeadersEnabled(string) bool {
+	return m.queryStatsHeadersEnabled
+}
+
 func TestWriteError(t *testing.T) {
 	for _, test := range []struct {
 		status int
@@ -331,7 +342,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			t.Cleanup(func() { require.NoError(t, at.Close()) })
 
 			logger := &testLogger{}
-			handler := NewHandler(tt.cfg, roundTripper, logger, reg, at)
+			handler := NewHandler(tt.cfg, roundTripper, mockLimits{}, logger, reg, at)
 
 			req := tt.request()
 			req = req.WithContext(user.InjectOrgID(req.Context(), "12345"))
@@ -421,6 +432,105 @@ func TestHandler_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestHandler_QueryStatsHeaders(t *testing.T) {
+	queryStatsHeaders := []string{QueryWallTimeHeaderName, QueryFetchedSeriesHeaderName, QueryFetchedChunkBytesHeaderName, QueryQueueTimeHeaderName}
+
+	for _, tt := range []struct {
+		name            string
+		cfg             HandlerConfig
+		headersEnabled  bool
+		downstreamErr   error
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "disabled for the tenant",
+			cfg:            HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "successful response",
+			cfg:            HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024},
+			headersEnabled: true,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				QueryWallTimeHeaderName:          "1.5",
+				QueryFetchedSeriesHeaderName:     "10",
+				QueryFetchedChunkBytesHeaderName: "1024",
+				QueryQueueTimeHeaderName:         "0.25",
+			},
+		},
+		{
+			name:           "error response",
+			cfg:            HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024},
+			headersEnabled: true,
+			downstreamErr:  apierror.New(apierror.TypeExec, "execution failed"),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedHeaders: map[string]string{
+				QueryWallTimeHeaderName:          "1.5",
+				QueryFetchedSeriesHeaderName:     "10",
+				QueryFetchedChunkBytesHeaderName: "1024",
+				QueryQueueTimeHeaderName:         "0.25",
+			},
+		},
+		{
+			name:           "downstream URL",
+			cfg:            HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024, DownstreamURLEnabled: true},
+			headersEnabled: true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "query stats disabled",
+			cfg:            HandlerConfig{MaxBodySize: 1024},
+			headersEnabled: true,
+			expectedStatus: http.StatusOK,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// Mimic the stats returned by the querier.
+				stats := querier_stats.FromContext(req.Context())
+				stats.AddWallTime(1500 * time.Millisecond)
+				stats.AddFetchedSeries(10)
+				stats.AddFetchedChunkBytes(1024)
+				stats.AddQueueTime(250 * time.Millisecond)
+
+				if tt.downstreamErr != nil {
+					return nil, tt.downstreamErr
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			handler := NewHandler(tt.cfg, roundTripper, mockLimits{queryStatsHeadersEnabled: tt.headersEnabled}, log.NewNopLogger(), prometheus.NewPedanticRegistry(), nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query?query=up", nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, tt.expectedStatus, resp.Code)
+
+			switch {
+			case !tt.headersEnabled:
+				for _, name := range queryStatsHeaders {
+					require.Empty(t, resp.Header().Get(name), name)
+				}
+			case tt.expectedHeaders == nil:
+				// Only the wall time measured by the query-frontend is available.
+				wallTime, err := strconv.ParseFloat(resp.Header().Get(QueryWallTimeHeaderName), 64)
+				require.NoError(t, err)
+				require.Less(t, wallTime, 1.5)
+				for _, name := range queryStatsHeaders[1:] {
+					require.Empty(t, resp.Header().Get(name), name)
+				}
+			default:
+				for name, value := range tt.expectedHeaders {
+					require.Equal(t, value, resp.Header().Get(name), name)
+				}
+			}
+		})
+	}
+}
+
 func TestHandler_FailedRoundTrip(t *testing.T) {
 	for _, test := range []struct {
 		name                string
@@ -476,7 +586,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 			reg := prometheus.NewPedanticRegistry()
 			logs := &concurrency.SyncBuffer{}
 			logger := log.NewLogfmtLogger(logs)
-			handler := NewHandler(test.cfg, test.queryResponseFunc, logger, reg, nil)
+			handler := NewHandler(test.cfg, test.queryResponseFunc, mockLimits{}, logger, reg, nil)
 
 			ctx := user.InjectOrgID(context.Background(), "12345")
 			req := httptest.NewRequest("GET", test.path, nil)
@@ -506,6 +616,139 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 	}
 }
 
+func TestHandler_FailedQueriesAndSizeMetrics(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		cfg               HandlerConfig
+		body              string
+		queryResponseFunc roundTripperFunc
+		expectedStatus    int
+		expectedReason    string
+		expectedSizeUser  string
+	}{
+		{
+			name: "request body too large",
+			cfg:  HandlerConfig{MaxBodySize: 10, SizeMetricsPerTenantEnabled: true},
+			body: "query=some_metric_with_a_long_name&time=42",
+			queryResponseFunc: func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("the request shouldn't be sent downstream")
+			},
+			expectedStatus:   http.StatusRequestEntityTooLarge,
+			expectedReason:   failedQueryReasonBodyTooLarge,
+			expectedSizeUser: "12345",
+		},
+		{
+			name: "downstream 500",
+			cfg:  HandlerConfig{MaxBodySize: 1024},
+			body: "query=some_metric&time=42",
+			queryResponseFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedReason: failedQueryReasonDownstream5xx,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			handler := NewHandler(test.cfg, test.queryResponseFunc, mockLimits{}, log.NewNopLogger(), reg, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(test.body))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, test.expectedStatus, resp.Code)
+
+			var expected strings.Builder
+			expected.WriteString(`
+				# HELP cortex_query_frontend_failed_queries_total Total number of queries failed by the query-frontend, by coarse reason.
+				# TYPE cortex_query_frontend_failed_queries_total counter
+			`)
+			for _, reason := range []string{failedQueryReasonBodyTooLarge, failedQueryReasonCanceled, failedQueryReasonDeadline, failedQueryReasonDownstream5xx, failedQueryReasonOther} {
+				value := 0
+				if reason == test.expectedReason {
+					value = 1
+				}
+				fmt.Fprintf(&expected, "cortex_query_frontend_failed_queries_total{reason=%q} %d\n", reason, value)
+			}
+			require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(expected.String()), "cortex_query_frontend_failed_queries_total"))
+
+			// The body is tracked even if it's too large, while the response is only tracked once sent downstream.
+			metrics, err := reg.Gather()
+			require.NoError(t, err)
+			sizes := map[string]*dto.Histogram{}
+			for _, mf := range metrics {
+				for _, m := range mf.GetMetric() {
+					if strings.HasSuffix(mf.GetName(), "_size_bytes") {
+						if test.expectedSizeUser == "" {
+							require.Empty(t, m.GetLabel())
+						} else {
+							require.Len(t, m.GetLabel(), 1)
+							require.Equal(t, "user", m.GetLabel()[0].GetName())
+							require.Equal(t, test.expectedSizeUser, m.GetLabel()[0].GetValue())
+						}
+						sizes[mf.GetName()] = m.GetHistogram()
+					}
+				}
+			}
+			require.Contains(t, sizes, "cortex_query_frontend_request_body_size_bytes")
+			require.Equal(t, uint64(1), sizes["cortex_query_frontend_request_body_size_bytes"].GetSampleCount())
+			require.Positive(t, sizes["cortex_query_frontend_request_body_size_bytes"].GetSampleSum())
+			if test.expectedStatus == http.StatusRequestEntityTooLarge {
+				require.NotContains(t, sizes, "cortex_query_frontend_response_size_bytes")
+			} else {
+				require.Equal(t, uint64(1), sizes["cortex_query_frontend_response_size_bytes"].GetSampleCount())
+				require.Equal(t, float64(len("{}")), sizes["cortex_query_frontend_response_size_bytes"].GetSampleSum())
+			}
+		})
+	}
+}
+
+func TestHandler_CleanupSizeMetricsPerTenant(t *testing.T) {
+	roundTripper := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil
+	})
+	reg := prometheus.NewPedanticRegistry()
+	handler := NewHandler(HandlerConfig{MaxBodySize: 1024, SizeMetricsPerTenantEnabled: true}, roundTripper, mockLimits{}, log.NewNopLogger(), reg, nil)
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader("query=some_metric&time=42"))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(user.InjectOrgID(context.Background(), userID))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	sizeSeriesByUser := func() map[string]int {
+		metrics, err := reg.Gather()
+		require.NoError(t, err)
+		series := map[string]int{}
+		for _, mf := range metrics {
+			if !strings.HasSuffix(mf.GetName(), "_size_bytes") {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				require.Len(t, m.GetLabel(), 1)
+				series[m.GetLabel()[0].GetValue()]++
+			}
+		}
+		return series
+	}
+	require.Equal(t, map[string]int{"user-1": 2, "user-2": 2}, sizeSeriesByUser())
+
+	handler.cleanupMetricsForUser("user-1")
+	require.Equal(t, map[string]int{"user-2": 2}, sizeSeriesByUser())
+}
+
 // Test Handler.Stop.
 func TestHandler_Stop(t *testing.T) {
 	const (
@@ -527,7 +770,7 @@ func TestHandler_Stop(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 	cfg := HandlerConfig{MaxBodySize: 1024}
 	logger := &testLogger{}
-	handler := NewHandler(cfg, roundTripper, logger, reg, nil)
+	handler := NewHandler(cfg, roundTripper, mockLimits{}, logger, reg, nil)
 
 	var wg sync.WaitGroup
 	for i := 0; i < requests; i++ {
@@ -736,7 +979,7 @@ func TestHandler_LogsFormattedQueryDetails(t *testing.T) {
 			t.Cleanup(func() { require.NoError(t, at.Close()) })
 
 			logger := &testLogger{}
-			handler := NewHandler(HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024, LogQueryRequestHeaders: tt.logQueryRequestHeaders}, roundTripper, logger, reg, at)
+			handler := NewHandler(HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024, LogQueryRequestHeaders: tt.logQueryRequestHeaders}, roundTripper, mockLimits{}, logger, reg, at)
 
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/query", nil)
 			for header, value := range tt.requestAdditionalHeaders {
@@ -823,7 +1066,7 @@ func TestHandler_ActiveSeriesWriteTimeout(t *testing.T) {
 
 			handler := NewHandler(
 				HandlerConfig{ActiveSeriesWriteTimeout: activeSeriesWriteTimeout},
-				roundTripper, log.NewNopLogger(), nil, nil,
+				roundTripper, mockLimits{}, log.NewNopLogger(), nil, nil,
 			)
 
 			server := httptest.NewUnstartedServer(handler)
@@ -889,3 +1132,276 @@ func TestFormatRequestHeaders(t *testing.T) {
 
 	assert.Equal(t, expected, fields)
 }
+
+func TestHandler_RouteMaxBodySizes(t *testing.T) {
+	// The downstream reads the whole body, as when proxying it.
+	roundTripper := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	for _, tt := range []struct {
+		name               string
+		rules              []string
+		path               string
+		form               bool
+		bodySize           int
+		expectedStatusCode int
+		expectedError      string
+		expectedLog        string
+		expectedWarning    string
+	}{
+		{
+			name:               "global limit applies to requests not matching any rule",
+			rules:              []string{"/api/v1/admin/=4096"},
+			path:               "/api/v1/query",
+			form:               true,
+			bodySize:           2048,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedError:      "http: request body too large: the limit is 1024 bytes, set by -query-frontend.max-body-size",
+		},
+		{
+			name:               "route limit higher than the global one",
+			rules:              []string{"/api/v1/admin/=4096"},
+			path:               "/api/v1/admin/tsdb/delete_series",
+			bodySize:           2048,
+			expectedStatusCode: http.StatusOK,
+			expectedLog:        `max_body_size=4096 max_body_size_rule=/api/v1/admin/`,
+		},
+		{
+			name:               "route limit higher than the global one, exceeded",
+			rules:              []string{"/api/v1/admin/=4096"},
+			path:               "/api/v1/admin/tsdb/delete_series",
+			bodySize:           8192,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedError:      `http: request body too large: the limit is 4096 bytes, set by the route rule "/api/v1/admin/"`,
+		},
+		{
+			name:               "route limit lower than the global one",
+			rules:              []string{`/api/v1/query_(range|exemplars)=100`},
+			path:               "/api/v1/query_range",
+			form:               true,
+			bodySize:           512,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedError:      `http: request body too large: the limit is 100 bytes, set by the route rule "/api/v1/query_(range|exemplars)"`,
+		},
+		{
+			name:               "route limit lower than the global one, not matching",
+			rules:              []string{`/api/v1/query_(range|exemplars)=100`},
+			path:               "/api/v1/query",
+			form:               true,
+			bodySize:           512,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "regular expressions match the start of the path",
+			rules:              []string{`v1/admin/=4096`},
+			path:               "/api/v1/admin/tsdb/delete_series",
+			bodySize:           2048,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedError:      "set by -query-frontend.max-body-size",
+		},
+		{
+			name:               "first matching rule wins",
+			rules:              []string{"/api/v1/admin/=100", "/api/v1/admin/tsdb/=4096"},
+			path:               "/api/v1/admin/tsdb/delete_series",
+			bodySize:           2048,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedError:      `the limit is 100 bytes, set by the route rule "/api/v1/admin/"`,
+			expectedWarning:    `rule=/api/v1/admin/tsdb/ earlier_rule=/api/v1/admin/`,
+		},
+		{
+			name:               "more specific rule first",
+			rules:              []string{"/api/v1/admin/tsdb/=4096", "/api/v1/admin/=100"},
+			path:               "/api/v1/admin/tsdb/delete_series",
+			bodySize:           2048,
+			expectedStatusCode: http.StatusOK,
+			expectedLog:        `max_body_size=4096 max_body_size_rule=/api/v1/admin/tsdb/`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := HandlerConfig{QueryStatsEnabled: true, MaxBodySize: 1024, RouteMaxBodySizes: tt.rules, SlowQueryLogFormat: slowQueryLogFormatLogfmt}
+			require.NoError(t, cfg.Validate())
+
+			logs := &concurrency.SyncBuffer{}
+			handler := NewHandler(cfg, roundTripper, mockLimits{}, log.NewLogfmtLogger(logs), prometheus.NewPedanticRegistry(), nil)
+
+			var req *http.Request
+			if tt.form {
+				req = httptest.NewRequest("POST", tt.path, strings.NewReader(url.Values{"query": []string{strings.Repeat("a", tt.bodySize)}}.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest("POST", tt.path, bytes.NewReader(make([]byte, tt.bodySize)))
+				req.Header.Set("Content-Type", "application/octet-stream")
+			}
+			req = req.WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, tt.expectedStatusCode, resp.Code, resp.Body.String())
+			if tt.expectedError != "" {
+				require.Contains(t, resp.Body.String(), tt.expectedError)
+			}
+			if tt.expectedLog != "" {
+				require.Contains(t, logs.String(), tt.expectedLog)
+			} else {
+				require.NotContains(t, logs.String(), "max_body_size")
+			}
+			if tt.expectedWarning != "" {
+				require.Contains(t, logs.String(), "level=warn")
+				require.Contains(t, logs.String(), tt.expectedWarning)
+			} else {
+				require.NotContains(t, logs.String(), "level=warn")
+			}
+		})
+	}
+}
+
+func TestHandlerConfig_Validate_RouteMaxBodySizes(t *testing.T) {
+	for _, rule := range []string{"", "/api/v1/admin/", "=100", "/api/v1/admin/=", "/api/v1/admin/=-1", "/api/v1/admin/=10MB", "/api/v1/(admin=100"} {
+		cfg := HandlerConfig{RouteMaxBodySizes: []string{rule}}
+		require.Error(t, cfg.Validate(), rule)
+	}
+
+	// The limit is after the last equal sign, so the regular expression can have some.
+	limits, err := parseRouteBodySizeLimits([]string{"/api/v1/query[?]a=b=100"})
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/query[?]a=b", limits[0].pattern)
+	require.Equal(t, int64(100), limits[0].limit)
+}
+
+func TestHandler_SlowQueryLog(t *testing.T) {
+	const longQuery = `sum by (namespace) (rate(http_requests_total{job="api", route!~"/debug/.*"}[5m]))`
+
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if details := querymiddleware.QueryDetailsFromContext(req.Context()); details != nil {
+			details.QuerierStats.AddShardedQueries(16)
+			details.QuerierStats.AddSplitQueries(4)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	for _, tt := range []struct {
+		name              string
+		cfg               HandlerConfig
+		expectedFields    map[string]string
+		expectedMissing   []string
+		expectedJSONField string
+	}{
+		{
+			name: "logfmt with all the params",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt},
+			expectedFields: map[string]string{
+				"param_query":         longQuery,
+				"param_step":          "60",
+				"param_stats":         "all",
+				"response_size_bytes": "2",
+			},
+			expectedMissing: []string{"params", "sharded_queries", "split_queries"},
+		},
+		{
+			name: "query stats from downstream",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt, QueryStatsEnabled: true},
+			expectedFields: map[string]string{
+				"param_query":         longQuery,
+				"response_size_bytes": "2",
+				"sharded_queries":     "16",
+				"split_queries":       "4",
+			},
+		},
+		{
+			name: "allowlist",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt, SlowQueryLogParamsAllowlist: []string{"step", "unknown"}},
+			expectedFields: map[string]string{
+				"param_step": "60",
+			},
+			expectedMissing: []string{"param_query", "param_stats", "param_unknown"},
+		},
+		{
+			name: "denylist",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt, SlowQueryLogParamsDenylist: []string{"query"}},
+			expectedFields: map[string]string{
+				"param_step":  "60",
+				"param_stats": "all",
+			},
+			expectedMissing: []string{"param_query"},
+		},
+		{
+			name: "denylist applies after the allowlist",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt, SlowQueryLogParamsAllowlist: []string{"query", "step"}, SlowQueryLogParamsDenylist: []string{"query"}},
+			expectedFields: map[string]string{
+				"param_step": "60",
+			},
+			expectedMissing: []string{"param_query", "param_stats"},
+		},
+		{
+			name: "truncation",
+			cfg:  HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatLogfmt, SlowQueryLogMaxParamValueLength: 10},
+			expectedFields: map[string]string{
+				"param_query": longQuery[:10],
+				"param_step":  "60",
+				"param_stats": "all",
+			},
+		},
+		{
+			name:              "json",
+			cfg:               HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatJSON, SlowQueryLogParamsDenylist: []string{"stats"}, SlowQueryLogMaxParamValueLength: 10},
+			expectedJSONField: `{"query":"sum by (na","step":"60"}`,
+			expectedMissing:   []string{"param_query", "param_step", "param_stats"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.LogQueriesLongerThan = time.Nanosecond
+			cfg.MaxBodySize = 1024
+			require.NoError(t, cfg.Validate())
+
+			logger := &testLogger{}
+			handler := NewHandler(cfg, roundTripper, mockLimits{}, logger, prometheus.NewPedanticRegistry(), nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+url.Values{"query": {longQuery}, "step": {"60"}, "stats": {"all"}}.Encode(), nil)
+			req = req.WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var msg map[string]any
+			for _, m := range logger.logMessages {
+				if m["msg"] == "slow query detected" {
+					msg = m
+				}
+			}
+			require.NotNil(t, msg)
+
+			for field, expected := range tt.expectedFields {
+				assert.Equal(t, expected, fmt.Sprint(msg[field]), field)
+			}
+			for _, field := range tt.expectedMissing {
+				assert.NotContains(t, msg, field)
+			}
+			if tt.expectedJSONField != "" {
+				assert.JSONEq(t, tt.expectedJSONField, msg["params"].(string))
+			}
+		})
+	}
+}
+
+func TestHandlerConfig_Validate_SlowQueryLog(t *testing.T) {
+	cfg := HandlerConfig{SlowQueryLogFormat: "yaml"}
+	require.ErrorContains(t, cfg.Validate(), `invalid slow queries log format "yaml"`)
+
+	cfg = HandlerConfig{SlowQueryLogFormat: slowQueryLogFormatJSON, SlowQueryLogMaxParamValueLength: -1}
+	require.Error(t, cfg.Validate())
+}
+
+func TestTruncateParamValue(t *testing.T) {
+	require.Equal(t, "abc", truncateParamValue("abc", 0))
+	require.Equal(t, "abc", truncateParamValue("abc", 3))
+	require.Equal(t, "ab", truncateParamValue("abc", 2))
+	// Multi-byte characters aren't split.
+	require.Equal(t, "a", truncateParamValue("aé", 2))
+	require.Equal(t, "aé", truncateParamValue("aé", 3))
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware"
+)
+
+const (
+	slowQueryLogFormatLogfmt = "logfmt"
+	slowQueryLogFormatJSON   = "json"
+
+	// slowQueryLogParamsField is the field holding the request parameters, when the slow queries log format is JSON.
+	slowQueryLogParamsField = "params"
+)
+
+var slowQueryLogFormats = []string{slowQueryLogFormatLogfmt, slowQueryLogFormatJSON}
+
+// slowQueryParams selects the request parameters logged with slow queries, and formats them.
+type slowQueryParams struct {
+	format         string
+	allowed        map[string]struct{}
+	denied         map[string]struct{}
+	maxValueLength int
+}
+
+func newSlowQueryParams(cfg HandlerConfig) slowQueryParams {
+	p := slowQueryParams{
+		format:         cfg.SlowQueryLogFormat,
+		maxValueLength: cfg.SlowQueryLogMaxParamValueLength,
+	}
+	if len(cfg.SlowQueryLogParamsAllowlist) > 0 {
+		p.allowed = namesSet(cfg.SlowQueryLogParamsAllowlist)
+	}
+	if len(cfg.SlowQueryLogParamsDenylist) > 0 {
+		p.denied = namesSet(cfg.SlowQueryLogParamsDenylist)
+	}
+	return p
+}
+
+func namesSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+// included returns whether the parameter is logged: it must be in the allowlist, if any, and not in the denylist.
+func (p slowQueryParams) included(name string) bool {
+	if p.allowed != nil {
+		if _, ok := p.allowed[name]; !ok {
+			return false
+		}
+	}
+	_, denied := p.denied[name]
+	return !denied
+}
+
+// fields returns the log fields of the request parameters: a param_<name> field per parameter in the logfmt format,
+// or a single field holding a JSON object of the parameters in the JSON format.
+func (p slowQueryParams) fields(details *querymiddleware.QueryDetails, queryString url.Values) []any {
+	values := make(map[string]string, len(queryString))
+	for name, v := range queryString {
+		if !p.included(name) {
+			continue
+		}
+		values[name] = truncateParamValue(formatParamValue(details, name, v), p.maxValueLength)
+	}
+
+	if p.format == slowQueryLogFormatJSON {
+		// Marshalling a map of strings can't fail, and the keys are sorted.
+		params, _ := json.Marshal(values)
+		return []any{slowQueryLogParamsField, string(params)}
+	}
+
+	fields := make([]any, 0, 2*len(values))
+	for name, v := range values {
+		fields = append(fields, fmt.Sprintf("param_%s", name), v)
+	}
+	return fields
+}
+
+// truncateParamValue truncates the value to at most maxLength bytes, without splitting a multi-byte character.
+// A maxLength of 0 disables the truncation.
+func truncateParamValue(v string, maxLength int) string {
+	if maxLength <= 0 || len(v) <= maxLength {
+		return v
+	}
+	for maxLength > 0 && !utf8.RuneStart(v[maxLength]) {
+		maxLength--
+	}
+	return v[:maxLength]
+}
+
+func validateSlowQueryLogFormat(format string) error {
+	if !slices.Contains(slowQueryLogFormats, format) {
+		return fmt.Errorf("invalid slow queries log format %q: supported values are %s", format, strings.Join(slowQueryLogFormats, ", "))
+	}
+	return nil
+}
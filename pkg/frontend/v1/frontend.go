@@ -131,6 +131,8 @@ func New(cfg Config, limits Limits, log log.Logger, registerer prometheus.Regist
 		f.discardedRequests,
 		enqueueDuration,
 		querierInflightRequests,
+		queue.StarvationDetectorConfig{},
+		nil,
 	)
 	if err != nil {
 		return nil, err
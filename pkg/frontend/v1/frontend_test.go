@@ -342,7 +342,7 @@ func testFrontend(t *testing.T, config Config, handler http.Handler, test func(a
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(handlerCfg, rt, logger, nil, nil)))
+	).Wrap(transport.NewHandler(handlerCfg, rt, limits{}, logger, nil, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -369,9 +369,14 @@ func defaultFrontendConfig() Config {
 }
 
 type limits struct {
-	queriers int
+	queriers                 int
+	queryStatsHeadersEnabled bool
 }
 
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
+
+func (l limits) QueryStatsHeadersEnabled(string) bool {
+	return l.queryStatsHeadersEnabled
+}
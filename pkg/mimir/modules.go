@@ -765,6 +765,7 @@ func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
 		t.Cfg.Frontend,
 		t.Overrides,
 		t.Overrides,
+		t.Overrides,
 		t.Cfg.Server.GRPCListenPort,
 		util_log.Logger,
 		t.Registerer,
@@ -792,7 +793,8 @@ func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
 		roundTripper = querymiddleware.NewFrontendRunningRoundTripper(roundTripper, frontendSvc, t.Cfg.Frontend.QueryMiddleware.NotRunningTimeout, util_log.Logger)
 	}
 
-	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, t.Registerer, t.ActivityTracker)
+	t.Cfg.Frontend.Handler.DownstreamURLEnabled = t.Cfg.Frontend.DownstreamURL != ""
+	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, t.Overrides, util_log.Logger, t.Registerer, t.ActivityTracker)
 	t.API.RegisterQueryFrontendHandler(handler, t.BuildInfoHandler)
 
 	w := services.NewFailureWatcher()
@@ -1099,12 +1101,14 @@ func (t *Mimir) initBlockBuilder() (_ services.Service, err error) {
 
 func (t *Mimir) initBlockBuilderScheduler() (services.Service, error) {
 	t.Cfg.BlockBuilderScheduler.Kafka = t.Cfg.IngestStorage.KafkaConfig
+	t.Cfg.BlockBuilderScheduler.Bucket = t.Cfg.BlocksStorage.Bucket
 
 	s, err := blockbuilderscheduler.New(t.Cfg.BlockBuilderScheduler, util_log.Logger, t.Registerer)
 	if err != nil {
 		return nil, errors.Wrap(err, "block-builder-scheduler init")
 	}
 	t.BlockBuilderScheduler = s
+	t.API.RegisterBlockBuilderScheduler(s)
 	return s, nil
 }
 
@@ -12,6 +12,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 )
 
@@ -21,6 +22,9 @@ type GenericChunk struct {
 	MinTime int64
 	MaxTime int64
 
+	// rank is the position of the chunk among the merged ones, in ascending order of min time.
+	rank int32
+
 	iterator func(reuse chunk.Iterator) chunk.Iterator
 }
 
@@ -40,6 +44,7 @@ func (c GenericChunk) Iterator(reuse chunk.Iterator) chunk.Iterator {
 type iterator interface {
 	// Seek advances the iterator forward to batch containing the sample at or after the given timestamp.
 	// If the current batch contains a sample at or after the given timestamp, then Seek retains the current batch.
+	// Iterators going backward in time advance to the sample at or before the given timestamp instead.
 	//
 	// The batch's Index is advanced to point to the sample at or after the given timestamp.
 	Seek(t int64, size int) chunkenc.ValueType
@@ -58,28 +63,54 @@ type iterator interface {
 	Err() error
 }
 
-// NewChunkMergeIterator returns a chunkenc.Iterator that merges Mimir chunks together.
-func NewChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []chunk.Chunk) chunkenc.Iterator {
+// NewChunkMergeIterator returns a chunkenc.Iterator that merges Mimir chunks together with the given options.
+// The statistics of the merge are added to queryStats, and the conflicting samples to conflicts, if not nil.
+func NewChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []chunk.Chunk, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) chunkenc.Iterator {
+	converted := make([]GenericChunk, len(chunks))
+	for i, c := range chunks {
+		converted[i] = NewGenericChunk(int64(c.From), int64(c.Through), c.Data.NewIterator)
+	}
+
+	return NewGenericChunkMergeIterator(it, lbls, converted, queryStats, conflicts, opts)
+}
+
+// NewGenericChunkMergeIterator returns a chunkenc.Iterator that merges generic chunks together with the given options.
+// The statistics of the merge are added to queryStats, and the conflicting samples to conflicts, if not nil.
+// Samples conflict when they're floats with the same timestamp and different values: only one of them is kept.
+func NewGenericChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []GenericChunk, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) chunkenc.Iterator {
+	return newGenericChunkMergeIterator(it, lbls, chunks, false, queryStats, conflicts, opts)
+}
+
+// NewReverseChunkMergeIterator is like NewChunkMergeIterator, but the returned iterator goes backward in time.
+// See NewReverseGenericChunkMergeIterator.
+func NewReverseChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []chunk.Chunk, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) chunkenc.Iterator {
 	converted := make([]GenericChunk, len(chunks))
 	for i, c := range chunks {
 		converted[i] = NewGenericChunk(int64(c.From), int64(c.Through), c.Data.NewIterator)
 	}
 
-	return NewGenericChunkMergeIterator(it, lbls, converted)
+	return NewReverseGenericChunkMergeIterator(it, lbls, converted, queryStats, conflicts, opts)
+}
+
+// NewReverseGenericChunkMergeIterator returns a chunkenc.Iterator that merges generic chunks together, and goes
+// backward in time: Next moves to the preceding sample, and Seek(t) moves to the latest sample at or before t.
+// Only the chunk being read is decoded in memory, rather than the whole series. The counter reset hints of the
+// histograms are always unknown, since they're only meaningful going forward.
+func NewReverseGenericChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []GenericChunk, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) chunkenc.Iterator {
+	return newGenericChunkMergeIterator(it, lbls, chunks, true, queryStats, conflicts, opts)
 }
 
-// NewGenericChunkMergeIterator returns a chunkenc.Iterator that merges generic chunks together.
-func NewGenericChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []GenericChunk) chunkenc.Iterator {
+func newGenericChunkMergeIterator(it chunkenc.Iterator, lbls labels.Labels, chunks []GenericChunk, reverse bool, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) chunkenc.Iterator {
 	var iter *mergeIterator
 
 	adapter, ok := it.(*iteratorAdapter)
 	if ok {
-		iter = newMergeIterator(adapter.underlying, chunks)
+		iter = newMergeIterator(adapter.underlying, lbls, chunks, reverse, queryStats, conflicts, opts)
 	} else {
-		iter = newMergeIterator(nil, chunks)
+		iter = newMergeIterator(nil, lbls, chunks, reverse, queryStats, conflicts, opts)
 	}
 
-	return newIteratorAdapter(adapter, iter, lbls)
+	return newIteratorAdapter(adapter, iter, lbls, reverse)
 }
 
 // iteratorAdapter turns a batchIterator into a chunkenc.Iterator.
@@ -90,20 +121,25 @@ type iteratorAdapter struct {
 	curr       chunk.Batch
 	underlying iterator
 	labels     labels.Labels
+
+	// reverse is true if the underlying iterator goes backward in time.
+	reverse bool
 }
 
-func newIteratorAdapter(it *iteratorAdapter, underlying iterator, lbls labels.Labels) chunkenc.Iterator {
+func newIteratorAdapter(it *iteratorAdapter, underlying iterator, lbls labels.Labels, reverse bool) chunkenc.Iterator {
 	if it != nil {
 		it.batchSize = 1
 		it.underlying = underlying
 		it.curr = chunk.Batch{}
 		it.labels = lbls
+		it.reverse = reverse
 		return it
 	}
 	return &iteratorAdapter{
 		batchSize:  1,
 		underlying: underlying,
 		labels:     lbls,
+		reverse:    reverse,
 	}
 }
 
@@ -111,14 +147,14 @@ func newIteratorAdapter(it *iteratorAdapter, underlying iterator, lbls labels.La
 func (a *iteratorAdapter) Seek(t int64) chunkenc.ValueType {
 	// Optimisation: fulfill the seek using current batch if possible.
 	if a.curr.Length > 0 && a.curr.Index < a.curr.Length {
-		if t <= a.curr.Timestamps[a.curr.Index] {
+		if !a.before(a.curr.Timestamps[a.curr.Index], t) {
 			//In this case, the interface's requirement is met, so state of this
 			//iterator does not need any change.
 			return a.curr.ValueType
-		} else if t <= a.curr.Timestamps[a.curr.Length-1] {
+		} else if !a.before(a.curr.Timestamps[a.curr.Length-1], t) {
 			//In this case, some timestamp between current sample and end of batch can fulfill
 			//the seek. Let's find it.
-			for a.curr.Index < a.curr.Length && t > a.curr.Timestamps[a.curr.Index] {
+			for a.curr.Index < a.curr.Length && a.before(a.curr.Timestamps[a.curr.Index], t) {
 				a.curr.Index++
 			}
 			return a.curr.ValueType
@@ -136,6 +172,14 @@ func (a *iteratorAdapter) Seek(t int64) chunkenc.ValueType {
 	return chunkenc.ValNone
 }
 
+// before returns true if a sample at t1 comes before a sample at t2 in the iteration order.
+func (a *iteratorAdapter) before(t1, t2 int64) bool {
+	if a.reverse {
+		return t1 > t2
+	}
+	return t1 < t2
+}
+
 // Next implements chunkenc.Iterator.
 func (a *iteratorAdapter) Next() chunkenc.ValueType {
 	a.curr.Index++
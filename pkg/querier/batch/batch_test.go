@@ -48,7 +48,7 @@ func BenchmarkNewChunkMergeIterator_CreateAndIterate(b *testing.B) {
 					fh *histogram.FloatHistogram
 				)
 				for n := 0; n < b.N; n++ {
-					it = NewChunkMergeIterator(it, lbls, chunks)
+					it = NewChunkMergeIterator(it, lbls, chunks, nil, nil, MergeOptions{})
 					for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
 						switch valType {
 						case chunkenc.ValFloat:
@@ -72,12 +72,49 @@ func BenchmarkNewChunkMergeIterator_CreateAndIterate(b *testing.B) {
 	}
 }
 
+// BenchmarkNewChunkMergeIterator_OverlappingChunksPerSeries creates a new iterator for each series,
+// like queries do when the number of chunks of the series varies, so the merge buffers can't be reused.
+func BenchmarkNewChunkMergeIterator_OverlappingChunksPerSeries(b *testing.B) {
+	const numSeries = 100
+
+	for _, encoding := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk} {
+		chunks := createChunks(b, 10, 100, 3, encoding)
+
+		b.Run(fmt.Sprintf("encoding: %s", encoding), func(b *testing.B) {
+			b.ReportAllocs()
+
+			var (
+				h  *histogram.Histogram
+				fh *histogram.FloatHistogram
+			)
+			for n := 0; n < b.N; n++ {
+				for s := 0; s < numSeries; s++ {
+					it := NewChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+					for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+						switch valType {
+						case chunkenc.ValFloat:
+							it.At()
+						case chunkenc.ValHistogram:
+							_, h = it.AtHistogram(h)
+						case chunkenc.ValFloatHistogram:
+							_, fh = it.AtFloatHistogram(fh)
+						}
+					}
+					if it.Err() != nil {
+						b.Fatal(it.Err().Error())
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestSeekCorrectlyDealWithSinglePointChunks(t *testing.T) {
 	chunkOne := mkChunk(t, model.Time(1*step/time.Millisecond), 1, chunk.PrometheusXorChunk)
 	chunkTwo := mkChunk(t, model.Time(10*step/time.Millisecond), 1, chunk.PrometheusXorChunk)
 	chunks := []chunk.Chunk{chunkOne, chunkTwo}
 
-	sut := NewChunkMergeIterator(nil, labels.EmptyLabels(), chunks)
+	sut := NewChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
 
 	// Following calls mimics Prometheus's query engine behaviour for VectorSelector.
 	require.Equal(t, chunkenc.ValFloat, sut.Next())
@@ -100,3 +137,199 @@ func createChunks(b *testing.B, numChunks, numSamplesPerChunk, duplicationFactor
 
 	return result
 }
+
+func TestReverseChunkMergeIterator(t *testing.T) {
+	overlapping := func(t *testing.T, enc chunk.Encoding) []GenericChunk {
+		var chunks []GenericChunk
+		for i := int64(0); i < 5; i++ {
+			chunks = append(chunks, mkGenericChunk(t, model.TimeFromUnix(i*25), 100, enc))
+		}
+		return chunks
+	}
+	scenarios := map[string]func(t *testing.T, enc chunk.Encoding) []GenericChunk{
+		"single chunk": func(t *testing.T, enc chunk.Encoding) []GenericChunk {
+			return []GenericChunk{mkGenericChunk(t, 0, 100, enc)}
+		},
+		"non-overlapping chunks": func(t *testing.T, enc chunk.Encoding) []GenericChunk {
+			var chunks []GenericChunk
+			for i := int64(0); i < 10; i++ {
+				chunks = append(chunks, mkGenericChunk(t, model.TimeFromUnix(i*10), 10, enc))
+			}
+			return chunks
+		},
+		"overlapping chunks": overlapping,
+		"duplicated chunks": func(t *testing.T, enc chunk.Encoding) []GenericChunk {
+			return append(overlapping(t, enc), overlapping(t, enc)...)
+		},
+		"floats and histograms": func(t *testing.T, enc chunk.Encoding) []GenericChunk {
+			// At equal timestamps, histograms take precedence over floats in both directions.
+			return []GenericChunk{
+				mkGenericChunk(t, 0, 100, chunk.PrometheusXorChunk),
+				mkGenericChunk(t, model.TimeFromUnix(50), 100, enc),
+				mkGenericChunk(t, model.TimeFromUnix(120), 50, chunk.PrometheusXorChunk),
+			}
+		},
+	}
+
+	for name, chunksFn := range scenarios {
+		for _, enc := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk, chunk.PrometheusFloatHistogramChunk} {
+			t.Run(fmt.Sprintf("%s/%s", name, enc), func(t *testing.T) {
+				chunks := chunksFn(t, enc)
+
+				forward := iterateSamples(t, NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{}))
+				require.NotEmpty(t, forward)
+				expected := make([]iteratedSample, 0, len(forward))
+				for i := len(forward) - 1; i >= 0; i-- {
+					expected = append(expected, forward[i].withUnknownCounterResetHint())
+				}
+
+				it := NewReverseGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+				require.Equal(t, expected, iterateSamples(t, it))
+
+				// Reuse the iterator, in both directions.
+				it = NewGenericChunkMergeIterator(it, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+				require.Equal(t, forward, iterateSamples(t, it))
+				it = NewReverseGenericChunkMergeIterator(it, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+				require.Equal(t, expected, iterateSamples(t, it))
+			})
+		}
+	}
+}
+
+func TestReverseChunkMergeIterator_Seek(t *testing.T) {
+	for _, enc := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk, chunk.PrometheusFloatHistogramChunk} {
+		t.Run(enc.String(), func(t *testing.T) {
+			var chunks []GenericChunk
+			for i := int64(0); i < 5; i++ {
+				chunks = append(chunks, mkGenericChunk(t, model.TimeFromUnix(i*25), 100, enc))
+			}
+			const (
+				points = 200
+				stepMs = int64(step / time.Millisecond)
+			)
+
+			it := NewReverseGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+
+			// Seeking after the last sample moves to the last sample.
+			require.NotEqual(t, chunkenc.ValNone, it.Seek(1000*stepMs))
+			require.Equal(t, (points-1)*stepMs, it.AtT())
+
+			for i := points - 1; i >= 0; i -= points / 10 {
+				// Seek between samples, to check that it moves to the latest sample at or before the timestamp.
+				require.NotEqual(t, chunkenc.ValNone, it.Seek(int64(i)*stepMs+stepMs/2), i)
+				require.Equal(t, int64(i)*stepMs, it.AtT(), i)
+
+				// Seeking to a later timestamp doesn't move the iterator.
+				require.NotEqual(t, chunkenc.ValNone, it.Seek(int64(i+5)*stepMs), i)
+				require.Equal(t, int64(i)*stepMs, it.AtT(), i)
+
+				for j := i - 1; j > i-points/10 && j >= 0; j-- {
+					require.NotEqual(t, chunkenc.ValNone, it.Next(), j)
+					require.Equal(t, int64(j)*stepMs, it.AtT(), j)
+				}
+			}
+
+			require.Equal(t, chunkenc.ValNone, it.Seek(-1))
+			require.NoError(t, it.Err())
+		})
+	}
+}
+
+func BenchmarkReverseChunkMergeIterator(b *testing.B) {
+	for _, encoding := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk} {
+		chunks := createChunks(b, 100, 100, 3, encoding)
+
+		b.Run(fmt.Sprintf("encoding: %s/reverse iterator", encoding), func(b *testing.B) {
+			b.ReportAllocs()
+
+			var (
+				it chunkenc.Iterator
+				h  *histogram.Histogram
+			)
+			for n := 0; n < b.N; n++ {
+				it = NewReverseChunkMergeIterator(it, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+				for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+					if valType == chunkenc.ValFloat {
+						it.At()
+					} else {
+						_, h = it.AtHistogram(h)
+					}
+				}
+				if it.Err() != nil {
+					b.Fatal(it.Err().Error())
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("encoding: %s/buffer and reverse", encoding), func(b *testing.B) {
+			b.ReportAllocs()
+
+			var it chunkenc.Iterator
+			for n := 0; n < b.N; n++ {
+				it = NewChunkMergeIterator(it, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+
+				// The whole series is buffered, since the samples can't be read back from the iterator.
+				var (
+					ts     []int64
+					floats []float64
+					hs     []*histogram.Histogram
+				)
+				for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+					if valType == chunkenc.ValFloat {
+						t, f := it.At()
+						ts, floats = append(ts, t), append(floats, f)
+					} else {
+						t, h := it.AtHistogram(nil)
+						ts, hs = append(ts, t), append(hs, h)
+					}
+				}
+				if it.Err() != nil {
+					b.Fatal(it.Err().Error())
+				}
+				for i := len(ts) - 1; i >= 0; i-- {
+					_ = ts[i]
+				}
+			}
+		})
+	}
+}
+
+// iteratedSample is a sample read from an iterator, with copies of its histograms.
+type iteratedSample struct {
+	t  int64
+	f  float64
+	h  *histogram.Histogram
+	fh *histogram.FloatHistogram
+}
+
+// withUnknownCounterResetHint returns a copy of the sample whose histogram counter reset hint is unknown,
+// unless it's a gauge histogram.
+func (s iteratedSample) withUnknownCounterResetHint() iteratedSample {
+	if s.h != nil && s.h.CounterResetHint != histogram.GaugeType {
+		s.h = s.h.Copy()
+		s.h.CounterResetHint = histogram.UnknownCounterReset
+	}
+	if s.fh != nil && s.fh.CounterResetHint != histogram.GaugeType {
+		s.fh = s.fh.Copy()
+		s.fh.CounterResetHint = histogram.UnknownCounterReset
+	}
+	return s
+}
+
+func iterateSamples(t *testing.T, it chunkenc.Iterator) []iteratedSample {
+	var samples []iteratedSample
+	for valType := it.Next(); valType != chunkenc.ValNone; valType = it.Next() {
+		var s iteratedSample
+		switch valType {
+		case chunkenc.ValFloat:
+			s.t, s.f = it.At()
+		case chunkenc.ValHistogram:
+			s.t, s.h = it.AtHistogram(nil)
+		case chunkenc.ValFloatHistogram:
+			s.t, s.fh = it.AtFloatHistogram(nil)
+		}
+		samples = append(samples, s)
+	}
+	require.NoError(t, it.Err())
+	return samples
+}
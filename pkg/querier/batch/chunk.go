@@ -6,6 +6,9 @@
 package batch
 
 import (
+	"sort"
+	"unsafe"
+
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
@@ -23,6 +26,24 @@ type chunkIterator struct {
 
 	hPool  *zeropool.Pool[*histogram.Histogram]
 	fhPool *zeropool.Pool[*histogram.FloatHistogram]
+
+	// reverse is true if the samples are iterated in descending order of time. Chunks can only be decoded
+	// forward, so in reverse mode each chunk is decoded as a whole, and batches are taken from its end.
+	reverse bool
+	decoded decodedChunk
+}
+
+// decodedChunk holds the samples of a chunk decoded for reverse iteration, in ascending order of time.
+type decodedChunk struct {
+	done      bool
+	valueType chunkenc.ValueType
+	ts        []int64
+	values    []float64
+	pointers  []unsafe.Pointer
+
+	// remaining is the number of samples not yet returned in a batch: the samples before it, since batches
+	// are taken from the end.
+	remaining int
 }
 
 func (i *chunkIterator) reset(chunk GenericChunk) {
@@ -30,9 +51,15 @@ func (i *chunkIterator) reset(chunk GenericChunk) {
 	i.it = chunk.Iterator(i.it)
 	i.batch.Length = 0
 	i.batch.Index = 0
+	i.discardDecoded(0)
+	i.decoded.done = false
 }
 
 func (i *chunkIterator) Seek(t int64, size int) chunkenc.ValueType {
+	if i.reverse {
+		return i.seekReverse(t, size)
+	}
+
 	// We assume seeks only care about a specific window; if this chunk doesn't
 	// contain samples in that window, we can shortcut.
 	if i.chunk.MaxTime < t {
@@ -60,6 +87,10 @@ func (i *chunkIterator) Seek(t int64, size int) chunkenc.ValueType {
 }
 
 func (i *chunkIterator) Next(size int) chunkenc.ValueType {
+	if i.reverse {
+		return i.nextReverse(size)
+	}
+
 	if typ := i.it.Scan(); typ != chunkenc.ValNone {
 		i.batch = i.it.Batch(size, typ, i.hPool, i.fhPool)
 		if i.batch.Length > 0 {
@@ -80,3 +111,95 @@ func (i *chunkIterator) Batch() chunk.Batch {
 func (i *chunkIterator) Err() error {
 	return i.it.Err()
 }
+
+// seekReverse moves to the batch containing the sample at or before the given timestamp, in reverse mode.
+// The samples after it are skipped, so they're never returned, even if a later seek is to a later timestamp.
+func (i *chunkIterator) seekReverse(t int64, size int) chunkenc.ValueType {
+	if i.chunk.MinTime > t {
+		return chunkenc.ValNone
+	}
+
+	// If the current batch contains samples at or before t, it's retained.
+	if i.batch.Length > 0 && t >= i.batch.Timestamps[i.batch.Length-1] {
+		i.batch.Index = 0
+		for i.batch.Timestamps[i.batch.Index] > t {
+			i.batch.Index++
+		}
+		return i.batch.ValueType
+	}
+
+	i.decode()
+	d := &i.decoded
+	i.discardDecoded(sort.Search(d.remaining, func(j int) bool { return d.ts[j] > t }))
+	return i.nextReverse(size)
+}
+
+// nextReverse returns the batch of the samples preceding the ones already returned, in descending order of time.
+func (i *chunkIterator) nextReverse(size int) chunkenc.ValueType {
+	i.decode()
+	d := &i.decoded
+	n := min(size, d.remaining, chunk.BatchSize)
+	if n == 0 {
+		i.batch.Length = 0
+		return chunkenc.ValNone
+	}
+
+	i.batch = chunk.Batch{ValueType: d.valueType, Length: n}
+	for j := 0; j < n; j++ {
+		src := d.remaining - 1 - j
+		i.batch.Timestamps[j] = d.ts[src]
+		if d.valueType == chunkenc.ValFloat {
+			i.batch.Values[j] = d.values[src]
+		} else {
+			i.batch.PointerValues[j] = d.pointers[src]
+			d.pointers[src] = nil
+		}
+	}
+	d.remaining -= n
+	return d.valueType
+}
+
+// decode decodes all the samples of the chunk, unless it was already done since the last reset.
+// The counter reset hints of the histograms are set to unknown, because they're only meaningful
+// when going forward.
+func (i *chunkIterator) decode() {
+	d := &i.decoded
+	if d.done {
+		return
+	}
+	d.done = true
+	d.ts, d.values, d.pointers = d.ts[:0], d.values[:0], d.pointers[:0]
+
+	for typ := i.it.Scan(); typ != chunkenc.ValNone; typ = i.it.Scan() {
+		b := i.it.Batch(chunk.BatchSize, typ, i.hPool, i.fhPool)
+		d.valueType = typ
+		d.ts = append(d.ts, b.Timestamps[:b.Length]...)
+		if typ == chunkenc.ValFloat {
+			d.values = append(d.values, b.Values[:b.Length]...)
+			continue
+		}
+		for j := 0; j < b.Length; j++ {
+			resetCounterResetHint(typ, b.PointerValues[j])
+		}
+		d.pointers = append(d.pointers, b.PointerValues[:b.Length]...)
+	}
+	d.remaining = len(d.ts)
+}
+
+// discardDecoded drops the decoded samples from the given index onwards which weren't returned yet,
+// returning their histograms to the pools.
+func (i *chunkIterator) discardDecoded(from int) {
+	d := &i.decoded
+	for j := from; j < d.remaining; j++ {
+		if j >= len(d.pointers) || d.pointers[j] == nil {
+			continue
+		}
+		if d.valueType == chunkenc.ValHistogram {
+			putHistogram(i.hPool, (*histogram.Histogram)(d.pointers[j]))
+		} else if d.valueType == chunkenc.ValFloatHistogram {
+			putFloatHistogram(i.fhPool, (*histogram.FloatHistogram)(d.pointers[j]))
+		}
+		d.pointers[j] = nil
+	}
+	d.remaining = min(d.remaining, from)
+}
@@ -37,10 +37,10 @@ func testChunkIter(t *testing.T, encoding chunk.Encoding) {
 	iter := &chunkIterator{}
 
 	iter.reset(chunk)
-	testIter(t, 100, newIteratorAdapter(nil, iter, labels.EmptyLabels()), encoding)
+	testIter(t, 100, newIteratorAdapter(nil, iter, labels.EmptyLabels(), false), encoding)
 
 	iter.reset(chunk)
-	testSeek(t, 100, newIteratorAdapter(nil, iter, labels.EmptyLabels()), encoding)
+	testSeek(t, 100, newIteratorAdapter(nil, iter, labels.EmptyLabels(), false), encoding)
 }
 
 func mkChunk(t require.TestingT, from model.Time, points int, encoding chunk.Encoding) chunk.Chunk {
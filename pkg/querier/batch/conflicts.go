@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package batch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/util/annotations"
+)
+
+// maxAnnotatedConflictingSeries is the max number of series named by the annotations of MergeConflicts.
+// The conflicts of the other series are summed up in a single annotation.
+const maxAnnotatedConflictingSeries = 5
+
+type mergeConflictsContextKey int
+
+const mergeConflictsKey mergeConflictsContextKey = 0
+
+// MergeConflicts collects the float samples with equal timestamps and different values found while merging the
+// chunks of the series, of which only one value is kept, and the series whose samples were merged from chunks
+// interleaving in time. It's safe for concurrent use.
+type MergeConflicts struct {
+	mtx sync.Mutex
+
+	// named are the first conflicting series, in the order their conflicts were found.
+	named []conflictingSeries
+
+	// others are the hashes of the labels of the other conflicting series, and otherSamples their conflicting samples.
+	others       map[uint64]struct{}
+	otherSamples int
+
+	// mergedAcrossSources are the hashes of the labels of the series merged from chunks interleaving in time.
+	mergedAcrossSources map[uint64]struct{}
+}
+
+type conflictingSeries struct {
+	labels  labels.Labels
+	samples int
+}
+
+// NewMergeConflicts returns an empty MergeConflicts.
+func NewMergeConflicts() *MergeConflicts {
+	return &MergeConflicts{}
+}
+
+// ContextWithMergeConflicts returns a context carrying c, so that the queriers can collect the merge conflicts of
+// the series they return in it.
+func ContextWithMergeConflicts(ctx context.Context, c *MergeConflicts) context.Context {
+	return context.WithValue(ctx, mergeConflictsKey, c)
+}
+
+// MergeConflictsFromContext returns the MergeConflicts carried by the context, or nil if there's none.
+func MergeConflictsFromContext(ctx context.Context) *MergeConflicts {
+	c, _ := ctx.Value(mergeConflictsKey).(*MergeConflicts)
+	return c
+}
+
+// add records samples conflicting samples of the series with the given labels.
+func (c *MergeConflicts) add(lbls labels.Labels, samples int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for i := range c.named {
+		if labels.Equal(c.named[i].labels, lbls) {
+			c.named[i].samples += samples
+			return
+		}
+	}
+	if len(c.named) < maxAnnotatedConflictingSeries {
+		c.named = append(c.named, conflictingSeries{labels: lbls, samples: samples})
+		return
+	}
+
+	if c.others == nil {
+		c.others = map[uint64]struct{}{}
+	}
+	c.others[lbls.Hash()] = struct{}{}
+	c.otherSamples += samples
+}
+
+// addMergedAcrossSources records that samples of the series with the given labels were merged from chunks
+// interleaving in time.
+func (c *MergeConflicts) addMergedAcrossSources(lbls labels.Labels) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.mergedAcrossSources == nil {
+		c.mergedAcrossSources = map[uint64]struct{}{}
+	}
+	c.mergedAcrossSources[lbls.Hash()] = struct{}{}
+}
+
+// Annotations returns an info annotation for each of the first conflicting series, one for all the others, and one
+// for the series merged from chunks interleaving in time.
+func (c *MergeConflicts) Annotations() annotations.Annotations {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var res annotations.Annotations
+	for _, s := range c.named {
+		res.Add(fmt.Errorf("%w: %d conflicting samples resolved during merge for series %s", annotations.PromQLInfo, s.samples, s.labels))
+	}
+	if len(c.others) > 0 {
+		res.Add(fmt.Errorf("%w: %d conflicting samples resolved during merge for %d more series", annotations.PromQLInfo, c.otherSamples, len(c.others)))
+	}
+	if len(c.mergedAcrossSources) > 0 {
+		res.Add(fmt.Errorf("%w: samples of %d series were merged from chunks interleaving in time, functions like rate() may see counter resets between their values", annotations.PromQLInfo, len(c.mergedAcrossSources)))
+	}
+	return res
+}
@@ -10,9 +10,11 @@ import (
 	"sort"
 
 	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/util/zeropool"
 
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 )
 
@@ -27,9 +29,22 @@ type mergeIterator struct {
 	fhPool zeropool.Pool[*histogram.FloatHistogram]
 
 	currErr error
+
+	// queryStats, if not nil, collects the statistics of the merge.
+	queryStats *stats.Stats
+	// conflicts, if not nil, collects the conflicting samples of the series, whose labels are lbls.
+	conflicts *MergeConflicts
+	lbls      labels.Labels
+	// recorded is the part of the statistics of the batch stream already added to queryStats and conflicts.
+	recorded MergeStats
+	// mergedAcrossSources is true if any batch returned by Batch was merged across sources.
+	mergedAcrossSources bool
 }
 
-func newMergeIterator(it iterator, cs []GenericChunk) *mergeIterator {
+// newMergeIterator returns an iterator merging the given chunks of the series with the given labels, with the given
+// options. If reverse is true, the batches are in descending order of time, and so are the samples in each batch.
+// The statistics of the merge are added to queryStats, and the conflicting samples to conflicts, if not nil.
+func newMergeIterator(it iterator, lbls labels.Labels, cs []GenericChunk, reverse bool, queryStats *stats.Stats, conflicts *MergeConflicts, opts MergeOptions) *mergeIterator {
 	c, ok := it.(*mergeIterator)
 	if ok {
 		c.currErr = nil
@@ -45,12 +60,25 @@ func newMergeIterator(it iterator, cs []GenericChunk) *mergeIterator {
 		c.h = c.h[:0]
 		c.batches.empty()
 	} else {
+		if c.batches != nil {
+			// Return the storage of the previous stream to the pools.
+			c.batches.empty()
+		}
 		c.its = make([]*nonOverlappingIterator, len(css))
 		c.h = make(iteratorHeap, 0, len(c.its))
-		c.batches = newBatchStream(len(c.its), &c.hPool, &c.fhPool)
+		c.batches = newBatchStream(len(c.its), opts.PreferLaterChunks, &c.hPool, &c.fhPool)
 	}
+	// The stream is reused across merge iterators, which may iterate in different directions and have different options.
+	c.batches.reverse = reverse
+	c.batches.preferLaterChunks = opts.PreferLaterChunks
+	c.batches.copyOnConflict = opts.CopyHistogramsOnConflict
+	c.queryStats = queryStats
+	c.conflicts = conflicts
+	c.lbls = lbls
+	c.recorded = MergeStats{}
+	c.mergedAcrossSources = false
 	for i, cs := range css {
-		c.its[i] = newNonOverlappingIterator(c.its[i], i, cs, &c.hPool, &c.fhPool)
+		c.its[i] = newNonOverlappingIterator(c.its[i], i, cs, reverse, &c.hPool, &c.fhPool)
 	}
 
 	for _, iter := range c.its {
@@ -75,24 +103,9 @@ func (c *mergeIterator) Seek(t int64, size int) chunkenc.ValueType {
 	}
 
 	// Optimisation to see if the seek is within our current caches batches.
-found:
-	for c.batches.len() > 0 {
-		batch := c.batches.curr()
-		// The first sample in the batch can be after the seek time.
-		if batch.Timestamps[batch.Length-1] >= t {
-			batch.Index = 0
-			for batch.Index < batch.Length && batch.Timestamps[batch.Index] < t {
-				batch.Index++
-			}
-			break found
-		}
-		// The first batch is not needed anymore, so we remove it.
-		c.batches.removeFirst()
-	}
-
 	// If we didn't find anything in the current set of batches, reset the heap
 	// and seek.
-	if c.batches.len() == 0 {
+	if c.batches.seek(t) == chunkenc.ValNone {
 		c.h = c.h[:0]
 
 		for _, iter := range c.its {
@@ -136,9 +149,18 @@ func (c *mergeIterator) nextBatchEndTime() int64 {
 func (c *mergeIterator) buildNextBatch(size int) chunkenc.ValueType {
 	// All we need to do is get enough batches that our first batch's last entry
 	// is before all iterators next entry.
-	for len(c.h) > 0 && (c.batches.len() == 0 || c.nextBatchEndTime() >= c.h[0].AtTime()) {
+	merged := false
+	for len(c.h) > 0 && (c.batches.len() == 0 || !c.batches.before(c.nextBatchEndTime(), c.h[0].AtTime())) {
 		batch := c.h[0].Batch()
+		if c.batches.preferLaterChunks {
+			// The stream needs the ranks of the chunks of the samples to resolve the conflicts.
+			rank := c.h[0].rank()
+			for i := batch.Index; i < batch.Length; i++ {
+				batch.ChunkRanks[i] = rank
+			}
+		}
 		c.batches.merge(&batch, size, c.h[0].id)
+		merged = true
 
 		if c.h[0].Next(size) != chunkenc.ValNone {
 			heap.Fix(&c.h, 0)
@@ -146,6 +168,9 @@ func (c *mergeIterator) buildNextBatch(size int) chunkenc.ValueType {
 			heap.Pop(&c.h)
 		}
 	}
+	if merged {
+		c.recordStats()
+	}
 
 	if c.batches.len() > 0 {
 		return c.batches.curr().ValueType
@@ -153,12 +178,56 @@ func (c *mergeIterator) buildNextBatch(size int) chunkenc.ValueType {
 	return chunkenc.ValNone
 }
 
+// recordStats adds the statistics of the batch stream not added yet to the query stats, and the conflicting
+// samples to the merge conflicts.
+func (c *mergeIterator) recordStats() {
+	curr := c.batches.stats
+	if c.conflicts != nil {
+		if d := curr.ConflictingSamples - c.recorded.ConflictingSamples; d > 0 {
+			c.conflicts.add(c.lbls, d)
+		}
+	}
+	if c.queryStats == nil {
+		c.recorded = curr
+		return
+	}
+
+	if d := curr.DuplicateSamples - c.recorded.DuplicateSamples; d > 0 {
+		c.queryStats.AddChunkMergeDuplicateSamples(uint64(d))
+	}
+	if d := curr.AppendedBatches - c.recorded.AppendedBatches; d > 0 {
+		c.queryStats.AddChunkMergeAppendedBatches(uint64(d))
+	}
+	if d := curr.MergedBatches - c.recorded.MergedBatches; d > 0 {
+		c.queryStats.AddChunkMergeMergedBatches(uint64(d))
+	}
+	c.recorded = curr
+}
+
+// Stats returns the statistics of the merge since the iterator was created.
+func (c *mergeIterator) Stats() MergeStats {
+	return c.batches.stats
+}
+
 func (c *mergeIterator) AtTime() int64 {
 	return c.batches.curr().Timestamps[0]
 }
 
 func (c *mergeIterator) Batch() chunk.Batch {
-	return *c.batches.curr()
+	b := c.batches.curr()
+	if b.Flags&chunk.BatchMergedAcrossSources != 0 && !c.mergedAcrossSources {
+		c.mergedAcrossSources = true
+		if c.conflicts != nil {
+			c.conflicts.addMergedAcrossSources(c.lbls)
+		}
+	}
+	return *b
+}
+
+// MergedAcrossSources returns true if any batch returned so far was made of the samples of chunks which interleave
+// in time. See chunk.BatchMergedAcrossSources.
+func (c *mergeIterator) MergedAcrossSources() bool {
+	return c.mergedAcrossSources
 }
 
 func (c *mergeIterator) Err() error {
@@ -173,6 +242,9 @@ func (h *iteratorHeap) Swap(i, j int) { (*h)[i], (*h)[j] = (*h)[j], (*h)[i] }
 func (h *iteratorHeap) Less(i, j int) bool {
 	iT := (*h)[i].AtTime()
 	jT := (*h)[j].AtTime()
+	if (*h)[i].reverse {
+		return iT > jT
+	}
 	return iT < jT
 }
 
@@ -190,7 +262,11 @@ func (h *iteratorHeap) Pop() interface{} {
 
 // Build a list of lists of non-overlapping chunks.
 func partitionChunks(cs []GenericChunk) [][]GenericChunk {
-	sort.Sort(byMinTime(cs))
+	// The sort is stable so that the ranks of chunks with the same min time follow their given order.
+	sort.Stable(byMinTime(cs))
+	for i := range cs {
+		cs[i].rank = int32(i)
+	}
 
 	css := [][]GenericChunk{}
 outer:
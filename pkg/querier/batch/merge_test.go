@@ -6,15 +6,21 @@
 package batch
 
 import (
+	"fmt"
+	"slices"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/prometheus/prometheus/util/zeropool"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	"github.com/grafana/mimir/pkg/util/test"
 )
@@ -28,20 +34,45 @@ func TestMergeIter(t *testing.T) {
 			chunk4 := mkGenericChunk(t, model.TimeFromUnix(75), 100, enc)
 			chunk5 := mkGenericChunk(t, model.TimeFromUnix(100), 100, enc)
 
-			iter := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5})
+			iter := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5}, nil, nil, MergeOptions{})
 			testIter(t, 200, iter, enc, setNotCounterResetHintsAsUnknown)
-			iter = NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5})
+			iter = NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5}, nil, nil, MergeOptions{})
 			testSeek(t, 200, iter, enc, setNotCounterResetHintsAsUnknown)
 
 			// Re-use iterator.
-			iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5})
+			iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5}, nil, nil, MergeOptions{})
 			testIter(t, 200, iter, enc, setNotCounterResetHintsAsUnknown)
-			iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5})
+			iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), []GenericChunk{chunk1, chunk2, chunk3, chunk4, chunk5}, nil, nil, MergeOptions{})
 			testSeek(t, 200, iter, enc, setNotCounterResetHintsAsUnknown)
 		})
 	}
 }
 
+func TestMergeIter_ConcurrentIterators(t *testing.T) {
+	// The iterators share the pool of batches, so run them concurrently to let the race detector check it's safe.
+	for _, enc := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk, chunk.PrometheusFloatHistogramChunk} {
+		for i := 0; i < 4; i++ {
+			t.Run(fmt.Sprintf("%s/%d", enc, i), func(t *testing.T) {
+				t.Parallel()
+
+				chunks := make([]GenericChunk, 0, 8)
+				for c := 0; c < 8; c++ {
+					chunks = append(chunks, mkGenericChunk(t, model.TimeFromUnix(int64(c*25)), 100, enc))
+				}
+
+				var iter chunkenc.Iterator
+				for n := 2; n <= len(chunks); n++ {
+					// Growing the number of chunks doesn't allow the iterator to reuse its batch stream.
+					iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), chunks[:n], nil, nil, MergeOptions{})
+					testIter(t, 100+(n-1)*25, iter, enc, setNotCounterResetHintsAsUnknown)
+					iter = NewGenericChunkMergeIterator(iter, labels.EmptyLabels(), chunks[:n], nil, nil, MergeOptions{})
+					testSeek(t, 100+(n-1)*25, iter, enc, setNotCounterResetHintsAsUnknown)
+				}
+			})
+		}
+	}
+}
+
 func TestMergeHarder(t *testing.T) {
 	var (
 		numChunks = 24 * 15
@@ -57,15 +88,350 @@ func TestMergeHarder(t *testing.T) {
 				chunks = append(chunks, mkGenericChunk(t, from, samples, enc))
 				from = from.Add(time.Duration(offset) * time.Second)
 			}
-			iter := newMergeIterator(nil, chunks)
-			testIter(t, offset*numChunks+samples-offset, newIteratorAdapter(nil, iter, labels.EmptyLabels()), enc, setNotCounterResetHintsAsUnknown)
+			iter := newMergeIterator(nil, labels.EmptyLabels(), chunks, false, nil, nil, MergeOptions{})
+			testIter(t, offset*numChunks+samples-offset, newIteratorAdapter(nil, iter, labels.EmptyLabels(), false), enc, setNotCounterResetHintsAsUnknown)
 
-			iter = newMergeIterator(nil, chunks)
-			testSeek(t, offset*numChunks+samples-offset, newIteratorAdapter(nil, iter, labels.EmptyLabels()), enc, setNotCounterResetHintsAsUnknown)
+			iter = newMergeIterator(nil, labels.EmptyLabels(), chunks, false, nil, nil, MergeOptions{})
+			testSeek(t, offset*numChunks+samples-offset, newIteratorAdapter(nil, iter, labels.EmptyLabels(), false), enc, setNotCounterResetHintsAsUnknown)
 		})
 	}
 }
 
+func TestMergeIter_Stats(t *testing.T) {
+	for _, enc := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk, chunk.PrometheusFloatHistogramChunk} {
+		t.Run(enc.String(), func(t *testing.T) {
+			iterate := func(t *testing.T, chunks []GenericChunk, expectedSamples int) (MergeStats, *stats.Stats) {
+				queryStats := &stats.Stats{}
+				it := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, queryStats, nil, MergeOptions{})
+				require.Len(t, iterateSamples(t, it), expectedSamples)
+				return it.(*iteratorAdapter).underlying.(*mergeIterator).Stats(), queryStats
+			}
+
+			t.Run("disjoint chunks", func(t *testing.T) {
+				chunks := []GenericChunk{
+					mkGenericChunk(t, 0, 100, enc),
+					mkGenericChunk(t, model.TimeFromUnix(100), 100, enc),
+				}
+				mergeStats, queryStats := iterate(t, chunks, 200)
+
+				require.Zero(t, mergeStats.DuplicateSamples)
+				require.Zero(t, mergeStats.MergedBatches)
+				require.Positive(t, mergeStats.AppendedBatches)
+				require.Equal(t, uint64(0), queryStats.LoadChunkMergeDuplicateSamples())
+				require.Equal(t, uint64(0), queryStats.LoadChunkMergeMergedBatches())
+				require.Equal(t, uint64(mergeStats.AppendedBatches), queryStats.LoadChunkMergeAppendedBatches())
+			})
+
+			t.Run("overlapping chunks", func(t *testing.T) {
+				chunks := []GenericChunk{
+					mkGenericChunk(t, 0, 100, enc),
+					mkGenericChunk(t, model.TimeFromUnix(50), 100, enc),
+				}
+				mergeStats, queryStats := iterate(t, chunks, 150)
+
+				require.Equal(t, 50, mergeStats.DuplicateSamples)
+				require.Positive(t, mergeStats.MergedBatches)
+				require.Equal(t, uint64(50), queryStats.LoadChunkMergeDuplicateSamples())
+				require.Equal(t, uint64(mergeStats.MergedBatches), queryStats.LoadChunkMergeMergedBatches())
+				require.Equal(t, uint64(mergeStats.AppendedBatches), queryStats.LoadChunkMergeAppendedBatches())
+			})
+
+			t.Run("duplicated chunks", func(t *testing.T) {
+				chunks := []GenericChunk{
+					mkGenericChunk(t, 0, 100, enc),
+					mkGenericChunk(t, 0, 100, enc),
+				}
+				mergeStats, queryStats := iterate(t, chunks, 100)
+
+				require.Equal(t, 100, mergeStats.DuplicateSamples)
+				require.Equal(t, uint64(100), queryStats.LoadChunkMergeDuplicateSamples())
+			})
+
+			t.Run("reused iterator", func(t *testing.T) {
+				chunks := []GenericChunk{
+					mkGenericChunk(t, 0, 100, enc),
+					mkGenericChunk(t, model.TimeFromUnix(50), 100, enc),
+				}
+				queryStats := &stats.Stats{}
+				it := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, queryStats, nil, MergeOptions{})
+				require.Len(t, iterateSamples(t, it), 150)
+
+				// The statistics of the previous merge aren't carried over.
+				it = NewGenericChunkMergeIterator(it, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{})
+				require.Len(t, iterateSamples(t, it), 150)
+				require.Equal(t, 50, it.(*iteratorAdapter).underlying.(*mergeIterator).Stats().DuplicateSamples)
+				require.Equal(t, uint64(50), queryStats.LoadChunkMergeDuplicateSamples())
+			})
+		})
+	}
+}
+
+func TestMergeIter_Conflicts(t *testing.T) {
+	// mkFloatChunk returns a chunk of float samples whose values are their timestamp plus the offset.
+	mkFloatChunk := func(t *testing.T, from model.Time, points int, offset float64) GenericChunk {
+		pc, err := chunk.NewForEncoding(chunk.PrometheusXorChunk)
+		require.NoError(t, err)
+		ts := from
+		for i := 0; i < points; i++ {
+			npc, err := pc.Add(model.SamplePair{Timestamp: ts, Value: model.SampleValue(float64(ts) + offset)})
+			require.NoError(t, err)
+			require.Nil(t, npc)
+			ts = ts.Add(step)
+		}
+		return NewGenericChunk(int64(from), int64(ts.Add(-step)), pc.NewIterator)
+	}
+	series1 := labels.FromStrings(model.MetricNameLabel, "foo", "series", "1")
+	series2 := labels.FromStrings(model.MetricNameLabel, "foo", "series", "2")
+
+	t.Run("conflicting float samples", func(t *testing.T) {
+		conflicts := NewMergeConflicts()
+		chunks := []GenericChunk{
+			mkFloatChunk(t, 0, 100, 0),
+			mkFloatChunk(t, model.TimeFromUnix(50), 100, 0.5),
+		}
+		it := NewGenericChunkMergeIterator(nil, series1, chunks, nil, conflicts, MergeOptions{})
+		samples := iterateSamples(t, it)
+		require.Len(t, samples, 150)
+		// One of the conflicting values is kept.
+		require.Contains(t, []float64{float64(model.TimeFromUnix(60)), float64(model.TimeFromUnix(60)) + 0.5}, samples[60].f)
+		require.Equal(t, float64(model.TimeFromUnix(120))+0.5, samples[120].f)
+
+		mergeStats := it.(*iteratorAdapter).underlying.(*mergeIterator).Stats()
+		require.Equal(t, 50, mergeStats.DuplicateSamples)
+		require.Equal(t, 50, mergeStats.ConflictingSamples)
+
+		// The conflicts of the series are summed up across iterators.
+		it = NewGenericChunkMergeIterator(it, series2, chunks[:1], nil, conflicts, MergeOptions{})
+		require.Len(t, iterateSamples(t, it), 100)
+		it = NewGenericChunkMergeIterator(it, series1, []GenericChunk{mkFloatChunk(t, 0, 10, 0), mkFloatChunk(t, 0, 10, 1)}, nil, conflicts, MergeOptions{})
+		require.Len(t, iterateSamples(t, it), 10)
+
+		require.Equal(t, []string{
+			`PromQL info: 60 conflicting samples resolved during merge for series {__name__="foo", series="1"}`,
+		}, annotationStrings(conflicts.Annotations()))
+	})
+
+	t.Run("exact duplicates don't conflict", func(t *testing.T) {
+		for _, enc := range []chunk.Encoding{chunk.PrometheusXorChunk, chunk.PrometheusHistogramChunk, chunk.PrometheusFloatHistogramChunk} {
+			conflicts := NewMergeConflicts()
+			chunks := []GenericChunk{
+				mkGenericChunk(t, 0, 100, enc),
+				mkGenericChunk(t, 0, 100, enc),
+			}
+			it := NewGenericChunkMergeIterator(nil, series1, chunks, nil, conflicts, MergeOptions{})
+			require.Len(t, iterateSamples(t, it), 100)
+
+			mergeStats := it.(*iteratorAdapter).underlying.(*mergeIterator).Stats()
+			require.Equal(t, 100, mergeStats.DuplicateSamples, enc.String())
+			require.Zero(t, mergeStats.ConflictingSamples, enc.String())
+			require.Empty(t, conflicts.Annotations(), enc.String())
+		}
+	})
+}
+
+func TestMergeIter_PreferLaterChunks(t *testing.T) {
+	isHistogram := func(s iteratedSample) bool { return s.h != nil || s.fh != nil }
+
+	for _, tc := range []struct {
+		earlier, later chunk.Encoding
+	}{
+		{earlier: chunk.PrometheusXorChunk, later: chunk.PrometheusHistogramChunk},
+		{earlier: chunk.PrometheusHistogramChunk, later: chunk.PrometheusXorChunk},
+		{earlier: chunk.PrometheusXorChunk, later: chunk.PrometheusFloatHistogramChunk},
+		{earlier: chunk.PrometheusFloatHistogramChunk, later: chunk.PrometheusXorChunk},
+		{earlier: chunk.PrometheusHistogramChunk, later: chunk.PrometheusFloatHistogramChunk},
+		{earlier: chunk.PrometheusFloatHistogramChunk, later: chunk.PrometheusHistogramChunk},
+	} {
+		for _, preferLater := range []bool{false, true} {
+			for _, laterFirst := range []bool{false, true} {
+				t.Run(fmt.Sprintf("earlier=%s, later=%s, prefer later=%t, later given first=%t", tc.earlier, tc.later, preferLater, laterFirst), func(t *testing.T) {
+					// The later chunk starts in the middle of the earlier one. The order the chunks are given in doesn't matter.
+					chunks := []GenericChunk{
+						mkGenericChunk(t, 0, 100, tc.earlier),
+						mkGenericChunk(t, model.TimeFromUnix(50), 100, tc.later),
+					}
+					if laterFirst {
+						chunks[0], chunks[1] = chunks[1], chunks[0]
+					}
+					it := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), chunks, nil, nil, MergeOptions{PreferLaterChunks: preferLater})
+					samples := iterateSamples(t, it)
+					require.Len(t, samples, 150)
+
+					expectLater := preferLater
+					if !preferLater {
+						if isHistogram(samples[0]) == isHistogram(samples[100]) {
+							// Between samples of the same kind, the one merged first is kept, which depends on the batches.
+							return
+						}
+						// Histograms take precedence over floats.
+						expectLater = isHistogram(samples[100])
+					}
+					for _, s := range samples[50:100] {
+						if expectLater {
+							require.Equal(t, isHistogram(samples[100]), isHistogram(s))
+							require.Equal(t, s.fh != nil, samples[100].fh != nil)
+						} else {
+							require.Equal(t, isHistogram(samples[0]), isHistogram(s))
+							require.Equal(t, s.fh != nil, samples[0].fh != nil)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// aliasingIterator returns the same histograms for the same timestamps as the other aliasingIterators sharing
+// its histograms, like the same chunk returned by several sources whose histograms come from the same pool.
+type aliasingIterator struct {
+	chunk.Iterator
+	histograms map[int64]unsafe.Pointer
+}
+
+func (it aliasingIterator) Batch(size int, valueType chunkenc.ValueType, _ *zeropool.Pool[*histogram.Histogram], _ *zeropool.Pool[*histogram.FloatHistogram]) chunk.Batch {
+	batch := it.Iterator.Batch(size, valueType, nil, nil)
+	for i := 0; i < batch.Length && valueType == chunkenc.ValHistogram; i++ {
+		if p, ok := it.histograms[batch.Timestamps[i]]; ok {
+			batch.PointerValues[i] = p
+		} else {
+			it.histograms[batch.Timestamps[i]] = batch.PointerValues[i]
+		}
+	}
+	return batch
+}
+
+func TestMergeIter_CopyHistogramsOnConflict(t *testing.T) {
+	const points = 100
+	expected := iterateSamples(t, NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{mkGenericChunk(t, 0, points, chunk.PrometheusHistogramChunk)}, nil, nil, MergeOptions{}))
+
+	for _, copyOnConflict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy on conflict=%t", copyOnConflict), func(t *testing.T) {
+			ck := mkChunk(t, 0, points, chunk.PrometheusHistogramChunk)
+			histograms := map[int64]unsafe.Pointer{}
+			mkAliasingChunk := func() GenericChunk {
+				return NewGenericChunk(int64(ck.From), int64(ck.Through), func(chunk.Iterator) chunk.Iterator {
+					return aliasingIterator{Iterator: ck.Data.NewIterator(nil), histograms: histograms}
+				})
+			}
+
+			it := newMergeIterator(nil, labels.EmptyLabels(), []GenericChunk{mkAliasingChunk(), mkAliasingChunk()}, false, nil, nil, MergeOptions{CopyHistogramsOnConflict: copyOnConflict})
+			var shared, samples int
+			for it.Next(chunk.BatchSize) != chunkenc.ValNone {
+				batch := it.Batch()
+				for i := batch.Index; i < batch.Length; i++ {
+					if batch.PointerValues[i] == histograms[batch.Timestamps[i]] {
+						shared++
+					}
+					// The counter reset hints depend on the chunk the sample is taken from.
+					actual := iteratedSample{t: batch.Timestamps[i], h: (*histogram.Histogram)(batch.PointerValues[i])}
+					require.Equal(t, expected[samples].withUnknownCounterResetHint(), actual.withUnknownCounterResetHint())
+					samples++
+				}
+			}
+			require.NoError(t, it.Err())
+			require.Equal(t, points, samples)
+			require.Equal(t, int64(points), int64(it.Stats().DuplicateSamples))
+
+			// The merged batches only hold the histograms shared by the chunks if they aren't copied.
+			if copyOnConflict {
+				require.Zero(t, shared)
+			} else {
+				require.Equal(t, points, shared)
+			}
+		})
+	}
+}
+
+func TestMergeIter_MergedAcrossSources(t *testing.T) {
+	// mkChunk returns a chunk of float samples every interval, whose values are their timestamp.
+	mkChunk := func(t *testing.T, from model.Time, points int, interval time.Duration) GenericChunk {
+		pc, err := chunk.NewForEncoding(chunk.PrometheusXorChunk)
+		require.NoError(t, err)
+		ts := from
+		for i := 0; i < points; i++ {
+			npc, err := pc.Add(model.SamplePair{Timestamp: ts, Value: model.SampleValue(ts)})
+			require.NoError(t, err)
+			require.Nil(t, npc)
+			ts = ts.Add(interval)
+		}
+		return NewGenericChunk(int64(from), int64(ts.Add(-interval)), pc.NewIterator)
+	}
+	series := labels.FromStrings(model.MetricNameLabel, "foo")
+
+	for name, tc := range map[string]struct {
+		chunks          []GenericChunk
+		expectedSamples int
+		expectedMerged  bool
+	}{
+		"disjoint chunks": {
+			chunks:          []GenericChunk{mkChunk(t, 0, 100, step), mkChunk(t, model.TimeFromUnix(100), 100, step)},
+			expectedSamples: 200,
+		},
+		"overlapping chunks with the same samples": {
+			chunks:          []GenericChunk{mkChunk(t, 0, 100, step), mkChunk(t, model.TimeFromUnix(50), 100, step)},
+			expectedSamples: 150,
+		},
+		"interleaving chunks": {
+			chunks:          []GenericChunk{mkChunk(t, 0, 100, 2*step), mkChunk(t, model.TimeFromUnix(1), 100, 2*step)},
+			expectedSamples: 200,
+			expectedMerged:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conflicts := NewMergeConflicts()
+			it := NewGenericChunkMergeIterator(nil, series, tc.chunks, nil, conflicts, MergeOptions{})
+			require.Len(t, iterateSamples(t, it), tc.expectedSamples)
+			require.Equal(t, tc.expectedMerged, it.(*iteratorAdapter).underlying.(*mergeIterator).MergedAcrossSources())
+
+			if !tc.expectedMerged {
+				require.Empty(t, conflicts.Annotations())
+				return
+			}
+			require.Equal(t, []string{
+				`PromQL info: samples of 1 series were merged from chunks interleaving in time, functions like rate() may see counter resets between their values`,
+			}, annotationStrings(conflicts.Annotations()))
+
+			// The flag isn't carried over when the iterator is reused.
+			it = NewGenericChunkMergeIterator(it, series, tc.chunks[:1], nil, conflicts, MergeOptions{})
+			require.Len(t, iterateSamples(t, it), tc.expectedSamples/2)
+			require.False(t, it.(*iteratorAdapter).underlying.(*mergeIterator).MergedAcrossSources())
+		})
+	}
+}
+
+func TestMergeConflicts_Annotations(t *testing.T) {
+	conflicts := NewMergeConflicts()
+	require.Empty(t, conflicts.Annotations())
+
+	for i := 0; i < maxAnnotatedConflictingSeries+2; i++ {
+		conflicts.add(labels.FromStrings("series", fmt.Sprint(i)), i+1)
+	}
+	conflicts.add(labels.FromStrings("series", "0"), 10)
+	conflicts.add(labels.FromStrings("series", "6"), 10)
+	conflicts.addMergedAcrossSources(labels.FromStrings("series", "0"))
+	conflicts.addMergedAcrossSources(labels.FromStrings("series", "7"))
+	conflicts.addMergedAcrossSources(labels.FromStrings("series", "7"))
+
+	require.Equal(t, []string{
+		`PromQL info: 11 conflicting samples resolved during merge for series {series="0"}`,
+		`PromQL info: 2 conflicting samples resolved during merge for series {series="1"}`,
+		`PromQL info: 23 conflicting samples resolved during merge for 2 more series`,
+		`PromQL info: 3 conflicting samples resolved during merge for series {series="2"}`,
+		`PromQL info: 4 conflicting samples resolved during merge for series {series="3"}`,
+		`PromQL info: 5 conflicting samples resolved during merge for series {series="4"}`,
+		`PromQL info: samples of 2 series were merged from chunks interleaving in time, functions like rate() may see counter resets between their values`,
+	}, annotationStrings(conflicts.Annotations()))
+}
+
+func annotationStrings(annos annotations.Annotations) []string {
+	res := make([]string, 0, len(annos))
+	for s := range annos {
+		res = append(res, s)
+	}
+	slices.Sort(res)
+	return res
+}
+
 type checkHintTestSample struct {
 	t       int64
 	v       int
@@ -271,7 +637,7 @@ func TestMergeHistogramCheckHints(t *testing.T) {
 				},
 			} {
 				t.Run(tc.name, func(t *testing.T) {
-					iter := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), tc.chunks)
+					iter := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), tc.chunks, nil, nil, MergeOptions{})
 					for i, s := range tc.expectedSamples {
 						valType := iter.Next()
 						require.NotEqual(t, chunkenc.ValNone, valType, "expectedSamples has extra samples")
@@ -331,7 +697,7 @@ func TestMergeIteratorSeek(t *testing.T) {
 		}))
 	}
 
-	c3It := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), genericChunks)
+	c3It := NewGenericChunkMergeIterator(nil, labels.EmptyLabels(), genericChunks, nil, nil, MergeOptions{})
 
 	c3It.Seek(15)
 	// These Next() calls are necessary to reproduce the bug.
@@ -19,20 +19,27 @@ type nonOverlappingIterator struct {
 	iter   chunkIterator
 	// id is used to detect when the iterator has changed when merging
 	id int
+	// reverse is true if the chunks are iterated from the last one, in descending order of time.
+	reverse bool
 }
 
 // newNonOverlappingIterator returns a single iterator over a slice of sorted,
 // non-overlapping iterators.
-func newNonOverlappingIterator(it *nonOverlappingIterator, id int, chunks []GenericChunk, hPool *zeropool.Pool[*histogram.Histogram], fhPool *zeropool.Pool[*histogram.FloatHistogram]) *nonOverlappingIterator {
+func newNonOverlappingIterator(it *nonOverlappingIterator, id int, chunks []GenericChunk, reverse bool, hPool *zeropool.Pool[*histogram.Histogram], fhPool *zeropool.Pool[*histogram.FloatHistogram]) *nonOverlappingIterator {
 	if it == nil {
 		it = &nonOverlappingIterator{}
 	}
 	it.id = id
 	it.chunks = chunks
+	it.reverse = reverse
 	it.curr = 0
+	if reverse {
+		it.curr = len(chunks) - 1
+	}
 	it.iter.hPool = hPool
 	it.iter.fhPool = fhPool
-	it.iter.reset(it.chunks[0])
+	it.iter.reverse = reverse
+	it.iter.reset(it.chunks[it.curr])
 	return it
 }
 
@@ -61,11 +68,19 @@ func (it *nonOverlappingIterator) Next(size int) chunkenc.ValueType {
 }
 
 func (it *nonOverlappingIterator) next() bool {
-	it.curr++
-	if it.curr < len(it.chunks) {
+	if it.reverse {
+		it.curr--
+	} else {
+		it.curr++
+	}
+	if it.valid() {
 		it.iter.reset(it.chunks[it.curr])
 	}
-	return it.curr < len(it.chunks)
+	return it.valid()
+}
+
+func (it *nonOverlappingIterator) valid() bool {
+	return it.curr >= 0 && it.curr < len(it.chunks)
 }
 
 func (it *nonOverlappingIterator) AtTime() int64 {
@@ -76,8 +91,13 @@ func (it *nonOverlappingIterator) Batch() chunk.Batch {
 	return it.iter.Batch()
 }
 
+// rank returns the rank of the current chunk among the merged ones.
+func (it *nonOverlappingIterator) rank() int32 {
+	return it.chunks[it.curr].rank
+}
+
 func (it *nonOverlappingIterator) Err() error {
-	if it.curr < len(it.chunks) {
+	if it.valid() {
 		return it.iter.Err()
 	}
 	return nil
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package batch
+
+import "context"
+
+type mergeOptionsContextKey int
+
+const mergeOptionsKey mergeOptionsContextKey = 0
+
+// MergeOptions are the options of the merge of the chunks of a series.
+type MergeOptions struct {
+	// PreferLaterChunks makes the sample of the chunk with the later min time take precedence over the samples with
+	// the same timestamp of the other chunks, whatever their value types. Chunks with the same min time are ordered
+	// as given. By default, a native histogram takes precedence over a float, and between samples of the same kind
+	// the one merged first is kept.
+	PreferLaterChunks bool
+
+	// CopyHistogramsOnConflict makes the histogram kept for samples with equal timestamps a copy taken from the pools,
+	// rather than the one of its chunk, and keeps the discarded histogram out of the pools. This avoids sharing
+	// the histograms between the merged batches when the chunks may decode into the same histograms.
+	CopyHistogramsOnConflict bool
+}
+
+// ContextWithMergeOptions returns a context carrying opts, so that the queriers merge the chunks of the series
+// they return with them.
+func ContextWithMergeOptions(ctx context.Context, opts MergeOptions) context.Context {
+	return context.WithValue(ctx, mergeOptionsKey, opts)
+}
+
+// MergeOptionsFromContext returns the MergeOptions carried by the context, or the default ones if there are none.
+func MergeOptionsFromContext(ctx context.Context) MergeOptions {
+	opts, _ := ctx.Value(mergeOptionsKey).(MergeOptions)
+	return opts
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package batch
+
+import (
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/util/zeropool"
+)
+
+// putHistogram returns h to the pool, if any. Builds with the batchpoolcheck tag panic if h is already in the pool.
+func putHistogram(pool *zeropool.Pool[*histogram.Histogram], h *histogram.Histogram) {
+	if pool == nil {
+		return
+	}
+	checkHistogramPut(h)
+	pool.Put(h)
+}
+
+// putFloatHistogram returns fh to the pool, if any. Builds with the batchpoolcheck tag panic if fh is already in the pool.
+func putFloatHistogram(pool *zeropool.Pool[*histogram.FloatHistogram], fh *histogram.FloatHistogram) {
+	if pool == nil {
+		return
+	}
+	checkFloatHistogramPut(fh)
+	pool.Put(fh)
+}
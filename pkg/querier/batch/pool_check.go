@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build batchpoolcheck
+
+package batch
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/histogram"
+)
+
+// pooledSchema marks the histograms in the pools. Histograms taken from the pools are always overwritten before
+// being put back, which clears the mark, so a histogram put with the mark is put twice.
+const pooledSchema = math.MinInt32
+
+// pooledSchemas keeps the schema of the histograms put into the pools, so that tests can check their content.
+var pooledSchemas sync.Map
+
+func checkHistogramPut(h *histogram.Histogram) {
+	if h.Schema == pooledSchema {
+		panic(fmt.Sprintf("histogram %p put twice into the pool", h))
+	}
+	pooledSchemas.Store(h, h.Schema)
+	h.Schema = pooledSchema
+}
+
+func checkFloatHistogramPut(fh *histogram.FloatHistogram) {
+	if fh.Schema == pooledSchema {
+		panic(fmt.Sprintf("float histogram %p put twice into the pool", fh))
+	}
+	pooledSchemas.Store(fh, fh.Schema)
+	fh.Schema = pooledSchema
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build batchpoolcheck
+
+package batch
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/util/zeropool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestPutHistogram_PanicsOnDoublePut(t *testing.T) {
+	hPool := zeropool.New(func() *histogram.Histogram { return &histogram.Histogram{} })
+	fhPool := zeropool.New(func() *histogram.FloatHistogram { return &histogram.FloatHistogram{} })
+
+	h := test.GenerateTestHistogram(1)
+	putHistogram(&hPool, h)
+	require.Panics(t, func() { putHistogram(&hPool, h) })
+
+	fh := test.GenerateTestFloatHistogram(1)
+	putFloatHistogram(&fhPool, fh)
+	require.Panics(t, func() { putFloatHistogram(&fhPool, fh) })
+
+	// A histogram taken from the pool and overwritten can be put again.
+	h = test.GenerateTestHistogram(2)
+	putHistogram(&hPool, h)
+	test.GenerateTestHistogram(3).CopyTo(h)
+	require.NotPanics(t, func() { putHistogram(&hPool, h) })
+}
+
+// unmarkHistogram restores the schema of a histogram taken from the pool, so that its content can be checked.
+func unmarkHistogram(h *histogram.Histogram) {
+	if schema, ok := pooledSchemas.LoadAndDelete(h); ok {
+		h.Schema = schema.(int32)
+	}
+}
+
+// unmarkFloatHistogram restores the schema of a float histogram taken from the pool, so that its content can be checked.
+func unmarkFloatHistogram(fh *histogram.FloatHistogram) {
+	if schema, ok := pooledSchemas.LoadAndDelete(fh); ok {
+		fh.Schema = schema.(int32)
+	}
+}
@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build !batchpoolcheck
+
+package batch
+
+import (
+	"github.com/prometheus/prometheus/model/histogram"
+)
+
+func checkHistogramPut(*histogram.Histogram) {}
+
+func checkFloatHistogramPut(*histogram.FloatHistogram) {}
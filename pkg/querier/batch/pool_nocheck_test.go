@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build !batchpoolcheck
+
+package batch
+
+import "github.com/prometheus/prometheus/model/histogram"
+
+// unmarkHistogram is a no-op, since the histograms put into the pools are only marked with the batchpoolcheck tag.
+func unmarkHistogram(*histogram.Histogram) {}
+
+// unmarkFloatHistogram is a no-op, since the float histograms put into the pools are only marked with the batchpoolcheck tag.
+func unmarkFloatHistogram(*histogram.FloatHistogram) {}
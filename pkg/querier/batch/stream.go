@@ -6,13 +6,60 @@
 package batch
 
 import (
+	"math"
+	"unsafe"
+
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/util/zeropool"
 
 	"github.com/grafana/mimir/pkg/storage/chunk"
+	"github.com/grafana/mimir/pkg/util/pool"
 )
 
+// maxPooledBatches is the capacity of the largest slices of batches kept in batchesPool.
+const maxPooledBatches = 1024
+
+// batchesPool holds the storage of the batches of all the batch streams, so that it's reused across series and queries.
+// Slices are cleared before being returned to the pool, so that they don't retain references to histograms.
+var batchesPool = pool.NewBucketedPool(maxPooledBatches, func(size int) []chunk.Batch {
+	return make([]chunk.Batch, 0, size)
+})
+
+// growBatches returns s with the capacity for at least n batches. If s is too small, its batches are moved
+// to a larger slice from the pool, and s is returned to the pool.
+func growBatches(s []chunk.Batch, n int) []chunk.Batch {
+	if cap(s) >= n {
+		return s
+	}
+	grown := append(batchesPool.Get(max(n, 2*cap(s))), s...)
+	putBatches(s)
+	return grown
+}
+
+func putBatches(s []chunk.Batch) {
+	if cap(s) == 0 {
+		return
+	}
+	clear(s[:cap(s)])
+	batchesPool.Put(s)
+}
+
+// MergeStats are the statistics of how the batches of overlapping chunks are merged by a batch stream.
+type MergeStats struct {
+	// DuplicateSamples is the number of samples discarded because another sample has the same timestamp.
+	DuplicateSamples int
+
+	// ConflictingSamples is the number of the duplicate float samples whose value differs from the kept one.
+	ConflictingSamples int
+
+	// AppendedBatches is the number of batches appended to the stream as a whole, because they come after it.
+	AppendedBatches int
+
+	// MergedBatches is the number of batches merged into the stream sample by sample, because they overlap it.
+	MergedBatches int
+}
+
 // batchStream deals with iterating through multiple, non-overlapping batches,
 // and building new slices of non-overlapping batches.  Designed to be used
 // without allocations.
@@ -24,30 +71,84 @@ type batchStream struct {
 	// This helps reduce the number of hints that are set to unknown across merge calls.
 	prevIteratorID int
 
+	// preferLaterChunks is true if, when the timestamps of samples are equal, the sample of the chunk with the
+	// higher rank takes precedence, whatever their value types. The ranks are taken from the ChunkRanks of the batches.
+	preferLaterChunks bool
+
+	// reverse is true if the batches are in descending order of time, and so are the samples in each batch.
+	reverse bool
+
+	// copyOnConflict is true if the histogram kept for samples with equal timestamps is copied into one taken from
+	// the pools, rather than moved from its batch. This is needed when the merged batches may share histograms,
+	// e.g. if the same batch is merged twice: none of the histograms of the conflicting samples is returned to
+	// the pools then, since they may still be used by the other batch.
+	copyOnConflict bool
+
+	stats MergeStats
+
+	// mixedBatches is the buffer of the indexes of the result batches of mergeByTime with samples of both inputs.
+	mixedBatches []int
+
 	hPool  *zeropool.Pool[*histogram.Histogram]
 	fhPool *zeropool.Pool[*histogram.FloatHistogram]
 }
 
-func newBatchStream(size int, hPool *zeropool.Pool[*histogram.Histogram], fhPool *zeropool.Pool[*histogram.FloatHistogram]) *batchStream {
-	batches := make([]chunk.Batch, 0, size)
-	batchesBuf := make([]chunk.Batch, size)
+func newBatchStream(size int, preferLaterChunks bool, hPool *zeropool.Pool[*histogram.Histogram], fhPool *zeropool.Pool[*histogram.FloatHistogram]) *batchStream {
+	batchesBuf := batchesPool.Get(size)
 	return &batchStream{
-		batches:        batches,
-		batchesBuf:     batchesBuf,
-		prevIteratorID: -1,
-		hPool:          hPool,
-		fhPool:         fhPool,
+		batches:           batchesPool.Get(size),
+		batchesBuf:        batchesBuf[:cap(batchesBuf)],
+		prevIteratorID:    -1,
+		preferLaterChunks: preferLaterChunks,
+		hPool:             hPool,
+		fhPool:            fhPool,
 	}
 }
 
 func (bs *batchStream) putPointerValuesToThePool(batch *chunk.Batch) {
 	if batch.ValueType == chunkenc.ValHistogram && bs.hPool != nil {
 		for i := 0; i < batch.Length; i++ {
-			bs.hPool.Put((*histogram.Histogram)(batch.PointerValues[i]))
+			putHistogram(bs.hPool, (*histogram.Histogram)(batch.PointerValues[i]))
 		}
 	} else if batch.ValueType == chunkenc.ValFloatHistogram && bs.fhPool != nil {
 		for i := 0; i < batch.Length; i++ {
-			bs.fhPool.Put((*histogram.FloatHistogram)(batch.PointerValues[i]))
+			putFloatHistogram(bs.fhPool, (*histogram.FloatHistogram)(batch.PointerValues[i]))
+		}
+	}
+}
+
+// resolveConflict is called when the current sample of the discarded batch is discarded in favour of the last
+// sample appended to the result batch b, which has the same timestamp. The histogram of the discarded sample is
+// put to the pool, so it can be reused, unless it's the same as the kept one. If the stream copies on conflict,
+// the kept histogram is replaced by a copy taken from the pool instead, and no histogram is put to the pool.
+func (bs *batchStream) resolveConflict(b *chunk.Batch, discarded *chunk.Batch, discardedType chunkenc.ValueType) {
+	var kept unsafe.Pointer
+	if b.ValueType == chunkenc.ValHistogram || b.ValueType == chunkenc.ValFloatHistogram {
+		kept = b.PointerValues[b.Index-1]
+	}
+
+	if bs.copyOnConflict {
+		switch {
+		case b.ValueType == chunkenc.ValHistogram && bs.hPool != nil:
+			h := bs.hPool.Get()
+			(*histogram.Histogram)(kept).CopyTo(h)
+			b.PointerValues[b.Index-1] = unsafe.Pointer(h)
+		case b.ValueType == chunkenc.ValFloatHistogram && bs.fhPool != nil:
+			fh := bs.fhPool.Get()
+			(*histogram.FloatHistogram)(kept).CopyTo(fh)
+			b.PointerValues[b.Index-1] = unsafe.Pointer(fh)
+		}
+		return
+	}
+
+	if discardedType != chunkenc.ValHistogram && discardedType != chunkenc.ValFloatHistogram {
+		return
+	}
+	if p := discarded.PointerValues[discarded.Index]; p != kept {
+		if discardedType == chunkenc.ValHistogram {
+			putHistogram(bs.hPool, (*histogram.Histogram)(p))
+		} else {
+			putFloatHistogram(bs.fhPool, (*histogram.FloatHistogram)(p))
 		}
 	}
 }
@@ -56,14 +157,56 @@ func (bs *batchStream) removeFirst() {
 	bs.putPointerValuesToThePool(bs.curr())
 	copy(bs.batches, bs.batches[1:])
 	bs.batches = bs.batches[:len(bs.batches)-1]
+	if len(bs.batches) == 0 {
+		putBatches(bs.batches)
+		bs.batches = nil
+	}
 }
 
+// empty removes all the batches, and returns their histograms and storage to the pools.
 func (bs *batchStream) empty() {
 	for i := range bs.batches {
 		bs.putPointerValuesToThePool(&bs.batches[i])
 	}
-	bs.batches = bs.batches[:0]
+	putBatches(bs.batches)
+	putBatches(bs.batchesBuf)
+	bs.batches = nil
+	bs.batchesBuf = nil
 	bs.prevIteratorID = -1
+	bs.stats = MergeStats{}
+}
+
+// seek drops the leading batches whose samples are all before t, returning their histograms to the pools,
+// and moves the first remaining batch to its first sample at or after t. In reverse, "before" and "after"
+// are swapped.
+// If any sample was skipped, the counter reset hint of the first remaining histogram is reset, because it
+// can't be trusted without the preceding samples.
+func (bs *batchStream) seek(t int64) chunkenc.ValueType {
+	skipped := false
+	for bs.len() > 0 {
+		b := bs.curr()
+		if b.Length > 0 && !bs.before(b.Timestamps[b.Length-1], t) {
+			b.Index = 0
+			for bs.before(b.Timestamps[b.Index], t) {
+				b.Index++
+			}
+			if skipped || b.Index > 0 {
+				resetCounterResetHint(b.ValueType, b.PointerValues[b.Index])
+			}
+			return b.ValueType
+		}
+		bs.removeFirst()
+		skipped = true
+	}
+	return chunkenc.ValNone
+}
+
+// before returns true if a sample at t1 comes before a sample at t2 in the order of the stream.
+func (bs *batchStream) before(t1, t2 int64) bool {
+	if bs.reverse {
+		return t1 > t2
+	}
+	return t1 < t2
 }
 
 func (bs *batchStream) len() int {
@@ -98,7 +241,123 @@ func (bs *batchStream) curr() *chunk.Batch {
 // merge merges this streams of chunk.Batch objects and the given chunk.Batch of the same series over time.
 // Samples are simply merged by time when they are the same type (float/histogram/...), with the left stream taking precedence if the timestamps are equal.
 // When sample are different type, batches are not merged. In case of equal timestamps, histograms take precedence since they have more information.
+// If the stream prefers the later chunks, the sample of the chunk with the higher rank takes precedence if the timestamps are equal,
+// whatever the types of the samples.
 func (bs *batchStream) merge(batch *chunk.Batch, size int, iteratorID int) {
+	// Sequential chunks of the same series don't overlap, so the given batch commonly comes entirely
+	// after the stream and can be appended without merging sample by sample.
+	if bs.canAppendDisjoint(batch, size) {
+		bs.appendDisjoint(batch, size, iteratorID)
+		bs.stats.AppendedBatches++
+		return
+	}
+	bs.mergeByTime(batch, size, iteratorID)
+	bs.stats.MergedBatches++
+}
+
+// canAppendDisjoint returns true if appendDisjoint gives the same result as mergeByTime: the batch starts strictly
+// after the end of the stream, and the stream is already laid out as mergeByTime would lay it out, i.e. none of
+// its samples was consumed, and each batch but the last one is either full or followed by a different value type.
+func (bs *batchStream) canAppendDisjoint(batch *chunk.Batch, size int) bool {
+	if batch.HasNext() == chunkenc.ValNone || batch.Length-batch.Index > len(batch.Timestamps) {
+		return false
+	}
+	if bs.len() == 0 {
+		return true
+	}
+	if bs.curr().Index != 0 {
+		return false
+	}
+
+	last := &bs.batches[len(bs.batches)-1]
+	if last.Length == 0 || !bs.before(last.Timestamps[last.Length-1], batch.AtTime()) {
+		return false
+	}
+	for i := range bs.batches {
+		b := &bs.batches[i]
+		if b.Length == 0 || b.Length > size {
+			return false
+		}
+		if i < len(bs.batches)-1 && b.Length < size && bs.batches[i+1].ValueType == b.ValueType {
+			return false
+		}
+	}
+	return true
+}
+
+// appendDisjoint appends the samples of the given batch to the stream, filling up the last batch of the stream first.
+// It must only be called if canAppendDisjoint returns true.
+func (bs *batchStream) appendDisjoint(batch *chunk.Batch, size int, iteratorID int) {
+	valueType := batch.ValueType
+	isHistogram := valueType == chunkenc.ValHistogram || valueType == chunkenc.ValFloatHistogram
+
+	// The iterator id of the sample preceding the batch, as tracked by mergeByTime.
+	prevIteratorID := bs.prevIteratorID
+	if bs.len() > 0 {
+		// mergeByTime would re-append the stream, starting by comparing the iterator id of its first sample
+		// with the one of the last sample, so do the same for the counter reset hint of the first sample.
+		if first := bs.curr(); first.ValueType == chunkenc.ValHistogram || first.ValueType == chunkenc.ValFloatHistogram {
+			if itID := first.GetIteratorID(); prevIteratorID != itID && prevIteratorID != -1 {
+				resetCounterResetHint(first.ValueType, first.PointerValues[0])
+			}
+		}
+
+		last := &bs.batches[len(bs.batches)-1]
+		prevIteratorID = -1
+		if last.ValueType == chunkenc.ValHistogram || last.ValueType == chunkenc.ValFloatHistogram {
+			prevIteratorID = int(last.Values[last.Length-1])
+		}
+	}
+	if isHistogram && prevIteratorID != iteratorID && prevIteratorID != -1 {
+		// We switched non overlapping iterators, so the hint of the first sample of the batch can't be trusted.
+		resetCounterResetHint(valueType, batch.PointerValues[batch.Index])
+	}
+
+	for batch.Index < batch.Length {
+		var b *chunk.Batch
+		if n := len(bs.batches); n > 0 && bs.batches[n-1].ValueType == valueType && bs.batches[n-1].Length < size {
+			b = &bs.batches[n-1]
+		} else {
+			bs.batches = append(growBatches(bs.batches, len(bs.batches)+1), chunk.Batch{ValueType: valueType})
+			b = &bs.batches[len(bs.batches)-1]
+		}
+
+		n := min(size-b.Length, batch.Length-batch.Index)
+		copy(b.Timestamps[b.Length:b.Length+n], batch.Timestamps[batch.Index:batch.Index+n])
+		if isHistogram {
+			copy(b.PointerValues[b.Length:b.Length+n], batch.PointerValues[batch.Index:batch.Index+n])
+			for i := b.Length; i < b.Length+n; i++ {
+				b.Values[i] = float64(iteratorID)
+			}
+		} else {
+			copy(b.Values[b.Length:b.Length+n], batch.Values[batch.Index:batch.Index+n])
+		}
+		copy(b.ChunkRanks[b.Length:b.Length+n], batch.ChunkRanks[batch.Index:batch.Index+n])
+		b.Length += n
+		batch.Index += n
+	}
+
+	bs.prevIteratorID = iteratorID
+}
+
+// resetCounterResetHint sets the counter reset hint of the given histogram to unknown, unless it's a gauge histogram.
+func resetCounterResetHint(valueType chunkenc.ValueType, p unsafe.Pointer) {
+	switch valueType {
+	case chunkenc.ValHistogram:
+		h := (*histogram.Histogram)(p)
+		if h.CounterResetHint != histogram.GaugeType && h.CounterResetHint != histogram.UnknownCounterReset {
+			h.CounterResetHint = histogram.UnknownCounterReset
+		}
+	case chunkenc.ValFloatHistogram:
+		h := (*histogram.FloatHistogram)(p)
+		if h.CounterResetHint != histogram.GaugeType && h.CounterResetHint != histogram.UnknownCounterReset {
+			h.CounterResetHint = histogram.UnknownCounterReset
+		}
+	}
+}
+
+// mergeByTime merges the stream and the given batch sample by sample. See merge.
+func (bs *batchStream) mergeByTime(batch *chunk.Batch, size int, iteratorID int) {
 	// We store this at the beginning to avoid additional allocations.
 	// Namely, the merge method will go through all the batches from bs.batch,
 	// check whether their elements should be kept (and copy them to the result)
@@ -111,25 +370,50 @@ func (bs *batchStream) merge(batch *chunk.Batch, size int, iteratorID int) {
 	// the merge method.
 	origBatches := bs.batches[:0]
 
-	// Reset the Index and Length of existing batches.
+	// The storage of the batches is returned to the pool when the stream is emptied.
+	if len(bs.batchesBuf) == 0 {
+		bs.batchesBuf = growBatches(bs.batchesBuf, 1)
+		bs.batchesBuf = bs.batchesBuf[:cap(bs.batchesBuf)]
+	}
+
+	// Reset the Index, Length and Flags of existing batches.
 	for i := range bs.batchesBuf {
 		bs.batchesBuf[i].Index = 0
 		bs.batchesBuf[i].Length = 0
+		bs.batchesBuf[i].Flags = 0
 	}
 
 	resultLen := 1 // Number of batches in the final result.
 	b := &bs.batchesBuf[0]
 
+	// The inputs interleave if the result switches between their samples more than once: the samples of one input
+	// aren't all before or after the ones of the other, apart from the duplicates, which are only taken from one of
+	// them. The result batches with samples of both inputs are then flagged as merged across sources.
+	const (
+		fromStream = 1 << iota
+		fromBatch
+	)
+	prevSource, sources, switches := 0, 0, 0
+	bs.mixedBatches = bs.mixedBatches[:0]
+	closeBatch := func() {
+		if sources == fromStream|fromBatch {
+			bs.mixedBatches = append(bs.mixedBatches, resultLen-1)
+		}
+		sources = 0
+	}
+
 	// Step to the next Batch in the result, create it if it does not exist
 	nextBatch := func(valueType chunkenc.ValueType) {
 		// The Index is the place at which new sample
 		// has to be appended, hence it tells the length.
 		b.Length = b.Index
+		closeBatch()
 		resultLen++
 		if resultLen > len(bs.batchesBuf) {
 			// It is possible that result can grow longer
 			// then the one provided.
-			bs.batchesBuf = append(bs.batchesBuf, chunk.Batch{})
+			bs.batchesBuf = growBatches(bs.batchesBuf, resultLen)
+			bs.batchesBuf = bs.batchesBuf[:cap(bs.batchesBuf)]
 		}
 		b = &bs.batchesBuf[resultLen-1]
 		b.ValueType = valueType
@@ -147,6 +431,18 @@ func (bs *batchStream) merge(batch *chunk.Batch, size int, iteratorID int) {
 			nextBatch(valueType)
 		}
 
+		source := fromBatch
+		if itID == -1 {
+			source = fromStream
+		}
+		if prevSource != 0 && source != prevSource {
+			switches++
+		}
+		prevSource = source
+		sources |= source
+		// Propagate the flags of the batches of previous merges to the batches their samples end up in.
+		b.Flags |= batch.Flags
+
 		switch valueType {
 		case chunkenc.ValFloat:
 			b.Timestamps[b.Index], b.Values[b.Index] = batch.At()
@@ -179,35 +475,43 @@ func (bs *batchStream) merge(batch *chunk.Batch, size int, iteratorID int) {
 			}
 			b.SetIteratorID(itID)
 		}
+		b.ChunkRanks[b.Index] = batch.ChunkRanks[batch.Index]
 		prevIteratorID = itID
 		b.Index++
 	}
 
 	for lt, rt := bs.hasNext(), batch.HasNext(); lt != chunkenc.ValNone && rt != chunkenc.ValNone; lt, rt = bs.hasNext(), batch.HasNext() {
 		t1, t2 := bs.curr().AtTime(), batch.AtTime()
-		if t1 < t2 {
+		if bs.before(t1, t2) {
 			populate(bs.curr(), lt, -1)
 			bs.next()
-		} else if t1 > t2 {
+		} else if bs.before(t2, t1) {
 			populate(batch, rt, iteratorID)
 			batch.Next()
 		} else {
-			if (rt == chunkenc.ValHistogram || rt == chunkenc.ValFloatHistogram) && lt == chunkenc.ValFloat {
-				// Prefer histograms than floats. Take left side if both have histograms.
+			// Take the sample of the later chunk if preferred. Otherwise, prefer histograms than floats, and the
+			// left side for samples of the same kind.
+			var takeRight bool
+			if bs.preferLaterChunks {
+				l := bs.curr()
+				takeRight = batch.ChunkRanks[batch.Index] > l.ChunkRanks[l.Index]
+			} else {
+				takeRight = (rt == chunkenc.ValHistogram || rt == chunkenc.ValFloatHistogram) && lt == chunkenc.ValFloat
+			}
+			if lt == chunkenc.ValFloat && rt == chunkenc.ValFloat {
+				l, r := bs.curr(), batch
+				if math.Float64bits(l.Values[l.Index]) != math.Float64bits(r.Values[r.Index]) {
+					bs.stats.ConflictingSamples++
+				}
+			}
+			if takeRight {
 				populate(batch, rt, iteratorID)
+				bs.resolveConflict(b, bs.curr(), lt)
 			} else {
 				populate(bs.curr(), lt, -1)
-				// if bs.hPool is not nil, we put there the discarded histogram.Histogram object from batch, so it can be reused.
-				if rt == chunkenc.ValHistogram && bs.hPool != nil {
-					_, h := batch.AtHistogram()
-					bs.hPool.Put((*histogram.Histogram)(h))
-				}
-				// if bs.fhPool is not nil, we put there the discarded histogram.FloatHistogram object from batch, so it can be reused.
-				if rt == chunkenc.ValFloatHistogram && bs.fhPool != nil {
-					_, fh := batch.AtFloatHistogram()
-					bs.fhPool.Put((*histogram.FloatHistogram)(fh))
-				}
+				bs.resolveConflict(b, batch, rt)
 			}
+			bs.stats.DuplicateSamples++
 			bs.next()
 			batch.Next()
 		}
@@ -226,10 +530,17 @@ func (bs *batchStream) merge(batch *chunk.Batch, size int, iteratorID int) {
 	// The Index is the place at which new sample
 	// has to be appended, hence it tells the length.
 	b.Length = b.Index
+	closeBatch()
+
+	if switches > 1 {
+		for _, i := range bs.mixedBatches {
+			bs.batchesBuf[i].Flags |= chunk.BatchMergedAcrossSources
+		}
+	}
 
 	// Store the last iterator id.
 	bs.prevIteratorID = prevIteratorID
 
-	bs.batches = append(origBatches, bs.batchesBuf[:resultLen]...)
+	bs.batches = append(growBatches(origBatches, resultLen), bs.batchesBuf[:resultLen]...)
 	bs.reset()
 }
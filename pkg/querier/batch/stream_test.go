@@ -7,12 +7,14 @@ package batch
 
 import (
 	"fmt"
+	"math/rand"
 	"strconv"
 	"testing"
 	"unsafe"
 
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/util/zeropool"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/mimir/pkg/storage/chunk"
@@ -88,7 +90,7 @@ func TestBatchStream_Merge(t *testing.T) {
 		},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			s := newBatchStream(len(tc.batches), nil, nil)
+			s := newBatchStream(len(tc.batches), false, nil, nil)
 			s.batches = tc.batches
 
 			for i := range tc.newBatches {
@@ -105,8 +107,516 @@ func TestBatchStream_Merge(t *testing.T) {
 	}
 }
 
+func TestBatchStream_MergePrecedence(t *testing.T) {
+	const (
+		ts = 10
+		// The sources are also used as the iterator ids of the histograms.
+		leftSource  = 1
+		rightSource = 2
+	)
+
+	// mkSample returns a batch with a single sample at ts, from the chunk with the given rank. Float values and
+	// histogram iterator ids tell the sides apart.
+	mkSample := func(valueType chunkenc.ValueType, source int, rank int32) chunk.Batch {
+		batch := chunk.Batch{ValueType: valueType, Length: 1}
+		batch.Timestamps[0] = ts
+		batch.ChunkRanks[0] = rank
+		switch valueType {
+		case chunkenc.ValFloat:
+			batch.Values[0] = float64(source)
+		case chunkenc.ValHistogram:
+			batch.PointerValues[0] = unsafe.Pointer(test.GenerateTestHistogram(source))
+			batch.Values[0] = float64(source)
+		case chunkenc.ValFloatHistogram:
+			batch.PointerValues[0] = unsafe.Pointer(test.GenerateTestFloatHistogram(source))
+			batch.Values[0] = float64(source)
+		}
+		return batch
+	}
+
+	for _, tc := range []struct {
+		left, right       chunkenc.ValueType
+		preferLaterChunks bool
+		rightIsLater      bool
+		expectRight       bool
+	}{
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloat, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValHistogram, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValHistogram, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValHistogram, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		// Histograms take precedence over floats, unless the later chunks are preferred.
+		{left: chunkenc.ValFloat, right: chunkenc.ValHistogram, preferLaterChunks: false, rightIsLater: false, expectRight: true},
+		{left: chunkenc.ValFloat, right: chunkenc.ValHistogram, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValFloat, right: chunkenc.ValHistogram, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloat, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValHistogram, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloatHistogram, preferLaterChunks: false, rightIsLater: false, expectRight: true},
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValFloat, right: chunkenc.ValFloatHistogram, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloat, preferLaterChunks: false, rightIsLater: true, expectRight: false},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: true, expectRight: true},
+		{left: chunkenc.ValFloatHistogram, right: chunkenc.ValFloat, preferLaterChunks: true, rightIsLater: false, expectRight: false},
+	} {
+		t.Run(fmt.Sprintf("left=%s, right=%s, prefer later chunks=%t, right is later=%t", tc.left, tc.right, tc.preferLaterChunks, tc.rightIsLater), func(t *testing.T) {
+			leftRank, rightRank := int32(1), int32(0)
+			if tc.rightIsLater {
+				leftRank, rightRank = rightRank, leftRank
+			}
+			expected, discarded := mkSample(tc.left, leftSource, leftRank), mkSample(tc.right, rightSource, rightRank)
+			if tc.expectRight {
+				expected, discarded = discarded, expected
+			}
+
+			// The race detector makes sync.Pool randomly drop the objects put to it, so merge a few times
+			// to check the discarded histogram is returned to the pool.
+			var hPooled []*histogram.Histogram
+			var fhPooled []*histogram.FloatHistogram
+			for i := 0; i < 10; i++ {
+				hPool := zeropool.New(func() *histogram.Histogram { return nil })
+				fhPool := zeropool.New(func() *histogram.FloatHistogram { return nil })
+
+				s := newBatchStream(1, tc.preferLaterChunks, &hPool, &fhPool)
+				s.batches = append(s.batches, mkSample(tc.left, leftSource, leftRank))
+				right := mkSample(tc.right, rightSource, rightRank)
+				s.merge(&right, chunk.BatchSize, rightSource)
+
+				require.Equal(t, 1, s.len())
+				requireBatchEqual(t, expected, s.batches[0])
+				require.Equal(t, expected.Values[0], s.batches[0].Values[0])
+				require.Equal(t, expected.ChunkRanks[0], s.batches[0].ChunkRanks[0])
+
+				for h := hPool.Get(); h != nil; h = hPool.Get() {
+					unmarkHistogram(h)
+					hPooled = append(hPooled, h)
+				}
+				for fh := fhPool.Get(); fh != nil; fh = fhPool.Get() {
+					unmarkFloatHistogram(fh)
+					fhPooled = append(fhPooled, fh)
+				}
+			}
+
+			switch discarded.ValueType {
+			case chunkenc.ValFloat:
+				require.Empty(t, hPooled)
+				require.Empty(t, fhPooled)
+			case chunkenc.ValHistogram:
+				require.NotEmpty(t, hPooled)
+				for _, h := range hPooled {
+					require.Equal(t, test.GenerateTestHistogram(int(discarded.Values[0])), h)
+				}
+				require.Empty(t, fhPooled)
+			case chunkenc.ValFloatHistogram:
+				require.Empty(t, hPooled)
+				require.NotEmpty(t, fhPooled)
+				for _, fh := range fhPooled {
+					require.Equal(t, test.GenerateTestFloatHistogram(int(discarded.Values[0])), fh)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchStream_MergeAliasedHistograms(t *testing.T) {
+	const size = 4
+
+	// mkBatches returns two batches with the same timestamps sharing their histograms, like the same chunk
+	// merged twice, and the expected histograms.
+	mkBatches := func(valueType chunkenc.ValueType) (chunk.Batch, chunk.Batch, chunk.Batch) {
+		left := chunk.Batch{ValueType: valueType, Length: size}
+		expected := chunk.Batch{ValueType: valueType, Length: size}
+		for i := 0; i < size; i++ {
+			left.Timestamps[i], expected.Timestamps[i] = int64(i), int64(i)
+			if valueType == chunkenc.ValHistogram {
+				left.PointerValues[i] = unsafe.Pointer(test.GenerateTestHistogram(i))
+				expected.PointerValues[i] = unsafe.Pointer(test.GenerateTestHistogram(i))
+			} else {
+				left.PointerValues[i] = unsafe.Pointer(test.GenerateTestFloatHistogram(i))
+				expected.PointerValues[i] = unsafe.Pointer(test.GenerateTestFloatHistogram(i))
+			}
+		}
+		return left, left, expected
+	}
+	// drainPools takes all the histograms in the pools, and overwrites them like a reader of the pools would.
+	drainPools := func(hPool *zeropool.Pool[*histogram.Histogram], fhPool *zeropool.Pool[*histogram.FloatHistogram]) int {
+		n := 0
+		for h := hPool.Get(); h != nil; h = hPool.Get() {
+			test.GenerateTestHistogram(100).CopyTo(h)
+			n++
+		}
+		for fh := fhPool.Get(); fh != nil; fh = fhPool.Get() {
+			test.GenerateTestFloatHistogram(100).CopyTo(fh)
+			n++
+		}
+		return n
+	}
+
+	for _, valueType := range []chunkenc.ValueType{chunkenc.ValHistogram, chunkenc.ValFloatHistogram} {
+		t.Run(valueType.String(), func(t *testing.T) {
+			t.Run("shared histograms aren't put to the pools", func(t *testing.T) {
+				hPool := zeropool.New(func() *histogram.Histogram { return nil })
+				fhPool := zeropool.New(func() *histogram.FloatHistogram { return nil })
+
+				left, right, expected := mkBatches(valueType)
+				s := newBatchStream(1, false, &hPool, &fhPool)
+				s.batches = append(s.batches, left)
+				s.merge(&right, chunk.BatchSize, 1)
+
+				require.Equal(t, 0, drainPools(&hPool, &fhPool))
+				require.Equal(t, 1, s.len())
+				requireBatchEqual(t, expected, s.batches[0])
+			})
+
+			t.Run("copy on conflict", func(t *testing.T) {
+				// The pools return new histograms when they're empty, so that they can be copied into.
+				hPool := zeropool.New(func() *histogram.Histogram { return &histogram.Histogram{} })
+				fhPool := zeropool.New(func() *histogram.FloatHistogram { return &histogram.FloatHistogram{} })
+
+				left, right, expected := mkBatches(valueType)
+				s := newBatchStream(1, false, &hPool, &fhPool)
+				s.copyOnConflict = true
+				s.batches = append(s.batches, left)
+				s.merge(&right, chunk.BatchSize, 1)
+
+				require.Equal(t, 1, s.len())
+				for i := 0; i < size; i++ {
+					require.NotEqual(t, left.PointerValues[i], s.batches[0].PointerValues[i], "the kept histogram at %d is shared with the merged batches", i)
+				}
+				requireBatchEqual(t, expected, s.batches[0])
+
+				// Changing the histograms of the merged batches doesn't change the stream.
+				for i := 0; i < size; i++ {
+					if valueType == chunkenc.ValHistogram {
+						test.GenerateTestHistogram(100).CopyTo((*histogram.Histogram)(left.PointerValues[i]))
+					} else {
+						test.GenerateTestFloatHistogram(100).CopyTo((*histogram.FloatHistogram)(left.PointerValues[i]))
+					}
+				}
+				requireBatchEqual(t, expected, s.batches[0])
+			})
+		})
+	}
+}
+
+func TestBatchStream_MergeDisjointMatchesMergeByTime(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		t.Run(strconv.FormatInt(seed, 10), func(t *testing.T) {
+			// Both streams get their own copies of the same batches, since merging may change the counter reset hints.
+			fast := newBatchStream(1, false, nil, nil)
+			slow := newBatchStream(1, false, nil, nil)
+			fastGen := newRandomBatchGenerator(seed)
+			slowGen := newRandomBatchGenerator(seed)
+			rnd := rand.New(rand.NewSource(seed))
+			size := 1 + rnd.Intn(chunk.BatchSize)
+
+			for step := 0; step < 50; step++ {
+				fastBatch, iteratorID := fastGen.next()
+				slowBatch, _ := slowGen.next()
+				fast.merge(&fastBatch, size, iteratorID)
+				slow.mergeByTime(&slowBatch, size, iteratorID)
+				requireBatchStreamsEqual(t, slow, fast, "step %d", step)
+
+				// Consume some samples, as the merge iterator does between merges.
+				switch rnd.Intn(4) {
+				case 0:
+					if fast.len() > 0 {
+						fast.removeFirst()
+						slow.removeFirst()
+					}
+				case 1:
+					for i := rnd.Intn(3); i > 0 && fast.len() > 0; i-- {
+						fast.next()
+						slow.next()
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBatchStream_MergeFlags(t *testing.T) {
+	// mkBatch returns a batch of float samples at the given timestamps, whose values are their timestamps.
+	mkBatch := func(ts ...int64) chunk.Batch {
+		b := chunk.Batch{ValueType: chunkenc.ValFloat, Length: len(ts)}
+		for i, t := range ts {
+			b.Timestamps[i], b.Values[i] = t, float64(t)
+		}
+		return b
+	}
+	flags := func(s *batchStream) []chunk.BatchFlags {
+		res := make([]chunk.BatchFlags, 0, s.len())
+		for _, b := range s.batches {
+			res = append(res, b.Flags)
+		}
+		return res
+	}
+	const merged = chunk.BatchMergedAcrossSources
+
+	for name, tc := range map[string]struct {
+		stream        chunk.Batch
+		batches       []chunk.Batch
+		size          int
+		expectedFlags []chunk.BatchFlags
+	}{
+		"disjoint inputs": {
+			stream:        mkBatch(0, 1, 2),
+			batches:       []chunk.Batch{mkBatch(3, 4, 5)},
+			size:          chunk.BatchSize,
+			expectedFlags: []chunk.BatchFlags{0},
+		},
+		"batch before the stream": {
+			stream:        mkBatch(3, 4, 5),
+			batches:       []chunk.Batch{mkBatch(0, 1, 2)},
+			size:          chunk.BatchSize,
+			expectedFlags: []chunk.BatchFlags{0},
+		},
+		"overlapping inputs with duplicates only": {
+			stream:        mkBatch(0, 1, 2, 3),
+			batches:       []chunk.Batch{mkBatch(2, 3, 4, 5)},
+			size:          chunk.BatchSize,
+			expectedFlags: []chunk.BatchFlags{0},
+		},
+		"interleaving inputs": {
+			stream:        mkBatch(0, 2, 4, 6),
+			batches:       []chunk.Batch{mkBatch(1, 3)},
+			size:          chunk.BatchSize,
+			expectedFlags: []chunk.BatchFlags{merged},
+		},
+		"interleaving inputs across result batches": {
+			stream:        mkBatch(0, 2, 4, 6, 8, 10),
+			batches:       []chunk.Batch{mkBatch(1, 3)},
+			size:          4,
+			expectedFlags: []chunk.BatchFlags{merged, 0},
+		},
+		"flags propagated across merges": {
+			stream:        mkBatch(0, 2, 4, 6),
+			batches:       []chunk.Batch{mkBatch(1, 3), mkBatch(6, 7)},
+			size:          chunk.BatchSize,
+			expectedFlags: []chunk.BatchFlags{merged},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newBatchStream(1, false, nil, nil)
+			s.batches = append(s.batches, tc.stream)
+			for i := range tc.batches {
+				s.merge(&tc.batches[i], tc.size, i)
+			}
+			require.Equal(t, tc.expectedFlags, flags(s))
+		})
+	}
+}
+
+func requireBatchStreamsEqual(t *testing.T, expected, actual *batchStream, msgAndArgs ...any) {
+	require.Equal(t, expected.prevIteratorID, actual.prevIteratorID, msgAndArgs...)
+	require.Equal(t, expected.len(), actual.len(), msgAndArgs...)
+	for i := range expected.batches {
+		e, a := expected.batches[i], actual.batches[i]
+		require.Equal(t, e.Index, a.Index, msgAndArgs...)
+		requireBatchEqual(t, e, a)
+		require.Equal(t, e.Timestamps[:e.Length], a.Timestamps[:a.Length], msgAndArgs...)
+		// For histograms, Values holds the iterator ids.
+		require.Equal(t, e.Values[:e.Length], a.Values[:a.Length], msgAndArgs...)
+	}
+}
+
+// randomBatchGenerator generates batches of random value types and counter reset hints, which mostly
+// follow each other but sometimes overlap with the previous ones.
+type randomBatchGenerator struct {
+	rnd    *rand.Rand
+	nextTs int64
+}
+
+func newRandomBatchGenerator(seed int64) *randomBatchGenerator {
+	return &randomBatchGenerator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (g *randomBatchGenerator) next() (chunk.Batch, int) {
+	valueTypes := []chunkenc.ValueType{chunkenc.ValFloat, chunkenc.ValHistogram, chunkenc.ValFloatHistogram}
+	hints := []histogram.CounterResetHint{histogram.UnknownCounterReset, histogram.CounterReset, histogram.NotCounterReset, histogram.GaugeType}
+
+	from := g.nextTs
+	if g.rnd.Intn(4) == 0 {
+		from -= int64(g.rnd.Intn(2 * chunk.BatchSize))
+	}
+	batch := chunk.Batch{ValueType: valueTypes[g.rnd.Intn(len(valueTypes))], Length: 1 + g.rnd.Intn(chunk.BatchSize)}
+	ts := from
+	for i := 0; i < batch.Length; i++ {
+		batch.Timestamps[i] = ts
+		switch batch.ValueType {
+		case chunkenc.ValFloat:
+			batch.Values[i] = float64(ts)
+		case chunkenc.ValHistogram:
+			h := test.GenerateTestHistogram(int(ts))
+			h.CounterResetHint = hints[g.rnd.Intn(len(hints))]
+			batch.PointerValues[i] = unsafe.Pointer(h)
+		case chunkenc.ValFloatHistogram:
+			h := test.GenerateTestFloatHistogram(int(ts))
+			h.CounterResetHint = hints[g.rnd.Intn(len(hints))]
+			batch.PointerValues[i] = unsafe.Pointer(h)
+		}
+		ts += int64(1 + g.rnd.Intn(3))
+	}
+	g.nextTs = max(g.nextTs, ts)
+	return batch, g.rnd.Intn(3)
+}
+
+func BenchmarkBatchStream_MergeNonOverlapping(b *testing.B) {
+	const numBatches = 1000
+
+	for _, valueType := range []chunkenc.ValueType{chunkenc.ValFloat, chunkenc.ValHistogram} {
+		batches := make([]chunk.Batch, 0, numBatches)
+		for i := 0; i < numBatches; i++ {
+			if valueType == chunkenc.ValFloat {
+				batches = append(batches, mkFloatBatch(int64(i*chunk.BatchSize)))
+			} else {
+				batches = append(batches, mkHistogramBatch(int64(i*chunk.BatchSize)))
+			}
+		}
+
+		for _, fastPath := range []bool{false, true} {
+			b.Run(fmt.Sprintf("value type=%s, fast path=%t", valueType, fastPath), func(b *testing.B) {
+				s := newBatchStream(numBatches, false, nil, nil)
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for n := 0; n < b.N; n++ {
+					s.empty()
+					for i := range batches {
+						batch := batches[i]
+						if fastPath {
+							s.merge(&batch, chunk.BatchSize, 0)
+						} else {
+							s.mergeByTime(&batch, chunk.BatchSize, 0)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBatchStream_MergeAndSeek(t *testing.T) {
+	const iteratorID = 0
+
+	for _, valueType := range []chunkenc.ValueType{chunkenc.ValFloat, chunkenc.ValHistogram, chunkenc.ValFloatHistogram} {
+		for _, tc := range []struct {
+			name            string
+			seekT           int64
+			expectedBatches int
+			expectedTs      int64
+			expectHintReset bool
+			expectDropped   bool
+		}{
+			{name: "before the first sample", seekT: -5, expectedBatches: 2, expectedTs: 0},
+			{name: "at the first sample", seekT: 0, expectedBatches: 2, expectedTs: 0},
+			{name: "within the first batch", seekT: 5, expectedBatches: 2, expectedTs: 5, expectHintReset: true},
+			{name: "at the first sample of the second batch", seekT: 12, expectedBatches: 1, expectedTs: 12, expectHintReset: true, expectDropped: true},
+			{name: "within the second batch", seekT: 15, expectedBatches: 1, expectedTs: 15, expectHintReset: true, expectDropped: true},
+			{name: "after the last sample", seekT: 30, expectedBatches: 0, expectDropped: true},
+		} {
+			t.Run(fmt.Sprintf("value type=%s, %s", valueType, tc.name), func(t *testing.T) {
+				// The race detector makes sync.Pool randomly drop the objects put to it, so seek a few times
+				// to check the histograms of the dropped batches are returned to the pools.
+				pooled := 0
+				for i := 0; i < 10; i++ {
+					hPool := zeropool.New(func() *histogram.Histogram { return nil })
+					fhPool := zeropool.New(func() *histogram.FloatHistogram { return nil })
+
+					s := newBatchStream(chunk.BatchSize, false, &hPool, &fhPool)
+					for _, from := range []int64{0, chunk.BatchSize} {
+						batch := mkSeekBatch(valueType, from, chunk.BatchSize)
+						s.merge(&batch, chunk.BatchSize, iteratorID)
+					}
+					require.Equal(t, 2, s.len())
+
+					if tc.expectedBatches == 0 {
+						require.Equal(t, chunkenc.ValNone, s.seek(tc.seekT))
+						require.Equal(t, 0, s.len())
+					} else {
+						require.Equal(t, valueType, s.seek(tc.seekT))
+						require.Equal(t, tc.expectedBatches, s.len())
+						require.Equal(t, tc.expectedTs, s.curr().AtTime())
+						if hint, ok := counterResetHintAt(s.curr()); ok {
+							if tc.expectHintReset {
+								require.Equal(t, histogram.UnknownCounterReset, hint)
+							} else {
+								require.Equal(t, histogram.NotCounterReset, hint)
+							}
+						}
+
+						// Merging after seeking keeps the samples at or after the seek time only.
+						batch := mkSeekBatch(valueType, 2*chunk.BatchSize, chunk.BatchSize)
+						s.merge(&batch, chunk.BatchSize, iteratorID)
+						samples := 0
+						for ; s.hasNext() != chunkenc.ValNone; s.next() {
+							require.Equal(t, tc.expectedTs+int64(samples), s.curr().AtTime())
+							samples++
+						}
+						require.Equal(t, 3*chunk.BatchSize-int(tc.expectedTs), samples)
+					}
+
+					for h := hPool.Get(); h != nil; h = hPool.Get() {
+						pooled++
+					}
+					for fh := fhPool.Get(); fh != nil; fh = fhPool.Get() {
+						pooled++
+					}
+				}
+
+				if tc.expectDropped && valueType != chunkenc.ValFloat {
+					require.NotZero(t, pooled)
+				} else {
+					require.Zero(t, pooled)
+				}
+			})
+		}
+	}
+}
+
+// mkSeekBatch returns a batch of the given value type, with the counter reset hint of the histograms set to
+// NotCounterReset so that tests can tell if it's reset.
+func mkSeekBatch(valueType chunkenc.ValueType, from int64, size int) chunk.Batch {
+	switch valueType {
+	case chunkenc.ValHistogram:
+		batch := mkGenericHistogramBatch(from, size)
+		for i := 0; i < size; i++ {
+			(*histogram.Histogram)(batch.PointerValues[i]).CounterResetHint = histogram.NotCounterReset
+		}
+		return batch
+	case chunkenc.ValFloatHistogram:
+		batch := chunk.Batch{ValueType: chunkenc.ValFloatHistogram, Length: size}
+		for i := 0; i < size; i++ {
+			fh := test.GenerateTestFloatHistogram(int(from) + i)
+			fh.CounterResetHint = histogram.NotCounterReset
+			batch.Timestamps[i] = from + int64(i)
+			batch.PointerValues[i] = unsafe.Pointer(fh)
+		}
+		return batch
+	default:
+		return mkGenericFloatBatch(from, size)
+	}
+}
+
+func counterResetHintAt(b *chunk.Batch) (histogram.CounterResetHint, bool) {
+	switch b.ValueType {
+	case chunkenc.ValHistogram:
+		return (*histogram.Histogram)(b.PointerValues[b.Index]).CounterResetHint, true
+	case chunkenc.ValFloatHistogram:
+		return (*histogram.FloatHistogram)(b.PointerValues[b.Index]).CounterResetHint, true
+	}
+	return 0, false
+}
+
 func TestBatchStream_Empty(t *testing.T) {
-	s := newBatchStream(1, nil, nil)
+	s := newBatchStream(1, false, nil, nil)
 	b1 := mkHistogramBatch(0)
 	b2 := mkHistogramBatch(chunk.BatchSize)
 	s.batches = []chunk.Batch{b1, b2}
@@ -120,7 +630,7 @@ func TestBatchStream_Empty(t *testing.T) {
 }
 
 func TestBatchStream_RemoveFirst(t *testing.T) {
-	s := newBatchStream(1, nil, nil)
+	s := newBatchStream(1, false, nil, nil)
 	b1 := mkHistogramBatch(0)
 	b2 := mkHistogramBatch(chunk.BatchSize)
 	s.batches = []chunk.Batch{b1, b2}
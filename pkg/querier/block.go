@@ -17,6 +17,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/querier/batch"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	"github.com/grafana/mimir/pkg/storage/series"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
@@ -54,6 +55,13 @@ type blockQuerierSeriesSet struct {
 	next int
 
 	currSeries storage.Series
+
+	// queryStats, if not nil, collects the statistics of the merge of the chunks of the series.
+	queryStats *stats.Stats
+	// mergeConflicts, if not nil, collects the conflicting samples found merging the chunks of the series.
+	mergeConflicts *batch.MergeConflicts
+	// mergeOptions are the options of the merge of the chunks of the series.
+	mergeOptions batch.MergeOptions
 }
 
 func (bqss *blockQuerierSeriesSet) Next() bool {
@@ -76,7 +84,7 @@ func (bqss *blockQuerierSeriesSet) Next() bool {
 		bqss.next++
 	}
 
-	bqss.currSeries = newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(currLabels), currChunks)
+	bqss.currSeries = newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(currLabels), currChunks, bqss.queryStats, bqss.mergeConflicts, bqss.mergeOptions)
 	return true
 }
 
@@ -93,17 +101,20 @@ func (bqss *blockQuerierSeriesSet) Warnings() annotations.Annotations {
 }
 
 // newBlockQuerierSeries makes a new blockQuerierSeries. Input labels must be already sorted by name.
-func newBlockQuerierSeries(lbls labels.Labels, chunks []storepb.AggrChunk) *blockQuerierSeries {
+func newBlockQuerierSeries(lbls labels.Labels, chunks []storepb.AggrChunk, queryStats *stats.Stats, mergeConflicts *batch.MergeConflicts, mergeOptions batch.MergeOptions) *blockQuerierSeries {
 	sort.Slice(chunks, func(i, j int) bool {
 		return chunks[i].MinTime < chunks[j].MinTime
 	})
 
-	return &blockQuerierSeries{labels: lbls, chunks: chunks}
+	return &blockQuerierSeries{labels: lbls, chunks: chunks, queryStats: queryStats, mergeConflicts: mergeConflicts, mergeOptions: mergeOptions}
 }
 
 type blockQuerierSeries struct {
-	labels labels.Labels
-	chunks []storepb.AggrChunk
+	labels         labels.Labels
+	chunks         []storepb.AggrChunk
+	queryStats     *stats.Stats
+	mergeConflicts *batch.MergeConflicts
+	mergeOptions   batch.MergeOptions
 }
 
 func (bqs *blockQuerierSeries) Labels() labels.Labels {
@@ -116,10 +127,10 @@ func (bqs *blockQuerierSeries) Iterator(reuse chunkenc.Iterator) chunkenc.Iterat
 		return series.NewErrIterator(errors.New("no chunks"))
 	}
 
-	return newBlockQuerierSeriesIterator(reuse, bqs.Labels(), bqs.chunks)
+	return newBlockQuerierSeriesIterator(reuse, bqs.Labels(), bqs.chunks, bqs.queryStats, bqs.mergeConflicts, bqs.mergeOptions)
 }
 
-func newBlockQuerierSeriesIterator(reuse chunkenc.Iterator, lbls labels.Labels, chunks []storepb.AggrChunk) chunkenc.Iterator {
+func newBlockQuerierSeriesIterator(reuse chunkenc.Iterator, lbls labels.Labels, chunks []storepb.AggrChunk, queryStats *stats.Stats, mergeConflicts *batch.MergeConflicts, mergeOptions batch.MergeOptions) chunkenc.Iterator {
 	genericChunks := make([]batch.GenericChunk, 0, len(chunks))
 
 	for _, c := range chunks {
@@ -144,5 +155,5 @@ func newBlockQuerierSeriesIterator(reuse chunkenc.Iterator, lbls labels.Labels,
 		genericChunks = append(genericChunks, genericChunk)
 	}
 
-	return batch.NewGenericChunkMergeIterator(reuse, lbls, genericChunks)
+	return batch.NewGenericChunkMergeIterator(reuse, lbls, genericChunks, queryStats, mergeConflicts, mergeOptions)
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/util/annotations"
 
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/series"
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
@@ -41,6 +42,13 @@ type blockStreamingQuerierSeriesSet struct {
 	// For debug logging.
 	chunkInfo     *chunkinfologger.ChunkInfoLogger
 	remoteAddress string
+
+	// queryStats, if not nil, collects the statistics of the merge of the chunks of the series.
+	queryStats *stats.Stats
+	// mergeConflicts, if not nil, collects the conflicting samples found merging the chunks of the series.
+	mergeConflicts *batch.MergeConflicts
+	// mergeOptions are the options of the merge of the chunks of the series.
+	mergeOptions batch.MergeOptions
 }
 
 type chunkStreamReader interface {
@@ -65,7 +73,7 @@ func (bqss *blockStreamingQuerierSeriesSet) Next() bool {
 		bqss.nextSeriesIndex++
 	}
 
-	bqss.currSeries = newBlockStreamingQuerierSeries(currLabels, seriesIdxStart, bqss.nextSeriesIndex-1, bqss.streamReader, bqss.chunkInfo, bqss.nextSeriesIndex >= len(bqss.series), bqss.remoteAddress)
+	bqss.currSeries = newBlockStreamingQuerierSeries(currLabels, seriesIdxStart, bqss.nextSeriesIndex-1, bqss.streamReader, bqss.chunkInfo, bqss.nextSeriesIndex >= len(bqss.series), bqss.remoteAddress, bqss.queryStats, bqss.mergeConflicts, bqss.mergeOptions)
 
 	// Clear any labels we no longer need, to allow them to be garbage collected when they're no longer needed elsewhere.
 	clear(bqss.series[seriesIdxStart : bqss.nextSeriesIndex-1])
@@ -86,7 +94,7 @@ func (bqss *blockStreamingQuerierSeriesSet) Warnings() annotations.Annotations {
 }
 
 // newBlockStreamingQuerierSeries makes a new blockQuerierSeries. Input labels must be already sorted by name.
-func newBlockStreamingQuerierSeries(lbls labels.Labels, seriesIdxStart, seriesIdxEnd int, streamReader chunkStreamReader, chunkInfo *chunkinfologger.ChunkInfoLogger, lastOne bool, remoteAddress string) *blockStreamingQuerierSeries {
+func newBlockStreamingQuerierSeries(lbls labels.Labels, seriesIdxStart, seriesIdxEnd int, streamReader chunkStreamReader, chunkInfo *chunkinfologger.ChunkInfoLogger, lastOne bool, remoteAddress string, queryStats *stats.Stats, mergeConflicts *batch.MergeConflicts, mergeOptions batch.MergeOptions) *blockStreamingQuerierSeries {
 	return &blockStreamingQuerierSeries{
 		labels:         lbls,
 		seriesIdxStart: seriesIdxStart,
@@ -95,6 +103,9 @@ func newBlockStreamingQuerierSeries(lbls labels.Labels, seriesIdxStart, seriesId
 		chunkInfo:      chunkInfo,
 		lastOne:        lastOne,
 		remoteAddress:  remoteAddress,
+		queryStats:     queryStats,
+		mergeConflicts: mergeConflicts,
+		mergeOptions:   mergeOptions,
 	}
 }
 
@@ -107,6 +118,10 @@ type blockStreamingQuerierSeries struct {
 	chunkInfo     *chunkinfologger.ChunkInfoLogger
 	lastOne       bool
 	remoteAddress string
+
+	queryStats     *stats.Stats
+	mergeConflicts *batch.MergeConflicts
+	mergeOptions   batch.MergeOptions
 }
 
 func (bqs *blockStreamingQuerierSeries) Labels() labels.Labels {
@@ -139,7 +154,7 @@ func (bqs *blockStreamingQuerierSeries) Iterator(reuse chunkenc.Iterator) chunke
 		return allChunks[i].MinTime < allChunks[j].MinTime
 	})
 
-	return newBlockQuerierSeriesIterator(reuse, bqs.Labels(), allChunks)
+	return newBlockQuerierSeriesIterator(reuse, bqs.Labels(), allChunks, bqs.queryStats, bqs.mergeConflicts, bqs.mergeOptions)
 }
 
 // storeGatewayStreamReader is responsible for managing the streaming of chunks from a storegateway and buffering
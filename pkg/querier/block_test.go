@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/test"
@@ -125,7 +126,7 @@ func TestBlockQuerierSeries(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			series := newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(testData.series.Labels), testData.series.Chunks)
+			series := newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(testData.series.Labels), testData.series.Chunks, nil, nil, batch.MergeOptions{})
 
 			assert.True(t, labels.Equal(testData.expectedMetric, series.Labels()))
 
@@ -546,7 +547,7 @@ func Benchmark_newBlockQuerierSeries(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		newBlockQuerierSeries(lbls, chunks)
+		newBlockQuerierSeries(lbls, chunks, nil, nil, batch.MergeOptions{})
 	}
 }
 
@@ -645,7 +646,7 @@ func TestBlockQuerierSeriesIterator_ShouldMergeOverlappingChunks(t *testing.T) {
 
 	for idx, permutation := range permutations {
 		t.Run(fmt.Sprintf("permutation %d", idx), func(t *testing.T) {
-			it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), permutation)
+			it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), permutation, nil, nil, batch.MergeOptions{})
 
 			var actual []promql.FPoint
 			for it.Next() != chunkenc.ValNone {
@@ -676,7 +677,7 @@ func TestBlockQuerierSeriesIterator_ShouldMergeNonOverlappingChunks(t *testing.T
 	chunk1 := createAggrChunkWithSamples(promql.FPoint{T: 1, F: 1}, promql.FPoint{T: 2, F: 2}, promql.FPoint{T: 3, F: 3})
 	chunk2 := createAggrChunkWithSamples(promql.FPoint{T: 4, F: 4}, promql.FPoint{T: 5, F: 5}, promql.FPoint{T: 6, F: 6})
 
-	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2})
+	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2}, nil, nil, batch.MergeOptions{})
 
 	var actual []promql.FPoint
 	for it.Next() != chunkenc.ValNone {
@@ -703,7 +704,7 @@ func TestBlockQuerierSeriesIterator_SeekWithNonOverlappingChunks(t *testing.T) {
 	chunk2 := createAggrChunkWithSamples(promql.FPoint{T: 4, F: 4}, promql.FPoint{T: 5, F: 5}, promql.FPoint{T: 6, F: 6})
 	chunk3 := createAggrChunkWithSamples(promql.FPoint{T: 7, F: 7}, promql.FPoint{T: 8, F: 8}, promql.FPoint{T: 9, F: 9})
 
-	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2, chunk3})
+	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2, chunk3}, nil, nil, batch.MergeOptions{})
 
 	// Seek to middle of first chunk.
 	require.Equal(t, chunkenc.ValFloat, it.Seek(2))
@@ -752,6 +753,6 @@ func TestBlockQuerierSeriesIterator_SeekPastEnd(t *testing.T) {
 	chunk2 := createAggrChunkWithSamples(promql.FPoint{T: 4, F: 4}, promql.FPoint{T: 5, F: 5}, promql.FPoint{T: 6, F: 6})
 	chunk3 := createAggrChunkWithSamples(promql.FPoint{T: 7, F: 7}, promql.FPoint{T: 8, F: 8}, promql.FPoint{T: 9, F: 9})
 
-	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2, chunk3})
+	it := newBlockQuerierSeriesIterator(nil, labels.EmptyLabels(), []storepb.AggrChunk{chunk1, chunk2, chunk3}, nil, nil, batch.MergeOptions{})
 	require.Equal(t, chunkenc.ValNone, it.Seek(10))
 }
@@ -38,6 +38,7 @@ import (
 	grpc_metadata "google.golang.org/grpc/metadata"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/series"
@@ -740,6 +741,8 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(ctx context.Context, sp *stor
 		spanLog       = spanlogger.FromContext(ctx, q.logger)
 		queryLimiter  = limiter.QueryLimiterFromContextWithFallback(ctx)
 		reqStats      = stats.FromContext(ctx)
+		reqConflicts  = batch.MergeConflictsFromContext(ctx)
+		reqOptions    = batch.MergeOptionsFromContext(ctx)
 		streamReaders []*storeGatewayStreamReader
 		streams       []storegatewaypb.StoreGateway_SeriesClient
 	)
@@ -930,16 +933,19 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(ctx context.Context, sp *stor
 			// Store the result.
 			mtx.Lock()
 			if len(mySeries) > 0 {
-				seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries})
+				seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries, queryStats: reqStats, mergeConflicts: reqConflicts, mergeOptions: reqOptions})
 			} else if len(myStreamingSeriesLabels) > 0 {
 				if chunkInfo != nil {
 					chunkInfo.SetMsg("store-gateway streaming")
 				}
 				seriesSets = append(seriesSets, &blockStreamingQuerierSeriesSet{
-					series:        myStreamingSeriesLabels,
-					streamReader:  streamReader,
-					chunkInfo:     chunkInfo,
-					remoteAddress: c.RemoteAddress(),
+					series:         myStreamingSeriesLabels,
+					streamReader:   streamReader,
+					chunkInfo:      chunkInfo,
+					remoteAddress:  c.RemoteAddress(),
+					queryStats:     reqStats,
+					mergeConflicts: reqConflicts,
+					mergeOptions:   reqOptions,
 				})
 				streamReaders = append(streamReaders, streamReader)
 			}
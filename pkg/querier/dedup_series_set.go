@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+// NewDedupSeriesSet returns a SeriesSet whose series iterators drop the samples with the same timestamp as the
+// previous one, keeping the first. Samples are matched by timestamp only, regardless of their value and type.
+// Duplicated samples may be returned by the ingesters or the store-gateways, and cause rate() to return NaN.
+func NewDedupSeriesSet(set storage.SeriesSet) storage.SeriesSet {
+	return &dedupSeriesSet{SeriesSet: set}
+}
+
+type dedupSeriesSet struct {
+	storage.SeriesSet
+}
+
+func (s *dedupSeriesSet) At() storage.Series {
+	return dedupSeries{Series: s.SeriesSet.At()}
+}
+
+type dedupSeries struct {
+	storage.Series
+}
+
+func (s dedupSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	if d, ok := it.(*dedupIterator); ok {
+		d.reset(s.Series.Iterator(d.Iterator))
+		return d
+	}
+	d := &dedupIterator{}
+	d.reset(s.Series.Iterator(it))
+	return d
+}
+
+// dedupIterator skips the samples with the same timestamp as the previous one.
+type dedupIterator struct {
+	chunkenc.Iterator
+
+	// lastT is the timestamp of the current sample, or math.MinInt64 before the first one.
+	lastT int64
+}
+
+func (it *dedupIterator) reset(next chunkenc.Iterator) {
+	it.Iterator = next
+	it.lastT = math.MinInt64
+}
+
+func (it *dedupIterator) Next() chunkenc.ValueType {
+	for {
+		typ := it.Iterator.Next()
+		if typ == chunkenc.ValNone {
+			return typ
+		}
+		if t := it.Iterator.AtT(); t != it.lastT {
+			it.lastT = t
+			return typ
+		}
+	}
+}
+
+func (it *dedupIterator) Seek(t int64) chunkenc.ValueType {
+	// Seek doesn't move the iterator if the current sample is at or after t, so it never lands on a duplicate
+	// of the current sample.
+	typ := it.Iterator.Seek(t)
+	if typ != chunkenc.ValNone {
+		it.lastT = it.Iterator.AtT()
+	}
+	return typ
+}
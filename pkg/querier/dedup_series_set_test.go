@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestDedupSeriesSet(t *testing.T) {
+	type sample struct {
+		t  int64
+		f  float64
+		h  *histogram.Histogram
+		fh *histogram.FloatHistogram
+	}
+	floats := func(ts ...int64) (samples []mimirpb.Sample) {
+		for i, t := range ts {
+			samples = append(samples, mimirpb.Sample{TimestampMs: t, Value: float64(i)})
+		}
+		return samples
+	}
+
+	for name, tc := range map[string]struct {
+		series   mimirpb.TimeSeries
+		expected []sample
+	}{
+		"floats": {
+			series:   mimirpb.TimeSeries{Samples: floats(1, 1, 2, 3, 3, 3, 4)},
+			expected: []sample{{t: 1, f: 0}, {t: 2, f: 2}, {t: 3, f: 3}, {t: 4, f: 6}},
+		},
+		"floats without duplicates": {
+			series:   mimirpb.TimeSeries{Samples: floats(1, 2, 3)},
+			expected: []sample{{t: 1, f: 0}, {t: 2, f: 1}, {t: 3, f: 2}},
+		},
+		"native histograms": {
+			series: mimirpb.TimeSeries{Histograms: []mimirpb.Histogram{
+				mimirpb.FromHistogramToHistogramProto(1, test.GenerateTestHistogram(1)),
+				mimirpb.FromHistogramToHistogramProto(1, test.GenerateTestHistogram(2)),
+				mimirpb.FromHistogramToHistogramProto(2, test.GenerateTestHistogram(3)),
+			}},
+			expected: []sample{{t: 1, h: test.GenerateTestHistogram(1)}, {t: 2, h: test.GenerateTestHistogram(3)}},
+		},
+		"float histograms": {
+			series: mimirpb.TimeSeries{Histograms: []mimirpb.Histogram{
+				mimirpb.FromFloatHistogramToHistogramProto(1, test.GenerateTestFloatHistogram(1)),
+				mimirpb.FromFloatHistogramToHistogramProto(2, test.GenerateTestFloatHistogram(2)),
+				mimirpb.FromFloatHistogramToHistogramProto(2, test.GenerateTestFloatHistogram(3)),
+			}},
+			expected: []sample{{t: 1, fh: test.GenerateTestFloatHistogram(1)}, {t: 2, fh: test.GenerateTestFloatHistogram(2)}},
+		},
+		"mixed floats and histograms are matched by timestamp only": {
+			series: mimirpb.TimeSeries{
+				// The underlying iterator returns the histogram first when a float has the same timestamp.
+				Samples: floats(1, 3, 3),
+				Histograms: []mimirpb.Histogram{
+					mimirpb.FromHistogramToHistogramProto(2, test.GenerateTestHistogram(1)),
+					mimirpb.FromHistogramToHistogramProto(3, test.GenerateTestHistogram(2)),
+					mimirpb.FromHistogramToHistogramProto(4, test.GenerateTestHistogram(3)),
+				},
+			},
+			expected: []sample{{t: 1, f: 0}, {t: 2, h: test.GenerateTestHistogram(1)}, {t: 3, h: test.GenerateTestHistogram(2)}, {t: 4, h: test.GenerateTestHistogram(3)}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.series.Labels = []mimirpb.LabelAdapter{{Name: "__name__", Value: "metric"}}
+			set := NewDedupSeriesSet(newTimeSeriesSeriesSet([]mimirpb.TimeSeries{tc.series}))
+
+			require.True(t, set.Next())
+			it := set.At().Iterator(nil)
+			var actual []sample
+			for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+				switch typ {
+				case chunkenc.ValFloat:
+					ts, f := it.At()
+					actual = append(actual, sample{t: ts, f: f})
+				case chunkenc.ValHistogram:
+					ts, h := it.AtHistogram(nil)
+					actual = append(actual, sample{t: ts, h: h})
+				case chunkenc.ValFloatHistogram:
+					ts, fh := it.AtFloatHistogram(nil)
+					actual = append(actual, sample{t: ts, fh: fh})
+				}
+			}
+			require.NoError(t, it.Err())
+			require.Equal(t, tc.expected, actual)
+			require.False(t, set.Next())
+			require.NoError(t, set.Err())
+		})
+	}
+
+	t.Run("seek", func(t *testing.T) {
+		set := NewDedupSeriesSet(newTimeSeriesSeriesSet([]mimirpb.TimeSeries{{
+			Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: "metric"}},
+			Samples: floats(1, 2, 2, 3, 3),
+		}}))
+		require.True(t, set.Next())
+		it := set.At().Iterator(nil)
+
+		require.Equal(t, chunkenc.ValFloat, it.Seek(2))
+		require.Equal(t, int64(2), it.AtT())
+		// Seeking before the current sample doesn't move the iterator.
+		require.Equal(t, chunkenc.ValFloat, it.Seek(1))
+		require.Equal(t, int64(2), it.AtT())
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+		require.Equal(t, int64(3), it.AtT())
+		require.Equal(t, chunkenc.ValNone, it.Next())
+	})
+
+	t.Run("iterator reuse", func(t *testing.T) {
+		set := NewDedupSeriesSet(newTimeSeriesSeriesSet([]mimirpb.TimeSeries{
+			{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: "a"}}, Samples: floats(5, 5, 6)},
+			{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: "b"}}, Samples: floats(1, 1, 2)},
+		}))
+		var it chunkenc.Iterator
+		for _, expected := range [][]int64{{5, 6}, {1, 2}} {
+			require.True(t, set.Next())
+			reused := set.At().Iterator(it)
+			if it != nil {
+				require.Same(t, it, reused)
+			}
+			it = reused
+
+			var actual []int64
+			for it.Next() != chunkenc.ValNone {
+				actual = append(actual, it.AtT())
+			}
+			require.Equal(t, expected, actual)
+		}
+	})
+}
+
+func BenchmarkDedupSeriesSet(b *testing.B) {
+	const numSamples = 10000
+
+	samples := make([]mimirpb.Sample, 0, numSamples)
+	for i := 0; i < numSamples; i++ {
+		samples = append(samples, mimirpb.Sample{TimestampMs: int64(i), Value: float64(i)})
+	}
+	series := []mimirpb.TimeSeries{{Labels: []mimirpb.LabelAdapter{{Name: "__name__", Value: "metric"}}, Samples: samples}}
+
+	for _, dedup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("dedup=%t", dedup), func(b *testing.B) {
+			b.ReportAllocs()
+			var it chunkenc.Iterator
+			for n := 0; n < b.N; n++ {
+				var set storage.SeriesSet = newTimeSeriesSeriesSet(series)
+				if dedup {
+					set = NewDedupSeriesSet(set)
+				}
+				for set.Next() {
+					it = set.At().Iterator(it)
+					for it.Next() != chunkenc.ValNone {
+						it.At()
+					}
+				}
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/grafana/mimir/pkg/cardinality"
 	"github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/series"
 	"github.com/grafana/mimir/pkg/util"
@@ -139,6 +140,9 @@ func (q *distributorQuerier) streamingSelect(ctx context.Context, minT, maxT int
 		chunkInfo.LogSelect("ingester", minT, maxT)
 	}
 
+	queryStats := stats.FromContext(ctx)
+	mergeConflicts := batch.MergeConflictsFromContext(ctx)
+	mergeOptions := batch.MergeOptionsFromContext(ctx)
 	serieses := make([]storage.Series, 0, len(results.Chunkseries))
 	for i, result := range results.Chunkseries {
 		ls := mimirpb.FromLabelAdaptersToLabels(result.Labels)
@@ -160,8 +164,11 @@ func (q *distributorQuerier) streamingSelect(ctx context.Context, minT, maxT int
 		}
 
 		serieses = append(serieses, &chunkSeries{
-			labels: ls,
-			chunks: chunks,
+			labels:         ls,
+			chunks:         chunks,
+			queryStats:     queryStats,
+			mergeConflicts: mergeConflicts,
+			mergeOptions:   mergeOptions,
 		})
 	}
 
@@ -172,8 +179,10 @@ func (q *distributorQuerier) streamingSelect(ctx context.Context, minT, maxT int
 	if len(results.StreamingSeries) > 0 {
 		streamingSeries := make([]storage.Series, 0, len(results.StreamingSeries))
 		streamingChunkSeriesConfig := &streamingChunkSeriesContext{
-			queryMetrics: q.queryMetrics,
-			queryStats:   stats.FromContext(ctx),
+			queryMetrics:   q.queryMetrics,
+			queryStats:     queryStats,
+			mergeConflicts: mergeConflicts,
+			mergeOptions:   mergeOptions,
 		}
 
 		if chunkInfo != nil {
@@ -16,8 +16,10 @@ import (
 )
 
 type streamingChunkSeriesContext struct {
-	queryMetrics *stats.QueryMetrics
-	queryStats   *stats.Stats
+	queryMetrics   *stats.QueryMetrics
+	queryStats     *stats.Stats
+	mergeConflicts *batch.MergeConflicts
+	mergeOptions   batch.MergeOptions
 }
 
 // streamingChunkSeries is a storage.Series that reads chunks from sources in a streaming way. The chunks are read from
@@ -90,5 +92,5 @@ func (s *streamingChunkSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator
 		return series.NewErrIterator(err)
 	}
 
-	return batch.NewChunkMergeIterator(it, s.labels, chunks)
+	return batch.NewChunkMergeIterator(it, s.labels, chunks, s.context.queryStats, s.context.mergeConflicts, s.context.mergeOptions)
 }
@@ -74,6 +74,12 @@ func TestDuplicatesSamples(t *testing.T) {
 		out := runPromQLAndGetJSONResult(t, "rate(metr[1m])", deduped, 10*time.Second)
 		require.NotContains(t, out, "\"NaN\"")
 	}
+
+	// run same query, but deduplicating the samples at iteration time
+	{
+		out := runPromQLOnSeriesSetAndGetJSONResult(t, "rate(metr[1m])", NewDedupSeriesSet(newTimeSeriesSeriesSet([]mimirpb.TimeSeries{ts})), ts, 10*time.Second)
+		require.NotContains(t, out, "\"NaN\"")
+	}
 }
 
 func dedupeSorted(samples []mimirpb.Sample) []mimirpb.Sample {
@@ -91,7 +97,12 @@ func dedupeSorted(samples []mimirpb.Sample) []mimirpb.Sample {
 }
 
 func runPromQLAndGetJSONResult(t *testing.T, query string, ts mimirpb.TimeSeries, step time.Duration) string {
-	tq := &testQueryable{ts: newTimeSeriesSeriesSet([]mimirpb.TimeSeries{ts})}
+	return runPromQLOnSeriesSetAndGetJSONResult(t, query, newTimeSeriesSeriesSet([]mimirpb.TimeSeries{ts}), ts, step)
+}
+
+// runPromQLOnSeriesSetAndGetJSONResult runs the query on the set, over the time range of the samples of ts.
+func runPromQLOnSeriesSetAndGetJSONResult(t *testing.T, query string, set storage.SeriesSet, ts mimirpb.TimeSeries, step time.Duration) string {
+	tq := &testQueryable{ts: set}
 
 	engine := promql.NewEngine(promql.EngineOpts{
 		Logger:     promslog.NewNopLogger(),
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+
+	"github.com/grafana/mimir/pkg/querier/batch"
+)
+
+// mergeConflictsEngine is a promql.QueryEngine whose query results include an info annotation naming the series with
+// float samples of equal timestamps and different values, of which the queriers only kept one while merging chunks,
+// and one counting the series whose samples were merged from chunks interleaving in time.
+type mergeConflictsEngine struct {
+	promql.QueryEngine
+}
+
+func newMergeConflictsEngine(eng promql.QueryEngine) promql.QueryEngine {
+	return mergeConflictsEngine{QueryEngine: eng}
+}
+
+func (e mergeConflictsEngine) NewInstantQuery(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, ts time.Time) (promql.Query, error) {
+	query, err := e.QueryEngine.NewInstantQuery(ctx, q, opts, qs, ts)
+	if err != nil {
+		return nil, err
+	}
+	return mergeConflictsQuery{Query: query}, nil
+}
+
+func (e mergeConflictsEngine) NewRangeQuery(ctx context.Context, q storage.Queryable, opts promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (promql.Query, error) {
+	query, err := e.QueryEngine.NewRangeQuery(ctx, q, opts, qs, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+	return mergeConflictsQuery{Query: query}, nil
+}
+
+type mergeConflictsQuery struct {
+	promql.Query
+}
+
+// Exec runs the query with the merge conflicts collected in the context, so that they're all known once the
+// samples have been evaluated. The engines read the warnings of the series sets before iterating their samples.
+func (q mergeConflictsQuery) Exec(ctx context.Context) *promql.Result {
+	conflicts := batch.NewMergeConflicts()
+	res := q.Query.Exec(batch.ContextWithMergeConflicts(ctx, conflicts))
+	if res.Err == nil {
+		res.Warnings.Merge(conflicts.Annotations())
+	}
+	return res
+}
+
+// withMergeConflicts returns a SeriesSet whose warnings include the merge conflicts found so far in the samples of
+// its series, if conflicts is not nil. Only the series iterated before calling Warnings are accounted for.
+func withMergeConflicts(set storage.SeriesSet, conflicts *batch.MergeConflicts) storage.SeriesSet {
+	if conflicts == nil {
+		return set
+	}
+	return &mergeConflictsSeriesSet{SeriesSet: set, conflicts: conflicts}
+}
+
+type mergeConflictsSeriesSet struct {
+	storage.SeriesSet
+	conflicts *batch.MergeConflicts
+}
+
+func (s *mergeConflictsSeriesSet) Warnings() annotations.Annotations {
+	var res annotations.Annotations
+	res.Merge(s.SeriesSet.Warnings())
+	return res.Merge(s.conflicts.Annotations())
+}
@@ -11,12 +11,15 @@ import (
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 
 	"github.com/grafana/mimir/pkg/querier/batch"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	seriesset "github.com/grafana/mimir/pkg/storage/series"
 )
 
-// Series in the returned set are sorted alphabetically by labels.
-func partitionChunks(chunks []chunk.Chunk) storage.SeriesSet {
+// Series in the returned set are sorted alphabetically by labels. The chunks of the series are merged with
+// mergeOptions. The statistics of the merge are added to queryStats, and the conflicting samples found merging them
+// are collected in mergeConflicts, if not nil.
+func partitionChunks(chunks []chunk.Chunk, queryStats *stats.Stats, mergeConflicts *batch.MergeConflicts, mergeOptions batch.MergeOptions) storage.SeriesSet {
 	chunksBySeries := map[string][]chunk.Chunk{}
 	var buf [1024]byte
 	for _, c := range chunks {
@@ -27,8 +30,11 @@ func partitionChunks(chunks []chunk.Chunk) storage.SeriesSet {
 	series := make([]storage.Series, 0, len(chunksBySeries))
 	for i := range chunksBySeries {
 		series = append(series, &chunkSeries{
-			labels: chunksBySeries[i][0].Metric,
-			chunks: chunksBySeries[i],
+			labels:         chunksBySeries[i][0].Metric,
+			chunks:         chunksBySeries[i],
+			queryStats:     queryStats,
+			mergeConflicts: mergeConflicts,
+			mergeOptions:   mergeOptions,
 		})
 	}
 
@@ -39,6 +45,13 @@ func partitionChunks(chunks []chunk.Chunk) storage.SeriesSet {
 type chunkSeries struct {
 	labels labels.Labels
 	chunks []chunk.Chunk
+
+	// queryStats, if not nil, collects the statistics of the merge of the chunks.
+	queryStats *stats.Stats
+	// mergeConflicts, if not nil, collects the conflicting samples found merging the chunks.
+	mergeConflicts *batch.MergeConflicts
+	// mergeOptions are the options of the merge of the chunks.
+	mergeOptions batch.MergeOptions
 }
 
 func (s *chunkSeries) Labels() labels.Labels {
@@ -47,7 +60,7 @@ func (s *chunkSeries) Labels() labels.Labels {
 
 // Iterator returns a new iterator of the data of the series.
 func (s *chunkSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
-	return batch.NewChunkMergeIterator(it, s.labels, s.chunks)
+	return batch.NewChunkMergeIterator(it, s.labels, s.chunks, s.queryStats, s.mergeConflicts, s.mergeOptions)
 }
 
 // Chunks implements SeriesWithChunks interface.
@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	"github.com/grafana/mimir/pkg/util/test"
 )
@@ -73,7 +74,7 @@ func testPartitionChunksOutputIsSortedByLabels(t *testing.T, encoding chunk.Enco
 		allChunks = append(allChunks, ch)
 	}
 
-	res := partitionChunks(allChunks)
+	res := partitionChunks(allChunks, nil, nil, batch.MergeOptions{})
 
 	// collect labels from each series
 	var seriesLabels []labels.Labels
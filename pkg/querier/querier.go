@@ -26,6 +26,7 @@ import (
 	v1 "github.com/prometheus/prometheus/web/api/v1"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/querier/engine"
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
@@ -58,6 +59,11 @@ type Config struct {
 	QueryEngine               string `yaml:"query_engine" category:"experimental"`
 	EnableQueryEngineFallback bool   `yaml:"enable_query_engine_fallback" category:"experimental"`
 
+	DeduplicateSamples            bool `yaml:"deduplicate_samples" category:"experimental"`
+	AnnotateMergeConflicts        bool `yaml:"annotate_merge_conflicts" category:"experimental"`
+	MergePreferLaterChunks        bool `yaml:"merge_prefer_later_chunks" category:"experimental"`
+	MergeCopyHistogramsOnConflict bool `yaml:"merge_copy_histograms_on_conflict" category:"experimental"`
+
 	// PromQL engine config.
 	EngineConfig engine.Config `yaml:",inline"`
 }
@@ -88,6 +94,11 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.QueryEngine, "querier.query-engine", prometheusEngine, fmt.Sprintf("Query engine to use, either '%v' or '%v'", prometheusEngine, mimirEngine))
 	f.BoolVar(&cfg.EnableQueryEngineFallback, "querier.enable-query-engine-fallback", true, "If set to true and the Mimir query engine is in use, fall back to using the Prometheus query engine for any queries not supported by the Mimir query engine.")
 
+	f.BoolVar(&cfg.DeduplicateSamples, "querier.deduplicate-samples", false, "If true, samples with the same timestamp as the previous sample of the series are dropped when merging the series from ingesters and store-gateways, keeping the first one.")
+	f.BoolVar(&cfg.AnnotateMergeConflicts, "querier.annotate-merge-conflicts", false, "If true, queries return an info annotation naming the series with float samples of equal timestamps and different values, found while merging the chunks of the series. Only one of the conflicting values is kept. Queries also return an info annotation counting the series whose samples were merged from chunks interleaving in time.")
+	f.BoolVar(&cfg.MergePreferLaterChunks, "querier.merge-prefer-later-chunks", false, "If true, when merging the chunks of a series, the sample of the chunk starting later takes precedence over the samples with the same timestamp of the other chunks, whatever their types. If false, a native histogram takes precedence over a float, and between samples of the same type the one merged first is kept.")
+	f.BoolVar(&cfg.MergeCopyHistogramsOnConflict, "querier.merge-copy-histograms-on-conflict", false, "If true, when merging the chunks of a series, the native histogram kept for samples with equal timestamps is copied, rather than shared with the chunk it comes from, and the discarded one is not reused. This guards against corrupted histograms when the same chunk is returned by several sources, at the cost of more allocations.")
+
 	cfg.EngineConfig.RegisterFlags(f)
 }
 
@@ -181,6 +192,9 @@ func New(cfg Config, limits *validation.Overrides, distributor Distributor, quer
 	default:
 		panic(fmt.Sprintf("invalid config not caught by validation: unknown PromQL engine '%s'", cfg.QueryEngine))
 	}
+	if cfg.AnnotateMergeConflicts {
+		eng = newMergeConflictsEngine(eng)
+	}
 
 	return NewSampleAndChunkQueryable(lazyQueryable), exemplarQueryable, eng, nil
 }
@@ -358,8 +372,23 @@ func (mq multiQuerier) Select(ctx context.Context, _ bool, sp *storage.SelectHin
 		return storage.ErrSeriesSet(NewMaxQueryLengthError(endTime.Sub(startTime), maxQueryLength))
 	}
 
+	// The merge conflicts are reported by whoever collects them: the engine running the query, if it does,
+	// or the returned series set.
+	var conflicts *batch.MergeConflicts
+	if mq.cfg.AnnotateMergeConflicts && batch.MergeConflictsFromContext(ctx) == nil {
+		conflicts = batch.NewMergeConflicts()
+		ctx = batch.ContextWithMergeConflicts(ctx, conflicts)
+	}
+
+	if mq.cfg.MergePreferLaterChunks || mq.cfg.MergeCopyHistogramsOnConflict {
+		ctx = batch.ContextWithMergeOptions(ctx, batch.MergeOptions{
+			PreferLaterChunks:        mq.cfg.MergePreferLaterChunks,
+			CopyHistogramsOnConflict: mq.cfg.MergeCopyHistogramsOnConflict,
+		})
+	}
+
 	if len(queriers) == 1 {
-		return queriers[0].Select(ctx, true, sp, matchers...)
+		return withMergeConflicts(mq.dedupSamples(queriers[0].Select(ctx, true, sp, matchers...)), conflicts)
 	}
 
 	sets := make(chan storage.SeriesSet, len(queriers))
@@ -382,7 +411,15 @@ func (mq multiQuerier) Select(ctx context.Context, _ bool, sp *storage.SelectHin
 	// we have all the sets from different sources (chunk from store, chunks from ingesters,
 	// time series from store and time series from ingesters).
 	// mergeSeriesSets will return sorted set.
-	return mq.mergeSeriesSets(result)
+	return withMergeConflicts(mq.dedupSamples(mq.mergeSeriesSets(result, stats.FromContext(ctx), batch.MergeConflictsFromContext(ctx), batch.MergeOptionsFromContext(ctx))), conflicts)
+}
+
+// dedupSamples drops the samples with duplicated timestamps from the series of the set, if enabled.
+func (mq multiQuerier) dedupSamples(set storage.SeriesSet) storage.SeriesSet {
+	if !mq.cfg.DeduplicateSamples {
+		return set
+	}
+	return NewDedupSeriesSet(set)
 }
 
 func clampToMaxLabelQueryLength(spanLog *spanlogger.SpanLogger, startMs, endMs, nowMs, maxLabelQueryLengthMs int64) int64 {
@@ -521,7 +558,7 @@ func (multiQuerier) Close() error {
 	return nil
 }
 
-func (mq multiQuerier) mergeSeriesSets(sets []storage.SeriesSet) storage.SeriesSet {
+func (mq multiQuerier) mergeSeriesSets(sets []storage.SeriesSet, queryStats *stats.Stats, mergeConflicts *batch.MergeConflicts, mergeOptions batch.MergeOptions) storage.SeriesSet {
 	// Here we deal with sets that are based on chunks and build single set from them.
 	// Remaining sets are merged with chunks-based one using storage.NewMergeSeriesSet
 
@@ -554,7 +591,7 @@ func (mq multiQuerier) mergeSeriesSets(sets []storage.SeriesSet) storage.SeriesS
 	}
 
 	// partitionChunks returns set with sorted series, so it can be used by NewMergeSeriesSet
-	chunksSet := partitionChunks(chunks)
+	chunksSet := partitionChunks(chunks, queryStats, mergeConflicts, mergeOptions)
 
 	if len(otherSets) == 0 {
 		return chunksSet
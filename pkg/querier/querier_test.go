@@ -425,6 +425,231 @@ func TestBatchMergeChunks(t *testing.T) {
 	require.ElementsMatch(t, m[0].Histograms, m[1].Histograms)
 }
 
+func TestQuerier_AnnotateMergeConflicts(t *testing.T) {
+	var (
+		logger     = log.NewNopLogger()
+		queryStart = mustParseTime("2021-11-01T06:00:00Z")
+		queryEnd   = mustParseTime("2021-11-01T06:01:00Z")
+		queryStep  = time.Second
+	)
+
+	limits := defaultLimitsConfig()
+	limits.QueryIngestersWithin = 0 // Always query ingesters in this test.
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	// The two sources have the same samples, but the values of some of them differ for the series "foo".
+	var s1, s2 []mimirpb.Sample
+	for i := 0; i < 12; i++ {
+		ts := queryStart.Add(time.Duration(i) * time.Second).UnixMilli()
+		s1 = append(s1, mimirpb.Sample{Value: float64(i), TimestampMs: ts})
+		if i%4 == 0 {
+			s2 = append(s2, mimirpb.Sample{Value: float64(i) + 0.5, TimestampMs: ts})
+		} else {
+			s2 = append(s2, mimirpb.Sample{Value: float64(i), TimestampMs: ts})
+		}
+	}
+	c1 := convertToChunks(t, samplesToInterface(s1), false)
+	c2 := convertToChunks(t, samplesToInterface(s2), false)
+
+	distributor := &mockDistributor{}
+	distributor.On("QueryStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		client.CombinedQueryStreamResponse{
+			Chunkseries: []client.TimeSeriesChunk{
+				{
+					Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "one"}, {Name: labels.InstanceName, Value: "foo"}},
+					Chunks: append(append([]client.Chunk{}, c1...), c2...),
+				},
+				{
+					Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "one"}, {Name: labels.InstanceName, Value: "bar"}},
+					Chunks: append(append([]client.Chunk{}, c1...), c1...),
+				},
+			},
+		},
+		nil)
+
+	const expected = `PromQL info: 3 conflicting samples resolved during merge for series {__name__="one", instance="foo"}`
+	infos := func(annos annotations.Annotations) []string {
+		_, infos := annos.AsStrings("", 0, 0)
+		return infos
+	}
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			var cfg Config
+			flagext.DefaultValues(&cfg)
+			cfg.AnnotateMergeConflicts = enabled
+
+			queryable, _, engine, err := New(cfg, overrides, distributor, nil, nil, logger, nil)
+			require.NoError(t, err)
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+
+			t.Run("query", func(t *testing.T) {
+				query, err := engine.NewRangeQuery(ctx, queryable, nil, `one`, queryStart, queryEnd, queryStep)
+				require.NoError(t, err)
+				defer query.Close()
+
+				r := query.Exec(ctx)
+				m, err := r.Matrix()
+				require.NoError(t, err)
+				require.Equal(t, 2, m.Len())
+				if enabled {
+					require.Equal(t, []string{expected}, infos(r.Warnings))
+				} else {
+					require.Empty(t, infos(r.Warnings))
+				}
+			})
+
+			t.Run("select", func(t *testing.T) {
+				querier, err := queryable.Querier(queryStart.UnixMilli(), queryEnd.UnixMilli())
+				require.NoError(t, err)
+				defer querier.Close()
+
+				set := querier.Select(ctx, true, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "one"))
+				var it chunkenc.Iterator
+				for set.Next() {
+					it = set.At().Iterator(it)
+					for it.Next() != chunkenc.ValNone {
+					}
+					require.NoError(t, it.Err())
+				}
+				require.NoError(t, set.Err())
+				if enabled {
+					require.Equal(t, []string{expected}, infos(set.Warnings()))
+				} else {
+					require.Empty(t, infos(set.Warnings()))
+				}
+			})
+		})
+	}
+}
+
+func TestQuerier_ChunkMergeStats(t *testing.T) {
+	var (
+		logger     = log.NewNopLogger()
+		queryStart = mustParseTime("2021-11-01T06:00:00Z")
+		queryEnd   = mustParseTime("2021-11-01T06:01:00Z")
+		queryStep  = time.Second
+	)
+
+	limits := defaultLimitsConfig()
+	limits.QueryIngestersWithin = 0 // Always query ingesters in this test.
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	var samples []mimirpb.Sample
+	for i := 0; i < 12; i++ {
+		samples = append(samples, mimirpb.Sample{Value: float64(i), TimestampMs: queryStart.Add(time.Duration(i) * time.Second).UnixMilli()})
+	}
+	chunks := convertToChunks(t, samplesToInterface(samples), false)
+
+	// The chunks of the series "foo" are returned twice, so all their samples are duplicates, while the ones
+	// of the series "bar" don't overlap.
+	distributor := &mockDistributor{}
+	distributor.On("QueryStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		client.CombinedQueryStreamResponse{
+			Chunkseries: []client.TimeSeriesChunk{
+				{
+					Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "one"}, {Name: labels.InstanceName, Value: "foo"}},
+					Chunks: append(append([]client.Chunk{}, chunks...), chunks...),
+				},
+				{
+					Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "one"}, {Name: labels.InstanceName, Value: "bar"}},
+					Chunks: chunks,
+				},
+			},
+		},
+		nil)
+
+	var cfg Config
+	flagext.DefaultValues(&cfg)
+	queryable, _, engine, err := New(cfg, overrides, distributor, nil, nil, logger, nil)
+	require.NoError(t, err)
+
+	queryStats, ctx := stats.ContextWithEmptyStats(user.InjectOrgID(context.Background(), "user-1"))
+	query, err := engine.NewRangeQuery(ctx, queryable, nil, `one`, queryStart, queryEnd, queryStep)
+	require.NoError(t, err)
+	defer query.Close()
+
+	r := query.Exec(ctx)
+	m, err := r.Matrix()
+	require.NoError(t, err)
+	require.Equal(t, 2, m.Len())
+
+	assert.Equal(t, uint64(len(samples)), queryStats.LoadChunkMergeDuplicateSamples())
+	assert.Positive(t, queryStats.LoadChunkMergeAppendedBatches())
+	assert.Positive(t, queryStats.LoadChunkMergeMergedBatches())
+}
+
+func TestQuerier_MergePreferLaterChunks(t *testing.T) {
+	queryStart := mustParseTime("2021-11-01T06:00:00Z")
+
+	limits := defaultLimitsConfig()
+	limits.QueryIngestersWithin = 0 // Always query ingesters in this test.
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	// mkChunks returns the chunks of the samples every second in the given range of seconds, with the given value.
+	mkChunks := func(from, to int, value float64) []client.Chunk {
+		var samples []mimirpb.Sample
+		for i := from; i < to; i++ {
+			samples = append(samples, mimirpb.Sample{Value: value, TimestampMs: queryStart.Add(time.Duration(i) * time.Second).UnixMilli()})
+		}
+		return convertToChunks(t, samplesToInterface(samples), false)
+	}
+
+	// The samples of the later chunk overlap the last 6 samples of the earlier one, which fits a single batch.
+	distributor := &mockDistributor{}
+	distributor.On("QueryStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		client.CombinedQueryStreamResponse{
+			Chunkseries: []client.TimeSeriesChunk{
+				{
+					Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "one"}},
+					Chunks: append(mkChunks(6, 12, 2), mkChunks(0, 12, 1)...),
+				},
+			},
+		},
+		nil)
+
+	for _, preferLater := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prefer later chunks=%t", preferLater), func(t *testing.T) {
+			var cfg Config
+			flagext.DefaultValues(&cfg)
+			cfg.MergePreferLaterChunks = preferLater
+			queryable, _, _, err := New(cfg, overrides, distributor, nil, nil, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			querier, err := queryable.Querier(queryStart.UnixMilli(), queryStart.Add(time.Minute).UnixMilli())
+			require.NoError(t, err)
+			set := querier.Select(ctx, true, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "one"))
+			require.True(t, set.Next())
+
+			var values []float64
+			it := set.At().Iterator(nil)
+			for it.Next() != chunkenc.ValNone {
+				_, v := it.At()
+				values = append(values, v)
+			}
+			require.NoError(t, it.Err())
+			require.False(t, set.Next())
+			require.NoError(t, set.Err())
+
+			require.Len(t, values, 12)
+			require.Equal(t, []float64{1, 1, 1, 1, 1, 1}, values[:6])
+			if preferLater {
+				require.Equal(t, []float64{2, 2, 2, 2, 2, 2}, values[6:])
+				return
+			}
+			// Otherwise, the sample merged first is kept, which depends on the size of the merged batches.
+			for _, v := range values[6:] {
+				require.Contains(t, []float64{1, 2}, v)
+			}
+		})
+	}
+}
+
 func BenchmarkQueryExecute(b *testing.B) {
 	var (
 		logger    = log.NewNopLogger()
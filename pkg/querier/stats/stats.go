@@ -203,6 +203,54 @@ func (s *Stats) LoadEncodeTime() time.Duration {
 	return time.Duration(atomic.LoadInt64((*int64)(&s.EncodeTime)))
 }
 
+func (s *Stats) AddChunkMergeDuplicateSamples(count uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.ChunkMergeDuplicateSamples, count)
+}
+
+func (s *Stats) LoadChunkMergeDuplicateSamples() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.ChunkMergeDuplicateSamples)
+}
+
+func (s *Stats) AddChunkMergeAppendedBatches(count uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.ChunkMergeAppendedBatches, count)
+}
+
+func (s *Stats) LoadChunkMergeAppendedBatches() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.ChunkMergeAppendedBatches)
+}
+
+func (s *Stats) AddChunkMergeMergedBatches(count uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.ChunkMergeMergedBatches, count)
+}
+
+func (s *Stats) LoadChunkMergeMergedBatches() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.ChunkMergeMergedBatches)
+}
+
 // Merge the provided Stats into this one.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
@@ -219,6 +267,9 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddEstimatedSeriesCount(other.LoadEstimatedSeriesCount())
 	s.AddQueueTime(other.LoadQueueTime())
 	s.AddEncodeTime(other.LoadEncodeTime())
+	s.AddChunkMergeDuplicateSamples(other.LoadChunkMergeDuplicateSamples())
+	s.AddChunkMergeAppendedBatches(other.LoadChunkMergeAppendedBatches())
+	s.AddChunkMergeMergedBatches(other.LoadChunkMergeMergedBatches())
 }
 
 // Copy returns a copy of the stats. Use this rather than regular struct assignment
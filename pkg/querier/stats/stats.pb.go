@@ -50,6 +50,12 @@ type Stats struct {
 	QueueTime time.Duration `protobuf:"bytes,9,opt,name=queue_time,json=queueTime,proto3,stdduration" json:"queue_time"`
 	// The time spent at the frontend encoding the query's final results. Does not include time spent serializing results at the querier.
 	EncodeTime time.Duration `protobuf:"bytes,10,opt,name=encode_time,json=encodeTime,proto3,stdduration" json:"encode_time"`
+	// The number of samples of the chunks of a series discarded while merging them, because another sample had the same timestamp.
+	ChunkMergeDuplicateSamples uint64 `protobuf:"varint,11,opt,name=chunk_merge_duplicate_samples,json=chunkMergeDuplicateSamples,proto3" json:"chunk_merge_duplicate_samples,omitempty"`
+	// The number of batches of chunk samples appended to their series as a whole while merging, because they came after the samples already merged.
+	ChunkMergeAppendedBatches uint64 `protobuf:"varint,12,opt,name=chunk_merge_appended_batches,json=chunkMergeAppendedBatches,proto3" json:"chunk_merge_appended_batches,omitempty"`
+	// The number of batches of chunk samples merged sample by sample into their series, because they overlapped the samples already merged.
+	ChunkMergeMergedBatches uint64 `protobuf:"varint,13,opt,name=chunk_merge_merged_batches,json=chunkMergeMergedBatches,proto3" json:"chunk_merge_merged_batches,omitempty"`
 }
 
 func (m *Stats) Reset()      { *m = Stats{} }
@@ -154,6 +160,27 @@ func (m *Stats) GetEncodeTime() time.Duration {
 	return 0
 }
 
+func (m *Stats) GetChunkMergeDuplicateSamples() uint64 {
+	if m != nil {
+		return m.ChunkMergeDuplicateSamples
+	}
+	return 0
+}
+
+func (m *Stats) GetChunkMergeAppendedBatches() uint64 {
+	if m != nil {
+		return m.ChunkMergeAppendedBatches
+	}
+	return 0
+}
+
+func (m *Stats) GetChunkMergeMergedBatches() uint64 {
+	if m != nil {
+		return m.ChunkMergeMergedBatches
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Stats)(nil), "stats.Stats")
 }
@@ -161,32 +188,37 @@ func init() {
 func init() { proto.RegisterFile("stats.proto", fileDescriptor_b4756a0aec8b9d44) }
 
 var fileDescriptor_b4756a0aec8b9d44 = []byte{
-	// 394 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x92, 0xb1, 0x4e, 0xc2, 0x40,
-	0x18, 0xc7, 0x7b, 0x0a, 0x08, 0x87, 0x68, 0xac, 0xc4, 0x54, 0x86, 0x83, 0xe8, 0x20, 0x53, 0x31,
-	0xea, 0xe6, 0x62, 0x0a, 0x8b, 0xa3, 0xe0, 0xe4, 0xd2, 0x94, 0xf6, 0x28, 0x8d, 0xa5, 0x07, 0xbd,
-	0x6b, 0xd4, 0xcd, 0x47, 0x70, 0x34, 0xf1, 0x05, 0x7c, 0x14, 0x46, 0x46, 0x26, 0x95, 0xb2, 0x38,
-	0xf2, 0x08, 0xe6, 0xee, 0x5a, 0x02, 0x4e, 0x6c, 0xbd, 0xef, 0xf7, 0xfd, 0xee, 0xff, 0xe5, 0xbe,
-	0xc2, 0x22, 0x65, 0x16, 0xa3, 0xfa, 0x30, 0x24, 0x8c, 0xa8, 0x59, 0x71, 0xa8, 0x94, 0x5d, 0xe2,
-	0x12, 0x51, 0x69, 0xf0, 0x2f, 0x09, 0x2b, 0xc8, 0x25, 0xc4, 0xf5, 0x71, 0x43, 0x9c, 0xba, 0x51,
-	0xaf, 0xe1, 0x44, 0xa1, 0xc5, 0x3c, 0x12, 0x48, 0x7e, 0xf2, 0x91, 0x81, 0xd9, 0x0e, 0xf7, 0xd5,
-	0x1b, 0x58, 0x78, 0xb2, 0x7c, 0xdf, 0x64, 0xde, 0x00, 0x6b, 0xa0, 0x06, 0xea, 0xc5, 0x8b, 0x63,
-	0x5d, 0xda, 0x7a, 0x6a, 0xeb, 0xad, 0xc4, 0x36, 0xf2, 0xe3, 0xaf, 0xaa, 0xf2, 0xfe, 0x5d, 0x05,
-	0xed, 0x3c, 0xb7, 0xee, 0xbd, 0x01, 0x56, 0xcf, 0x61, 0xb9, 0x87, 0x99, 0xdd, 0xc7, 0x8e, 0x49,
-	0x71, 0xe8, 0x61, 0x6a, 0xda, 0x24, 0x0a, 0x98, 0xb6, 0x55, 0x03, 0xf5, 0x4c, 0x5b, 0x4d, 0x58,
-	0x47, 0xa0, 0x26, 0x27, 0xaa, 0x0e, 0x0f, 0x53, 0xc3, 0xee, 0x47, 0xc1, 0xa3, 0xd9, 0x7d, 0x61,
-	0x98, 0x6a, 0xdb, 0x42, 0x38, 0x48, 0x50, 0x93, 0x13, 0x83, 0x83, 0xd5, 0x04, 0xd1, 0x9f, 0x26,
-	0x64, 0xd6, 0x12, 0x84, 0x90, 0x24, 0x9c, 0xc1, 0x7d, 0xda, 0xb7, 0x42, 0x07, 0x3b, 0xe6, 0x28,
-	0x12, 0xc9, 0x5a, 0xb6, 0x06, 0xea, 0xa5, 0xf6, 0x5e, 0x52, 0xbe, 0x93, 0x55, 0xf5, 0x14, 0x96,
-	0xe8, 0xd0, 0xf7, 0xd8, 0xb2, 0x2d, 0x27, 0xda, 0x76, 0x45, 0x31, 0x6d, 0x5a, 0x99, 0xd7, 0x0b,
-	0x1c, 0xfc, 0x9c, 0xcc, 0xbb, 0xb3, 0x36, 0xef, 0x2d, 0x27, 0x72, 0xde, 0x2b, 0x78, 0x84, 0x29,
-	0xf3, 0x06, 0x16, 0xfb, 0xff, 0x26, 0x79, 0xa1, 0x94, 0x97, 0x74, 0xf5, 0x55, 0x0c, 0x08, 0x47,
-	0x11, 0x8e, 0xb0, 0x5c, 0x45, 0x61, 0xf3, 0x55, 0x14, 0x84, 0x26, 0x76, 0xd1, 0x82, 0x45, 0x1c,
-	0xd8, 0xc4, 0x49, 0x2e, 0x81, 0x9b, 0x5f, 0x02, 0xa5, 0xc7, 0x6f, 0x31, 0xae, 0x27, 0x33, 0xa4,
-	0x4c, 0x67, 0x48, 0x59, 0xcc, 0x10, 0x78, 0x8d, 0x11, 0xf8, 0x8c, 0x11, 0x18, 0xc7, 0x08, 0x4c,
-	0x62, 0x04, 0x7e, 0x62, 0x04, 0x7e, 0x63, 0xa4, 0x2c, 0x62, 0x04, 0xde, 0xe6, 0x48, 0x99, 0xcc,
-	0x91, 0x32, 0x9d, 0x23, 0xe5, 0x41, 0xfe, 0x90, 0xdd, 0x9c, 0x48, 0xb9, 0xfc, 0x0b, 0x00, 0x00,
-	0xff, 0xff, 0xa6, 0x97, 0xf0, 0x6e, 0xad, 0x02, 0x00, 0x00,
+	// 478 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xb1, 0x72, 0xd3, 0x40,
+	0x10, 0x86, 0x75, 0x10, 0x07, 0xfb, 0x1c, 0xc3, 0x20, 0x3c, 0xa0, 0x78, 0xe0, 0xe2, 0x81, 0x02,
+	0x57, 0x36, 0x03, 0x74, 0x29, 0x20, 0x8a, 0x1b, 0x0a, 0x0a, 0x6c, 0x2a, 0x1a, 0xcd, 0x59, 0xda,
+	0xc8, 0x1a, 0x24, 0x9d, 0xa2, 0x3b, 0x0d, 0xd0, 0xf1, 0x08, 0x94, 0x3c, 0x02, 0x8f, 0x92, 0xd2,
+	0x65, 0x0a, 0x06, 0xb0, 0xdc, 0x50, 0xe6, 0x11, 0x98, 0xdb, 0x93, 0x62, 0x85, 0x2a, 0x8d, 0xc6,
+	0xda, 0xff, 0xff, 0xfe, 0x5d, 0xef, 0x8e, 0x68, 0x57, 0x2a, 0xae, 0xe4, 0x38, 0xcb, 0x85, 0x12,
+	0x76, 0x0b, 0x5f, 0x06, 0xfd, 0x50, 0x84, 0x02, 0x2b, 0x13, 0xfd, 0xcb, 0x88, 0x03, 0x16, 0x0a,
+	0x11, 0xc6, 0x30, 0xc1, 0xb7, 0x45, 0x71, 0x32, 0x09, 0x8a, 0x9c, 0xab, 0x48, 0xa4, 0x46, 0x7f,
+	0xfc, 0xb3, 0x45, 0x5b, 0x73, 0xcd, 0xdb, 0xaf, 0x69, 0xe7, 0x13, 0x8f, 0x63, 0x4f, 0x45, 0x09,
+	0x38, 0x64, 0x48, 0x46, 0xdd, 0xe7, 0xfb, 0x63, 0x43, 0x8f, 0x6b, 0x7a, 0x3c, 0xad, 0x68, 0xb7,
+	0x7d, 0xf6, 0xeb, 0xc0, 0xfa, 0xfe, 0xfb, 0x80, 0xcc, 0xda, 0x9a, 0x7a, 0x1f, 0x25, 0x60, 0x3f,
+	0xa3, 0xfd, 0x13, 0x50, 0xfe, 0x12, 0x02, 0x4f, 0x42, 0x1e, 0x81, 0xf4, 0x7c, 0x51, 0xa4, 0xca,
+	0xb9, 0x31, 0x24, 0xa3, 0x9d, 0x99, 0x5d, 0x69, 0x73, 0x94, 0x8e, 0xb5, 0x62, 0x8f, 0xe9, 0xbd,
+	0x9a, 0xf0, 0x97, 0x45, 0xfa, 0xd1, 0x5b, 0x7c, 0x51, 0x20, 0x9d, 0x9b, 0x08, 0xdc, 0xad, 0xa4,
+	0x63, 0xad, 0xb8, 0x5a, 0x68, 0x76, 0x40, 0x7f, 0xdd, 0x61, 0xe7, 0x4a, 0x07, 0x04, 0xaa, 0x0e,
+	0x4f, 0xe9, 0x1d, 0xb9, 0xe4, 0x79, 0x00, 0x81, 0x77, 0x5a, 0x60, 0x67, 0xa7, 0x35, 0x24, 0xa3,
+	0xde, 0xec, 0x76, 0x55, 0x7e, 0x67, 0xaa, 0xf6, 0x13, 0xda, 0x93, 0x59, 0x1c, 0xa9, 0x4b, 0xdb,
+	0x2e, 0xda, 0xf6, 0xb0, 0x58, 0x9b, 0x1a, 0xf3, 0x46, 0x69, 0x00, 0x9f, 0xab, 0x79, 0x6f, 0x5d,
+	0x99, 0xf7, 0x8d, 0x56, 0xcc, 0xbc, 0x2f, 0xe9, 0x7d, 0x90, 0x2a, 0x4a, 0xb8, 0xfa, 0x7f, 0x27,
+	0x6d, 0x44, 0xfa, 0x97, 0x6a, 0x73, 0x2b, 0x2e, 0xa5, 0xa7, 0x05, 0x14, 0x60, 0x4e, 0xd1, 0xb9,
+	0xfe, 0x29, 0x3a, 0x88, 0xe1, 0x2d, 0xa6, 0xb4, 0x0b, 0xa9, 0x2f, 0x82, 0x2a, 0x84, 0x5e, 0x3f,
+	0x84, 0x1a, 0x0e, 0x53, 0x8e, 0xe8, 0x23, 0x73, 0x97, 0x04, 0xf2, 0x10, 0xbc, 0xa0, 0xc8, 0xe2,
+	0xc8, 0xe7, 0x0a, 0x3c, 0xc9, 0x93, 0x2c, 0x06, 0xe9, 0x74, 0xf1, 0x6f, 0x0c, 0xd0, 0xf4, 0x56,
+	0x7b, 0xa6, 0xb5, 0x65, 0x6e, 0x1c, 0xf6, 0x2b, 0xfa, 0xb0, 0x19, 0xc1, 0xb3, 0x0c, 0x52, 0x7d,
+	0x8d, 0x05, 0xd7, 0xcb, 0x92, 0xce, 0x1e, 0x26, 0xec, 0x6f, 0x13, 0x8e, 0x2a, 0x87, 0x6b, 0x0c,
+	0xf6, 0x21, 0x1d, 0x34, 0x03, 0xf0, 0xb9, 0xc5, 0x7b, 0x88, 0x3f, 0xd8, 0xe2, 0xf8, 0xa8, 0x61,
+	0xf7, 0x70, 0xb5, 0x66, 0xd6, 0xf9, 0x9a, 0x59, 0x17, 0x6b, 0x46, 0xbe, 0x96, 0x8c, 0xfc, 0x28,
+	0x19, 0x39, 0x2b, 0x19, 0x59, 0x95, 0x8c, 0xfc, 0x29, 0x19, 0xf9, 0x5b, 0x32, 0xeb, 0xa2, 0x64,
+	0xe4, 0xdb, 0x86, 0x59, 0xab, 0x0d, 0xb3, 0xce, 0x37, 0xcc, 0xfa, 0x60, 0xbe, 0xa8, 0xc5, 0x2e,
+	0xae, 0xe9, 0xc5, 0xbf, 0x01, 0x00, 0x48, 0xa6, 0x41, 0xb6, 0x6e, 0x03, 0x00, 0x00,
 }
 
 func (this *Stats) Equal(that interface{}) bool {
@@ -238,13 +270,22 @@ func (this *Stats) Equal(that interface{}) bool {
 	if this.EncodeTime != that1.EncodeTime {
 		return false
 	}
+	if this.ChunkMergeDuplicateSamples != that1.ChunkMergeDuplicateSamples {
+		return false
+	}
+	if this.ChunkMergeAppendedBatches != that1.ChunkMergeAppendedBatches {
+		return false
+	}
+	if this.ChunkMergeMergedBatches != that1.ChunkMergeMergedBatches {
+		return false
+	}
 	return true
 }
 func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 14)
+	s := make([]string, 0, 17)
 	s = append(s, "&stats.Stats{")
 	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
 	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
@@ -256,6 +297,9 @@ func (this *Stats) GoString() string {
 	s = append(s, "EstimatedSeriesCount: "+fmt.Sprintf("%#v", this.EstimatedSeriesCount)+",\n")
 	s = append(s, "QueueTime: "+fmt.Sprintf("%#v", this.QueueTime)+",\n")
 	s = append(s, "EncodeTime: "+fmt.Sprintf("%#v", this.EncodeTime)+",\n")
+	s = append(s, "ChunkMergeDuplicateSamples: "+fmt.Sprintf("%#v", this.ChunkMergeDuplicateSamples)+",\n")
+	s = append(s, "ChunkMergeAppendedBatches: "+fmt.Sprintf("%#v", this.ChunkMergeAppendedBatches)+",\n")
+	s = append(s, "ChunkMergeMergedBatches: "+fmt.Sprintf("%#v", this.ChunkMergeMergedBatches)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -287,6 +331,21 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ChunkMergeMergedBatches != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ChunkMergeMergedBatches))
+		i--
+		dAtA[i] = 0x68
+	}
+	if m.ChunkMergeAppendedBatches != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ChunkMergeAppendedBatches))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.ChunkMergeDuplicateSamples != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ChunkMergeDuplicateSamples))
+		i--
+		dAtA[i] = 0x58
+	}
 	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EncodeTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EncodeTime):])
 	if err1 != nil {
 		return 0, err1
@@ -393,6 +452,15 @@ func (m *Stats) Size() (n int) {
 	n += 1 + l + sovStats(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EncodeTime)
 	n += 1 + l + sovStats(uint64(l))
+	if m.ChunkMergeDuplicateSamples != 0 {
+		n += 1 + sovStats(uint64(m.ChunkMergeDuplicateSamples))
+	}
+	if m.ChunkMergeAppendedBatches != 0 {
+		n += 1 + sovStats(uint64(m.ChunkMergeAppendedBatches))
+	}
+	if m.ChunkMergeMergedBatches != 0 {
+		n += 1 + sovStats(uint64(m.ChunkMergeMergedBatches))
+	}
 	return n
 }
 
@@ -417,6 +485,9 @@ func (this *Stats) String() string {
 		`EstimatedSeriesCount:` + fmt.Sprintf("%v", this.EstimatedSeriesCount) + `,`,
 		`QueueTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.QueueTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
 		`EncodeTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EncodeTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`ChunkMergeDuplicateSamples:` + fmt.Sprintf("%v", this.ChunkMergeDuplicateSamples) + `,`,
+		`ChunkMergeAppendedBatches:` + fmt.Sprintf("%v", this.ChunkMergeAppendedBatches) + `,`,
+		`ChunkMergeMergedBatches:` + fmt.Sprintf("%v", this.ChunkMergeMergedBatches) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -690,6 +761,63 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkMergeDuplicateSamples", wireType)
+			}
+			m.ChunkMergeDuplicateSamples = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ChunkMergeDuplicateSamples |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkMergeAppendedBatches", wireType)
+			}
+			m.ChunkMergeAppendedBatches = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ChunkMergeAppendedBatches |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkMergeMergedBatches", wireType)
+			}
+			m.ChunkMergeMergedBatches = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ChunkMergeMergedBatches |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -142,6 +142,9 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
 		stats1.AddQueueTime(5 * time.Second)
+		stats1.AddChunkMergeDuplicateSamples(3)
+		stats1.AddChunkMergeAppendedBatches(4)
+		stats1.AddChunkMergeMergedBatches(5)
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -151,6 +154,9 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
 		stats2.AddQueueTime(10 * time.Second)
+		stats2.AddChunkMergeDuplicateSamples(6)
+		stats2.AddChunkMergeAppendedBatches(7)
+		stats2.AddChunkMergeMergedBatches(8)
 
 		stats1.Merge(stats2)
 
@@ -161,6 +167,9 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
 		assert.Equal(t, 15*time.Second, stats1.LoadQueueTime())
+		assert.Equal(t, uint64(9), stats1.LoadChunkMergeDuplicateSamples())
+		assert.Equal(t, uint64(11), stats1.LoadChunkMergeAppendedBatches())
+		assert.Equal(t, uint64(13), stats1.LoadChunkMergeMergedBatches())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
@@ -239,7 +239,15 @@ func (sp *schedulerProcessor) querierLoop(execCtx context.Context, c schedulerpb
 			}
 			logger := util_log.WithContext(ctx, sp.log)
 
-			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, request.StatsEnabled, request.HttpRequest, time.Duration(request.QueueTimeNanos))
+			// Prefer the queue time from the metadata attached by the scheduler, and fall back
+			// to the message field for schedulers which don't attach it.
+			queueTime := time.Duration(request.QueueTimeNanos)
+			if md, ok := schedulerpb.ExtractQueueMetadata(request.HttpRequest); ok {
+				ctx = schedulerpb.ContextWithQueueMetadata(ctx, md)
+				queueTime = md.QueueDuration
+			}
+
+			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, request.StatsEnabled, request.HttpRequest, queueTime)
 
 			// Report back to scheduler that processing of the query has finished.
 			if err := c.Send(&schedulerpb.QuerierToScheduler{}); err != nil {
@@ -329,6 +329,62 @@ func TestSchedulerProcessor_QueryTime(t *testing.T) {
 	})
 }
 
+func TestSchedulerProcessor_QueueMetadata(t *testing.T) {
+	fp, processClient, requestHandler, frontend := prepareSchedulerProcessor(t)
+
+	recvCount := atomic.NewInt64(0)
+	expected := schedulerpb.QueueMetadata{
+		EnqueueTime:       time.Unix(1000, 0),
+		QueueDuration:     5 * time.Second,
+		QueryComponent:    "ingester",
+		TenantQueueLength: 7,
+	}
+
+	processClient.On("Recv").Return(func() (*schedulerpb.SchedulerToQuerier, error) {
+		switch recvCount.Inc() {
+		case 1:
+			req := &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"}
+			schedulerpb.InjectQueueMetadata(req, expected)
+
+			return &schedulerpb.SchedulerToQuerier{
+				QueryID:         1,
+				HttpRequest:     req,
+				FrontendAddress: frontend.addr,
+				UserID:          "user-1",
+				StatsEnabled:    true,
+				// The queue time in the metadata takes precedence.
+				QueueTimeNanos: time.Second.Nanoseconds(),
+			}, nil
+		default:
+			// No more messages to process, so waiting until terminated.
+			<-processClient.Context().Done()
+			return nil, toRPCErr(processClient.Context().Err())
+		}
+	})
+
+	workerCtx, workerCancel := context.WithCancel(context.Background())
+
+	requestHandler.On("Handle", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		workerCancel()
+
+		ctx := args.Get(0).(context.Context)
+		md, ok := schedulerpb.QueueMetadataFromContext(ctx)
+		require.True(t, ok)
+		require.True(t, expected.EnqueueTime.Equal(md.EnqueueTime))
+		require.Equal(t, expected.QueueDuration, md.QueueDuration)
+		require.Equal(t, expected.QueryComponent, md.QueryComponent)
+		require.Equal(t, expected.TenantQueueLength, md.TenantQueueLength)
+		require.Equal(t, expected.QueueDuration, stats.FromContext(ctx).LoadQueueTime())
+
+		// Queue metadata headers are not passed to the handler.
+		require.Empty(t, args.Get(1).(*httpgrpc.HTTPRequest).Headers)
+	}).Return(&httpgrpc.HTTPResponse{}, nil)
+
+	fp.processQueriesOnSingleStream(workerCtx, nil, "127.0.0.1")
+
+	require.Equal(t, 1, int(frontend.queryResultCalls.Load()), "expected frontend to be informed of query result exactly once")
+}
+
 func TestCreateSchedulerProcessor(t *testing.T) {
 	conf := grpcclient.Config{}
 	flagext.DefaultValues(&conf)
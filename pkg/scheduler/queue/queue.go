@@ -55,6 +55,10 @@ type SchedulerRequest struct {
 
 	EnqueueTime time.Time
 
+	// TenantQueueLength is the number of requests left in the tenant queue when this request was dequeued.
+	// It's set by the RequestQueue before the request is sent to a querier-worker.
+	TenantQueueLength int
+
 	Ctx        context.Context
 	CancelFunc context.CancelCauseFunc
 	QueueSpan  opentracing.Span
@@ -138,6 +142,7 @@ const (
 	unregisterConnection
 	notifyShutdown
 	forgetDisconnected
+	checkStarvation
 )
 
 // querierWorkerOperation is a message to the RequestQueue's dispatcherLoop to perform operations
@@ -215,6 +220,8 @@ func NewRequestQueue(
 	discardedRequests *prometheus.CounterVec,
 	enqueueDuration prometheus.Histogram,
 	querierInflightRequestsMetric *prometheus.SummaryVec,
+	starvationCfg StarvationDetectorConfig,
+	starvingTenants *prometheus.GaugeVec,
 ) (*RequestQueue, error) {
 	queryComponentCapacity, err := NewQueryComponentUtilization(querierInflightRequestsMetric)
 	if err != nil {
@@ -248,6 +255,10 @@ func NewRequestQueue(
 		queueBroker:               newQueueBroker(maxOutstandingPerTenant, forgetDelay),
 	}
 
+	if starvationCfg.Threshold > 0 {
+		q.queueBroker.starvation = newStarvationDetector(starvationCfg, log, starvingTenants)
+	}
+
 	q.Service = services.NewBasicService(q.starting, q.running, q.stop).WithName("request queue")
 
 	return q, nil
@@ -271,12 +282,22 @@ func (q *RequestQueue) running(ctx context.Context) error {
 	inflightRequestsTicker := time.NewTicker(250 * time.Millisecond)
 	defer inflightRequestsTicker.Stop()
 
+	// periodically submit a message to dispatcherLoop to check for starving tenants, if enabled
+	var starvationCheckTicker <-chan time.Time
+	if q.queueBroker.starvation != nil {
+		t := time.NewTicker(starvationCheckPeriod)
+		defer t.Stop()
+		starvationCheckTicker = t.C
+	}
+
 	for {
 		select {
 		case <-forgetDisconnectedQueriersTicker.C:
 			q.submitForgetDisconnectedQueriers(ctx)
 		case <-inflightRequestsTicker.C:
 			q.QueryComponentUtilization.ObserveInflightRequests()
+		case <-starvationCheckTicker:
+			q.submitCheckStarvation(ctx)
 		case <-ctx.Done():
 			// context done case serves as a default case to bail out
 			// if the waiting querier-worker connection's context times out or is canceled,
@@ -401,6 +422,10 @@ func (q *RequestQueue) trySendNextRequestForQuerier(dequeueReq *QuerierWorkerDeq
 		return false
 	}
 
+	if schedulerReq, ok := req.req.(*SchedulerRequest); ok {
+		schedulerReq.TenantQueueLength = q.queueBroker.tenantQueueSize(tenant.tenantID)
+	}
+
 	reqForQuerier := querierWorkerDequeueResponse{
 		queryRequest:    req.req,
 		lastTenantIndex: TenantIndex{last: idx},
@@ -530,6 +555,14 @@ func (q *RequestQueue) submitForgetDisconnectedQueriers(ctx context.Context) {
 	q.submitQuerierWorkerOperation(querierWorkerOp, forgetDisconnected)
 }
 
+// submitCheckStarvation is called in a ticker from the RequestQueue's `running` goroutine,
+// when the detection of starving tenants is enabled.
+func (q *RequestQueue) submitCheckStarvation(ctx context.Context) {
+	// Create a generic querier-worker connection to submit the operation.
+	querierWorkerOp := NewUnregisteredQuerierWorkerConn(ctx, "")
+	q.submitQuerierWorkerOperation(querierWorkerOp, checkStarvation)
+}
+
 // SubmitNotifyQuerierShutdown is called by the v1 frontend or scheduler when NotifyQuerierShutdown requests
 // are submitted from the querier to an endpoint, separate from any specific querier-worker connection.
 func (q *RequestQueue) SubmitNotifyQuerierShutdown(ctx context.Context, querierID string) {
@@ -568,6 +601,8 @@ func (q *RequestQueue) processQuerierWorkerOperation(querierWorkerOp *querierWor
 		resharded = q.queueBroker.notifyQuerierShutdown(querierWorkerOp.conn.QuerierID)
 	case forgetDisconnected:
 		resharded = q.processForgetDisconnectedQueriers()
+	case checkStarvation:
+		q.queueBroker.starvation.check(time.Now())
 	default:
 		msg := fmt.Sprintf(
 			"received unknown querier-worker event %v for querier ID %v",
@@ -600,6 +635,15 @@ func (q *RequestQueue) processForgetDisconnectedQueriers() (resharded bool) {
 	return q.queueBroker.forgetDisconnectedQueriers(time.Now())
 }
 
+// StarvingTenants returns the tenants whose requests are starving for a query component, as of the last check.
+// It returns nil if the detection of starving tenants is disabled.
+func (q *RequestQueue) StarvingTenants() []StarvingTenant {
+	if q.queueBroker.starvation == nil {
+		return nil
+	}
+	return q.queueBroker.starvation.starvingTenants()
+}
+
 // TenantIndex is opaque type that allows to resume iteration over tenants
 // between successive calls of RequestQueue.AwaitRequestForQuerier method.
 type TenantIndex struct {
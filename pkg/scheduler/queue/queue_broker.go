@@ -34,6 +34,9 @@ type queueBroker struct {
 	querierConnections       *querierConnections
 
 	maxTenantQueueSize int
+
+	// starvation is nil if the detection of starving tenants is disabled.
+	starvation *starvationDetector
 }
 
 func newQueueBroker(
@@ -88,6 +91,9 @@ func (qb *queueBroker) enqueueRequestBack(request *tenantRequest, tenantMaxQueri
 	}
 
 	err = qb.tree.EnqueueBackByPath(queuePath, request)
+	if err == nil && qb.starvation != nil {
+		qb.starvation.enqueued(queuePath[0], request.tenantID, time.Now())
+	}
 	return err
 }
 
@@ -106,7 +112,11 @@ func (qb *queueBroker) enqueueRequestFront(request *tenantRequest, tenantMaxQuer
 	if err != nil {
 		return err
 	}
-	return qb.tree.EnqueueFrontByPath(queuePath, request)
+	err = qb.tree.EnqueueFrontByPath(queuePath, request)
+	if err == nil && qb.starvation != nil {
+		qb.starvation.enqueued(queuePath[0], request.tenantID, time.Now())
+	}
+	return err
 }
 
 func (qb *queueBroker) makeQueuePath(request *tenantRequest) (tree.QueuePath, error) {
@@ -149,6 +159,10 @@ func (qb *queueBroker) dequeueRequestForQuerier(
 	request := queueElement.(*tenantRequest)
 	tenantID := request.tenantID
 
+	if qb.starvation != nil {
+		qb.starvation.dequeued(queuePath[0], tenantID, time.Now())
+	}
+
 	var tenant *queueTenant
 	if tenantID != "" {
 		tenant = qb.tenantQuerierAssignments.tenantsByID[tenantID]
@@ -163,6 +177,11 @@ func (qb *queueBroker) dequeueRequestForQuerier(
 	return request, tenant, qb.tenantQuerierAssignments.queuingAlgorithm.TenantOrderIndex(), nil
 }
 
+// tenantQueueSize returns the number of requests currently queued for the tenant, across all query components.
+func (qb *queueBroker) tenantQueueSize(tenantID string) int {
+	return qb.tenantQuerierAssignments.queuingAlgorithm.TotalQueueSizeForTenant(tenantID)
+}
+
 // below methods simply pass through to the queueBroker's tenantQuerierShards; this layering could be skipped
 // but there is no reason to make consumers know that they need to call through to the tenantQuerierShards.
 
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build stress
+
+package queue
+
+import "time"
+
+// brokerStressOps is the number of operations of each TestQueueBroker_Stress script.
+const brokerStressOps = 20000
+
+// brokerStressSeeds returns random seeds. Failures print the seed, so that they can be reproduced.
+func brokerStressSeeds() []int64 {
+	base := time.Now().UnixNano()
+	seeds := make([]int64, 0, 500)
+	for i := int64(0); i < 500; i++ {
+		seeds = append(seeds, base+i)
+	}
+	return seeds
+}
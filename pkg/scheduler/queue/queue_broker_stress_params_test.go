@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+//go:build !stress
+
+package queue
+
+// brokerStressOps is the number of operations of each TestQueueBroker_Stress script.
+const brokerStressOps = 2000
+
+// brokerStressSeeds returns fixed seeds, so that TestQueueBroker_Stress is deterministic.
+func brokerStressSeeds() []int64 {
+	seeds := make([]int64, 0, 20)
+	for seed := int64(1); seed <= 20; seed++ {
+		seeds = append(seeds, seed)
+	}
+	return seeds
+}
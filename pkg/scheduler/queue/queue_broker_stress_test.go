@@ -0,0 +1,703 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/scheduler/queue/tree"
+)
+
+// This file contains a model-based stress test for the queueBroker. Randomized sequences of broker operations
+// are run against both the queueBroker and a simple reference model, and invariants are checked after every step.
+// Failing sequences are minimized and printed as scripts, which can be replayed by adding them to
+// TestQueueBroker_StressScripts.
+//
+// The default mode runs a few deterministic seeds, and is fast enough for CI. Run with `-tags stress` to run
+// many more, longer, randomly seeded sequences.
+
+type brokerOpKind string
+
+const (
+	opEnqueueBack  brokerOpKind = "enqueue-back"
+	opEnqueueFront brokerOpKind = "enqueue-front"
+	opDequeue      brokerOpKind = "dequeue"
+	opAddConn      brokerOpKind = "add-conn"
+	opRemoveConn   brokerOpKind = "remove-conn"
+	opShutdown     brokerOpKind = "shutdown"
+	opForget       brokerOpKind = "forget"
+	opAdvance      brokerOpKind = "advance"
+)
+
+// brokerOp is a single step of a stress test script.
+type brokerOp struct {
+	kind brokerOpKind
+
+	tenant      string
+	component   string
+	maxQueriers int
+
+	querier string
+	worker  int
+
+	duration time.Duration
+}
+
+func (op brokerOp) String() string {
+	switch op.kind {
+	case opEnqueueBack:
+		return fmt.Sprintf("%s %s %s %d", op.kind, op.tenant, op.component, op.maxQueriers)
+	case opDequeue:
+		return fmt.Sprintf("%s %s %d", op.kind, op.querier, op.worker)
+	case opAddConn, opRemoveConn, opShutdown:
+		return fmt.Sprintf("%s %s", op.kind, op.querier)
+	case opAdvance:
+		return fmt.Sprintf("%s %s", op.kind, op.duration)
+	default:
+		return string(op.kind)
+	}
+}
+
+// formatBrokerScript formats ops as a script, one operation per line.
+func formatBrokerScript(ops []brokerOp) string {
+	lines := make([]string, 0, len(ops))
+	for _, op := range ops {
+		lines = append(lines, op.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseBrokerScript parses a script formatted by formatBrokerScript. Empty lines and lines starting with # are ignored.
+func parseBrokerScript(script string) ([]brokerOp, error) {
+	var ops []brokerOp
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		op := brokerOp{kind: brokerOpKind(fields[0])}
+		args := fields[1:]
+
+		var err error
+		switch op.kind {
+		case opEnqueueBack:
+			if len(args) != 3 {
+				return nil, fmt.Errorf("line %d: expected tenant, component and max queriers", i+1)
+			}
+			op.tenant, op.component = args[0], args[1]
+			op.maxQueriers, err = strconv.Atoi(args[2])
+		case opDequeue:
+			if len(args) != 2 {
+				return nil, fmt.Errorf("line %d: expected querier and worker", i+1)
+			}
+			op.querier = args[0]
+			op.worker, err = strconv.Atoi(args[1])
+		case opAddConn, opRemoveConn, opShutdown:
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: expected querier", i+1)
+			}
+			op.querier = args[0]
+		case opAdvance:
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: expected duration", i+1)
+			}
+			op.duration, err = time.ParseDuration(args[0])
+		case opEnqueueFront, opForget:
+			if len(args) != 0 {
+				return nil, fmt.Errorf("line %d: unexpected arguments", i+1)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown operation %q", i+1, op.kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+var stressQueryComponents = []string{ingesterQueueDimension, storeGatewayQueueDimension, ingesterAndStoreGatewayQueueDimension, unknownQueueDimension}
+
+// generateBrokerScript generates a random script of numOps operations.
+func generateBrokerScript(rnd *rand.Rand, numOps, numTenants, numQueriers int) []brokerOp {
+	ops := make([]brokerOp, 0, numOps)
+	for len(ops) < numOps {
+		querier := fmt.Sprintf("querier-%d", rnd.Intn(numQueriers))
+
+		var op brokerOp
+		switch p := rnd.Intn(100); {
+		case p < 40:
+			op = brokerOp{
+				kind:        opEnqueueBack,
+				tenant:      fmt.Sprintf("tenant-%d", rnd.Intn(numTenants)),
+				component:   stressQueryComponents[rnd.Intn(len(stressQueryComponents))],
+				maxQueriers: rnd.Intn(numQueriers),
+			}
+		case p < 70:
+			op = brokerOp{kind: opDequeue, querier: querier, worker: rnd.Intn(4)}
+		case p < 75:
+			op = brokerOp{kind: opEnqueueFront}
+		case p < 84:
+			op = brokerOp{kind: opAddConn, querier: querier}
+		case p < 90:
+			op = brokerOp{kind: opRemoveConn, querier: querier}
+		case p < 93:
+			op = brokerOp{kind: opShutdown, querier: querier}
+		case p < 96:
+			op = brokerOp{kind: opForget}
+		default:
+			op = brokerOp{kind: opAdvance, duration: time.Duration(1+rnd.Intn(10)) * time.Second}
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// brokerModelQuerier mirrors the state of a querier in the querierConnections.
+type brokerModelQuerier struct {
+	conns          []*QuerierWorkerConn
+	shuttingDown   bool
+	disconnectedAt time.Time
+}
+
+// brokerModelItem is a request in the reference model.
+type brokerModelItem struct {
+	queryID   uint64
+	tenant    string
+	component string
+}
+
+// brokerModel is the reference model of the queueBroker: plain FIFO queues per tenant and query component,
+// and the expected state of the queriers.
+type brokerModel struct {
+	maxTenantQueueSize int
+	forgetDelay        time.Duration
+	now                time.Time
+
+	queues          map[string]map[string][]brokerModelItem
+	tenantMaxQ      map[string]int
+	queriers        map[string]*brokerModelQuerier
+	lastTenantIndex map[string]int
+
+	// Dequeued requests which can be re-enqueued to the front, most recent last.
+	dequeued []*tenantRequest
+
+	nextQueryID                                   uint64
+	enqueued, requeued, dequeuedCount, rejections int
+}
+
+func newBrokerModel(maxTenantQueueSize int, forgetDelay time.Duration) *brokerModel {
+	return &brokerModel{
+		maxTenantQueueSize: maxTenantQueueSize,
+		forgetDelay:        forgetDelay,
+		now:                time.Unix(0, 0),
+		queues:             map[string]map[string][]brokerModelItem{},
+		tenantMaxQ:         map[string]int{},
+		queriers:           map[string]*brokerModelQuerier{},
+		lastTenantIndex:    map[string]int{},
+	}
+}
+
+func (m *brokerModel) tenantQueueSize(tenantID string) int {
+	size := 0
+	for _, items := range m.queues[tenantID] {
+		size += len(items)
+	}
+	return size
+}
+
+func (m *brokerModel) totalQueueSize() int {
+	size := 0
+	for tenantID := range m.queues {
+		size += m.tenantQueueSize(tenantID)
+	}
+	return size
+}
+
+func (m *brokerModel) querierAvailable(querierID string) bool {
+	q := m.queriers[querierID]
+	return q != nil && !q.shuttingDown
+}
+
+func workerKey(querierID string, workerID int) string {
+	return fmt.Sprintf("%s/%d", querierID, workerID)
+}
+
+// brokerInvariantError is returned when the queueBroker diverges from the model, or breaks an invariant.
+type brokerInvariantError struct {
+	step int
+	op   brokerOp
+	msg  string
+}
+
+func (e *brokerInvariantError) Error() string {
+	return fmt.Sprintf("step %d (%s): %s", e.step, e.op, e.msg)
+}
+
+// runBrokerScript runs ops against a new queueBroker and the model, and returns the first invariant violation.
+func runBrokerScript(ops []brokerOp, maxTenantQueueSize int, forgetDelay time.Duration) error {
+	qb := newQueueBroker(maxTenantQueueSize, forgetDelay)
+	m := newBrokerModel(maxTenantQueueSize, forgetDelay)
+
+	for step, op := range ops {
+		fail := func(format string, args ...any) error {
+			return &brokerInvariantError{step: step, op: op, msg: fmt.Sprintf(format, args...)}
+		}
+
+		if msg := applyBrokerOp(qb, m, op); msg != "" {
+			return fail("%s", msg)
+		}
+		if msg := checkBrokerInvariants(qb, m); msg != "" {
+			return fail("%s", msg)
+		}
+	}
+	return nil
+}
+
+// applyBrokerOp applies op to both the queueBroker and the model, and returns a description of
+// any difference between their outcomes.
+func applyBrokerOp(qb *queueBroker, m *brokerModel, op brokerOp) string {
+	switch op.kind {
+	case opEnqueueBack:
+		m.nextQueryID++
+		req := &SchedulerRequest{UserID: op.tenant, QueryID: m.nextQueryID}
+		if op.component != unknownQueueDimension {
+			req.AdditionalQueueDimensions = []string{op.component}
+		}
+
+		err := qb.enqueueRequestBack(&tenantRequest{tenantID: op.tenant, req: req}, op.maxQueriers)
+		expectRejected := m.tenantQueueSize(op.tenant)+1 > m.maxTenantQueueSize
+		switch {
+		case expectRejected && !errors.Is(err, ErrTooManyRequests):
+			return fmt.Sprintf("expected the request to be rejected, got error %v", err)
+		case expectRejected:
+			m.rejections++
+			m.tenantMaxQ[op.tenant] = op.maxQueriers
+		case err != nil:
+			return fmt.Sprintf("unexpected enqueue error: %v", err)
+		default:
+			m.enqueued++
+			m.tenantMaxQ[op.tenant] = op.maxQueriers
+			if m.queues[op.tenant] == nil {
+				m.queues[op.tenant] = map[string][]brokerModelItem{}
+			}
+			m.queues[op.tenant][op.component] = append(m.queues[op.tenant][op.component], brokerModelItem{queryID: req.QueryID, tenant: op.tenant, component: op.component})
+		}
+
+	case opEnqueueFront:
+		if len(m.dequeued) == 0 {
+			return ""
+		}
+		req := m.dequeued[len(m.dequeued)-1]
+		m.dequeued = m.dequeued[:len(m.dequeued)-1]
+
+		if err := qb.enqueueRequestFront(req, m.tenantMaxQ[req.tenantID]); err != nil {
+			return fmt.Sprintf("unexpected enqueue front error: %v", err)
+		}
+		m.requeued++
+		sr := req.req.(*SchedulerRequest)
+		component := sr.ExpectedQueryComponentName()
+		if m.queues[req.tenantID] == nil {
+			m.queues[req.tenantID] = map[string][]brokerModelItem{}
+		}
+		m.queues[req.tenantID][component] = append([]brokerModelItem{{queryID: sr.QueryID, tenant: req.tenantID, component: component}}, m.queues[req.tenantID][component]...)
+
+	case opDequeue:
+		q := m.queriers[op.querier]
+		if q == nil || len(q.conns) == 0 {
+			// Only connected querier-workers dequeue.
+			return ""
+		}
+		conn := q.conns[op.worker%len(q.conns)]
+		key := workerKey(op.querier, conn.WorkerID)
+		lastTenantIndex, ok := m.lastTenantIndex[key]
+		if !ok {
+			lastTenantIndex = -1
+		}
+
+		// The shards before dequeuing, since the tenant may be removed by the dequeue.
+		shards := map[string]map[tree.QuerierID]struct{}{}
+		for tenantID := range m.queues {
+			shards[tenantID] = qb.tenantQuerierAssignments.queriersForTenant(tenantID)
+		}
+		available := m.querierAvailable(op.querier)
+
+		tr, tenant, newTenantIndex, err := qb.dequeueRequestForQuerier(&QuerierWorkerDequeueRequest{
+			QuerierWorkerConn: conn,
+			lastTenantIndex:   TenantIndex{last: lastTenantIndex},
+		})
+		if !available {
+			if !errors.Is(err, ErrQuerierShuttingDown) || tr != nil {
+				return fmt.Sprintf("expected querier shutting down error for unavailable querier, got request %v and error %v", tr, err)
+			}
+			return ""
+		}
+		if err != nil {
+			return fmt.Sprintf("unexpected dequeue error: %v", err)
+		}
+		m.lastTenantIndex[key] = newTenantIndex
+
+		if tr == nil {
+			// Nothing should be left for this querier in any of the tenants it's assigned to.
+			for tenantID, shard := range shards {
+				if m.tenantQueueSize(tenantID) == 0 {
+					continue
+				}
+				if _, assigned := shard[tree.QuerierID(op.querier)]; shard == nil || assigned {
+					return fmt.Sprintf("no request dequeued, but tenant %s assigned to the querier has %d queued requests", tenantID, m.tenantQueueSize(tenantID))
+				}
+			}
+			return ""
+		}
+
+		if tenant == nil || tenant.tenantID != tr.tenantID {
+			return fmt.Sprintf("dequeued request for tenant %s with wrong tenant %v", tr.tenantID, tenant)
+		}
+		if shard := shards[tr.tenantID]; shard != nil {
+			if _, ok := shard[tree.QuerierID(op.querier)]; !ok {
+				return fmt.Sprintf("dequeued request for tenant %s by querier outside of the tenant shard %v", tr.tenantID, shard)
+			}
+		}
+
+		sr := tr.req.(*SchedulerRequest)
+		component := sr.ExpectedQueryComponentName()
+		items := m.queues[tr.tenantID][component]
+		if len(items) == 0 {
+			return fmt.Sprintf("dequeued query %d for tenant %s component %s, but the model has no queued requests for it", sr.QueryID, tr.tenantID, component)
+		}
+		if items[0].queryID != sr.QueryID {
+			return fmt.Sprintf("dequeued query %d for tenant %s component %s out of order, expected query %d", sr.QueryID, tr.tenantID, component, items[0].queryID)
+		}
+		m.queues[tr.tenantID][component] = items[1:]
+		if m.tenantQueueSize(tr.tenantID) == 0 {
+			delete(m.queues, tr.tenantID)
+		}
+		m.dequeuedCount++
+		m.dequeued = append(m.dequeued, tr)
+
+	case opAddConn:
+		conn := NewUnregisteredQuerierWorkerConn(context.Background(), op.querier)
+		qb.addQuerierWorkerConn(conn)
+
+		q := m.queriers[op.querier]
+		if q == nil {
+			q = &brokerModelQuerier{}
+			m.queriers[op.querier] = q
+		}
+		q.conns = append(q.conns, conn)
+		q.shuttingDown = false
+		q.disconnectedAt = time.Time{}
+
+	case opRemoveConn:
+		q := m.queriers[op.querier]
+		if q == nil || len(q.conns) == 0 {
+			return ""
+		}
+		conn := q.conns[len(q.conns)-1]
+		q.conns = q.conns[:len(q.conns)-1]
+		delete(m.lastTenantIndex, workerKey(op.querier, conn.WorkerID))
+		qb.removeQuerierWorkerConn(conn, m.now)
+
+		if len(q.conns) == 0 {
+			if q.shuttingDown || m.forgetDelay == 0 {
+				delete(m.queriers, op.querier)
+			} else {
+				q.disconnectedAt = m.now
+			}
+		}
+
+	case opShutdown:
+		qb.notifyQuerierShutdown(op.querier)
+
+		if q := m.queriers[op.querier]; q != nil {
+			if len(q.conns) == 0 {
+				delete(m.queriers, op.querier)
+			} else {
+				q.shuttingDown = true
+			}
+		}
+
+	case opForget:
+		qb.forgetDisconnectedQueriers(m.now)
+
+		if m.forgetDelay > 0 {
+			threshold := m.now.Add(-m.forgetDelay)
+			for querierID, q := range m.queriers {
+				if len(q.conns) == 0 && q.disconnectedAt.Before(threshold) {
+					delete(m.queriers, querierID)
+				}
+			}
+		}
+
+	case opAdvance:
+		m.now = m.now.Add(op.duration)
+	}
+	return ""
+}
+
+// checkBrokerInvariants returns a description of the first invariant broken by the queueBroker, if any.
+func checkBrokerInvariants(qb *queueBroker, m *brokerModel) string {
+	// Item conservation.
+	queued := m.totalQueueSize()
+	if m.enqueued+m.requeued != m.dequeuedCount+queued {
+		return fmt.Sprintf("items not conserved: enqueued=%d requeued=%d dequeued=%d queued=%d", m.enqueued, m.requeued, m.dequeuedCount, queued)
+	}
+	if itemCount := qb.tree.ItemCount(); itemCount != queued {
+		return fmt.Sprintf("tree item count %d doesn't match the %d queued requests", itemCount, queued)
+	}
+	if qb.isEmpty() != (queued == 0) {
+		return fmt.Sprintf("isEmpty=%t with %d queued requests", qb.isEmpty(), queued)
+	}
+
+	// Queue sizes per tenant and query component.
+	var tenantIDs []string
+	for _, tenantID := range qb.tenantQuerierAssignments.queuingAlgorithm.TenantIDOrder() {
+		if tenantID != "" {
+			tenantIDs = append(tenantIDs, tenantID)
+		}
+	}
+	if len(tenantIDs) != len(m.queues) {
+		return fmt.Sprintf("broker has tenants %v, expected the %d tenants with queued requests", tenantIDs, len(m.queues))
+	}
+	for _, tenantID := range tenantIDs {
+		if _, ok := m.queues[tenantID]; !ok {
+			return fmt.Sprintf("broker has tenant %s without queued requests", tenantID)
+		}
+		if size := qb.tenantQueueSize(tenantID); size != m.tenantQueueSize(tenantID) {
+			return fmt.Sprintf("tenant %s queue size is %d, expected %d", tenantID, size, m.tenantQueueSize(tenantID))
+		}
+		for _, component := range stressQueryComponents {
+			size := 0
+			if node := qb.tree.GetNode(tree.QueuePath{component, tenantID}); node != nil {
+				size = node.ItemCount()
+			}
+			if expected := len(m.queues[tenantID][component]); size != expected {
+				return fmt.Sprintf("tenant %s component %s queue size is %d, expected %d", tenantID, component, size, expected)
+			}
+		}
+	}
+
+	// Queriers.
+	var expectedQueriers []string
+	for querierID := range m.queriers {
+		expectedQueriers = append(expectedQueriers, querierID)
+	}
+	slices.Sort(expectedQueriers)
+	var queriers []string
+	for _, querierID := range qb.tenantQuerierAssignments.querierIDsSorted {
+		queriers = append(queriers, string(querierID))
+	}
+	if !slices.Equal(queriers, expectedQueriers) {
+		return fmt.Sprintf("broker has queriers %v, expected %v", queriers, expectedQueriers)
+	}
+	if len(qb.querierConnections.queriersByID) != len(expectedQueriers) {
+		return fmt.Sprintf("broker has %d querier connections, expected %d", len(qb.querierConnections.queriersByID), len(expectedQueriers))
+	}
+
+	// Tenant shards.
+	for _, tenantID := range tenantIDs {
+		maxQueriers := m.tenantMaxQ[tenantID]
+		shard := qb.tenantQuerierAssignments.queriersForTenant(tenantID)
+		if maxQueriers == 0 || maxQueriers >= len(expectedQueriers) {
+			if shard != nil {
+				return fmt.Sprintf("tenant %s with max queriers %d has a shard %v with %d queriers", tenantID, maxQueriers, shard, len(expectedQueriers))
+			}
+			continue
+		}
+		if len(shard) != maxQueriers {
+			return fmt.Sprintf("tenant %s shard %v doesn't have %d queriers", tenantID, shard, maxQueriers)
+		}
+		for querierID := range shard {
+			if _, ok := m.queriers[string(querierID)]; !ok {
+				return fmt.Sprintf("tenant %s shard has unknown querier %s", tenantID, querierID)
+			}
+		}
+	}
+
+	return ""
+}
+
+// minimizeBrokerScript returns a shorter script which still fails, by repeatedly removing chunks of operations.
+func minimizeBrokerScript(ops []brokerOp, fails func([]brokerOp) bool) []brokerOp {
+	for chunk := len(ops) / 2; chunk >= 1; {
+		removed := false
+		for start := 0; start+chunk <= len(ops); {
+			candidate := slices.Concat(ops[:start], ops[start+chunk:])
+			if fails(candidate) {
+				ops = candidate
+				removed = true
+				continue
+			}
+			start += chunk
+		}
+		if !removed {
+			chunk /= 2
+		}
+	}
+	return ops
+}
+
+type brokerStressConfig struct {
+	maxTenantQueueSize int
+	forgetDelay        time.Duration
+}
+
+var brokerStressConfigs = []brokerStressConfig{
+	{maxTenantQueueSize: 5, forgetDelay: 0},
+	{maxTenantQueueSize: 5, forgetDelay: 10 * time.Second},
+	{maxTenantQueueSize: 100, forgetDelay: 10 * time.Second},
+}
+
+func TestQueueBroker_Stress(t *testing.T) {
+	seeds := brokerStressSeeds()
+	for _, cfg := range brokerStressConfigs {
+		t.Run(fmt.Sprintf("max tenant queue size=%d, forget delay=%s", cfg.maxTenantQueueSize, cfg.forgetDelay), func(t *testing.T) {
+			for _, seed := range seeds {
+				ops := generateBrokerScript(rand.New(rand.NewSource(seed)), brokerStressOps, 8, 5)
+				err := runBrokerScript(ops, cfg.maxTenantQueueSize, cfg.forgetDelay)
+				if err == nil {
+					continue
+				}
+
+				minimized := minimizeBrokerScript(ops, func(ops []brokerOp) bool {
+					return runBrokerScript(ops, cfg.maxTenantQueueSize, cfg.forgetDelay) != nil
+				})
+				t.Fatalf("seed %d: %v\n\nminimized script, failing with %v:\n%s",
+					seed, err, runBrokerScript(minimized, cfg.maxTenantQueueSize, cfg.forgetDelay), formatBrokerScript(minimized))
+			}
+		})
+	}
+}
+
+// TestQueueBroker_StressScripts replays scripts, e.g. the minimized scripts printed by TestQueueBroker_Stress.
+func TestQueueBroker_StressScripts(t *testing.T) {
+	tests := map[string]struct {
+		cfg    brokerStressConfig
+		script string
+	}{
+		"requests are rejected once the tenant queue is full": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 2},
+			script: `
+				enqueue-back tenant-1 ingester 0
+				enqueue-back tenant-1 store-gateway 0
+				enqueue-back tenant-1 ingester 0
+				add-conn querier-1
+				dequeue querier-1 0
+				enqueue-back tenant-1 ingester 0
+			`,
+		},
+		"re-enqueued requests are dequeued first": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 10},
+			script: `
+				add-conn querier-1
+				enqueue-back tenant-1 unknown 0
+				enqueue-back tenant-1 unknown 0
+				dequeue querier-1 0
+				enqueue-front
+				dequeue querier-1 0
+				dequeue querier-1 0
+			`,
+		},
+		"shuffle sharded tenants are only dequeued by their queriers": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 10},
+			script: `
+				add-conn querier-1
+				add-conn querier-2
+				add-conn querier-3
+				enqueue-back tenant-1 ingester 1
+				enqueue-back tenant-2 ingester 2
+				dequeue querier-1 0
+				dequeue querier-2 0
+				dequeue querier-3 0
+				dequeue querier-1 0
+			`,
+		},
+		"shutting down queriers don't dequeue, and are forgotten": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 10, forgetDelay: 10 * time.Second},
+			script: `
+				add-conn querier-1
+				add-conn querier-2
+				enqueue-back tenant-1 ingester 1
+				shutdown querier-1
+				dequeue querier-1 0
+				remove-conn querier-1
+				remove-conn querier-2
+				advance 5s
+				forget
+				advance 10s
+				forget
+			`,
+		},
+		"querier-workers move on from query components with no tenants sharded to the querier": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 5},
+			script: `
+				enqueue-back tenant-5 unknown 4
+				enqueue-back tenant-4 store-gateway 2
+				add-conn querier-1
+				dequeue querier-1 1
+				add-conn querier-4
+				enqueue-back tenant-6 ingester-and-store-gateway 4
+				enqueue-back tenant-4 store-gateway 1
+				dequeue querier-4 3
+			`,
+		},
+		"tenants re-added without shuffle sharding don't keep their previous shard": {
+			cfg: brokerStressConfig{maxTenantQueueSize: 100, forgetDelay: 10 * time.Second},
+			script: `
+				add-conn querier-0
+				add-conn querier-4
+				add-conn querier-2
+				add-conn querier-3
+				add-conn querier-1
+				enqueue-back tenant-2 ingester-and-store-gateway 4
+				dequeue querier-0 1
+				enqueue-back tenant-2 ingester-and-store-gateway 0
+			`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ops, err := parseBrokerScript(tc.script)
+			require.NoError(t, err)
+			require.NoError(t, runBrokerScript(ops, tc.cfg.maxTenantQueueSize, tc.cfg.forgetDelay))
+		})
+	}
+}
+
+func TestBrokerScript_FormatAndParse(t *testing.T) {
+	ops := generateBrokerScript(rand.New(rand.NewSource(1)), 200, 4, 3)
+
+	parsed, err := parseBrokerScript(formatBrokerScript(ops))
+	require.NoError(t, err)
+	require.Equal(t, ops, parsed)
+
+	_, err = parseBrokerScript("enqueue-back tenant-1")
+	require.Error(t, err)
+	_, err = parseBrokerScript("explode")
+	require.Error(t, err)
+}
+
+func TestMinimizeBrokerScript(t *testing.T) {
+	ops := generateBrokerScript(rand.New(rand.NewSource(1)), 100, 4, 3)
+	culprit := brokerOp{kind: opShutdown, querier: "querier-42"}
+	ops = slices.Insert(ops, 37, culprit)
+
+	minimized := minimizeBrokerScript(ops, func(ops []brokerOp) bool {
+		return slices.Contains(ops, culprit)
+	})
+	require.Equal(t, []brokerOp{culprit}, minimized)
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// How frequently to check for starving tenants, when the starvation detection is enabled.
+const starvationCheckPeriod = 5 * time.Second
+
+// StarvationDetectorConfig configures the detection of tenants whose requests stay queued while the
+// requests of other tenants for the same query component are dequeued.
+type StarvationDetectorConfig struct {
+	// Threshold is how long a tenant can have requests queued for a query component without any of them
+	// being dequeued. 0 disables the detection.
+	Threshold time.Duration
+
+	// MinOtherDequeues is the minimum number of requests of other tenants dequeued for the same query
+	// component in the meantime, for the tenant to be considered starving rather than the queriers busy.
+	MinOtherDequeues int
+}
+
+// StarvingTenant describes a tenant whose requests for a query component are starving.
+type StarvingTenant struct {
+	TenantID       string    `json:"tenant"`
+	QueryComponent string    `json:"query_component"`
+	WaitingSince   time.Time `json:"waiting_since"`
+	OtherDequeues  int64     `json:"other_dequeues"`
+}
+
+// componentStarvation is the state of the tenants with requests queued for a query component.
+type componentStarvation struct {
+	dequeues int64
+	tenants  map[string]*tenantStarvation
+}
+
+type tenantStarvation struct {
+	queued int
+
+	// waitingSince is the time of the last dequeue of the tenant's requests, or of the enqueue which made the
+	// tenant's queue non-empty, whichever comes last. dequeuesAtWaitStart is the number of dequeues of the
+	// query component at that time.
+	waitingSince        time.Time
+	dequeuesAtWaitStart int64
+
+	starving bool
+}
+
+// starvationDetector tracks, per query component, how long each tenant has had requests queued without any of
+// them being dequeued, and how many requests of other tenants were dequeued in the meantime. A tenant is flagged
+// as starving when both exceed the configured thresholds, and the flag is cleared when one of its requests is
+// dequeued. Requests of other tenants being dequeued means that queriers could process requests for the query
+// component, so a tenant isn't flagged when the whole queue is stuck.
+//
+// All the methods but starvingTenants must be called by the RequestQueue's dispatcherLoop.
+type starvationDetector struct {
+	cfg      StarvationDetectorConfig
+	log      log.Logger
+	starving *prometheus.GaugeVec // per user and query component
+
+	components map[string]*componentStarvation
+
+	// starvingMtx guards starvingSnapshot, which is read outside the dispatcherLoop.
+	starvingMtx      sync.RWMutex
+	starvingSnapshot []StarvingTenant
+}
+
+func newStarvationDetector(cfg StarvationDetectorConfig, log log.Logger, starving *prometheus.GaugeVec) *starvationDetector {
+	return &starvationDetector{
+		cfg:        cfg,
+		log:        log,
+		starving:   starving,
+		components: map[string]*componentStarvation{},
+	}
+}
+
+func (sd *starvationDetector) tenant(component, tenantID string) (*componentStarvation, *tenantStarvation) {
+	c := sd.components[component]
+	if c == nil {
+		c = &componentStarvation{tenants: map[string]*tenantStarvation{}}
+		sd.components[component] = c
+	}
+	t := c.tenants[tenantID]
+	if t == nil {
+		t = &tenantStarvation{}
+		c.tenants[tenantID] = t
+	}
+	return c, t
+}
+
+// enqueued records a request of the tenant enqueued for the query component.
+func (sd *starvationDetector) enqueued(component, tenantID string, now time.Time) {
+	c, t := sd.tenant(component, tenantID)
+	if t.queued == 0 {
+		t.waitingSince = now
+		t.dequeuesAtWaitStart = c.dequeues
+	}
+	t.queued++
+}
+
+// dequeued records a request of the tenant dequeued for the query component, clearing its starvation flag.
+func (sd *starvationDetector) dequeued(component, tenantID string, now time.Time) {
+	c, t := sd.tenant(component, tenantID)
+	c.dequeues++
+	t.queued--
+	t.waitingSince = now
+	t.dequeuesAtWaitStart = c.dequeues
+
+	if t.starving {
+		t.starving = false
+		sd.starving.DeleteLabelValues(tenantID, component)
+		level.Info(sd.log).Log("msg", "tenant is no longer starving", "tenant", tenantID, "query_component", component)
+		defer sd.updateSnapshot()
+	}
+	if t.queued <= 0 {
+		delete(c.tenants, tenantID)
+	}
+}
+
+// check flags the tenants which are starving as of now.
+func (sd *starvationDetector) check(now time.Time) {
+	changed := false
+	for component, c := range sd.components {
+		for tenantID, t := range c.tenants {
+			otherDequeues := c.dequeues - t.dequeuesAtWaitStart
+			if t.starving || t.queued == 0 || now.Sub(t.waitingSince) < sd.cfg.Threshold || otherDequeues < int64(sd.cfg.MinOtherDequeues) {
+				continue
+			}
+
+			t.starving = true
+			changed = true
+			sd.starving.WithLabelValues(tenantID, component).Set(1)
+			level.Warn(sd.log).Log(
+				"msg", "tenant is starving: its requests are queued while requests of other tenants are dequeued",
+				"tenant", tenantID,
+				"query_component", component,
+				"queued", t.queued,
+				"waiting_for", now.Sub(t.waitingSince),
+				"other_dequeues", otherDequeues,
+			)
+		}
+	}
+	if changed {
+		sd.updateSnapshot()
+	}
+}
+
+func (sd *starvationDetector) updateSnapshot() {
+	var snapshot []StarvingTenant
+	for component, c := range sd.components {
+		for tenantID, t := range c.tenants {
+			if t.starving {
+				snapshot = append(snapshot, StarvingTenant{
+					TenantID:       tenantID,
+					QueryComponent: component,
+					WaitingSince:   t.waitingSince,
+					OtherDequeues:  c.dequeues - t.dequeuesAtWaitStart,
+				})
+			}
+		}
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].TenantID != snapshot[j].TenantID {
+			return snapshot[i].TenantID < snapshot[j].TenantID
+		}
+		return snapshot[i].QueryComponent < snapshot[j].QueryComponent
+	})
+
+	sd.starvingMtx.Lock()
+	sd.starvingSnapshot = snapshot
+	sd.starvingMtx.Unlock()
+}
+
+// starvingTenants returns the tenants starving as of the last check. It's safe for concurrent use.
+func (sd *starvationDetector) starvingTenants() []StarvingTenant {
+	sd.starvingMtx.RLock()
+	defer sd.starvingMtx.RUnlock()
+	return sd.starvingSnapshot
+}
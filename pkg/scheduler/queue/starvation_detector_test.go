@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/scheduler/queue/tree"
+)
+
+func TestStarvationDetector(t *testing.T) {
+	const (
+		threshold        = time.Minute
+		minOtherDequeues = 5
+	)
+
+	setup := func(t *testing.T) (*queueBroker, *prometheus.Registry) {
+		reg := prometheus.NewPedanticRegistry()
+		starving := promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_query_scheduler_tenant_starving",
+			Help: "Tenant starving.",
+		}, []string{"user", "query_component"})
+
+		qb := newQueueBroker(100, 0)
+		qb.starvation = newStarvationDetector(StarvationDetectorConfig{Threshold: threshold, MinOtherDequeues: minOtherDequeues}, log.NewNopLogger(), starving)
+		qb.addQuerierWorkerConn(NewUnregisteredQuerierWorkerConn(context.Background(), "querier-1"))
+		qb.addQuerierWorkerConn(NewUnregisteredQuerierWorkerConn(context.Background(), "querier-2"))
+		return qb, reg
+	}
+	enqueue := func(t *testing.T, qb *queueBroker, tenantID string, maxQueriers, count int) {
+		for i := 0; i < count; i++ {
+			req := &tenantRequest{tenantID: tenantID, req: &SchedulerRequest{UserID: tenantID, AdditionalQueueDimensions: []string{storeGatewayQueueDimension}}}
+			require.NoError(t, qb.enqueueRequestBack(req, maxQueriers))
+		}
+	}
+	dequeue := func(t *testing.T, qb *queueBroker, querierID string) *tenantRequest {
+		req, _, _, err := qb.dequeueRequestForQuerier(&QuerierWorkerDequeueRequest{
+			QuerierWorkerConn: &QuerierWorkerConn{QuerierID: querierID},
+			lastTenantIndex:   FirstTenant(),
+		})
+		require.NoError(t, err)
+		return req
+	}
+	// shardOf returns the only querier of the tenant's shard, and the other one.
+	shardOf := func(t *testing.T, qb *queueBroker, tenantID string) (string, string) {
+		queriers := qb.tenantQuerierAssignments.queriersForTenant(tenantID)
+		require.Len(t, queriers, 1)
+		if _, ok := queriers[tree.QuerierID("querier-1")]; ok {
+			return "querier-1", "querier-2"
+		}
+		return "querier-2", "querier-1"
+	}
+
+	t.Run("tenant starved by its shuffle shard", func(t *testing.T) {
+		qb, reg := setup(t)
+		start := time.Now()
+
+		// The tenant's shard only has one querier, which never asks for requests, e.g. because it's stuck.
+		enqueue(t, qb, "starved", 1, 3)
+		starvedQuerier, otherQuerier := shardOf(t, qb, "starved")
+		enqueue(t, qb, "busy", 0, 10)
+
+		for i := 0; i < minOtherDequeues; i++ {
+			require.Equal(t, "busy", dequeue(t, qb, otherQuerier).tenantID)
+		}
+
+		// The requests haven't been queued for long enough yet.
+		qb.starvation.check(start.Add(threshold / 2))
+		require.Empty(t, qb.starvation.starvingTenants())
+
+		qb.starvation.check(start.Add(2 * threshold))
+		starving := qb.starvation.starvingTenants()
+		require.Len(t, starving, 1)
+		require.Equal(t, "starved", starving[0].TenantID)
+		require.Equal(t, storeGatewayQueueDimension, starving[0].QueryComponent)
+		require.Equal(t, int64(minOtherDequeues), starving[0].OtherDequeues)
+		require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_scheduler_tenant_starving Tenant starving.
+			# TYPE cortex_query_scheduler_tenant_starving gauge
+			cortex_query_scheduler_tenant_starving{query_component="store-gateway",user="starved"} 1
+		`), "cortex_query_scheduler_tenant_starving"))
+
+		// The querier of the shard dequeues a request of the tenant, which clears the flag, even if more are queued.
+		require.Equal(t, "starved", dequeue(t, qb, starvedQuerier).tenantID)
+		require.Empty(t, qb.starvation.starvingTenants())
+		require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(""), "cortex_query_scheduler_tenant_starving"))
+
+		// The tenant is waiting again since the last dequeue, so it doesn't get flagged right away.
+		for i := 0; i < minOtherDequeues; i++ {
+			require.Equal(t, "busy", dequeue(t, qb, otherQuerier).tenantID)
+		}
+		qb.starvation.check(time.Now().Add(threshold / 2))
+		require.Empty(t, qb.starvation.starvingTenants())
+		qb.starvation.check(time.Now().Add(2 * threshold))
+		require.Len(t, qb.starvation.starvingTenants(), 1)
+	})
+
+	t.Run("no starvation when the whole scheduler is idle", func(t *testing.T) {
+		qb, _ := setup(t)
+
+		// No querier asks for requests, so there's no capacity for any tenant.
+		enqueue(t, qb, "tenant-1", 1, 3)
+		enqueue(t, qb, "tenant-2", 0, 3)
+
+		qb.starvation.check(time.Now().Add(time.Hour))
+		require.Empty(t, qb.starvation.starvingTenants())
+	})
+
+	t.Run("no starvation when too few requests of other tenants are dequeued", func(t *testing.T) {
+		qb, _ := setup(t)
+
+		enqueue(t, qb, "starved", 1, 3)
+		_, otherQuerier := shardOf(t, qb, "starved")
+		enqueue(t, qb, "busy", 0, 10)
+		for i := 0; i < minOtherDequeues-1; i++ {
+			require.Equal(t, "busy", dequeue(t, qb, otherQuerier).tenantID)
+		}
+
+		qb.starvation.check(time.Now().Add(time.Hour))
+		require.Empty(t, qb.starvation.starvingTenants())
+	})
+
+	t.Run("dequeues for other query components don't count", func(t *testing.T) {
+		qb, _ := setup(t)
+
+		enqueue(t, qb, "starved", 1, 3)
+		_, otherQuerier := shardOf(t, qb, "starved")
+		for i := 0; i < 10; i++ {
+			req := &tenantRequest{tenantID: "busy", req: &SchedulerRequest{UserID: "busy", AdditionalQueueDimensions: []string{ingesterQueueDimension}}}
+			require.NoError(t, qb.enqueueRequestBack(req, 0))
+		}
+		for i := 0; i < 10; i++ {
+			require.Equal(t, "busy", dequeue(t, qb, otherQuerier).tenantID)
+		}
+
+		qb.starvation.check(time.Now().Add(time.Hour))
+		require.Empty(t, qb.starvation.starvingTenants())
+	})
+}
@@ -234,6 +234,8 @@ func (qa *TenantQuerierQueuingAlgorithm) dequeueUpdateState(node *Node, dequeued
 		// When tenantIDOrder is very long, removing tenants from the order is performance-sensitive
 		// when clearing & deleting many tenant queues at the same time due to rollout operations.
 		if removeFromSharedQueueOrder {
+			// Forget the tenant's queriers, so that a stale shard is not used if the tenant is re-added.
+			delete(qa.tenantQuerierIDs, TenantID(childName))
 
 			// First, just replace the tenantID with the emptyTenantID sentinel value.
 			for idx, name := range qa.tenantIDOrder {
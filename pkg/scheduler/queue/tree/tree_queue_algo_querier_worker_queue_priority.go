@@ -61,6 +61,10 @@ type QuerierWorkerQueuePriorityAlgo struct {
 	currentNodeOrderIndex int
 	nodeOrder             []string
 	nodeCounts            map[string]int
+
+	// childrenCheckedAtSelect is the parent node's childrenChecked when the current node was selected;
+	// if it has increased by dequeueUpdateState, nothing could be dequeued from the selected node's subtree.
+	childrenCheckedAtSelect int
 }
 
 func NewQuerierWorkerQueuePriorityAlgo() *QuerierWorkerQueuePriorityAlgo {
@@ -114,6 +118,7 @@ func (qa *QuerierWorkerQueuePriorityAlgo) addChildNode(parent, child *Node) {
 }
 
 func (qa *QuerierWorkerQueuePriorityAlgo) dequeueSelectNode(node *Node) *Node {
+	qa.childrenCheckedAtSelect = node.childrenChecked
 	currentNodeName := qa.nodeOrder[qa.currentNodeOrderIndex]
 	if childNode, ok := node.queueMap[currentNodeName]; ok {
 		return childNode
@@ -150,5 +155,12 @@ func (qa *QuerierWorkerQueuePriorityAlgo) dequeueUpdateState(node *Node, dequeue
 
 		// delete child node from its parent's queueMap
 		delete(node.queueMap, childName)
+		return
+	}
+
+	// nothing could be dequeued from the selected node's subtree for this querier-worker, e.g. because none of
+	// its tenants are sharded to the querier; move on to the next node, rather than checking the same one again
+	if node.childrenChecked > qa.childrenCheckedAtSelect {
+		qa.wrapCurrentNodeOrderIndex(true)
 	}
 }
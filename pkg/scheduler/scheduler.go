@@ -49,6 +49,7 @@ import (
 
 var errEnqueuingRequestFailed = cancellation.NewErrorf("enqueuing request failed")
 var errFrontendDisconnected = cancellation.NewErrorf("frontend disconnected")
+var errInvalidStarvationConfig = errors.New("invalid query-scheduler starvation detection config, the values must not be negative")
 
 // Scheduler is responsible for queueing and dispatching queries to Queriers.
 type Scheduler struct {
@@ -80,6 +81,7 @@ type Scheduler struct {
 
 	// Metrics.
 	queueLength              *prometheus.GaugeVec
+	starvingTenants          *prometheus.GaugeVec
 	discardedRequests        *prometheus.CounterVec
 	cancelledRequests        *prometheus.CounterVec
 	connectedQuerierClients  prometheus.GaugeFunc
@@ -101,6 +103,9 @@ type Config struct {
 	MaxOutstandingPerTenant int           `yaml:"max_outstanding_requests_per_tenant"`
 	QuerierForgetDelay      time.Duration `yaml:"querier_forget_delay" category:"experimental"`
 
+	StarvationThreshold        time.Duration `yaml:"starvation_threshold" category:"experimental"`
+	StarvationMinOtherDequeues int           `yaml:"starvation_min_other_dequeues" category:"experimental"`
+
 	GRPCClientConfig grpcclient.Config         `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	ServiceDiscovery schedulerdiscovery.Config `yaml:",inline"`
 }
@@ -108,6 +113,8 @@ type Config struct {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 100, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
+	f.DurationVar(&cfg.StarvationThreshold, "query-scheduler.starvation-threshold", 0, "How long a tenant can have requests queued for a query component without any of them being dequeued, while requests of other tenants for the same query component are, before the tenant is reported as starving. Starving tenants are exported by the cortex_query_scheduler_tenant_starving metric and listed by the /query-scheduler/starvation endpoint. 0 to disable.")
+	f.IntVar(&cfg.StarvationMinOtherDequeues, "query-scheduler.starvation-min-other-dequeues", 10, "Minimum number of requests of other tenants dequeued for the same query component while a tenant's requests are queued, for the tenant to be reported as starving. Applies when -query-scheduler.starvation-threshold is set.")
 
 	cfg.GRPCClientConfig.CustomCompressors = []string{s2.Name}
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
@@ -115,6 +122,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 }
 
 func (cfg *Config) Validate() error {
+	if cfg.StarvationThreshold < 0 || cfg.StarvationMinOtherDequeues < 0 {
+		return errInvalidStarvationConfig
+	}
 	return cfg.ServiceDiscovery.Validate()
 }
 
@@ -145,6 +155,10 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Name: "cortex_query_scheduler_discarded_requests_total",
 		Help: "Total number of query requests discarded.",
 	}, []string{"user"})
+	s.starvingTenants = promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cortex_query_scheduler_tenant_starving",
+		Help: "1 if the tenant's requests for the query component are starving: they stay queued while requests of other tenants for the same query component are dequeued.",
+	}, []string{"user", "query_component"})
 	enqueueDuration := promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
 		Name: "cortex_query_scheduler_enqueue_duration_seconds",
 		Help: "Time spent by requests waiting to join the queue or be rejected.",
@@ -168,6 +182,11 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		s.discardedRequests,
 		enqueueDuration,
 		querierInflightRequestsMetric,
+		queue.StarvationDetectorConfig{
+			Threshold:        cfg.StarvationThreshold,
+			MinOtherDequeues: cfg.StarvationMinOtherDequeues,
+		},
+		s.starvingTenants,
 	)
 	if err != nil {
 		return nil, err
@@ -495,6 +514,13 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 
 	// Handle the stream sending & receiving on a goroutine so we can
 	// monitor the contexts in a select and cancel things appropriately.
+	schedulerpb.InjectQueueMetadata(req.Request, schedulerpb.QueueMetadata{
+		EnqueueTime:       req.EnqueueTime,
+		QueueDuration:     queueTime,
+		QueryComponent:    req.ExpectedQueryComponentName(),
+		TenantQueueLength: req.TenantQueueLength,
+	})
+
 	errCh := make(chan error, 1)
 	go func() {
 		span, _ := opentracing.StartSpanFromContext(req.Ctx, "forwardRequestToQuerier")
@@ -651,6 +677,7 @@ func (s *Scheduler) cleanupMetricsForInactiveUser(user string) {
 	s.queueLength.DeleteLabelValues(user)
 	s.discardedRequests.DeleteLabelValues(user)
 	s.cancelledRequests.DeleteLabelValues(user)
+	s.starvingTenants.DeletePartialMatch(prometheus.Labels{"user": user})
 }
 
 func (s *Scheduler) getConnectedFrontendClientsMetric() float64 {
@@ -685,3 +712,27 @@ func (s *Scheduler) RingHandler(w http.ResponseWriter, req *http.Request) {
 		</html>`
 	util.WriteHTMLResponse(w, ringDisabledPage)
 }
+
+// starvationContents is the response of the StarvationHandler.
+type starvationContents struct {
+	Enabled         bool                   `json:"enabled"`
+	StarvingTenants []queue.StarvingTenant `json:"starving_tenants"`
+}
+
+// StarvationHandler lists the tenants whose requests are starving. It responds with 503 Service Unavailable
+// if any tenant is starving, so that it can be probed.
+func (s *Scheduler) StarvationHandler(w http.ResponseWriter, _ *http.Request) {
+	contents := starvationContents{
+		Enabled:         s.cfg.StarvationThreshold > 0,
+		StarvingTenants: s.requestQueue.StarvingTenants(),
+	}
+	if contents.StarvingTenants == nil {
+		contents.StarvingTenants = []queue.StarvingTenant{}
+	}
+	if len(contents.StarvingTenants) > 0 {
+		// The content type must be set before the status code is written.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	util.WriteJSONResponse(w, contents)
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
@@ -116,6 +117,45 @@ func TestSchedulerBasicEnqueue(t *testing.T) {
 	verifyQueryComponentUtilizationLeft(t, scheduler)
 }
 
+func TestSchedulerForwardsQueueMetadata(t *testing.T) {
+	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	for queryID := uint64(1); queryID <= 2; queryID++ {
+		frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+			Type:                      schedulerpb.ENQUEUE,
+			QueryID:                   queryID,
+			UserID:                    "test",
+			HttpRequest:               &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+			AdditionalQueueDimensions: []string{"store-gateway"},
+		})
+	}
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+
+	msg, err := querierLoop.Recv()
+	require.NoError(t, err)
+
+	md, ok := schedulerpb.ExtractQueueMetadata(msg.HttpRequest)
+	require.True(t, ok)
+	require.Equal(t, "store-gateway", md.QueryComponent)
+	require.Equal(t, 1, md.TenantQueueLength)
+	require.Equal(t, msg.QueueTimeNanos, md.QueueDuration.Nanoseconds())
+	require.False(t, md.EnqueueTime.IsZero())
+	require.Empty(t, msg.HttpRequest.Headers, "queue metadata headers should be removed once extracted")
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+
+	msg, err = querierLoop.Recv()
+	require.NoError(t, err)
+
+	md, ok = schedulerpb.ExtractQueueMetadata(msg.HttpRequest)
+	require.True(t, ok)
+	require.Equal(t, 0, md.TenantQueueLength)
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+
+	verifyNoPendingRequestsLeft(t, scheduler)
+}
+
 func TestSchedulerEnqueueWithCancel(t *testing.T) {
 	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
 
@@ -537,6 +577,30 @@ func TestSchedulerQuerierMetrics(t *testing.T) {
 	}, time.Second, 10*time.Millisecond, "expected cortex_query_scheduler_connected_querier_clients metric to be decremented after querier disconnected")
 }
 
+func TestSchedulerStarvationHandler(t *testing.T) {
+	for name, threshold := range map[string]time.Duration{"disabled": 0, "enabled": time.Minute} {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			cfg.StarvationThreshold = threshold
+
+			s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), s))
+			t.Cleanup(func() {
+				_ = services.StopAndAwaitTerminated(context.Background(), s)
+			})
+
+			rec := httptest.NewRecorder()
+			s.StarvationHandler(rec, httptest.NewRequest(http.MethodGet, "/query-scheduler/starvation", nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.JSONEq(t, fmt.Sprintf(`{"enabled": %t, "starving_tenants": []}`, threshold > 0), rec.Body.String())
+		})
+	}
+}
+
 func initFrontendLoop(t *testing.T, client schedulerpb.SchedulerForFrontendClient, frontendAddr string) schedulerpb.SchedulerForFrontend_FrontendLoopClient {
 	loop, err := client.FrontendLoop(context.Background())
 	require.NoError(t, err)
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package schedulerpb
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+)
+
+const (
+	QueueEnqueueTimeHeader       = "X-Mimir-Queue-Enqueue-Time"
+	QueueDurationHeader          = "X-Mimir-Queue-Duration"
+	QueueComponentHeader         = "X-Mimir-Queue-Component"
+	QueueTenantQueueLengthHeader = "X-Mimir-Queue-Tenant-Queue-Length"
+)
+
+var queueMetadataHeaders = []string{
+	QueueEnqueueTimeHeader,
+	QueueDurationHeader,
+	QueueComponentHeader,
+	QueueTenantQueueLengthHeader,
+}
+
+// QueueMetadata describes how a request went through the query-scheduler queue.
+// It's attached by the query-scheduler to the request forwarded to queriers.
+type QueueMetadata struct {
+	// EnqueueTime is the time the request was enqueued in the query-scheduler.
+	EnqueueTime time.Time
+
+	// QueueDuration is the time the request spent in the queue.
+	QueueDuration time.Duration
+
+	// QueryComponent is the query component the request was queued for (e.g. "ingester", "store-gateway").
+	QueryComponent string
+
+	// TenantQueueLength is the number of requests left in the tenant queue when the request was dequeued.
+	TenantQueueLength int
+}
+
+// InjectQueueMetadata adds the queue metadata to the request headers, replacing any existing value.
+func InjectQueueMetadata(req *httpgrpc.HTTPRequest, md QueueMetadata) {
+	if req == nil {
+		return
+	}
+
+	req.Headers = removeQueueMetadataHeaders(req.Headers)
+	req.Headers = append(req.Headers,
+		&httpgrpc.Header{Key: QueueEnqueueTimeHeader, Values: []string{strconv.FormatInt(md.EnqueueTime.UnixNano(), 10)}},
+		&httpgrpc.Header{Key: QueueDurationHeader, Values: []string{strconv.FormatInt(md.QueueDuration.Nanoseconds(), 10)}},
+		&httpgrpc.Header{Key: QueueComponentHeader, Values: []string{md.QueryComponent}},
+		&httpgrpc.Header{Key: QueueTenantQueueLengthHeader, Values: []string{strconv.Itoa(md.TenantQueueLength)}},
+	)
+}
+
+// ExtractQueueMetadata reads and removes the queue metadata from the request headers.
+// The second return value is false if the request doesn't carry valid queue metadata,
+// for example because it was forwarded by a query-scheduler which doesn't attach it.
+func ExtractQueueMetadata(req *httpgrpc.HTTPRequest) (QueueMetadata, bool) {
+	if req == nil {
+		return QueueMetadata{}, false
+	}
+
+	values := map[string]string{}
+	for _, h := range req.Headers {
+		for _, name := range queueMetadataHeaders {
+			if h.Key == name && len(h.Values) > 0 {
+				values[name] = h.Values[0]
+			}
+		}
+	}
+	req.Headers = removeQueueMetadataHeaders(req.Headers)
+
+	if len(values) != len(queueMetadataHeaders) {
+		return QueueMetadata{}, false
+	}
+
+	enqueueTime, err := strconv.ParseInt(values[QueueEnqueueTimeHeader], 10, 64)
+	if err != nil {
+		return QueueMetadata{}, false
+	}
+	queueDuration, err := strconv.ParseInt(values[QueueDurationHeader], 10, 64)
+	if err != nil {
+		return QueueMetadata{}, false
+	}
+	tenantQueueLength, err := strconv.Atoi(values[QueueTenantQueueLengthHeader])
+	if err != nil {
+		return QueueMetadata{}, false
+	}
+
+	return QueueMetadata{
+		EnqueueTime:       time.Unix(0, enqueueTime),
+		QueueDuration:     time.Duration(queueDuration),
+		QueryComponent:    values[QueueComponentHeader],
+		TenantQueueLength: tenantQueueLength,
+	}, true
+}
+
+func removeQueueMetadataHeaders(headers []*httpgrpc.Header) []*httpgrpc.Header {
+	filtered := headers[:0]
+	for _, h := range headers {
+		isMetadata := false
+		for _, name := range queueMetadataHeaders {
+			if h.Key == name {
+				isMetadata = true
+				break
+			}
+		}
+		if !isMetadata {
+			filtered = append(filtered, h)
+		}
+	}
+	return filtered
+}
+
+type queueMetadataContextKey struct{}
+
+// ContextWithQueueMetadata returns a new context with the given queue metadata.
+// The metadata can be retrieved with QueueMetadataFromContext.
+func ContextWithQueueMetadata(ctx context.Context, md QueueMetadata) context.Context {
+	return context.WithValue(ctx, queueMetadataContextKey{}, md)
+}
+
+// QueueMetadataFromContext returns the queue metadata from the context if set via ContextWithQueueMetadata.
+// The second return value is true if the metadata was found in the context.
+func QueueMetadataFromContext(ctx context.Context) (QueueMetadata, bool) {
+	md, ok := ctx.Value(queueMetadataContextKey{}).(QueueMetadata)
+	return md, ok
+}
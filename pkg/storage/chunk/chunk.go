@@ -96,6 +96,15 @@ type Iterator interface {
 // 1 to 128.
 const BatchSize = 12
 
+// BatchFlags are properties of all the samples of a Batch.
+type BatchFlags uint8
+
+const (
+	// BatchMergedAcrossSources is set on the batches made of the samples of chunks which interleave in time, merged
+	// together. The counter resets found within such batches may come from the different values of the chunks.
+	BatchMergedAcrossSources BatchFlags = 1 << iota
+)
+
 // Batches are sorted sets of (timestamp, value) pairs, where all values are of the same type (i.e. floats/histograms).
 //
 // Batch is intended to be small, and passed by value!
@@ -116,6 +125,11 @@ type Batch struct {
 	ValueType     chunkenc.ValueType
 	Index         int
 	Length        int
+	// Flags are only set by the merge of overlapping chunks.
+	Flags BatchFlags
+	// ChunkRanks store the rank of the chunk each sample comes from, in ascending order of the chunks' min time.
+	// They are only set by the merge of overlapping chunks preferring the samples of the later chunks.
+	ChunkRanks [BatchSize]int32
 }
 
 func (b *Batch) HasNext() chunkenc.ValueType {
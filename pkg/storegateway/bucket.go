@@ -1769,6 +1769,57 @@ func storeCachedLabelValues(ctx context.Context, indexCache indexcache.IndexCach
 	indexCache.StoreLabelValues(userID, blockID, labelName, entry.MatchersKey, data)
 }
 
+// labelNameStats are the stats of a label name in the index of a block.
+type labelNameStats struct {
+	Name string `json:"name"`
+	// Values is the number of values of the label name.
+	Values int `json:"values"`
+	// Series is the number of series with the label name. It's estimated from the size of the posting lists, so it
+	// may be slightly overestimated for the last label name of the index.
+	Series int64 `json:"series"`
+}
+
+// blockLabelNamesStats returns the stats of each label name in the index-header of the block, sorted by label name.
+// They're cached by the block after the first call. The second return value is false if the block isn't loaded
+// by the store.
+func (s *BucketStore) blockLabelNamesStats(ctx context.Context, id ulid.ULID) ([]labelNameStats, bool, error) {
+	b := s.blockSet.get(id)
+	if b == nil {
+		return nil, false, nil
+	}
+	b.closedMtx.RLock()
+	defer b.closedMtx.RUnlock()
+	if b.closed {
+		return nil, false, nil
+	}
+
+	b.labelNamesStatsMtx.Lock()
+	defer b.labelNamesStatsMtx.Unlock()
+	if b.labelNamesStats != nil {
+		return b.labelNamesStats, true, nil
+	}
+
+	names, err := b.indexHeaderReader.LabelNames(ctx)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "read label names")
+	}
+	stats := make([]labelNameStats, 0, len(names))
+	for _, name := range names {
+		offsets, err := b.indexHeaderReader.LabelValuesOffsets(ctx, name, "", nil)
+		if err != nil {
+			return nil, true, errors.Wrapf(err, "read values of label %s", name)
+		}
+		st := labelNameStats{Name: name, Values: len(offsets)}
+		for _, o := range offsets {
+			// A posting list is its number of entries followed by 4 bytes per entry.
+			st.Series += max(o.Off.End-o.Off.Start-4, 0) / 4
+		}
+		stats = append(stats, st)
+	}
+	b.labelNamesStats = stats
+	return stats, true, nil
+}
+
 // bucketBlockSet holds all blocks.
 type bucketBlockSet struct {
 	// mtx protects the below data strcutures, helping to keep them in sync.
@@ -1834,6 +1885,15 @@ func (s *bucketBlockSet) contains(id ulid.ULID) bool {
 	return ok
 }
 
+// get returns the block identified by id, or nil if it isn't in the set. The returned block may be closed.
+func (s *bucketBlockSet) get(id ulid.ULID) *bucketBlock {
+	val, ok := s.blockSet.Load(id)
+	if !ok {
+		return nil
+	}
+	return val.(*bucketBlock)
+}
+
 func (s *bucketBlockSet) len() int {
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
@@ -1981,6 +2041,12 @@ type bucketBlock struct {
 
 	// Indicates whether the block was queried.
 	queried atomic.Bool
+
+	// labelNamesStats caches the stats of the label names in the index-header, shown by the block page, since
+	// computing them reads the offsets of all the label values. The index-header doesn't change, so they're
+	// computed once.
+	labelNamesStatsMtx sync.Mutex
+	labelNamesStats    []labelNameStats
 }
 
 func newBucketBlock(
@@ -29,6 +29,7 @@ import (
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
+	"github.com/grafana/mimir/pkg/util/blocksadmin"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -57,6 +58,8 @@ type Config struct {
 
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants" category:"advanced"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants" category:"advanced"`
+
+	BlocksAdmin blocksadmin.PagesConfig `yaml:",inline"`
 }
 
 // RegisterFlags registers the Config flags.
@@ -65,6 +68,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 
 	f.Var(&cfg.EnabledTenants, "store-gateway.enabled-tenants", "Comma separated list of tenants that can be loaded by the store-gateway. If specified, only blocks for these tenants will be loaded by the store-gateway, otherwise all tenants can be loaded. Subject to sharding.")
 	f.Var(&cfg.DisabledTenants, "store-gateway.disabled-tenants", "Comma separated list of tenants that cannot be loaded by the store-gateway. If specified, and the store-gateway would normally load a given tenant for (via -store-gateway.enabled-tenants or sharding), it will be ignored instead.")
+	cfg.BlocksAdmin.RegisterFlagsWithPrefix("store-gateway.", f)
 }
 
 // Validate the Config.
@@ -72,6 +76,9 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 	if limits.StoreGatewayTenantShardSize < 0 {
 		return errInvalidTenantShardSize
 	}
+	if err := cfg.BlocksAdmin.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -88,6 +95,9 @@ type StoreGateway struct {
 	stores     *BucketStores
 	tracker    *activitytracker.ActivityTracker
 
+	// Admin pages to browse the tenants and blocks in the bucket.
+	blocksAdmin *blocksadmin.Handler
+
 	// Ring used for sharding blocks.
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
@@ -188,6 +198,11 @@ func newStoreGateway(gatewayCfg Config, storageCfg mimir_tsdb.BlocksStorageConfi
 		return nil, errors.Wrap(err, "create bucket stores")
 	}
 
+	blocksAdminCfg := gatewayCfg.BlocksAdmin.HandlerConfig("Store-gateway", "/store-gateway")
+	blocksAdminCfg.LinkBlocks = true
+	blocksAdminCfg.BlockResponse = blockPageContents{}
+	g.blocksAdmin = blocksadmin.New(blocksAdminCfg, g.stores.bucket, g.stores.scanUsers, logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "store-gateway"}, reg))
+
 	g.Service = services.NewBasicService(g.starting, g.running, g.stopping)
 
 	return g, nil
@@ -4,164 +4,145 @@ package storegateway
 
 import (
 	_ "embed" // Used to embed html template
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/prometheus/prometheus/model/labels"
-	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
 
-	"github.com/grafana/mimir/pkg/storage/tsdb"
-	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/util"
-	"github.com/grafana/mimir/pkg/util/listblocks"
+	"github.com/grafana/mimir/pkg/util/blocksadmin"
 )
 
-//go:embed blocks.gohtml
-var blocksPageHTML string
-var blocksPageTemplate = template.Must(template.New("webpage").Parse(blocksPageHTML))
-
-type blocksPageContents struct {
-	Now             time.Time            `json:"now"`
-	Tenant          string               `json:"tenant,omitempty"`
-	RichMetas       []richMeta           `json:"metas"`
-	FormattedBlocks []formattedBlockData `json:"-"`
-	ShowDeleted     bool                 `json:"-"`
-	ShowSources     bool                 `json:"-"`
-	ShowParents     bool                 `json:"-"`
-	SplitCount      int                  `json:"-"`
+var (
+	//go:embed block.gohtml
+	blockPageHTML     string
+	blockPageTemplate = template.Must(template.New("webpage").Parse(blockPageHTML))
+)
+
+type blockPageContents struct {
+	Now     time.Time `json:"now"`
+	Tenant  string    `json:"tenant"`
+	BlockID string    `json:"blockId"`
+	blocksadmin.BlockDetails
+
+	// Loaded is whether the block is loaded by this store-gateway, in which case LabelNames are read from its index-header.
+	Loaded     bool             `json:"loaded"`
+	LabelNames []labelNameStats `json:"labelNames,omitempty"`
+	IndexError string           `json:"indexError,omitempty"`
+
+	// Replicas are the store-gateways owning the block in the ring.
+	Replicas  []blockReplica `json:"replicas"`
+	RingError string         `json:"ringError,omitempty"`
+}
+
+// MetaJSON returns the meta of the block, as shown in the HTML page.
+func (c blockPageContents) MetaJSON() string {
+	data, err := json.MarshalIndent(c.Meta, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
 }
 
-type formattedBlockData struct {
-	ULID             string
-	ULIDTime         string
-	SplitID          *uint32
-	MinTime          string
-	MaxTime          string
-	Duration         string
-	DeletedTime      string
-	CompactionLevel  int
-	BlockSize        string
-	Labels           string
-	NoCompactDetails []string
-	Sources          []string
-	Parents          []string
-	Stats            prom_tsdb.BlockStats
+type blockReplica struct {
+	ID    string `json:"id"`
+	Addr  string `json:"addr"`
+	Zone  string `json:"zone,omitempty"`
+	State string `json:"state"`
+	Local bool   `json:"local"`
 }
 
-type richMeta struct {
-	*block.Meta
-	DeletedTime *int64  `json:"deletedTime,omitempty"`
-	SplitID     *uint32 `json:"splitId,omitempty"`
+// TenantsHandler serves the list of tenants with blocks in the bucket.
+func (s *StoreGateway) TenantsHandler(w http.ResponseWriter, req *http.Request) {
+	s.blocksAdmin.TenantsHandler(w, req)
 }
 
+// BlocksHandler serves the list of blocks of a tenant.
 func (s *StoreGateway) BlocksHandler(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	tenantID := vars["tenant"]
-	if tenantID == "" {
-		util.WriteTextResponse(w, "Tenant ID can't be empty")
+	s.blocksAdmin.BlocksHandler(w, req)
+}
+
+// BlockHandler serves the details of a block of a tenant: its meta and markers in the bucket, the stats of its
+// index-header if it's loaded by this store-gateway, and the store-gateways owning it in the ring.
+func (s *StoreGateway) BlockHandler(w http.ResponseWriter, req *http.Request) {
+	params, ok := s.blocksAdmin.ParseBlockRequest(w, req)
+	if !ok {
 		return
 	}
+	tenantID, blockID := params.TenantID, params.BlockID
 
-	if err := req.ParseForm(); err != nil {
-		util.WriteTextResponse(w, fmt.Sprintf("Can't parse form: %s", err))
+	details, err := s.blocksAdmin.ReadBlock(req.Context(), tenantID, blockID)
+	if errors.Is(err, blocksadmin.ErrBlockNotFound) {
+		http.Error(w, fmt.Sprintf("block %s of tenant %s not found", blockID, tenantID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to read block", "user", tenantID, "block", blockID, "err", err)
+		http.Error(w, fmt.Sprintf("Failed to read block: %s", err), http.StatusInternalServerError)
 		return
 	}
 
-	showDeleted := req.Form.Get("show_deleted") == "on"
-	showSources := req.Form.Get("show_sources") == "on"
-	showParents := req.Form.Get("show_parents") == "on"
-	var splitCount int
-	if sc := req.Form.Get("split_count"); sc != "" {
-		splitCount, _ = strconv.Atoi(sc)
-		if splitCount < 0 {
-			splitCount = 0
+	contents := blockPageContents{
+		Now:          time.Now(),
+		Tenant:       tenantID,
+		BlockID:      blockID.String(),
+		BlockDetails: details,
+	}
+	if store := s.stores.getStore(tenantID); store != nil {
+		contents.LabelNames, contents.Loaded, err = store.blockLabelNamesStats(req.Context(), blockID)
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "failed to read block index-header", "user", tenantID, "block", blockID, "err", err)
+			contents.IndexError = err.Error()
 		}
 	}
-
-	metasMap, deleteMarkerDetails, noCompactMarkerDetails, err := listblocks.LoadMetaFilesAndMarkers(req.Context(), s.stores.bucket, tenantID, showDeleted, time.Time{})
+	contents.Replicas, err = s.blockReplicas(tenantID, blockID)
 	if err != nil {
-		util.WriteTextResponse(w, fmt.Sprintf("Failed to read block metadata: %s", err))
+		contents.RingError = err.Error()
+	}
+
+	if params.Format == "json" {
+		util.WriteJSONResponse(w, contents)
 		return
 	}
-	metas := listblocks.SortBlocks(metasMap)
+	util.RenderHTTPResponse(w, contents, blockPageTemplate, req)
+}
 
-	formattedBlocks := make([]formattedBlockData, 0, len(metas))
-	richMetas := make([]richMeta, 0, len(metas))
+// blockReplicas returns the store-gateways owning the tenant's block in the ring, as used to sync the blocks.
+func (s *StoreGateway) blockReplicas(tenantID string, blockID ulid.ULID) ([]blockReplica, error) {
+	if s.State() != services.Running {
+		// The ring can't be read before the store-gateway is running.
+		return nil, errors.New("store-gateway is not running yet")
+	}
 
-	for _, m := range metas {
-		if !showDeleted && deleteMarkerDetails[m.ULID].DeletionTime != 0 {
-			continue
-		}
-		var parents []string
-		for _, pb := range m.Compaction.Parents {
-			parents = append(parents, pb.ULID.String())
-		}
-		var sources []string
-		for _, pb := range m.Compaction.Sources {
-			sources = append(sources, pb.String())
-		}
-		var blockSplitID *uint32
-		if splitCount > 0 {
-			bsc := tsdb.HashBlockID(m.ULID) % uint32(splitCount)
-			blockSplitID = &bsc
-		}
-		lbls := labels.FromMap(m.Thanos.Labels)
-		noCompactDetails := []string{}
-		if val, ok := noCompactMarkerDetails[m.ULID]; ok {
-			noCompactDetails = []string{
-				fmt.Sprintf("Time: %s", formatTimeIfNotZero(val.NoCompactTime, time.RFC3339)),
-				fmt.Sprintf("Reason: %s", val.Reason),
-			}
-		}
+	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	r := GetShuffleShardingSubring(s.ring, tenantID, s.stores.limits)
+	set, err := r.Get(mimir_tsdb.HashBlockID(blockID), BlocksOwnerSync, bufDescs, bufHosts, bufZones)
+	if err != nil {
+		return nil, err
+	}
 
-		formattedBlocks = append(formattedBlocks, formattedBlockData{
-			ULID:             m.ULID.String(),
-			ULIDTime:         util.TimeFromMillis(int64(m.ULID.Time())).UTC().Format(time.RFC3339),
-			SplitID:          blockSplitID,
-			MinTime:          util.TimeFromMillis(m.MinTime).UTC().Format(time.RFC3339),
-			MaxTime:          util.TimeFromMillis(m.MaxTime).UTC().Format(time.RFC3339),
-			Duration:         util.TimeFromMillis(m.MaxTime).Sub(util.TimeFromMillis(m.MinTime)).String(),
-			DeletedTime:      formatTimeIfNotZero(deleteMarkerDetails[m.ULID].DeletionTime, time.RFC3339),
-			NoCompactDetails: noCompactDetails,
-			CompactionLevel:  m.Compaction.Level,
-			BlockSize:        listblocks.GetFormattedBlockSize(m),
-			Labels:           lbls.String(),
-			Sources:          sources,
-			Parents:          parents,
-			Stats:            m.Stats,
-		})
-		var deletedAt *int64
-		if dt, ok := deleteMarkerDetails[m.ULID]; ok {
-			deletedAtTime := dt.DeletionTime * int64(time.Second/time.Millisecond)
-			deletedAt = &deletedAtTime
-		}
-		richMetas = append(richMetas, richMeta{
-			Meta:        m,
-			DeletedTime: deletedAt,
-			SplitID:     blockSplitID,
+	replicas := make([]blockReplica, 0, len(set.Instances))
+	for _, inst := range set.Instances {
+		replicas = append(replicas, blockReplica{
+			ID:    inst.Id,
+			Addr:  inst.Addr,
+			Zone:  inst.Zone,
+			State: inst.State.String(),
+			Local: inst.Id == s.ringLifecycler.GetInstanceID(),
 		})
 	}
-
-	util.RenderHTTPResponse(w, blocksPageContents{
-		Now:             time.Now(),
-		Tenant:          tenantID,
-		RichMetas:       richMetas,
-		FormattedBlocks: formattedBlocks,
-
-		SplitCount:  splitCount,
-		ShowDeleted: showDeleted,
-		ShowSources: showSources,
-		ShowParents: showParents,
-	}, blocksPageTemplate, req)
+	return replicas, nil
 }
 
-func formatTimeIfNotZero(t int64, format string) string {
-	if t == 0 {
-		return ""
-	}
-	return time.Unix(t, 0).UTC().Format(format)
+// APIDocsHandler serves the OpenAPI document describing the store-gateway HTTP endpoints.
+func (s *StoreGateway) APIDocsHandler(w http.ResponseWriter, req *http.Request) {
+	s.blocksAdmin.APIDocsHandler(w, req)
 }
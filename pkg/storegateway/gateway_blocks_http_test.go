@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestStoreGateway_BlockHandler(t *testing.T) {
+	test.VerifyNoLeak(t)
+
+	const userID = "user-1"
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	storageDir := t.TempDir()
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	// The first block is loaded by the store-gateway when it starts, while the second one is uploaded afterwards.
+	now := time.Now()
+	minT, maxT := now.Add(-time.Hour).UnixMilli(), now.UnixMilli()
+	mockTSDB(t, path.Join(storageDir, userID), 3, 0, minT, maxT)
+	createBucketIndex(t, bucketClient, userID)
+	loadedID := listBlockIDs(t, bucketClient, userID)[0]
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), logger, nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	g, err := newStoreGateway(mockGatewayConfig(), mockStorageConfig(t), bucketClient, ringStore, defaultLimitsOverrides(t), logger, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, g))
+	t.Cleanup(func() { assert.NoError(t, services.StopAndAwaitTerminated(ctx, g)) })
+
+	mockTSDB(t, path.Join(storageDir, userID), 2, 0, minT, maxT)
+	var notLoadedID ulid.ULID
+	for _, id := range listBlockIDs(t, bucketClient, userID) {
+		if id != loadedID {
+			notLoadedID = id
+		}
+	}
+	userBkt := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	require.NoError(t, block.MarkForDeletion(ctx, logger, userBkt, notLoadedID, "test", prometheus.NewCounter(prometheus.CounterOpts{})))
+	require.NoError(t, block.MarkForNoCompact(ctx, logger, userBkt, notLoadedID, block.ManualNoCompactReason, "test", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	router := mux.NewRouter()
+	router.Path("/store-gateway/tenant/{tenant}/block/{block}").HandlerFunc(g.BlockHandler)
+	get := func(t *testing.T, blockID string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/"+userID+"/block/"+blockID, nil)
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	getJSON := func(t *testing.T, blockID ulid.ULID) blockPageContents {
+		resp := get(t, blockID.String(), "application/json")
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var contents blockPageContents
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &contents))
+		return contents
+	}
+	expectedReplicas := []blockReplica{{ID: "test", Addr: "127.0.0.1:0", State: ring.ACTIVE.String(), Local: true}}
+
+	t.Run("loaded block", func(t *testing.T) {
+		contents := getJSON(t, loadedID)
+
+		assert.Equal(t, userID, contents.Tenant)
+		require.NotNil(t, contents.Meta)
+		assert.Equal(t, loadedID, contents.Meta.ULID)
+		assert.Nil(t, contents.DeletionMark)
+		assert.Nil(t, contents.NoCompactMark)
+		assert.True(t, contents.Loaded)
+		assert.Empty(t, contents.IndexError)
+		assert.Equal(t, []labelNameStats{{Name: "series_id", Values: 3, Series: 3}}, contents.LabelNames)
+		assert.Equal(t, expectedReplicas, contents.Replicas)
+
+		// The stats are cached by the block, rather than read from the index-header on each request.
+		b := g.stores.getStore(userID).blockSet.get(loadedID)
+		require.NotNil(t, b)
+		assert.Equal(t, contents.LabelNames, b.labelNamesStats)
+		assert.Equal(t, contents.LabelNames, getJSON(t, loadedID).LabelNames)
+
+		resp := get(t, loadedID.String(), "text/html")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Body.String(), "<td>series_id</td>")
+		assert.Contains(t, resp.Body.String(), "test (this instance)")
+	})
+
+	t.Run("block not loaded locally", func(t *testing.T) {
+		contents := getJSON(t, notLoadedID)
+
+		require.NotNil(t, contents.Meta)
+		assert.Equal(t, notLoadedID, contents.Meta.ULID)
+		require.NotNil(t, contents.DeletionMark)
+		assert.Equal(t, notLoadedID, contents.DeletionMark.ID)
+		require.NotNil(t, contents.NoCompactMark)
+		assert.Equal(t, block.ManualNoCompactReason, contents.NoCompactMark.Reason)
+		assert.False(t, contents.Loaded)
+		assert.Empty(t, contents.LabelNames)
+		assert.Equal(t, expectedReplicas, contents.Replicas)
+
+		resp := get(t, notLoadedID.String(), "text/html")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Body.String(), "The block is not loaded by this store-gateway.")
+	})
+
+	t.Run("missing block", func(t *testing.T) {
+		resp := get(t, ulid.MustNew(1, nil).String(), "application/json")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("invalid block ID", func(t *testing.T) {
+		resp := get(t, "not-a-block", "application/json")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"parameter":"block"`)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		resp := get(t, loadedID.String()+"?format=csv", "application/json")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), `"parameter":"format"`)
+	})
+}
+
+// listBlockIDs returns the IDs of the blocks of the tenant in the bucket.
+func listBlockIDs(t *testing.T, bkt objstore.Bucket, userID string) []ulid.ULID {
+	var ids []ulid.ULID
+	require.NoError(t, bkt.Iter(context.Background(), userID+"/", func(key string) error {
+		if id, ok := block.IsBlockDir(strings.TrimPrefix(key, userID+"/")); ok {
+			ids = append(ids, id)
+		}
+		return nil
+	}))
+	return ids
+}
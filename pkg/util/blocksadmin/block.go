@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"context"
+	"net/http"
+	"path"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+// ErrBlockNotFound is returned by ReadBlock when the block has no meta.json in the bucket.
+var ErrBlockNotFound = errors.New("block not found")
+
+// BlockDetails is the meta and the markers of a block, as read from the bucket.
+type BlockDetails struct {
+	Meta          *block.Meta          `json:"meta"`
+	DeletionMark  *block.DeletionMark  `json:"deletionMark,omitempty"`
+	NoCompactMark *block.NoCompactMark `json:"noCompactMark,omitempty"`
+}
+
+// BlockRequest is a request of the block page.
+type BlockRequest struct {
+	TenantID string
+	BlockID  ulid.ULID
+	Format   string
+}
+
+// ParseBlockRequest parses and validates the parameters of a request of the block page. If they're invalid,
+// it writes a 400 response naming the invalid parameter and returns false.
+func (h *Handler) ParseBlockRequest(w http.ResponseWriter, req *http.Request) (BlockRequest, bool) {
+	params, err := parseHTTPParams(req, h.blockRoute.Params)
+	if err != nil {
+		writeHTTPParamError(w, err)
+		return BlockRequest{}, false
+	}
+	return BlockRequest{
+		TenantID: params.String("tenant"),
+		BlockID:  params.BlockID("block"),
+		Format:   params.String("format"),
+	}, true
+}
+
+// ReadBlock reads the meta.json of the tenant's block, and its deletion and no-compact markers if any, from the
+// block directory in the bucket. The reads are subject to the same limits as the ones of the pages. It returns
+// ErrBlockNotFound if the block has no meta.json.
+func (h *Handler) ReadBlock(ctx context.Context, tenantID string, blockID ulid.ULID) (BlockDetails, error) {
+	bkt := h.requestBucket("block")
+	blockDir := path.Join(tenantID, blockID.String())
+
+	r, err := bkt.Get(ctx, path.Join(blockDir, block.MetaFilename))
+	if err != nil {
+		if bkt.Bucket.IsObjNotFoundErr(err) {
+			return BlockDetails{}, ErrBlockNotFound
+		}
+		return BlockDetails{}, errors.Wrapf(err, "get block meta %s", blockID)
+	}
+	meta, err := block.ReadMeta(r)
+	if err != nil {
+		return BlockDetails{}, errors.Wrapf(err, "read block meta %s", blockID)
+	}
+	details := BlockDetails{Meta: meta}
+
+	instrBkt := objstore.WithNoopInstr(bkt)
+	deletionMark := &block.DeletionMark{}
+	if err := block.ReadMarker(ctx, h.logger, instrBkt, blockDir, deletionMark); err == nil {
+		details.DeletionMark = deletionMark
+	} else if !errors.Is(err, block.ErrorMarkerNotFound) {
+		return BlockDetails{}, errors.Wrapf(err, "read deletion mark of block %s", blockID)
+	}
+	noCompactMark := &block.NoCompactMark{}
+	if err := block.ReadMarker(ctx, h.logger, instrBkt, blockDir, noCompactMark); err == nil {
+		details.NoCompactMark = noCompactMark
+	} else if !errors.Is(err, block.ErrorMarkerNotFound) {
+		return BlockDetails{}, errors.Wrapf(err, "read no-compact mark of block %s", blockID)
+	}
+	return details, nil
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestHandler_ReadBlock(t *testing.T) {
+	const tenantID = "user-1"
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	upload := func(name string, v any) {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		require.NoError(t, bkt.Upload(ctx, path.Join(tenantID, name), bytes.NewReader(data)))
+	}
+
+	plainID, markedID := ulid.MustNew(1, nil), ulid.MustNew(2, nil)
+	for _, id := range []ulid.ULID{plainID, markedID} {
+		upload(path.Join(id.String(), block.MetaFilename), block.Meta{
+			BlockMeta: prom_tsdb.BlockMeta{ULID: id, Version: block.TSDBVersion1},
+			Thanos:    block.ThanosMeta{Version: block.ThanosVersion1},
+		})
+	}
+	upload(path.Join(markedID.String(), block.DeletionMarkFilename), block.DeletionMark{ID: markedID, DeletionTime: 100, Version: block.DeletionMarkVersion1})
+	upload(path.Join(markedID.String(), block.NoCompactMarkFilename), block.NoCompactMark{ID: markedID, NoCompactTime: 200, Reason: block.ManualNoCompactReason, Version: block.NoCompactMarkVersion1})
+
+	h := New(Config{Component: "Store-gateway", PathPrefix: "/store-gateway"}, bkt, nil, log.NewNopLogger(), nil)
+
+	details, err := h.ReadBlock(ctx, tenantID, plainID)
+	require.NoError(t, err)
+	assert.Equal(t, plainID, details.Meta.ULID)
+	assert.Nil(t, details.DeletionMark)
+	assert.Nil(t, details.NoCompactMark)
+
+	details, err = h.ReadBlock(ctx, tenantID, markedID)
+	require.NoError(t, err)
+	assert.Equal(t, markedID, details.Meta.ULID)
+	require.NotNil(t, details.DeletionMark)
+	assert.Equal(t, int64(100), details.DeletionMark.DeletionTime)
+	require.NotNil(t, details.NoCompactMark)
+	assert.Equal(t, block.ManualNoCompactReason, details.NoCompactMark.Reason)
+
+	_, err = h.ReadBlock(ctx, tenantID, ulid.MustNew(3, nil))
+	require.ErrorIs(t, err, ErrBlockNotFound)
+	_, err = h.ReadBlock(ctx, "user-2", plainID)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+}
@@ -0,0 +1,544 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bufio"
+	_ "embed" // Used to embed html template
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/listblocks"
+)
+
+//go:embed blocks.gohtml
+var blocksPageHTML string
+var blocksPageTemplate = template.Must(template.New("webpage").Parse(blocksPageHTML))
+
+const (
+	blocksFormatCSV  = "csv"
+	blocksFormatJSON = "json"
+	blocksFormatHTML = "html"
+)
+
+type blocksPageContents struct {
+	Component       string               `json:"-"`
+	Now             time.Time            `json:"now"`
+	Tenant          string               `json:"tenant,omitempty"`
+	Summary         blocksSummary        `json:"summary"`
+	FormattedBlocks []formattedBlockData `json:"-"`
+	ShowDeleted     bool                 `json:"-"`
+	ShowSources     bool                 `json:"-"`
+	ShowParents     bool                 `json:"-"`
+	SplitCount      int                  `json:"-"`
+	MinTime         string               `json:"-"`
+	MaxTime         string               `json:"-"`
+	CompactionLevel int                  `json:"-"`
+	OnlyOutOfOrder  bool                 `json:"-"`
+	Source          string               `json:"-"`
+	Sources         []string             `json:"-"`
+	LinkBlocks      bool                 `json:"-"`
+
+	// View is the view explicitly requested with the view parameter, if any, so that the form keeps it.
+	View string `json:"-"`
+	// Permalink is the link to the listing with the current display options and filters, so that they can be
+	// bookmarked or shared. The export links keep them too.
+	Permalink     string `json:"-"`
+	CSVExportURL  string `json:"-"`
+	JSONExportURL string `json:"-"`
+
+	Page        int    `json:"page"`
+	PageSize    int    `json:"pageSize"`
+	TotalPages  int    `json:"totalPages"`
+	TotalBlocks int    `json:"totalBlocks"`
+	PrevPageURL string `json:"-"`
+	NextPageURL string `json:"-"`
+
+	// Truncated explains why the listing is incomplete, if the limit of objects per request was reached.
+	Truncated string `json:"truncated,omitempty"`
+
+	DailySummary []dailySummary `json:"dailySummary"`
+	BlocksChart  template.HTML  `json:"-"`
+	BytesChart   template.HTML  `json:"-"`
+}
+
+type formattedBlockData struct {
+	ULID             string
+	ULIDTime         string
+	SplitID          *uint32
+	MinTime          string
+	MaxTime          string
+	Duration         string
+	DeletedTime      string
+	CompactionLevel  int
+	OutOfOrder       bool
+	SourceComponent  string
+	BlockSize        string
+	Labels           string
+	NoCompactDetails []string
+	Sources          []string
+	Parents          []string
+	Stats            prom_tsdb.BlockStats
+}
+
+type richMeta struct {
+	*block.Meta
+	DeletedTime     *int64  `json:"deletedTime,omitempty"`
+	SplitID         *uint32 `json:"splitId,omitempty"`
+	OutOfOrder      bool    `json:"outOfOrder"`
+	SourceComponent string  `json:"sourceComponent"`
+}
+
+func (h *Handler) BlocksHandler(w http.ResponseWriter, req *http.Request) {
+	params, err := parseHTTPParams(req, h.blocksRoute.Params)
+	if err != nil {
+		writeHTTPParamError(w, err)
+		return
+	}
+
+	minTime, hasMinTime := params.Time("min_time")
+	maxTime, hasMaxTime := params.Time("max_time")
+	if hasMinTime && hasMaxTime && minTime.After(maxTime) {
+		writeHTTPParamError(w, &httpParamError{Param: "min_time", Message: "must be before or equal to max_time"})
+		return
+	}
+
+	format := params.String("format")
+	if view := params.String("view"); view != "" {
+		if format != "" && format != view {
+			writeHTTPParamError(w, &httpParamError{Param: "view", Message: fmt.Sprintf("conflicts with format=%s", format)})
+			return
+		}
+		format = view
+	}
+	if format == "" && strings.Contains(req.Header.Get("Accept"), "text/csv") {
+		format = blocksFormatCSV
+	}
+	if (format == blocksFormatCSV || format == blocksFormatJSON) && h.exports != nil {
+		h.serveBlocksExport(w, req, params, format)
+		return
+	}
+
+	if err := h.writeBlocks(w, req, params, format); err != nil {
+		writeBlocksError(w, err)
+	}
+}
+
+// writeBlocks writes the blocks page, or the export in the given format. It returns an error, without writing
+// anything, if the blocks can't be listed.
+func (h *Handler) writeBlocks(w http.ResponseWriter, req *http.Request, params httpParams, format string) error {
+	tenantID := params.String("tenant")
+	showDeleted := params.Bool("show_deleted")
+	showSources := params.Bool("show_sources")
+	showParents := params.Bool("show_parents")
+	splitCount := params.Int("split_count")
+	minTime, hasMinTime := params.Time("min_time")
+	maxTime, hasMaxTime := params.Time("max_time")
+	compactionLevel := params.Int("compaction_level")
+	onlyOutOfOrder := params.Bool("only_ooo")
+	source := params.String("source")
+
+	bkt := h.requestBucket("blocks")
+	metasMap, deleteMarkerDetails, noCompactMarkerDetails, err := listblocks.LoadMetaFilesAndMarkers(req.Context(), bkt, tenantID, showDeleted, time.Time{})
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to read block metadata", "user", tenantID, "err", err)
+		return err
+	}
+	var truncated string
+	if skipped := bkt.truncated(); skipped > 0 {
+		truncated = fmt.Sprintf("The listing is incomplete: %d objects were not loaded because the limit of %d objects per request was reached.", skipped, h.cfg.MaxObjectsPerRequest)
+		level.Warn(h.logger).Log("msg", "blocks listing truncated", "user", tenantID, "skipped_objects", skipped, "limit", h.cfg.MaxObjectsPerRequest)
+	}
+	metas := listblocks.SortBlocks(metasMap)
+
+	filtered := make([]*block.Meta, 0, len(metas))
+	for _, m := range metas {
+		if !showDeleted && deleteMarkerDetails[m.ULID].DeletionTime != 0 {
+			continue
+		}
+		// Blocks partially overlapping the requested range are shown. The block max time is exclusive.
+		if hasMinTime && m.MaxTime <= minTime.UnixMilli() {
+			continue
+		}
+		if hasMaxTime && m.MinTime > maxTime.UnixMilli() {
+			continue
+		}
+		if compactionLevel > 0 && m.Compaction.Level != compactionLevel {
+			continue
+		}
+		if onlyOutOfOrder && !isOutOfOrderBlock(m) {
+			continue
+		}
+		if source != "" && blockSourceComponent(m) != source {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+
+	// Pages are applied after sorting and filtering, so that they're stable across requests.
+	page := params.Int("page")
+	pageSize := h.cfg.DefaultPageSize
+	if params.IsSet("page_size") {
+		pageSize = params.Int("page_size")
+	}
+	totalPages := (len(filtered) + pageSize - 1) / pageSize
+	pageStart := min((page-1)*pageSize, len(filtered))
+	pageEnd := min(pageStart+pageSize, len(filtered))
+
+	if format == blocksFormatCSV {
+		// CSV exports are meant to get all the blocks at once.
+		if !req.Form.Has("page") && !req.Form.Has("page_size") {
+			pageStart, pageEnd = 0, len(filtered)
+		}
+		h.writeBlocksCSV(w, tenantID, filtered[pageStart:pageEnd], deleteMarkerDetails, noCompactMarkerDetails)
+		return nil
+	}
+	metas = filtered[pageStart:pageEnd]
+
+	contents := blocksPageContents{
+		Component: h.cfg.Component,
+		Now:       time.Now(),
+		Tenant:    tenantID,
+		Summary:   summarizeBlocks(filtered),
+
+		SplitCount:      splitCount,
+		ShowDeleted:     showDeleted,
+		ShowSources:     showSources,
+		ShowParents:     showParents,
+		MinTime:         req.Form.Get("min_time"),
+		MaxTime:         req.Form.Get("max_time"),
+		CompactionLevel: compactionLevel,
+		OnlyOutOfOrder:  onlyOutOfOrder,
+		Source:          source,
+		Sources:         blockSourceComponents,
+		LinkBlocks:      h.cfg.LinkBlocks,
+
+		View:          params.String("view"),
+		Permalink:     blocksPermalink(req, params.String("view"), true),
+		CSVExportURL:  blocksPermalink(req, blocksFormatCSV, false),
+		JSONExportURL: blocksPermalink(req, blocksFormatJSON, false),
+
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  totalPages,
+		TotalBlocks: len(filtered),
+		PrevPageURL: blocksPageURL(req, page-1, 1, totalPages),
+		NextPageURL: blocksPageURL(req, page+1, 1, totalPages),
+		Truncated:   truncated,
+
+		DailySummary: summarizeBlocksByDay(metas),
+	}
+	// The HTML view is rendered if it's requested, even if the client accepts JSON.
+	if format == blocksFormatJSON || (format == "" && strings.Contains(req.Header.Get("Accept"), "application/json")) {
+		h.writeBlocksJSON(w, contents, metas, deleteMarkerDetails, splitCount)
+		return nil
+	}
+
+	contents.FormattedBlocks = make([]formattedBlockData, 0, len(metas))
+	for _, m := range metas {
+		var parents []string
+		for _, pb := range m.Compaction.Parents {
+			parents = append(parents, pb.ULID.String())
+		}
+		var sources []string
+		for _, pb := range m.Compaction.Sources {
+			sources = append(sources, pb.String())
+		}
+		lbls := labels.FromMap(m.Thanos.Labels)
+		noCompactDetails := []string{}
+		if val, ok := noCompactMarkerDetails[m.ULID]; ok {
+			noCompactDetails = []string{
+				fmt.Sprintf("Time: %s", formatTimeIfNotZero(val.NoCompactTime, time.RFC3339)),
+				fmt.Sprintf("Reason: %s", val.Reason),
+			}
+		}
+
+		contents.FormattedBlocks = append(contents.FormattedBlocks, formattedBlockData{
+			ULID:             m.ULID.String(),
+			ULIDTime:         util.TimeFromMillis(int64(m.ULID.Time())).UTC().Format(time.RFC3339),
+			SplitID:          blockSplitID(m, splitCount),
+			MinTime:          util.TimeFromMillis(m.MinTime).UTC().Format(time.RFC3339),
+			MaxTime:          util.TimeFromMillis(m.MaxTime).UTC().Format(time.RFC3339),
+			Duration:         util.TimeFromMillis(m.MaxTime).Sub(util.TimeFromMillis(m.MinTime)).String(),
+			DeletedTime:      formatTimeIfNotZero(deleteMarkerDetails[m.ULID].DeletionTime, time.RFC3339),
+			NoCompactDetails: noCompactDetails,
+			CompactionLevel:  m.Compaction.Level,
+			OutOfOrder:       isOutOfOrderBlock(m),
+			SourceComponent:  blockSourceComponent(m),
+			BlockSize:        listblocks.GetFormattedBlockSize(m),
+			Labels:           lbls.String(),
+			Sources:          sources,
+			Parents:          parents,
+			Stats:            m.Stats,
+		})
+	}
+
+	contents.BlocksChart, contents.BytesChart = dailySummaryCharts(contents.DailySummary)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := blocksPageTemplate.Execute(w, contents); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// blocksJSONResponse is the JSON encoding of the listing written by writeBlocksJSON.
+type blocksJSONResponse struct {
+	blocksPageContents
+	Metas []richMeta `json:"metas"`
+}
+
+// writeBlocksJSON writes the listing as a JSON object, with the metas of the blocks last. The metas are encoded one
+// by one as they're written, rather than marshalled all at once, so that the memory used doesn't grow with the
+// number of blocks.
+func (h *Handler) writeBlocksJSON(w http.ResponseWriter, contents blocksPageContents, metas []*block.Meta, deleteMarkerDetails map[ulid.ULID]block.DeletionMark, splitCount int) {
+	head, err := json.Marshal(contents)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// The metas are appended to the object marshalled from the contents, replacing its closing brace.
+	bw := bufio.NewWriter(w)
+	_, _ = bw.Write(head[:len(head)-1])
+	_, _ = bw.WriteString(`,"metas":[`)
+	enc := json.NewEncoder(bw)
+	for i, m := range metas {
+		if i > 0 {
+			_ = bw.WriteByte(',')
+		}
+		if err := enc.Encode(newRichMeta(m, deleteMarkerDetails, splitCount)); err != nil {
+			// The client went away, there's no point in writing the remaining metas.
+			level.Warn(h.logger).Log("msg", "failed to write blocks JSON", "user", contents.Tenant, "err", err)
+			return
+		}
+	}
+	_, _ = bw.WriteString("]}")
+	if err := bw.Flush(); err != nil {
+		level.Warn(h.logger).Log("msg", "failed to write blocks JSON", "user", contents.Tenant, "err", err)
+	}
+}
+
+func newRichMeta(m *block.Meta, deleteMarkerDetails map[ulid.ULID]block.DeletionMark, splitCount int) richMeta {
+	var deletedAt *int64
+	if dt, ok := deleteMarkerDetails[m.ULID]; ok {
+		deletedAtTime := dt.DeletionTime * int64(time.Second/time.Millisecond)
+		deletedAt = &deletedAtTime
+	}
+	return richMeta{
+		Meta:            m,
+		DeletedTime:     deletedAt,
+		SplitID:         blockSplitID(m, splitCount),
+		OutOfOrder:      isOutOfOrderBlock(m),
+		SourceComponent: blockSourceComponent(m),
+	}
+}
+
+// blockSplitID returns the split of the block when the blocks are split in splitCount groups, or nil if they aren't.
+func blockSplitID(m *block.Meta, splitCount int) *uint32 {
+	if splitCount <= 0 {
+		return nil
+	}
+	id := tsdb.HashBlockID(m.ULID) % uint32(splitCount)
+	return &id
+}
+
+func writeBlocksError(w http.ResponseWriter, err error) {
+	util.WriteTextResponse(w, fmt.Sprintf("Failed to read block metadata: %s", err))
+}
+
+// serveBlocksExport serves the export from a spool, so that the download can be resumed with Range requests.
+// The first request lists the blocks and spools the export, returning its token. Requests with the token
+// are served from the spool, so all the ranges of the download come from the same listing.
+func (h *Handler) serveBlocksExport(w http.ResponseWriter, req *http.Request, params httpParams, format string) {
+	// The export is identified by the path, with the tenant, and by all the parameters.
+	key := req.URL.Path + "?" + req.URL.Query().Encode() + "#" + format
+
+	if token := req.Header.Get(ExportTokenHeader); token != "" {
+		spool := h.exports.get(token)
+		if spool == nil {
+			writeExportGone(w)
+			return
+		}
+		if spool.key != key {
+			http.Error(w, "the export token was returned for a different export", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(ExportTokenHeader, spool.token)
+		h.serveExportSpool(w, req, spool)
+		return
+	}
+
+	sw := h.exports.newWriter()
+	if err := h.writeBlocks(sw, req, params, format); err != nil {
+		sw.discard()
+		writeBlocksError(w, err)
+		return
+	}
+	spool, err := sw.finish(key)
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to spool blocks export", "user", params.String("tenant"), "err", err)
+		http.Error(w, fmt.Sprintf("Failed to spool the export: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if h.exports.add(spool) {
+		w.Header().Set(ExportTokenHeader, spool.token)
+	} else {
+		// The export is too large to be kept, so it can't be resumed.
+		level.Warn(h.logger).Log("msg", "blocks export is larger than the spool disk limit, so it can't be resumed", "user", params.String("tenant"), "size", spool.size)
+		defer discardSpool(spool, h.logger)
+	}
+	h.serveExportSpool(w, req, spool)
+}
+
+// serveExportSpool serves the spooled export, handling Range and conditional requests.
+func (h *Handler) serveExportSpool(w http.ResponseWriter, req *http.Request, spool *exportSpool) {
+	r, err := spool.open()
+	if err != nil {
+		// The spool has been evicted in the meantime.
+		writeExportGone(w)
+		return
+	}
+	defer r.Close()
+
+	for name, values := range spool.header {
+		w.Header()[name] = values
+	}
+	http.ServeContent(w, req, "", spool.created, r)
+}
+
+func writeExportGone(w http.ResponseWriter) {
+	http.Error(w, fmt.Sprintf("the export token is unknown or expired: restart the export without the %s header", ExportTokenHeader), http.StatusGone)
+}
+
+var blocksCSVHeader = []string{
+	"ulid", "min_time", "max_time", "duration", "compaction_level", "size_bytes",
+	"series", "samples", "chunks", "deleted_time", "no_compact_reason",
+}
+
+// writeBlocksCSV writes one CSV row per block. Rows are written as they're produced, rather than buffered.
+func (h *Handler) writeBlocksCSV(w http.ResponseWriter, tenantID string, metas []*block.Meta, deleteMarkerDetails map[ulid.ULID]block.DeletionMark, noCompactMarkerDetails map[ulid.ULID]block.NoCompactMark) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", tenantID+"-blocks.csv"))
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(blocksCSVHeader)
+	for _, m := range metas {
+		if err := cw.Write([]string{
+			m.ULID.String(),
+			util.TimeFromMillis(m.MinTime).UTC().Format(time.RFC3339),
+			util.TimeFromMillis(m.MaxTime).UTC().Format(time.RFC3339),
+			util.TimeFromMillis(m.MaxTime).Sub(util.TimeFromMillis(m.MinTime)).String(),
+			strconv.Itoa(m.Compaction.Level),
+			strconv.FormatUint(listblocks.GetBlockSizeBytes(m), 10),
+			strconv.FormatUint(m.Stats.NumSeries, 10),
+			strconv.FormatUint(m.Stats.NumSamples, 10),
+			strconv.FormatUint(m.Stats.NumChunks, 10),
+			formatTimeIfNotZero(deleteMarkerDetails[m.ULID].DeletionTime, time.RFC3339),
+			string(noCompactMarkerDetails[m.ULID].Reason),
+		}); err != nil {
+			// The client went away, there's no point in writing the remaining rows.
+			level.Warn(h.logger).Log("msg", "failed to write blocks CSV", "user", tenantID, "err", err)
+			return
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		level.Warn(h.logger).Log("msg", "failed to write blocks CSV", "user", tenantID, "err", err)
+	}
+}
+
+// blocksPageURL returns the URL of the given page of the current listing, keeping all the other parameters.
+// It returns an empty string if the page is out of the [first, last] range.
+func blocksPageURL(req *http.Request, page, first, last int) string {
+	if page < first || page > last {
+		return ""
+	}
+	query := blocksListingQuery(req)
+	query.Set("page", strconv.Itoa(page))
+	return "?" + query.Encode()
+}
+
+// blocksPermalink returns the URL of the current listing in the given view, keeping the display options and filters.
+// The page is kept only if keepPage is true, since exports are meant to get all the blocks at once.
+func blocksPermalink(req *http.Request, view string, keepPage bool) string {
+	query := blocksListingQuery(req)
+	query.Del("format")
+	if !keepPage {
+		query.Del("page")
+		query.Del("page_size")
+	}
+	if view != "" {
+		query.Set("view", view)
+	} else {
+		query.Del("view")
+	}
+	return "?" + query.Encode()
+}
+
+// blocksListingQuery returns the query parameters of the current listing, without the empty ones sent by the form.
+func blocksListingQuery(req *http.Request) url.Values {
+	query := req.URL.Query()
+	for name, values := range query {
+		if len(values) == 0 || values[0] == "" {
+			query.Del(name)
+		}
+	}
+	return query
+}
+
+// Components producing blocks, as derived by blockSourceComponent.
+const (
+	blockSourceIngester       = "ingester"
+	blockSourceBlockBuilder   = "block-builder"
+	blockSourceCompactorSplit = "compactor-split"
+	blockSourceCompactorMerge = "compactor-merge"
+)
+
+var blockSourceComponents = []string{blockSourceIngester, blockSourceBlockBuilder, blockSourceCompactorSplit, blockSourceCompactorMerge}
+
+// blockSourceComponent returns the component which produced the block. Blocks of level 1 are uploaded by the
+// ingesters, unless their source is the block-builder. Compacted blocks are produced by the split stage of the
+// compactor if they have a shard ID and level 2, since the split stage compacts level 1 blocks, and by the merge
+// stage otherwise.
+func blockSourceComponent(m *block.Meta) string {
+	switch {
+	case m.Thanos.Source == block.BlockBuilderSource:
+		return blockSourceBlockBuilder
+	case m.Compaction.Level <= 1:
+		return blockSourceIngester
+	case m.Compaction.Level == 2 && m.Thanos.Labels[tsdb.CompactorShardIDExternalLabel] != "":
+		return blockSourceCompactorSplit
+	default:
+		return blockSourceCompactorMerge
+	}
+}
+
+// isOutOfOrderBlock returns whether the block contains out-of-order data, according to its compaction hints or
+// external labels.
+func isOutOfOrderBlock(m *block.Meta) bool {
+	return m.Compaction.FromOutOfOrder() || m.Thanos.Labels[tsdb.OutOfOrderExternalLabel] == tsdb.OutOfOrderExternalLabelValue
+}
+
+func formatTimeIfNotZero(t int64, format string) string {
+	if t == 0 {
+		return ""
+	}
+	return time.Unix(t, 0).UTC().Format(format)
+}
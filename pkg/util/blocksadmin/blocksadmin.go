@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+// Package blocksadmin implements the admin pages to browse the tenants and blocks in the bucket.
+// The same pages are served by the store-gateway and, optionally, by the compactor.
+package blocksadmin
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+const (
+	DefaultPageSize = 1000
+	MaxPageSize     = 10000
+)
+
+// TenantsLister lists the tenants shown in the tenants page.
+type TenantsLister func(ctx context.Context) ([]string, error)
+
+type Config struct {
+	// Component is the name of the component serving the pages, shown in their title.
+	Component string
+
+	// PathPrefix is the prefix of the routes of the pages, e.g. "/store-gateway".
+	PathPrefix string
+
+	// DefaultPageSize is the number of blocks per page when the page_size parameter is not set.
+	DefaultPageSize int
+
+	// OmitTenantsRoute leaves the tenants page out of the OpenAPI document, for the components serving
+	// their own tenants page at <PathPrefix>/tenants rather than the one of the Handler.
+	OmitTenantsRoute bool
+
+	// LinkBlocks links the blocks listed in the blocks page to the block page, which must be served by the
+	// component at <PathPrefix>/tenant/{tenant}/block/{block}.
+	LinkBlocks bool
+
+	// BlockResponse is the JSON response of the block page, described in the OpenAPI document when
+	// LinkBlocks is set. If nil, the response is described as BlockDetails.
+	BlockResponse any
+
+	// BucketRateLimit is the maximum number of object storage operations per second done by the pages,
+	// shared by all the requests. 0 means no limit.
+	BucketRateLimit float64
+
+	// BucketRateLimitBurst is the burst of object storage operations allowed by the rate limit.
+	BucketRateLimitBurst int
+
+	// MaxObjectsPerRequest is the maximum number of objects read from the object storage by a single
+	// request. The listing is truncated when the limit is reached. 0 means no limit.
+	MaxObjectsPerRequest int
+
+	// ExportSpoolTTL is how long the CSV and JSON exports are kept in a spool after being generated, so
+	// that their downloads can be resumed with Range requests. 0 disables the spool.
+	ExportSpoolTTL time.Duration
+
+	// ExportSpoolDir is the directory where the exports larger than ExportSpoolMemoryBytes are spooled.
+	// If empty, the default directory for temporary files is used.
+	ExportSpoolDir string
+
+	// ExportSpoolMaxDiskBytes is the maximum disk used by all the spooled exports. The oldest ones are
+	// removed to make room for new ones, and exports larger than the limit are not kept.
+	ExportSpoolMaxDiskBytes int64
+
+	// ExportSpoolMemoryBytes is the size up to which an export is spooled in memory. If 0,
+	// DefaultExportSpoolMemoryBytes is used.
+	ExportSpoolMemoryBytes int64
+}
+
+// Handler serves the tenants and blocks admin pages for a bucket.
+type Handler struct {
+	cfg         Config
+	bucket      objstore.Bucket
+	listTenants TenantsLister
+	logger      log.Logger
+
+	// limiter is nil if the rate of the object storage operations is not limited.
+	limiter       *rate.Limiter
+	bucketMetrics *bucketMetrics
+
+	// exports is nil if the exports are not spooled.
+	exports *exportSpools
+
+	tenantsRoute httpRouteSpec
+	blocksRoute  httpRouteSpec
+	blockRoute   httpRouteSpec
+	apiDocs      []byte
+}
+
+// New returns a Handler serving the pages for the bucket. If listTenants is nil, all the tenants
+// in the bucket are listed.
+func New(cfg Config, bkt objstore.Bucket, listTenants TenantsLister, logger log.Logger, reg prometheus.Registerer) *Handler {
+	if cfg.DefaultPageSize <= 0 {
+		cfg.DefaultPageSize = DefaultPageSize
+	}
+	if cfg.BlockResponse == nil {
+		cfg.BlockResponse = BlockDetails{}
+	}
+
+	h := &Handler{
+		cfg:           cfg,
+		bucket:        bkt,
+		listTenants:   listTenants,
+		logger:        logger,
+		bucketMetrics: newBucketMetrics(reg),
+		tenantsRoute:  tenantsRouteSpec(cfg.PathPrefix),
+		blocksRoute:   blocksRouteSpec(cfg.PathPrefix),
+		blockRoute:    blockRouteSpec(cfg.PathPrefix, cfg.BlockResponse),
+	}
+	if cfg.BucketRateLimit > 0 {
+		h.limiter = rate.NewLimiter(rate.Limit(cfg.BucketRateLimit), max(cfg.BucketRateLimitBurst, 1))
+	}
+	if cfg.ExportSpoolTTL > 0 {
+		h.exports = newExportSpools(cfg, logger, reg)
+	}
+	if h.listTenants == nil {
+		h.listTenants = func(ctx context.Context) ([]string, error) {
+			return tsdb.ListUsers(ctx, h.requestBucket("tenants"))
+		}
+	}
+	routes := []httpRouteSpec{h.blocksRoute}
+	if !cfg.OmitTenantsRoute {
+		routes = append([]httpRouteSpec{h.tenantsRoute}, routes...)
+	}
+	if cfg.LinkBlocks {
+		routes = append(routes, h.blockRoute)
+	}
+	h.apiDocs = mustBuildOpenAPIDocument(cfg.Component, routes)
+	return h
+}
+
+// requestBucket returns the bucket to be used by a single request of the given handler.
+func (h *Handler) requestBucket(handler string) *requestBucket {
+	return newRequestBucket(h.bucket, handler, h.limiter, h.bucketMetrics, h.cfg.MaxObjectsPerRequest)
+}
+
+// TenantsPath returns the route of the tenants page.
+func (h *Handler) TenantsPath() string {
+	return h.tenantsRoute.Path
+}
+
+// BlocksPath returns the route of the blocks page, with the {tenant} path parameter.
+func (h *Handler) BlocksPath() string {
+	return h.blocksRoute.Path
+}
+
+// BlockPath returns the route of the block page, with the {tenant} and {block} path parameters.
+func (h *Handler) BlockPath() string {
+	return h.blockRoute.Path
+}
+
+// APIDocsPath returns the route of the OpenAPI document.
+func (h *Handler) APIDocsPath() string {
+	return h.cfg.PathPrefix + "/api-docs.json"
+}
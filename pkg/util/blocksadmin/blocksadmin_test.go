@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestHandler_Hosts(t *testing.T) {
+	const tenantID = "user-1"
+
+	bkt := objstore.NewInMemBucket()
+	meta := block.Meta{
+		BlockMeta: prom_tsdb.BlockMeta{ULID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 1000, Version: block.TSDBVersion1},
+		Thanos:    block.ThanosMeta{Version: block.ThanosVersion1},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, meta.Write(&buf))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(tenantID, meta.ULID.String(), block.MetaFilename), &buf))
+
+	hosts := map[string]Config{
+		"store-gateway": {Component: "Store-gateway", PathPrefix: "/store-gateway"},
+		"compactor":     {Component: "Compactor", PathPrefix: "/compactor", OmitTenantsRoute: true},
+	}
+
+	for name, cfg := range hosts {
+		t.Run(name, func(t *testing.T) {
+			h := New(cfg, bkt, nil, log.NewNopLogger(), nil)
+
+			router := mux.NewRouter()
+			router.Path(h.TenantsPath()).HandlerFunc(h.TenantsHandler)
+			router.Path(h.BlocksPath()).HandlerFunc(h.BlocksHandler)
+			router.Path(h.APIDocsPath()).HandlerFunc(h.APIDocsHandler)
+
+			get := func(path string, accept string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if accept != "" {
+					req.Header.Set("Accept", accept)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				return rec
+			}
+
+			rec := get(cfg.PathPrefix+"/tenants", "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<h1>"+cfg.Component+": bucket tenants</h1>")
+			assert.Contains(t, rec.Body.String(), `href="tenant/`+tenantID+`/blocks"`)
+
+			rec = get(cfg.PathPrefix+"/tenant/"+tenantID+"/blocks", "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<h1>"+cfg.Component+": bucket tenant blocks</h1>")
+			assert.Contains(t, rec.Body.String(), meta.ULID.String())
+
+			rec = get(cfg.PathPrefix+"/tenant/"+tenantID+"/blocks", "application/json")
+			require.Equal(t, http.StatusOK, rec.Code)
+			var blocks struct {
+				TotalBlocks int `json:"totalBlocks"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &blocks))
+			assert.Equal(t, 1, blocks.TotalBlocks)
+
+			// The OpenAPI document describes the routes of the host.
+			rec = get(cfg.PathPrefix+"/api-docs.json", "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			var doc struct {
+				Info  map[string]string `json:"info"`
+				Paths map[string]any    `json:"paths"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+			assert.Equal(t, "Grafana Mimir "+name, doc.Info["title"])
+			assert.Contains(t, doc.Paths, cfg.PathPrefix+"/tenant/{tenant}/blocks")
+			if cfg.OmitTenantsRoute {
+				assert.NotContains(t, doc.Paths, cfg.PathPrefix+"/tenants")
+				assert.Len(t, doc.Paths, 1)
+			} else {
+				assert.Contains(t, doc.Paths, cfg.PathPrefix+"/tenants")
+				assert.Len(t, doc.Paths, 2)
+			}
+		})
+	}
+}
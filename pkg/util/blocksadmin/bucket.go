@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+)
+
+// errInvalidObjectName is returned when an object name has dot segments, which could escape the tenant's prefix.
+var errInvalidObjectName = errors.New("invalid object name")
+
+// errObjectsLimitReached is returned when reading an object would exceed the limit of objects read per request.
+// The bucket reports it as a not found error, so that the listing skips the object rather than failing.
+var errObjectsLimitReached = errors.New("the limit of objects read per request has been reached")
+
+// bucketMetrics tracks the object storage operations of the handlers.
+type bucketMetrics struct {
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+func newBucketMetrics(reg prometheus.Registerer) *bucketMetrics {
+	return &bucketMetrics{
+		operations: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_blocks_admin_bucket_operations_total",
+			Help: "Total number of object storage operations done by the blocks admin pages.",
+		}, []string{"handler", "operation"}),
+		duration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_blocks_admin_bucket_operation_duration_seconds",
+			Help:    "Duration of the object storage operations done by the blocks admin pages, including the time waiting for the rate limiter.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler", "operation"}),
+	}
+}
+
+// requestBucket is the bucket used by a single request of a handler. All the requests share the same rate limiter,
+// while the number of objects read is limited per request.
+type requestBucket struct {
+	objstore.Bucket
+
+	handler    string
+	limiter    *rate.Limiter
+	metrics    *bucketMetrics
+	maxObjects int
+
+	objectsRead    atomic.Int64
+	objectsSkipped atomic.Int64
+}
+
+// newRequestBucket returns a bucket for a single request of the handler. A nil limiter doesn't limit the rate
+// of the operations, and a maxObjects of 0 doesn't limit the number of objects read.
+func newRequestBucket(bkt objstore.Bucket, handler string, limiter *rate.Limiter, metrics *bucketMetrics, maxObjects int) *requestBucket {
+	return &requestBucket{
+		Bucket:     bkt,
+		handler:    handler,
+		limiter:    limiter,
+		metrics:    metrics,
+		maxObjects: maxObjects,
+	}
+}
+
+// truncated returns the number of objects which weren't read because of the limit of objects per request.
+func (b *requestBucket) truncated() int64 {
+	return b.objectsSkipped.Load()
+}
+
+// track validates the object name, waits for the rate limiter and then calls f, recording the operation in the metrics.
+// If the operation reads an object, it's skipped when the limit of objects per request is reached.
+func (b *requestBucket) track(ctx context.Context, operation, name string, readsObject bool, f func() error) error {
+	if err := validateObjectName(name); err != nil {
+		return err
+	}
+	if readsObject && b.maxObjects > 0 && b.objectsRead.Inc() > int64(b.maxObjects) {
+		b.objectsSkipped.Inc()
+		return errObjectsLimitReached
+	}
+
+	start := time.Now()
+	defer func() {
+		b.metrics.operations.WithLabelValues(b.handler, operation).Inc()
+		b.metrics.duration.WithLabelValues(b.handler, operation).Observe(time.Since(start).Seconds())
+	}()
+
+	if b.limiter != nil {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return f()
+}
+
+// validateObjectName returns an error if any segment of the object name is a dot segment, or has a backslash.
+// Object names are built from request parameters, so this is checked before any call to the bucket.
+func validateObjectName(name string) error {
+	for _, segment := range strings.Split(name, objstore.DirDelim) {
+		if segment == "." || segment == ".." || strings.Contains(segment, "\\") {
+			return errors.Wrapf(errInvalidObjectName, "%q", name)
+		}
+	}
+	return nil
+}
+
+func (b *requestBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	return b.track(ctx, "iter", dir, false, func() error {
+		return b.Bucket.Iter(ctx, dir, f, options...)
+	})
+}
+
+func (b *requestBucket) IterWithAttributes(ctx context.Context, dir string, f func(objstore.IterObjectAttributes) error, options ...objstore.IterOption) error {
+	return b.track(ctx, "iter", dir, false, func() error {
+		return b.Bucket.IterWithAttributes(ctx, dir, f, options...)
+	})
+}
+
+func (b *requestBucket) Get(ctx context.Context, name string) (r io.ReadCloser, err error) {
+	err = b.track(ctx, "get", name, true, func() error {
+		r, err = b.Bucket.Get(ctx, name)
+		return err
+	})
+	return r, err
+}
+
+func (b *requestBucket) GetRange(ctx context.Context, name string, off, length int64) (r io.ReadCloser, err error) {
+	err = b.track(ctx, "get_range", name, true, func() error {
+		r, err = b.Bucket.GetRange(ctx, name, off, length)
+		return err
+	})
+	return r, err
+}
+
+func (b *requestBucket) Exists(ctx context.Context, name string) (exists bool, err error) {
+	err = b.track(ctx, "exists", name, false, func() error {
+		exists, err = b.Bucket.Exists(ctx, name)
+		return err
+	})
+	return exists, err
+}
+
+func (b *requestBucket) Attributes(ctx context.Context, name string) (attrs objstore.ObjectAttributes, err error) {
+	err = b.track(ctx, "attributes", name, true, func() error {
+		attrs, err = b.Bucket.Attributes(ctx, name)
+		return err
+	})
+	return attrs, err
+}
+
+func (b *requestBucket) IsObjNotFoundErr(err error) bool {
+	return errors.Is(err, errObjectsLimitReached) || b.Bucket.IsObjNotFoundErr(err)
+}
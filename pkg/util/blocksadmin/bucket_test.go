@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+// countingBucket counts the operations that reach the underlying bucket.
+type countingBucket struct {
+	objstore.Bucket
+
+	iters  atomic.Int64
+	gets   atomic.Int64
+	exists atomic.Int64
+}
+
+func (b *countingBucket) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	b.iters.Inc()
+	return b.Bucket.Iter(ctx, dir, f, options...)
+}
+
+func (b *countingBucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	b.gets.Inc()
+	return b.Bucket.Get(ctx, name)
+}
+
+func (b *countingBucket) Exists(ctx context.Context, name string) (bool, error) {
+	b.exists.Inc()
+	return b.Bucket.Exists(ctx, name)
+}
+
+func TestRequestBucket_RateLimit(t *testing.T) {
+	const (
+		opsPerSecond = 20
+		numOps       = 6
+	)
+
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket()}
+	limiter := rate.NewLimiter(opsPerSecond, 1)
+	b := newRequestBucket(bkt, "blocks", limiter, newBucketMetrics(nil), 0)
+
+	start := time.Now()
+	for i := 0; i < numOps; i++ {
+		_, err := b.Exists(context.Background(), "object")
+		require.NoError(t, err)
+	}
+
+	// The first operation uses the burst, all the others wait for the limiter.
+	assert.GreaterOrEqual(t, time.Since(start), (numOps-1)*time.Second/opsPerSecond-10*time.Millisecond)
+	assert.Equal(t, int64(numOps), bkt.exists.Load())
+}
+
+func TestRequestBucket_RateLimitRespectsContextCancellation(t *testing.T) {
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket()}
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	b := newRequestBucket(bkt, "blocks", limiter, newBucketMetrics(nil), 0)
+
+	_, err := b.Exists(context.Background(), "object")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = b.Exists(ctx, "object")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Equal(t, int64(1), bkt.exists.Load())
+}
+
+func TestHandler_BlocksHandler_BucketLimits(t *testing.T) {
+	const (
+		tenantID   = "user-1"
+		numBlocks  = 5
+		maxObjects = 3
+	)
+
+	ctx := context.Background()
+	inmem := objstore.NewInMemBucket()
+	for i := 0; i < numBlocks; i++ {
+		meta := block.Meta{
+			BlockMeta: prom_tsdb.BlockMeta{ULID: ulid.MustNew(uint64(i), nil), MinTime: 0, MaxTime: 1000, Version: block.TSDBVersion1},
+			Thanos:    block.ThanosMeta{Version: block.ThanosVersion1},
+		}
+		var buf bytes.Buffer
+		require.NoError(t, meta.Write(&buf))
+		require.NoError(t, inmem.Upload(ctx, path.Join(tenantID, meta.ULID.String(), block.MetaFilename), &buf))
+	}
+
+	type jsonPage struct {
+		Metas     []json.RawMessage `json:"metas"`
+		Truncated string            `json:"truncated"`
+	}
+	getPage := func(t *testing.T, cfg Config) (*countingBucket, *prometheus.Registry, jsonPage, string) {
+		bkt := &countingBucket{Bucket: inmem}
+		reg := prometheus.NewPedanticRegistry()
+		cfg.Component = "Store-gateway"
+		cfg.PathPrefix = "/store-gateway"
+		h := New(cfg, bkt, nil, log.NewNopLogger(), reg)
+		router := mux.NewRouter()
+		router.Path(h.BlocksPath()).HandlerFunc(h.BlocksHandler)
+
+		req := httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/"+tenantID+"/blocks", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var page jsonPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+
+		req = httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/"+tenantID+"/blocks", nil)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		return bkt, reg, page, rec.Body.String()
+	}
+
+	t.Run("no limits", func(t *testing.T) {
+		bkt, reg, page, html := getPage(t, Config{})
+
+		assert.Len(t, page.Metas, numBlocks)
+		assert.Empty(t, page.Truncated)
+		assert.NotContains(t, html, "The listing is incomplete")
+		assert.Equal(t, int64(2*numBlocks), bkt.gets.Load())
+
+		// Each request lists the markers and the blocks, and gets the meta of each block.
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_blocks_admin_bucket_operations_total Total number of object storage operations done by the blocks admin pages.
+			# TYPE cortex_blocks_admin_bucket_operations_total counter
+			cortex_blocks_admin_bucket_operations_total{handler="blocks",operation="get"} 10
+			cortex_blocks_admin_bucket_operations_total{handler="blocks",operation="iter"} 4
+		`), "cortex_blocks_admin_bucket_operations_total"))
+		assert.Equal(t, 2, testutil.CollectAndCount(reg, "cortex_blocks_admin_bucket_operation_duration_seconds"))
+	})
+
+	t.Run("max objects per request", func(t *testing.T) {
+		bkt, reg, page, html := getPage(t, Config{MaxObjectsPerRequest: maxObjects})
+
+		assert.Len(t, page.Metas, maxObjects)
+		assert.Equal(t, "The listing is incomplete: 2 objects were not loaded because the limit of 3 objects per request was reached.", page.Truncated)
+		assert.Contains(t, html, "The listing is incomplete: 2 objects were not loaded")
+		assert.Equal(t, int64(2*maxObjects), bkt.gets.Load())
+
+		// The skipped objects are not counted as operations.
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_blocks_admin_bucket_operations_total Total number of object storage operations done by the blocks admin pages.
+			# TYPE cortex_blocks_admin_bucket_operations_total counter
+			cortex_blocks_admin_bucket_operations_total{handler="blocks",operation="get"} 6
+			cortex_blocks_admin_bucket_operations_total{handler="blocks",operation="iter"} 4
+		`), "cortex_blocks_admin_bucket_operations_total"))
+	})
+}
+
+func TestRequestBucket_RejectsDotSegments(t *testing.T) {
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket()}
+	b := newRequestBucket(bkt, "blocks", nil, newBucketMetrics(nil), 0)
+
+	for _, name := range []string{"..", "../user-2", "user-1/../user-2/meta.json", "user-1/./meta.json", `user-1\..\user-2`} {
+		_, err := b.Get(context.Background(), name)
+		require.ErrorIs(t, err, errInvalidObjectName, name)
+		require.False(t, b.IsObjNotFoundErr(err), name)
+
+		err = b.Iter(context.Background(), name, func(string) error { return nil })
+		require.ErrorIs(t, err, errInvalidObjectName, name)
+	}
+	assert.Zero(t, bkt.gets.Load())
+	assert.Zero(t, bkt.iters.Load())
+
+	// Names with dots which aren't dot segments are fine.
+	_, err := b.Exists(context.Background(), "user-1/01ARZ3NDEKTSV4RRFFQ69G5FAV/meta.json.tmp")
+	require.NoError(t, err)
+	require.NoError(t, b.Iter(context.Background(), "user-1/", func(string) error { return nil }))
+}
+
+func TestHandler_BlocksHandler_HostileTenantIDs(t *testing.T) {
+	bkt := &countingBucket{Bucket: objstore.NewInMemBucket()}
+	h := New(Config{Component: "Store-gateway", PathPrefix: "/store-gateway"}, bkt, nil, log.NewNopLogger(), nil)
+
+	for _, tenantID := range []string{
+		"..",
+		".",
+		"../user-2",
+		"user-1/../user-2",
+		`user-1\user-2`,
+		"user 1",
+		"<script>alert(1)</script>",
+		strings.Repeat("a", 200),
+	} {
+		t.Run(tenantID, func(t *testing.T) {
+			// The tenant is set as a path variable directly, since the router would reject some of them already.
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/x/blocks", nil), map[string]string{"tenant": tenantID})
+			rec := httptest.NewRecorder()
+			h.BlocksHandler(rec, req)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			var paramErr httpParamError
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &paramErr))
+			assert.Equal(t, "tenant", paramErr.Param)
+			assert.Contains(t, paramErr.Message, strconv.Quote(tenantID))
+			assert.NotContains(t, rec.Body.String(), "<script>")
+		})
+	}
+
+	// The request is rejected before any bucket operation.
+	assert.Zero(t, bkt.iters.Load())
+	assert.Zero(t, bkt.gets.Load())
+	assert.Zero(t, bkt.exists.Load())
+}
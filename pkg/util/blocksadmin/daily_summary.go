@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/listblocks"
+)
+
+const dailySummaryDayFormat = time.DateOnly
+
+// dailySummary aggregates the blocks whose min time falls in a day.
+type dailySummary struct {
+	Day    string              `json:"day"`
+	Blocks int                 `json:"blocks"`
+	Bytes  uint64              `json:"bytes"`
+	Levels []dailySummaryLevel `json:"levels"`
+}
+
+// dailySummaryLevel aggregates the blocks of a compaction level within a day.
+type dailySummaryLevel struct {
+	Level  int    `json:"level"`
+	Blocks int    `json:"blocks"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// blocksSummary aggregates all the blocks of a listing, across its pages.
+type blocksSummary struct {
+	TotalBlocks    int    `json:"totalBlocks"`
+	TotalSizeBytes uint64 `json:"totalSizeBytes"`
+	// MinTime and MaxTime are the earliest min time and the latest max time of the blocks, in milliseconds.
+	// They're 0 if there are no blocks.
+	MinTime int64 `json:"minTime"`
+	MaxTime int64 `json:"maxTime"`
+	// BlocksByCompactionLevel is the number of blocks of each compaction level.
+	BlocksByCompactionLevel map[int]int `json:"blocksByCompactionLevel"`
+}
+
+// summarizeBlocks returns the summary of the blocks, computed in a single pass.
+func summarizeBlocks(metas []*block.Meta) blocksSummary {
+	summary := blocksSummary{TotalBlocks: len(metas), BlocksByCompactionLevel: map[int]int{}}
+	for i, m := range metas {
+		summary.TotalSizeBytes += listblocks.GetBlockSizeBytes(m)
+		if i == 0 || m.MinTime < summary.MinTime {
+			summary.MinTime = m.MinTime
+		}
+		if i == 0 || m.MaxTime > summary.MaxTime {
+			summary.MaxTime = m.MaxTime
+		}
+		summary.BlocksByCompactionLevel[m.Compaction.Level]++
+	}
+	return summary
+}
+
+// summarizeBlocksByDay returns one summary per UTC day, from the day of the earliest block min time
+// to the day of the latest one. Days without blocks are included, so that gaps are visible.
+func summarizeBlocksByDay(metas []*block.Meta) []dailySummary {
+	if len(metas) == 0 {
+		return []dailySummary{}
+	}
+
+	day := func(ms int64) time.Time {
+		return util.TimeFromMillis(ms).UTC().Truncate(24 * time.Hour)
+	}
+	first, last := day(metas[0].MinTime), day(metas[0].MinTime)
+	for _, m := range metas[1:] {
+		d := day(m.MinTime)
+		if d.Before(first) {
+			first = d
+		}
+		if d.After(last) {
+			last = d
+		}
+	}
+
+	summaries := make([]dailySummary, int(last.Sub(first)/(24*time.Hour))+1)
+	for i := range summaries {
+		summaries[i].Day = first.Add(time.Duration(i) * 24 * time.Hour).Format(dailySummaryDayFormat)
+	}
+
+	for _, m := range metas {
+		s := &summaries[int(day(m.MinTime).Sub(first)/(24*time.Hour))]
+		size := listblocks.GetBlockSizeBytes(m)
+		s.Blocks++
+		s.Bytes += size
+
+		ix, found := slices.BinarySearchFunc(s.Levels, m.Compaction.Level, func(l dailySummaryLevel, level int) int {
+			return l.Level - level
+		})
+		if !found {
+			s.Levels = slices.Insert(s.Levels, ix, dailySummaryLevel{Level: m.Compaction.Level})
+		}
+		s.Levels[ix].Blocks++
+		s.Levels[ix].Bytes += size
+	}
+	return summaries
+}
+
+// compactionLevelColors are the colors of the compaction levels in the charts. Levels beyond the palette reuse its colors.
+var compactionLevelColors = []string{"#7eb26d", "#eab839", "#6ed0e0", "#ef843c", "#e24d42", "#1f78c1", "#ba43a9"}
+
+func compactionLevelColor(level int) string {
+	return compactionLevelColors[(max(level, 1)-1)%len(compactionLevelColors)]
+}
+
+// dailySummaryCharts renders the blocks-per-day and bytes-per-day charts, with bars split by compaction level.
+func dailySummaryCharts(summaries []dailySummary) (blocksChart, bytesChart template.HTML) {
+	blocksBars := make([]svgBar, 0, len(summaries))
+	bytesBars := make([]svgBar, 0, len(summaries))
+	var levels []int
+
+	for _, s := range summaries {
+		blocksBar := svgBar{Label: s.Day}
+		bytesBar := svgBar{Label: s.Day}
+		for _, l := range s.Levels {
+			color := compactionLevelColor(l.Level)
+			blocksBar.Segments = append(blocksBar.Segments, svgBarSegment{
+				Value: float64(l.Blocks),
+				Color: color,
+				Title: fmt.Sprintf("%s, level %d: %d blocks", s.Day, l.Level, l.Blocks),
+			})
+			bytesBar.Segments = append(bytesBar.Segments, svgBarSegment{
+				Value: float64(l.Bytes),
+				Color: color,
+				Title: fmt.Sprintf("%s, level %d: %s", s.Day, l.Level, humanize.IBytes(l.Bytes)),
+			})
+			if !slices.Contains(levels, l.Level) {
+				levels = append(levels, l.Level)
+			}
+		}
+		blocksBars = append(blocksBars, blocksBar)
+		bytesBars = append(bytesBars, bytesBar)
+	}
+
+	slices.Sort(levels)
+	legend := make([]svgLegendEntry, 0, len(levels))
+	for _, l := range levels {
+		legend = append(legend, svgLegendEntry{Label: fmt.Sprintf("L%d", l), Color: compactionLevelColor(l)})
+	}
+
+	blocksChart = renderBarChartSVG("Blocks per day", blocksBars, legend, func(v float64) string {
+		return fmt.Sprintf("%.0f", v)
+	})
+	bytesChart = renderBarChartSVG("Bytes per day", bytesBars, legend, func(v float64) string {
+		return humanize.IBytes(uint64(v))
+	})
+	return blocksChart, bytesChart
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files in testdata with the current output.")
+
+func testDailySummaryMeta(id uint64, minTime time.Time, level int, size int64) *block.Meta {
+	return &block.Meta{
+		BlockMeta: prom_tsdb.BlockMeta{
+			ULID:       ulid.MustNew(id, nil),
+			MinTime:    minTime.UnixMilli(),
+			MaxTime:    minTime.Add(2 * time.Hour).UnixMilli(),
+			Compaction: prom_tsdb.BlockMetaCompaction{Level: level},
+		},
+		Thanos: block.ThanosMeta{Files: []block.File{{RelPath: block.MetaFilename, SizeBytes: size}}},
+	}
+}
+
+// testDailySummaryMetas returns blocks over 4 days, with no blocks on the third one.
+func testDailySummaryMetas() []*block.Meta {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return []*block.Meta{
+		testDailySummaryMeta(1, day.Add(22*time.Hour), 1, 100),
+		testDailySummaryMeta(2, day, 2, 1000),
+		testDailySummaryMeta(3, day.Add(2*time.Hour), 1, 200),
+		testDailySummaryMeta(4, day.Add(24*time.Hour), 3, 5000),
+		testDailySummaryMeta(5, day.Add(3*24*time.Hour+23*time.Hour), 1, 300),
+	}
+}
+
+func TestSummarizeBlocksByDay(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		metas    []*block.Meta
+		expected []dailySummary
+	}{
+		"no blocks": {
+			metas:    nil,
+			expected: []dailySummary{},
+		},
+		"single day": {
+			metas: []*block.Meta{
+				testDailySummaryMeta(1, day.Add(time.Hour), 2, 10),
+				testDailySummaryMeta(2, day.Add(3*time.Hour), 1, 20),
+				testDailySummaryMeta(3, day, 1, 30),
+			},
+			expected: []dailySummary{
+				{Day: "2024-03-01", Blocks: 3, Bytes: 60, Levels: []dailySummaryLevel{
+					{Level: 1, Blocks: 2, Bytes: 50},
+					{Level: 2, Blocks: 1, Bytes: 10},
+				}},
+			},
+		},
+		"multiple days with a gap": {
+			metas: testDailySummaryMetas(),
+			expected: []dailySummary{
+				{Day: "2024-03-01", Blocks: 3, Bytes: 1300, Levels: []dailySummaryLevel{
+					{Level: 1, Blocks: 2, Bytes: 300},
+					{Level: 2, Blocks: 1, Bytes: 1000},
+				}},
+				{Day: "2024-03-02", Blocks: 1, Bytes: 5000, Levels: []dailySummaryLevel{
+					{Level: 3, Blocks: 1, Bytes: 5000},
+				}},
+				{Day: "2024-03-03"},
+				{Day: "2024-03-04", Blocks: 1, Bytes: 300, Levels: []dailySummaryLevel{
+					{Level: 1, Blocks: 1, Bytes: 300},
+				}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, summarizeBlocksByDay(tc.metas))
+		})
+	}
+}
+
+func TestDailySummaryCharts(t *testing.T) {
+	tests := map[string]struct {
+		metas []*block.Meta
+	}{
+		"no_blocks": {},
+		"single_day": {
+			metas: testDailySummaryMetas()[:3],
+		},
+		"multiple_days": {
+			metas: testDailySummaryMetas(),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			blocksChart, bytesChart := dailySummaryCharts(summarizeBlocksByDay(tc.metas))
+			assertGoldenFile(t, filepath.Join("testdata", "daily_summary_"+name+"_blocks.svg"), string(blocksChart))
+			assertGoldenFile(t, filepath.Join("testdata", "daily_summary_"+name+"_bytes.svg"), string(bytesChart))
+		})
+	}
+}
+
+func assertGoldenFile(t *testing.T, path, actual string) {
+	t.Helper()
+
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run the test with -update-golden to create the golden file")
+	assert.Equal(t, string(expected), actual)
+}
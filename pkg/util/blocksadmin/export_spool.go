@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ExportTokenHeader is the header returned with the token of a spooled export. Requests with the token get
+// the export from the spool, rather than listing the blocks again, so they can resume a download with Range.
+const ExportTokenHeader = "X-Blocks-Export-Token"
+
+// DefaultExportSpoolMemoryBytes is the size up to which an export is spooled in memory, before moving to disk.
+const DefaultExportSpoolMemoryBytes = 1 << 20
+
+// exportSpool is an export materialized once, so that byte ranges of it can be served later.
+type exportSpool struct {
+	token   string
+	key     string
+	created time.Time
+	header  http.Header
+	size    int64
+
+	// data holds the export if it's in memory, otherwise it's in the file at path.
+	data []byte
+	path string
+}
+
+// open returns a reader of the export. The caller must close it.
+func (s *exportSpool) open() (io.ReadSeekCloser, error) {
+	if s.path == "" {
+		return nopSeekCloser{bytes.NewReader(s.data)}, nil
+	}
+	return os.Open(s.path)
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// exportSpools keeps the spooled exports until they expire, or until they're evicted to bound the disk
+// they use. Expired spools are removed lazily, when spools are added or looked up.
+type exportSpools struct {
+	dir            string
+	ttl            time.Duration
+	maxDiskBytes   int64
+	maxMemoryBytes int64
+	logger         log.Logger
+	now            func() time.Time
+
+	diskBytes prometheus.Gauge
+	evictions prometheus.Counter
+
+	mtx    sync.Mutex
+	spools map[string]*exportSpool
+	// order holds the tokens of the spools, oldest first.
+	order []string
+	disk  int64
+}
+
+func newExportSpools(cfg Config, logger log.Logger, reg prometheus.Registerer) *exportSpools {
+	s := &exportSpools{
+		dir:            cfg.ExportSpoolDir,
+		ttl:            cfg.ExportSpoolTTL,
+		maxDiskBytes:   cfg.ExportSpoolMaxDiskBytes,
+		maxMemoryBytes: cfg.ExportSpoolMemoryBytes,
+		logger:         logger,
+		now:            time.Now,
+		spools:         map[string]*exportSpool{},
+		diskBytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_blocks_admin_export_spool_disk_bytes",
+			Help: "Size of the blocks exports spooled on disk, so that their downloads can be resumed.",
+		}),
+		evictions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_blocks_admin_export_spool_evictions_total",
+			Help: "Total number of spooled blocks exports removed before expiring, to bound the disk they use.",
+		}),
+	}
+	if s.maxMemoryBytes <= 0 {
+		s.maxMemoryBytes = DefaultExportSpoolMemoryBytes
+	}
+	return s
+}
+
+// get returns the spool of the token, or nil if it's unknown or expired.
+func (s *exportSpools) get(token string) *exportSpool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.removeExpired()
+	return s.spools[token]
+}
+
+// add keeps the spool until it expires. It returns false if the spool can't be kept because it's larger
+// than the disk available to all the spools, in which case the caller must discard it once served.
+func (s *exportSpools) add(spool *exportSpool) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.removeExpired()
+	if spool.path != "" {
+		if spool.size > s.maxDiskBytes {
+			return false
+		}
+		// Evict the oldest spools on disk until the new one fits.
+		for i := 0; i < len(s.order) && s.disk+spool.size > s.maxDiskBytes; {
+			if old := s.spools[s.order[i]]; old.path != "" {
+				level.Debug(s.logger).Log("msg", "evicting spooled blocks export to make room for a new one", "token", old.token, "size", old.size)
+				s.removeAt(i)
+				s.evictions.Inc()
+				continue
+			}
+			i++
+		}
+		s.disk += spool.size
+		s.diskBytes.Set(float64(s.disk))
+	}
+	s.spools[spool.token] = spool
+	s.order = append(s.order, spool.token)
+	return true
+}
+
+// removeExpired removes the spools older than the TTL. Spools are added in creation order, so it stops at
+// the first one which didn't expire.
+func (s *exportSpools) removeExpired() {
+	for len(s.order) > 0 && s.now().Sub(s.spools[s.order[0]].created) >= s.ttl {
+		s.removeAt(0)
+	}
+}
+
+func (s *exportSpools) removeAt(i int) {
+	spool := s.spools[s.order[i]]
+	delete(s.spools, spool.token)
+	s.order = append(s.order[:i], s.order[i+1:]...)
+	if spool.path != "" {
+		s.disk -= spool.size
+		s.diskBytes.Set(float64(s.disk))
+		// Readers still serving the spool keep their open file.
+		discardSpool(spool, s.logger)
+	}
+}
+
+// discardSpool removes the file of the spool, if any.
+func discardSpool(spool *exportSpool, logger log.Logger) {
+	if spool.path == "" {
+		return
+	}
+	if err := os.Remove(spool.path); err != nil {
+		level.Warn(logger).Log("msg", "failed to remove spooled blocks export", "path", spool.path, "err", err)
+	}
+}
+
+// spoolWriter is a http.ResponseWriter spooling the response in memory up to a limit, and on disk after that.
+type spoolWriter struct {
+	spools *exportSpools
+	header http.Header
+	status int
+
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+	err  error
+}
+
+func (s *exportSpools) newWriter() *spoolWriter {
+	return &spoolWriter{spools: s, header: http.Header{}, status: http.StatusOK}
+}
+
+func (w *spoolWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *spoolWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *spoolWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.file == nil && int64(w.buf.Len()+len(p)) > w.spools.maxMemoryBytes {
+		if w.file, w.err = os.CreateTemp(w.spools.dir, "blocks-export-*"); w.err != nil {
+			return 0, w.err
+		}
+		if _, w.err = w.file.Write(w.buf.Bytes()); w.err != nil {
+			return 0, w.err
+		}
+		w.buf = bytes.Buffer{}
+	}
+
+	var n int
+	if w.file != nil {
+		n, w.err = w.file.Write(p)
+	} else {
+		n, w.err = w.buf.Write(p)
+	}
+	w.size += int64(n)
+	return n, w.err
+}
+
+// finish returns the spooled export, with a new token. If spooling failed, the spool is discarded.
+func (w *spoolWriter) finish(key string) (*exportSpool, error) {
+	spool := &exportSpool{
+		key:     key,
+		created: w.spools.now(),
+		header:  w.header,
+		size:    w.size,
+	}
+	if w.file != nil {
+		spool.path = w.file.Name()
+		if err := w.file.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	} else {
+		spool.data = w.buf.Bytes()
+	}
+	if w.err != nil {
+		discardSpool(spool, w.spools.logger)
+		return nil, w.err
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		discardSpool(spool, w.spools.logger)
+		return nil, err
+	}
+	spool.token = hex.EncodeToString(token)
+	return spool, nil
+}
+
+// discard removes the partially spooled export.
+func (w *spoolWriter) discard() {
+	if w.file != nil {
+		_ = w.file.Close()
+		discardSpool(&exportSpool{path: w.file.Name()}, w.spools.logger)
+	}
+}
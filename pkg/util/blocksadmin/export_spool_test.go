@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	prom_tsdb "github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestHandler_BlocksHandler_ResumableExport(t *testing.T) {
+	const (
+		tenantID  = "user-1"
+		numBlocks = 10
+	)
+
+	ctx := context.Background()
+	inmem := objstore.NewInMemBucket()
+	for i := 0; i < numBlocks; i++ {
+		meta := block.Meta{
+			BlockMeta: prom_tsdb.BlockMeta{ULID: ulid.MustNew(uint64(i), nil), MinTime: int64(i) * 1000, MaxTime: int64(i+1) * 1000, Version: block.TSDBVersion1},
+			Thanos:    block.ThanosMeta{Version: block.ThanosVersion1},
+		}
+		var buf bytes.Buffer
+		require.NoError(t, meta.Write(&buf))
+		require.NoError(t, inmem.Upload(ctx, path.Join(tenantID, meta.ULID.String(), block.MetaFilename), &buf))
+	}
+
+	type server struct {
+		h      *Handler
+		bkt    *countingBucket
+		reg    *prometheus.Registry
+		router *mux.Router
+		now    time.Time
+	}
+	newServer := func(t *testing.T, cfg Config) *server {
+		s := &server{
+			bkt: &countingBucket{Bucket: inmem},
+			reg: prometheus.NewPedanticRegistry(),
+			now: time.Now(),
+		}
+		cfg.Component = "Store-gateway"
+		cfg.PathPrefix = "/store-gateway"
+		s.h = New(cfg, s.bkt, nil, log.NewNopLogger(), s.reg)
+		s.h.exports.now = func() time.Time { return s.now }
+		s.router = mux.NewRouter()
+		s.router.Path(s.h.BlocksPath()).HandlerFunc(s.h.BlocksHandler)
+		return s
+	}
+	get := func(s *server, query, token, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/"+tenantID+"/blocks?"+query, nil)
+		if token != "" {
+			req.Header.Set(ExportTokenHeader, token)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, format := range []string{blocksFormatCSV, blocksFormatJSON} {
+		t.Run(format+" ranged continuation", func(t *testing.T) {
+			s := newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolDir: t.TempDir(), ExportSpoolMaxDiskBytes: 1 << 20, ExportSpoolMemoryBytes: 100})
+
+			first := get(s, "format="+format, "", "")
+			require.Equal(t, http.StatusOK, first.Code)
+			token := first.Header().Get(ExportTokenHeader)
+			require.NotEmpty(t, token)
+			assert.Equal(t, "bytes", first.Header().Get("Accept-Ranges"))
+			full := first.Body.Bytes()
+			require.Greater(t, len(full), 100, "the export is spooled on disk")
+
+			iters, gets := s.bkt.iters.Load(), s.bkt.gets.Load()
+
+			// The continuation gets the exact bytes of the spooled export, without listing the blocks again.
+			rec := get(s, "format="+format, token, "bytes=100-")
+			require.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, full[100:], rec.Body.Bytes())
+			assert.Equal(t, fmt.Sprintf("bytes 100-%d/%d", len(full)-1, len(full)), rec.Header().Get("Content-Range"))
+			assert.Equal(t, first.Header().Get("Content-Type"), rec.Header().Get("Content-Type"))
+			assert.Equal(t, token, rec.Header().Get(ExportTokenHeader))
+
+			rec = get(s, "format="+format, token, "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, full, rec.Body.Bytes())
+
+			assert.Equal(t, iters, s.bkt.iters.Load())
+			assert.Equal(t, gets, s.bkt.gets.Load())
+
+			// The token can't be used for a different export.
+			rec = get(s, "format="+format+"&compaction_level=1", token, "bytes=100-")
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	t.Run("initial ranged request", func(t *testing.T) {
+		s := newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolMaxDiskBytes: 1 << 20})
+
+		full := get(s, "format=csv", "", "").Body.Bytes()
+		rec := get(s, "format=csv", "", "bytes=0-9")
+		require.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, full[:10], rec.Body.Bytes())
+		assert.NotEmpty(t, rec.Header().Get(ExportTokenHeader))
+	})
+
+	t.Run("token expiry", func(t *testing.T) {
+		s := newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolDir: t.TempDir(), ExportSpoolMaxDiskBytes: 1 << 20, ExportSpoolMemoryBytes: 100})
+
+		token := get(s, "format=csv", "", "").Header().Get(ExportTokenHeader)
+
+		s.now = s.now.Add(59 * time.Second)
+		require.Equal(t, http.StatusPartialContent, get(s, "format=csv", token, "bytes=10-").Code)
+
+		s.now = s.now.Add(time.Second)
+		rec := get(s, "format=csv", token, "bytes=10-")
+		require.Equal(t, http.StatusGone, rec.Code)
+		assert.Contains(t, rec.Body.String(), "unknown or expired")
+		assertSpoolFiles(t, s.h.cfg.ExportSpoolDir, 0)
+
+		require.Equal(t, http.StatusGone, get(s, "format=csv", "unknown", "").Code)
+	})
+
+	t.Run("disk bound eviction", func(t *testing.T) {
+		probe := newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolMaxDiskBytes: 1 << 20})
+		size := len(get(probe, "format=csv", "", "").Body.Bytes())
+
+		// Only one export fits on disk.
+		s := newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolDir: t.TempDir(), ExportSpoolMaxDiskBytes: int64(size * 3 / 2), ExportSpoolMemoryBytes: 100})
+
+		first := get(s, "format=csv", "", "").Header().Get(ExportTokenHeader)
+		require.NotEmpty(t, first)
+		assertSpoolFiles(t, s.h.cfg.ExportSpoolDir, 1)
+
+		second := get(s, "format=csv&show_deleted=on", "", "").Header().Get(ExportTokenHeader)
+		require.NotEmpty(t, second)
+		assertSpoolFiles(t, s.h.cfg.ExportSpoolDir, 1)
+
+		require.Equal(t, http.StatusGone, get(s, "format=csv", first, "bytes=10-").Code)
+		require.Equal(t, http.StatusPartialContent, get(s, "format=csv&show_deleted=on", second, "bytes=10-").Code)
+
+		assert.NoError(t, testutil.GatherAndCompare(s.reg, strings.NewReader(fmt.Sprintf(`
+			# HELP cortex_blocks_admin_export_spool_disk_bytes Size of the blocks exports spooled on disk, so that their downloads can be resumed.
+			# TYPE cortex_blocks_admin_export_spool_disk_bytes gauge
+			cortex_blocks_admin_export_spool_disk_bytes %d
+			# HELP cortex_blocks_admin_export_spool_evictions_total Total number of spooled blocks exports removed before expiring, to bound the disk they use.
+			# TYPE cortex_blocks_admin_export_spool_evictions_total counter
+			cortex_blocks_admin_export_spool_evictions_total 1
+		`, size)), "cortex_blocks_admin_export_spool_disk_bytes", "cortex_blocks_admin_export_spool_evictions_total"))
+
+		// An export larger than the limit is served, but not kept.
+		s = newServer(t, Config{ExportSpoolTTL: time.Minute, ExportSpoolDir: t.TempDir(), ExportSpoolMaxDiskBytes: int64(size / 2), ExportSpoolMemoryBytes: 100})
+		rec := get(s, "format=csv", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, rec.Body.Bytes(), size)
+		assert.Empty(t, rec.Header().Get(ExportTokenHeader))
+		assertSpoolFiles(t, s.h.cfg.ExportSpoolDir, 0)
+	})
+}
+
+func assertSpoolFiles(t *testing.T, dir string, expected int) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, expected)
+}
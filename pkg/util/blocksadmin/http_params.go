@@ -0,0 +1,466 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/oklog/ulid"
+)
+
+type httpParamType string
+
+const (
+	httpParamBool    httpParamType = "boolean"
+	httpParamInteger httpParamType = "integer"
+	httpParamString  httpParamType = "string"
+
+	// httpParamTimestamp is a time in RFC3339 format or in milliseconds since the Unix epoch.
+	httpParamTimestamp httpParamType = "timestamp"
+
+	// httpParamTenantID is a tenant ID, validated with the same rules as the tenant IDs of the requests,
+	// since it's used as a prefix of the bucket objects.
+	httpParamTenantID httpParamType = "tenant_id"
+
+	// httpParamBlockID is the ULID of a block.
+	httpParamBlockID httpParamType = "block_id"
+)
+
+type httpParamLocation string
+
+const (
+	httpParamInPath  httpParamLocation = "path"
+	httpParamInQuery httpParamLocation = "query"
+)
+
+// httpParamSpec declares a parameter accepted by an HTTP handler. The same spec is used to parse and
+// validate the request, and to describe the parameter in the OpenAPI document.
+type httpParamSpec struct {
+	Name        string
+	In          httpParamLocation
+	Type        httpParamType
+	Description string
+	Required    bool
+
+	// Default is the value used when the parameter is not set. Its type must match Type.
+	Default any
+
+	// Min and Max bound integer parameters, when set.
+	Min *int
+	Max *int
+
+	// Enum lists the allowed values of a string parameter, when set.
+	Enum []string
+}
+
+// httpRouteSpec declares an HTTP route served by the Handler.
+type httpRouteSpec struct {
+	Path        string
+	Method      string
+	Summary     string
+	Params      []httpParamSpec
+	Response    any
+	ContentType []string
+}
+
+func intPtr(v int) *int { return &v }
+
+func tenantsRouteSpec(pathPrefix string) httpRouteSpec {
+	return httpRouteSpec{
+		Path:        pathPrefix + "/tenants",
+		Method:      http.MethodGet,
+		Summary:     "List the tenants with blocks in the storage.",
+		Response:    tenantsPageContents{},
+		ContentType: []string{"application/json", "text/html"},
+	}
+}
+
+func blocksRouteSpec(pathPrefix string) httpRouteSpec {
+	return httpRouteSpec{
+		Path:    pathPrefix + "/tenant/{tenant}/blocks",
+		Method:  http.MethodGet,
+		Summary: "List the blocks of a tenant.",
+		Params: []httpParamSpec{
+			{Name: "tenant", In: httpParamInPath, Type: httpParamTenantID, Required: true, Description: "Tenant ID."},
+			{Name: "show_deleted", In: httpParamInQuery, Type: httpParamBool, Default: false, Description: "Include blocks marked for deletion."},
+			{Name: "show_sources", In: httpParamInQuery, Type: httpParamBool, Default: false, Description: "Show the source blocks of each block."},
+			{Name: "show_parents", In: httpParamInQuery, Type: httpParamBool, Default: false, Description: "Show the parent blocks of each block."},
+			{Name: "split_count", In: httpParamInQuery, Type: httpParamInteger, Default: 0, Min: intPtr(0), Description: "Number of split groups to compute the split ID of each block for. 0 disables it."},
+			{Name: "min_time", In: httpParamInQuery, Type: httpParamTimestamp, Description: "Only show blocks with data at or after this time."},
+			{Name: "max_time", In: httpParamInQuery, Type: httpParamTimestamp, Description: "Only show blocks with data at or before this time."},
+			{Name: "compaction_level", In: httpParamInQuery, Type: httpParamInteger, Min: intPtr(1), Description: "Only show blocks with this compaction level."},
+			{Name: "only_ooo", In: httpParamInQuery, Type: httpParamBool, Default: false, Description: "Only show blocks with out-of-order data."},
+			{Name: "source", In: httpParamInQuery, Type: httpParamString, Enum: blockSourceComponents, Description: "Only show blocks produced by this component."},
+			{Name: "page", In: httpParamInQuery, Type: httpParamInteger, Default: 1, Min: intPtr(1), Description: "Page of blocks to show, starting from 1."},
+			{Name: "page_size", In: httpParamInQuery, Type: httpParamInteger, Min: intPtr(1), Max: intPtr(MaxPageSize), Description: "Number of blocks per page. Defaults to the page size configured for the component."},
+			{Name: "format", In: httpParamInQuery, Type: httpParamString, Enum: []string{blocksFormatCSV, blocksFormatJSON}, Description: "Response format. When not set, the format is chosen from the Accept header, and defaults to HTML. CSV exports include all the blocks, unless page or page_size is set."},
+			{Name: "view", In: httpParamInQuery, Type: httpParamString, Enum: []string{blocksFormatHTML, blocksFormatJSON, blocksFormatCSV}, Description: "Response format, like format, which it must match if both are set. The html view is rendered regardless of the Accept header, so that links to it can be shared."},
+		},
+		Response:    blocksJSONResponse{},
+		ContentType: []string{"application/json", "text/html", "text/csv"},
+	}
+}
+
+func blockRouteSpec(pathPrefix string, response any) httpRouteSpec {
+	return httpRouteSpec{
+		Path:    pathPrefix + "/tenant/{tenant}/block/{block}",
+		Method:  http.MethodGet,
+		Summary: "Show the details of a block of a tenant.",
+		Params: []httpParamSpec{
+			{Name: "tenant", In: httpParamInPath, Type: httpParamTenantID, Required: true, Description: "Tenant ID."},
+			{Name: "block", In: httpParamInPath, Type: httpParamBlockID, Required: true, Description: "Block ID."},
+			{Name: "format", In: httpParamInQuery, Type: httpParamString, Enum: []string{blocksFormatJSON}, Description: "Response format. When not set, the format is chosen from the Accept header, and defaults to HTML."},
+		},
+		Response:    response,
+		ContentType: []string{"application/json", "text/html"},
+	}
+}
+
+// httpParamError is returned when a request parameter is invalid.
+type httpParamError struct {
+	Param   string `json:"parameter"`
+	Message string `json:"error"`
+}
+
+func (e *httpParamError) Error() string {
+	return fmt.Sprintf("invalid parameter %q: %s", e.Param, e.Message)
+}
+
+// httpParams holds the parsed parameters of a request, with defaults applied.
+type httpParams map[string]any
+
+func (p httpParams) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+func (p httpParams) Int(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+
+func (p httpParams) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+func (p httpParams) BlockID(name string) ulid.ULID {
+	v, _ := p[name].(ulid.ULID)
+	return v
+}
+
+// Time returns the value of a timestamp parameter. The second return value is false if the parameter is not set.
+func (p httpParams) Time(name string) (time.Time, bool) {
+	v, ok := p[name].(time.Time)
+	return v, ok
+}
+
+// IsSet returns true if the parameter was set, or has a default.
+func (p httpParams) IsSet(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+// parseHTTPParams parses and validates the request parameters according to the specs.
+func parseHTTPParams(req *http.Request, specs []httpParamSpec) (httpParams, error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, &httpParamError{Message: fmt.Sprintf("can't parse form: %s", err)}
+	}
+
+	params := make(httpParams, len(specs))
+	for _, spec := range specs {
+		var raw string
+		switch spec.In {
+		case httpParamInPath:
+			raw = mux.Vars(req)[spec.Name]
+		default:
+			raw = req.Form.Get(spec.Name)
+		}
+
+		if raw == "" {
+			if spec.Required {
+				return nil, &httpParamError{Param: spec.Name, Message: "parameter is required"}
+			}
+			if spec.Default != nil {
+				params[spec.Name] = spec.Default
+			}
+			continue
+		}
+
+		v, err := parseHTTPParam(spec, raw)
+		if err != nil {
+			return nil, &httpParamError{Param: spec.Name, Message: err.Error()}
+		}
+		params[spec.Name] = v
+	}
+	return params, nil
+}
+
+func parseHTTPParam(spec httpParamSpec, raw string) (any, error) {
+	switch spec.Type {
+	case httpParamBool:
+		// "on" and "off" are sent by HTML checkboxes.
+		switch strings.ToLower(raw) {
+		case "on", "true", "1":
+			return true, nil
+		case "off", "false", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %q", raw)
+
+	case httpParamInteger:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer, got %q", raw)
+		}
+		if spec.Min != nil && v < *spec.Min {
+			return nil, fmt.Errorf("must be greater than or equal to %d, got %d", *spec.Min, v)
+		}
+		if spec.Max != nil && v > *spec.Max {
+			return nil, fmt.Errorf("must be less than or equal to %d, got %d", *spec.Max, v)
+		}
+		return v, nil
+
+	case httpParamTimestamp:
+		if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC(), nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected a time in RFC3339 format or in milliseconds since the Unix epoch, got %q", raw)
+		}
+		return t, nil
+
+	case httpParamTenantID:
+		if err := tenant.ValidTenantID(raw); err != nil {
+			return nil, fmt.Errorf("expected a valid tenant ID, got %q", raw)
+		}
+		return raw, nil
+
+	case httpParamBlockID:
+		id, err := ulid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("expected a block ID, got %q", raw)
+		}
+		return id, nil
+
+	default:
+		if len(spec.Enum) > 0 && !slices.Contains(spec.Enum, raw) {
+			return nil, fmt.Errorf("expected one of %s, got %q", strings.Join(spec.Enum, ", "), raw)
+		}
+		return raw, nil
+	}
+}
+
+// openAPISchema returns the OpenAPI schema of the parameter.
+func (p httpParamSpec) openAPISchema() map[string]any {
+	var schema map[string]any
+	switch p.Type {
+	case httpParamTimestamp:
+		// Either an RFC3339 time or milliseconds since the Unix epoch, which can't be expressed with a single format.
+		schema = map[string]any{"type": "string"}
+	case httpParamTenantID:
+		schema = map[string]any{"type": "string", "maxLength": tenant.MaxTenantIDLength}
+	case httpParamBlockID:
+		schema = map[string]any{"type": "string", "minLength": ulid.EncodedSize, "maxLength": ulid.EncodedSize}
+	default:
+		schema = map[string]any{"type": string(p.Type)}
+	}
+	if p.Default != nil {
+		schema["default"] = p.Default
+	}
+	if p.Min != nil {
+		schema["minimum"] = *p.Min
+	}
+	if p.Max != nil {
+		schema["maximum"] = *p.Max
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	return schema
+}
+
+// writeHTTPParamError writes err as a JSON response with status 400.
+func writeHTTPParamError(w http.ResponseWriter, err error) {
+	paramErr, ok := err.(*httpParamError)
+	if !ok {
+		paramErr = &httpParamError{Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(paramErr)
+}
+
+// APIDocsHandler serves the OpenAPI document describing the blocks admin HTTP endpoints.
+func (h *Handler) APIDocsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(h.apiDocs)
+}
+
+func mustBuildOpenAPIDocument(component string, routes []httpRouteSpec) []byte {
+	doc, err := json.Marshal(buildOpenAPIDocument(component, routes))
+	if err != nil {
+		panic(fmt.Sprintf("failed to build the %s OpenAPI document: %v", component, err))
+	}
+	return doc
+}
+
+func buildOpenAPIDocument(component string, routes []httpRouteSpec) map[string]any {
+	paths := map[string]any{}
+	for _, route := range routes {
+		params := make([]any, 0, len(route.Params))
+		for _, p := range route.Params {
+			params = append(params, map[string]any{
+				"name":        p.Name,
+				"in":          string(p.In),
+				"description": p.Description,
+				"required":    p.Required || p.In == httpParamInPath,
+				"schema":      p.openAPISchema(),
+			})
+		}
+
+		content := map[string]any{}
+		for _, ct := range route.ContentType {
+			if ct == "application/json" {
+				content[ct] = map[string]any{"schema": jsonSchemaFor(reflect.TypeOf(route.Response), map[reflect.Type]bool{})}
+			} else {
+				content[ct] = map[string]any{"schema": map[string]any{"type": "string"}}
+			}
+		}
+
+		operations, _ := paths[route.Path].(map[string]any)
+		if operations == nil {
+			operations = map[string]any{}
+			paths[route.Path] = operations
+		}
+		operations[strings.ToLower(route.Method)] = map[string]any{
+			"summary":    route.Summary,
+			"parameters": params,
+			"responses": map[string]any{
+				"200": map[string]any{"description": "OK", "content": content},
+				"400": map[string]any{
+					"description": "Invalid parameter.",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": jsonSchemaFor(reflect.TypeOf(httpParamError{}), map[reflect.Type]bool{})},
+					},
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Grafana Mimir " + strings.ToLower(component),
+			"version": "1",
+		},
+		"paths": paths,
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// jsonSchemaFor returns the JSON schema of the JSON encoding of values of type t, following the encoding/json rules.
+func jsonSchemaFor(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// The encoding is custom, so we can't tell its shape.
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchemaFor(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaFor(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]any{}
+		addStructProperties(t, properties, visiting)
+		return map[string]any{"type": "object", "properties": properties}
+	default:
+		return map[string]any{}
+	}
+}
+
+func addStructProperties(t reflect.Type, properties map[string]any, visiting map[reflect.Type]bool) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = jsonSchemaFor(f.Type, visiting)
+	}
+
+	// Fields of embedded structs are promoted to the outer object, unless the outer object has a field with the same name.
+	for _, et := range embedded {
+		promoted := map[string]any{}
+		addStructProperties(et, promoted, visiting)
+		for name, schema := range promoted {
+			if _, exists := properties[name]; !exists {
+				properties[name] = schema
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestParseHTTPParams(t *testing.T) {
+	tests := map[string]struct {
+		tenant        string
+		query         string
+		expected      httpParams
+		expectedParam string
+	}{
+		"defaults are applied when parameters are not set": {
+			tenant:   "user-1",
+			expected: httpParams{"tenant": "user-1", "show_deleted": false, "show_sources": false, "show_parents": false, "only_ooo": false, "split_count": 0, "page": 1},
+		},
+		"values are parsed": {
+			tenant:   "user-1",
+			query:    "show_deleted=on&show_sources=true&show_parents=0&split_count=4&page=3&page_size=50",
+			expected: httpParams{"tenant": "user-1", "show_deleted": true, "show_sources": true, "show_parents": false, "only_ooo": false, "split_count": 4, "page": 3, "page_size": 50},
+		},
+		"invalid boolean": {
+			tenant:        "user-1",
+			query:         "show_deleted=maybe",
+			expectedParam: "show_deleted",
+		},
+		"invalid integer": {
+			tenant:        "user-1",
+			query:         "split_count=many",
+			expectedParam: "split_count",
+		},
+		"integer out of bounds": {
+			tenant:        "user-1",
+			query:         "split_count=-1",
+			expectedParam: "split_count",
+		},
+		"integer above the max": {
+			tenant:        "user-1",
+			query:         "page_size=10001",
+			expectedParam: "page_size",
+		},
+		"missing required path parameter": {
+			expectedParam: "tenant",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"tenant": tc.tenant})
+
+			params, err := parseHTTPParams(req, blocksRouteSpec("/store-gateway").Params)
+			if tc.expectedParam != "" {
+				var paramErr *httpParamError
+				require.ErrorAs(t, err, &paramErr)
+				assert.Equal(t, tc.expectedParam, paramErr.Param)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, params)
+		})
+	}
+}
+
+func TestHandler_BlocksHandler_InvalidParameter(t *testing.T) {
+	g := New(Config{Component: "Store-gateway", PathPrefix: "/store-gateway"}, objstore.NewInMemBucket(), nil, log.NewNopLogger(), nil)
+
+	router := mux.NewRouter()
+	router.Path(g.BlocksPath()).HandlerFunc(g.BlocksHandler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/store-gateway/tenant/user-1/blocks?split_count=-3", nil))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body httpParamError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "split_count", body.Param)
+	assert.Equal(t, "must be greater than or equal to 0, got -3", body.Message)
+}
+
+func TestHandler_APIDocsHandler(t *testing.T) {
+	g := New(Config{Component: "Store-gateway", PathPrefix: "/store-gateway", LinkBlocks: true, BlockResponse: BlockDetails{}}, objstore.NewInMemBucket(), nil, log.NewNopLogger(), nil)
+
+	rec := httptest.NewRecorder()
+	g.APIDocsHandler(rec, httptest.NewRequest(http.MethodGet, "/store-gateway/api-docs.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name     string         `json:"name"`
+				In       string         `json:"in"`
+				Required bool           `json:"required"`
+				Schema   map[string]any `json:"schema"`
+			} `json:"parameters"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Type       string         `json:"type"`
+						Properties map[string]any `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	// Every registered route is documented.
+	routes := []httpRouteSpec{g.tenantsRoute, g.blocksRoute, g.blockRoute}
+	require.Len(t, doc.Paths, len(routes))
+	for _, route := range routes {
+		require.Contains(t, doc.Paths, route.Path)
+		require.Contains(t, doc.Paths[route.Path], "get")
+	}
+
+	blocks := doc.Paths[g.BlocksPath()]["get"]
+	require.Len(t, blocks.Parameters, len(g.blocksRoute.Params))
+	assert.Equal(t, "tenant", blocks.Parameters[0].Name)
+	assert.Equal(t, "path", blocks.Parameters[0].In)
+	assert.True(t, blocks.Parameters[0].Required)
+	assert.Equal(t, "split_count", blocks.Parameters[4].Name)
+	assert.Equal(t, map[string]any{"type": "integer", "default": float64(0), "minimum": float64(0)}, blocks.Parameters[4].Schema)
+
+	// The response schema follows the JSON encoding of the response, including promoted fields of embedded structs.
+	schema := blocks.Responses["200"].Content["application/json"].Schema
+	assert.Equal(t, "object", schema.Type)
+	assert.Contains(t, schema.Properties, "now")
+	assert.Contains(t, schema.Properties, "tenant")
+	assert.Contains(t, schema.Properties, "metas")
+	assert.NotContains(t, schema.Properties, "FormattedBlocks")
+
+	metas := schema.Properties["metas"].(map[string]any)["items"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, metas["ulid"])
+	assert.Contains(t, metas, "deletedTime")
+	assert.Contains(t, metas, "minTime")
+
+	blk := doc.Paths[g.BlockPath()]["get"]
+	require.Len(t, blk.Parameters, 3)
+	assert.Equal(t, "block", blk.Parameters[1].Name)
+	assert.Equal(t, map[string]any{"type": "string", "minLength": float64(26), "maxLength": float64(26)}, blk.Parameters[1].Schema)
+	assert.Equal(t, map[string]any{"type": "string", "enum": []any{"json"}}, blk.Parameters[2].Schema)
+	assert.Contains(t, blk.Responses["200"].Content["application/json"].Schema.Properties, "meta")
+}
+
+func TestHandler_ParseBlockRequest(t *testing.T) {
+	g := New(Config{Component: "Store-gateway", PathPrefix: "/store-gateway", LinkBlocks: true}, objstore.NewInMemBucket(), nil, log.NewNopLogger(), nil)
+	blockID := ulid.MustNew(1, nil)
+
+	tests := map[string]struct {
+		tenant        string
+		block         string
+		query         string
+		expected      BlockRequest
+		expectedParam string
+	}{
+		"valid": {
+			tenant:   "user-1",
+			block:    blockID.String(),
+			query:    "format=json",
+			expected: BlockRequest{TenantID: "user-1", BlockID: blockID, Format: "json"},
+		},
+		"invalid tenant": {
+			tenant:        "..",
+			block:         blockID.String(),
+			expectedParam: "tenant",
+		},
+		"invalid block ID": {
+			tenant:        "user-1",
+			block:         "not-a-block",
+			expectedParam: "block",
+		},
+		"invalid format": {
+			tenant:        "user-1",
+			block:         blockID.String(),
+			query:         "format=csv",
+			expectedParam: "format",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"tenant": tc.tenant, "block": tc.block})
+			rec := httptest.NewRecorder()
+
+			params, ok := g.ParseBlockRequest(rec, req)
+			if tc.expectedParam != "" {
+				require.False(t, ok)
+				require.Equal(t, http.StatusBadRequest, rec.Code)
+				var body httpParamError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tc.expectedParam, body.Param)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, params)
+		})
+	}
+}
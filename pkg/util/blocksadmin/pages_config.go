@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	errInvalidPagesLimits   = errors.New("invalid blocks admin limits, the values must not be negative")
+	errInvalidPagesPageSize = fmt.Errorf("invalid blocks page size, the value must be between 1 and %d", MaxPageSize)
+)
+
+// PagesConfig holds the settings of the admin pages, shared by the components serving them.
+type PagesConfig struct {
+	PageSize int `yaml:"blocks_page_size" category:"experimental"`
+
+	BucketRateLimit      float64 `yaml:"blocks_admin_bucket_rate_limit" category:"experimental"`
+	BucketRateLimitBurst int     `yaml:"blocks_admin_bucket_rate_limit_burst" category:"experimental"`
+	MaxObjectsPerRequest int     `yaml:"blocks_admin_max_objects_per_request" category:"experimental"`
+
+	ExportSpoolTTL          time.Duration `yaml:"blocks_admin_export_spool_ttl" category:"experimental"`
+	ExportSpoolDir          string        `yaml:"blocks_admin_export_spool_dir" category:"experimental"`
+	ExportSpoolMaxDiskBytes int64         `yaml:"blocks_admin_export_spool_max_disk_bytes" category:"experimental"`
+}
+
+// RegisterFlagsWithPrefix registers the PagesConfig flags, prefixed with the component's, e.g. "store-gateway.".
+func (cfg *PagesConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.PageSize, prefix+"blocks-page-size", DefaultPageSize, fmt.Sprintf("Default number of blocks shown per page by the tenant blocks admin page, when the page_size parameter is not set. The maximum is %d.", MaxPageSize))
+	f.Float64Var(&cfg.BucketRateLimit, prefix+"blocks-admin-bucket-rate-limit", 0, "Maximum number of object storage operations per second done by the tenants and blocks admin pages, shared by all the requests. 0 to disable the limit.")
+	f.IntVar(&cfg.BucketRateLimitBurst, prefix+"blocks-admin-bucket-rate-limit-burst", 10, "Burst of object storage operations allowed by -"+prefix+"blocks-admin-bucket-rate-limit.")
+	f.IntVar(&cfg.MaxObjectsPerRequest, prefix+"blocks-admin-max-objects-per-request", 0, "Maximum number of objects read from the object storage by a single request of the blocks admin page. When the limit is reached, the listing is truncated. 0 to disable the limit.")
+	f.DurationVar(&cfg.ExportSpoolTTL, prefix+"blocks-admin-export-spool-ttl", 0, "How long the CSV and JSON exports of the blocks admin page are kept after being generated, so that their downloads can be resumed with Range requests carrying the token returned in the "+ExportTokenHeader+" header. 0 to disable it.")
+	f.StringVar(&cfg.ExportSpoolDir, prefix+"blocks-admin-export-spool-dir", "", "Directory where the exports of the blocks admin page are kept, when they don't fit in memory. If empty, the default directory for temporary files is used.")
+	f.Int64Var(&cfg.ExportSpoolMaxDiskBytes, prefix+"blocks-admin-export-spool-max-disk-bytes", 1<<30, "Maximum disk used by the kept exports of the blocks admin page. The oldest exports are removed to make room for new ones, and larger exports can't be resumed.")
+}
+
+// Validate the PagesConfig.
+func (cfg *PagesConfig) Validate() error {
+	if cfg.PageSize < 1 || cfg.PageSize > MaxPageSize {
+		return errInvalidPagesPageSize
+	}
+	if cfg.BucketRateLimit < 0 || cfg.BucketRateLimitBurst < 0 || cfg.MaxObjectsPerRequest < 0 ||
+		cfg.ExportSpoolTTL < 0 || cfg.ExportSpoolMaxDiskBytes < 0 {
+		return errInvalidPagesLimits
+	}
+	return nil
+}
+
+// HandlerConfig returns the Config of the Handler serving the pages of the component at the path prefix,
+// with the settings of the PagesConfig.
+func (cfg PagesConfig) HandlerConfig(component, pathPrefix string) Config {
+	return Config{
+		Component:       component,
+		PathPrefix:      pathPrefix,
+		DefaultPageSize: cfg.PageSize,
+
+		BucketRateLimit:      cfg.BucketRateLimit,
+		BucketRateLimitBurst: cfg.BucketRateLimitBurst,
+		MaxObjectsPerRequest: cfg.MaxObjectsPerRequest,
+
+		ExportSpoolTTL:          cfg.ExportSpoolTTL,
+		ExportSpoolDir:          cfg.ExportSpoolDir,
+		ExportSpoolMaxDiskBytes: cfg.ExportSpoolMaxDiskBytes,
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagesConfig_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		setup       func(cfg *PagesConfig)
+		expectedErr error
+	}{
+		"default config": {
+			setup: func(*PagesConfig) {},
+		},
+		"page size too small": {
+			setup:       func(cfg *PagesConfig) { cfg.PageSize = 0 },
+			expectedErr: errInvalidPagesPageSize,
+		},
+		"page size too large": {
+			setup:       func(cfg *PagesConfig) { cfg.PageSize = MaxPageSize + 1 },
+			expectedErr: errInvalidPagesPageSize,
+		},
+		"negative rate limit": {
+			setup:       func(cfg *PagesConfig) { cfg.BucketRateLimit = -1 },
+			expectedErr: errInvalidPagesLimits,
+		},
+		"negative spool TTL": {
+			setup:       func(cfg *PagesConfig) { cfg.ExportSpoolTTL = -time.Second },
+			expectedErr: errInvalidPagesLimits,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := PagesConfig{}
+			cfg.RegisterFlagsWithPrefix("store-gateway.", flag.NewFlagSet("test", flag.PanicOnError))
+			tc.setup(&cfg)
+			assert.Equal(t, tc.expectedErr, cfg.Validate())
+		})
+	}
+}
+
+func TestPagesConfig_HandlerConfig(t *testing.T) {
+	cfg := PagesConfig{}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	cfg.RegisterFlagsWithPrefix("compactor.", fs)
+	require.NoError(t, fs.Parse([]string{
+		"-compactor.blocks-page-size=50",
+		"-compactor.blocks-admin-bucket-rate-limit=5",
+		"-compactor.blocks-admin-max-objects-per-request=100",
+		"-compactor.blocks-admin-export-spool-ttl=1m",
+	}))
+
+	assert.Equal(t, Config{
+		Component:               "Compactor",
+		PathPrefix:              "/compactor",
+		DefaultPageSize:         50,
+		BucketRateLimit:         5,
+		BucketRateLimitBurst:    10,
+		MaxObjectsPerRequest:    100,
+		ExportSpoolTTL:          time.Minute,
+		ExportSpoolMaxDiskBytes: 1 << 30,
+	}, cfg.HandlerConfig("Compactor", "/compactor"))
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package blocksadmin
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// Dimensions of the charts rendered by renderBarChartSVG, in pixels.
+const (
+	svgChartWidth  = 720
+	svgChartHeight = 160
+	svgPlotLeft    = 70
+	svgPlotRight   = svgChartWidth - 10
+	svgPlotTop     = 25
+	svgPlotBottom  = svgChartHeight - 20
+)
+
+// svgBar is a bar of a chart, made of segments stacked from the bottom up.
+type svgBar struct {
+	Label    string
+	Segments []svgBarSegment
+}
+
+// svgBarSegment is a part of a bar. Title is shown as a tooltip.
+type svgBarSegment struct {
+	Value float64
+	Color string
+	Title string
+}
+
+func (b svgBar) total() float64 {
+	total := 0.0
+	for _, s := range b.Segments {
+		total += s.Value
+	}
+	return total
+}
+
+type svgLegendEntry struct {
+	Label string
+	Color string
+}
+
+// renderBarChartSVG renders bars as an inline SVG stacked bar chart, scaled to the highest bar. The y-axis is labeled
+// with the highest value, formatted by formatValue, and the x-axis with the labels of the first and last bars.
+func renderBarChartSVG(title string, bars []svgBar, legend []svgLegendEntry, formatValue func(float64) string) template.HTML {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		svgChartWidth, svgChartHeight, svgChartWidth, svgChartHeight)
+	fmt.Fprintf(sb, `<text x="%d" y="14" font-weight="bold">%s</text>`+"\n", svgPlotLeft, template.HTMLEscapeString(title))
+
+	legendX := svgPlotRight
+	for i := len(legend) - 1; i >= 0; i-- {
+		legendX -= 40
+		fmt.Fprintf(sb, `<rect x="%d" y="5" width="10" height="10" fill="%s"/><text x="%d" y="14">%s</text>`+"\n",
+			legendX, template.HTMLEscapeString(legend[i].Color), legendX+13, template.HTMLEscapeString(legend[i].Label))
+	}
+
+	maxTotal := 0.0
+	for _, b := range bars {
+		maxTotal = max(maxTotal, b.total())
+	}
+	if maxTotal <= 0 {
+		fmt.Fprintf(sb, `<text x="%d" y="%d" text-anchor="middle" fill="grey">No blocks</text>`+"\n",
+			(svgPlotLeft+svgPlotRight)/2, (svgPlotTop+svgPlotBottom)/2)
+		sb.WriteString("</svg>")
+		return template.HTML(sb.String())
+	}
+
+	// Axes and their labels.
+	fmt.Fprintf(sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="grey"/>`+"\n", svgPlotLeft, svgPlotTop, svgPlotLeft, svgPlotBottom)
+	fmt.Fprintf(sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="grey"/>`+"\n", svgPlotLeft, svgPlotBottom, svgPlotRight, svgPlotBottom)
+	fmt.Fprintf(sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgPlotLeft-4, svgPlotTop+8, template.HTMLEscapeString(formatValue(maxTotal)))
+	fmt.Fprintf(sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgPlotLeft-4, svgPlotBottom, template.HTMLEscapeString(formatValue(0)))
+	fmt.Fprintf(sb, `<text x="%d" y="%d">%s</text>`+"\n", svgPlotLeft, svgChartHeight-5, template.HTMLEscapeString(bars[0].Label))
+	if len(bars) > 1 {
+		fmt.Fprintf(sb, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgPlotRight, svgChartHeight-5, template.HTMLEscapeString(bars[len(bars)-1].Label))
+	}
+
+	// Leave a gap between bars, unless they're too thin to afford it.
+	barWidth := float64(svgPlotRight-svgPlotLeft) / float64(len(bars))
+	gap := 0.0
+	if barWidth >= 4 {
+		gap = barWidth * 0.1
+	}
+	plotHeight := float64(svgPlotBottom - svgPlotTop)
+
+	for i, b := range bars {
+		x := float64(svgPlotLeft) + float64(i)*barWidth + gap/2
+		y := float64(svgPlotBottom)
+		for _, s := range b.Segments {
+			h := s.Value / maxTotal * plotHeight
+			y -= h
+			fmt.Fprintf(sb, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"><title>%s</title></rect>`+"\n",
+				x, y, barWidth-gap, h, template.HTMLEscapeString(s.Color), template.HTMLEscapeString(s.Title))
+		}
+	}
+
+	sb.WriteString("</svg>")
+	return template.HTML(sb.String())
+}
@@ -1,6 +1,6 @@
 // SPDX-License-Identifier: AGPL-3.0-only
 
-package storegateway
+package blocksadmin
 
 import (
 	_ "embed" // Used to embed html template
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/go-kit/log/level"
+
 	"github.com/grafana/mimir/pkg/util"
 )
 
@@ -17,19 +19,22 @@ var tenantsPageHTML string
 var tenantsTemplate = template.Must(template.New("webpage").Parse(tenantsPageHTML))
 
 type tenantsPageContents struct {
-	Now     time.Time `json:"now"`
-	Tenants []string  `json:"tenants,omitempty"`
+	Now       time.Time `json:"now"`
+	Tenants   []string  `json:"tenants,omitempty"`
+	Component string    `json:"-"`
 }
 
-func (s *StoreGateway) TenantsHandler(w http.ResponseWriter, req *http.Request) {
-	tenantIDs, err := s.stores.scanUsers(req.Context())
+func (h *Handler) TenantsHandler(w http.ResponseWriter, req *http.Request) {
+	tenantIDs, err := h.listTenants(req.Context())
 	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to list tenants", "err", err)
 		util.WriteTextResponse(w, fmt.Sprintf("Can't read tenants: %s", err))
 		return
 	}
 
 	util.RenderHTTPResponse(w, tenantsPageContents{
-		Now:     time.Now(),
-		Tenants: tenantIDs,
+		Now:       time.Now(),
+		Tenants:   tenantIDs,
+		Component: h.cfg.Component,
 	}, tenantsTemplate, req)
 }
@@ -181,7 +181,12 @@ func SortBlocks(metas map[ulid.ULID]*block.Meta) []*block.Meta {
 		}
 
 		// ULID time.
-		return blocks[i].ULID.Time() < blocks[j].ULID.Time()
+		if blocks[i].ULID.Time() != blocks[j].ULID.Time() {
+			return blocks[i].ULID.Time() < blocks[j].ULID.Time()
+		}
+
+		// ULID, so that the order is stable.
+		return blocks[i].ULID.Compare(blocks[j].ULID) < 0
 	})
 	return blocks
 }
@@ -180,6 +180,8 @@ type Limits struct {
 	BlockedQueries                         []*BlockedQuery        `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty" doc:"nocli|description=List of queries to block." category:"experimental"`
 	AlignQueriesWithStep                   bool                   `yaml:"align_queries_with_step" json:"align_queries_with_step"`
 	EnabledPromQLExperimentalFunctions     flagext.StringSliceCSV `yaml:"enabled_promql_experimental_functions" json:"enabled_promql_experimental_functions" category:"experimental"`
+	QueryStatsHeadersEnabled               bool                   `yaml:"query_stats_headers_enabled" json:"query_stats_headers_enabled" category:"experimental"`
+	MaxConcurrentDownstreamRequests        int                    `yaml:"max_concurrent_downstream_requests" json:"max_concurrent_downstream_requests" category:"experimental"`
 
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
@@ -371,6 +373,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxQueryExpressionSizeBytes, MaxQueryExpressionSizeBytesFlag, 0, "Max size of the raw query, in bytes. This limit is enforced by the query-frontend for instant, range and remote read queries. 0 to not apply a limit to the size of the query.")
 	f.BoolVar(&l.AlignQueriesWithStep, alignQueriesWithStepFlag, false, "Mutate incoming queries to align their start and end with their step to improve result caching.")
 	f.Var(&l.EnabledPromQLExperimentalFunctions, "query-frontend.enabled-promql-experimental-functions", "Enable certain experimental PromQL functions, which are subject to being changed or removed at any time, on a per-tenant basis. Defaults to empty which means all experimental functions are disabled. Set to 'all' to enable all experimental functions.")
+	f.BoolVar(&l.QueryStatsHeadersEnabled, "query-frontend.query-stats-headers-enabled", false, "True to return the wall time, fetched series, fetched chunk bytes and queue time of queries to clients as X-Query-* response headers. When queries are sent to a downstream URL, only the wall time measured by the query-frontend is returned.")
+	f.IntVar(&l.MaxConcurrentDownstreamRequests, "query-frontend.max-concurrent-downstream-requests", 0, "Maximum number of concurrent requests of a tenant sent to the downstream URL. Requests over the limit wait up to -query-frontend.downstream-concurrency-wait-timeout, and are rejected with a 429 response after that. This option only works when using downstream URL. 0 to disable the limit.")
 
 	// Store-gateway.
 	f.IntVar(&l.StoreGatewayTenantShardSize, "store-gateway.tenant-shard-size", 0, "The tenant's shard size, used when store-gateway sharding is enabled. Value of 0 disables shuffle sharding for the tenant, that is all tenant blocks are sharded across all store-gateway replicas.")
@@ -1099,6 +1103,16 @@ func (o *Overrides) ResultsCacheForUnalignedQueryEnabled(userID string) bool {
 	return o.getOverridesForUser(userID).ResultsCacheForUnalignedQueryEnabled
 }
 
+// QueryStatsHeadersEnabled returns whether the query stats of the tenant's queries are returned as response headers.
+func (o *Overrides) QueryStatsHeadersEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).QueryStatsHeadersEnabled
+}
+
+// MaxConcurrentDownstreamRequests returns the max number of concurrent requests of the tenant sent to the downstream URL.
+func (o *Overrides) MaxConcurrentDownstreamRequests(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentDownstreamRequests
+}
+
 func (o *Overrides) EnabledPromQLExperimentalFunctions(userID string) []string {
 	return o.getOverridesForUser(userID).EnabledPromQLExperimentalFunctions
 }